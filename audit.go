@@ -0,0 +1,65 @@
+package vaultsync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DuplicateGroup is a set of secrets whose data is identical.
+type DuplicateGroup struct {
+	// Hash is the SHA-256 of the secrets' canonical JSON encoding.
+	Hash string
+	// Paths are the duplicated secrets as "engine/path", sorted.
+	Paths []string
+}
+
+// FindDuplicateSecretsAt walks the tree below ref and groups secrets that hold
+// exactly the same data (same keys and values). Only a content hash is kept
+// per secret, so the tree's values are never held in memory at once. Groups
+// are ordered by their first path; secrets that could not be read are
+// reported in the returned error alongside the groups found.
+func (v *VaultClient) FindDuplicateSecretsAt(ref SecretRef) ([]DuplicateGroup, error) {
+	pathsByHash := make(map[string][]string)
+
+	fetchErr, visitErr := v.walkSecrets(ref.MetadataPath(), func(fullPath string, secretData map[string]interface{}) error {
+		hash, err := secretContentHash(secretData)
+		if err != nil {
+			return fmt.Errorf("failed to hash %s: %w", fullPath, err)
+		}
+		secretRef := secretRefFromMetadataPath(fullPath)
+		pathsByHash[hash] = append(pathsByHash[hash], secretRef.Engine+"/"+secretRef.Path)
+		return nil
+	})
+	if visitErr != nil {
+		return nil, visitErr
+	}
+
+	var groups []DuplicateGroup
+	for hash, paths := range pathsByHash {
+		if len(paths) < 2 {
+			continue
+		}
+		slices.Sort(paths)
+		groups = append(groups, DuplicateGroup{Hash: hash, Paths: paths})
+	}
+	slices.SortFunc(groups, func(a, b DuplicateGroup) int {
+		return strings.Compare(a.Paths[0], b.Paths[0])
+	})
+
+	return groups, fetchErr
+}
+
+// secretContentHash hashes secretData's JSON encoding, which sorts map keys
+// and so is the same for equal data regardless of key order.
+func secretContentHash(secretData map[string]interface{}) (string, error) {
+	encoded, err := json.Marshal(secretData)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,55 @@
+package vaultsync
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestFindDuplicateSecretsAtGroupsIdenticalData(t *testing.T) {
+	t.Parallel()
+
+	secrets := map[string]map[string]any{
+		"/v1/kv/data/app/a":      {"user": "admin", "password": "s3cret"},
+		"/v1/kv/data/app/b":      {"password": "s3cret", "user": "admin"},
+		"/v1/kv/data/app/c":      {"user": "admin", "password": "other"},
+		"/v1/kv/data/app/team/d": {"user": "admin", "password": "s3cret"},
+		"/v1/kv/data/app/team/e": {"token": "abc"},
+		"/v1/kv/data/app/team/f": {"token": "abc"},
+	}
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		switch r.URL.Path {
+		case "/v1/kv/metadata/app":
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"keys": []string{"a", "b", "c", "team/"}}})
+		case "/v1/kv/metadata/app/team":
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"keys": []string{"d", "e", "f"}}})
+		}
+		data, ok := secrets[r.URL.Path]
+		if !ok {
+			t.Fatalf("unexpected request %s", r.URL.Path)
+		}
+		return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"data": data}})
+	})}
+
+	groups, err := client.FindDuplicateSecretsAt(NewSecretRef("kv", "app"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 duplicate groups, got %+v", groups)
+	}
+	if got := strings.Join(groups[0].Paths, ","); got != "kv/app/a,kv/app/b,kv/app/team/d" {
+		t.Fatalf("unexpected first group %s", got)
+	}
+	if got := strings.Join(groups[1].Paths, ","); got != "kv/app/team/e,kv/app/team/f" {
+		t.Fatalf("unexpected second group %s", got)
+	}
+	if len(groups[0].Hash) != 64 {
+		t.Fatalf("expected a SHA-256 hex hash, got %q", groups[0].Hash)
+	}
+}
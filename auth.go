@@ -0,0 +1,244 @@
+package vaultsync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultKubernetesJWTPath is where a pod's service account token is mounted.
+const DefaultKubernetesJWTPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// AuthMethod is a Vault auth method the client can log in with, and log in
+// with again when its token expires mid-run (see VaultClient.Auth).
+type AuthMethod interface {
+	// LoginRequest returns the login path, relative to /v1/, and its body.
+	LoginRequest() (path string, data map[string]interface{}, err error)
+}
+
+// AppRoleAuth logs in with an AppRole role ID and secret ID. Mount defaults
+// to "approle".
+type AppRoleAuth struct {
+	Mount    string
+	RoleID   string
+	SecretID string
+}
+
+func (a AppRoleAuth) LoginRequest() (string, map[string]interface{}, error) {
+	if a.RoleID == "" {
+		return "", nil, errors.New("approle login requires a role ID")
+	}
+	data := map[string]interface{}{"role_id": a.RoleID}
+	if a.SecretID != "" {
+		data["secret_id"] = a.SecretID
+	}
+	return authLoginPath(a.Mount, "approle"), data, nil
+}
+
+// KubernetesAuth logs in with a pod's service account token, read from
+// JWTPath (default DefaultKubernetesJWTPath) on every login so a rotated
+// token is picked up. Mount defaults to "kubernetes".
+type KubernetesAuth struct {
+	Mount   string
+	Role    string
+	JWTPath string
+}
+
+func (a KubernetesAuth) LoginRequest() (string, map[string]interface{}, error) {
+	if a.Role == "" {
+		return "", nil, errors.New("kubernetes login requires a role")
+	}
+	jwtPath := a.JWTPath
+	if jwtPath == "" {
+		jwtPath = DefaultKubernetesJWTPath
+	}
+	jwt, err := os.ReadFile(jwtPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read service account token: %w", err)
+	}
+	data := map[string]interface{}{"role": a.Role, "jwt": strings.TrimSpace(string(jwt))}
+	return authLoginPath(a.Mount, "kubernetes"), data, nil
+}
+
+func authLoginPath(mount, defaultMount string) string {
+	mount = strings.Trim(mount, "/")
+	if mount == "" {
+		mount = defaultMount
+	}
+	return "auth/" + mount + "/login"
+}
+
+// AuthFromEnv builds the auth method named method ("approle" or
+// "kubernetes") from the environment: VAULT_ROLE_ID and VAULT_SECRET_ID for
+// AppRole, VAULT_K8S_ROLE and optionally VAULT_K8S_JWT_PATH for Kubernetes.
+// An empty mount uses the method's default mount.
+func AuthFromEnv(method, mount string) (AuthMethod, error) {
+	switch method {
+	case "approle":
+		auth := AppRoleAuth{Mount: mount, RoleID: os.Getenv("VAULT_ROLE_ID"), SecretID: os.Getenv("VAULT_SECRET_ID")}
+		if auth.RoleID == "" {
+			return nil, fmt.Errorf("VAULT_ROLE_ID environment variable is required for approle auth")
+		}
+		return auth, nil
+	case "kubernetes":
+		auth := KubernetesAuth{Mount: mount, Role: os.Getenv("VAULT_K8S_ROLE"), JWTPath: os.Getenv("VAULT_K8S_JWT_PATH")}
+		if auth.Role == "" {
+			return nil, fmt.Errorf("VAULT_K8S_ROLE environment variable is required for kubernetes auth")
+		}
+		return auth, nil
+	default:
+		return nil, fmt.Errorf("unknown auth method %q: expected approle or kubernetes", method)
+	}
+}
+
+type vaultLoginResponse struct {
+	Auth struct {
+		ClientToken string `json:"client_token"`
+	} `json:"auth"`
+}
+
+// Login logs in with v.Auth and makes the returned token the client's token.
+func (v *VaultClient) Login() error {
+	if v.Auth == nil {
+		return errors.New("no auth method configured")
+	}
+
+	v.authMu.Lock()
+	defer v.authMu.Unlock()
+	return v.login()
+}
+
+// login is Login with authMu held.
+func (v *VaultClient) login() error {
+	path, data, err := v.Auth.LoginRequest()
+	if err != nil {
+		return err
+	}
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/v1/%s", v.Address, path)
+	resp, err := v.sendAuth(func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", url, strings.NewReader(string(jsonData)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Vault-Namespace", v.Namespace)
+		return req, nil
+	})
+	if err != nil {
+		return fmt.Errorf("login request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read login response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("login at %s failed: %w", path, &HTTPError{StatusCode: resp.StatusCode, Body: string(body)})
+	}
+
+	var loginResp vaultLoginResponse
+	if err := json.Unmarshal(body, &loginResp); err != nil {
+		return fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	if loginResp.Auth.ClientToken == "" {
+		return fmt.Errorf("login at %s returned no client token", path)
+	}
+
+	v.Token = loginResp.Auth.ClientToken
+	return nil
+}
+
+// token returns the client token, which re-authentication may replace while
+// requests are in flight.
+func (v *VaultClient) token() string {
+	v.authMu.Lock()
+	defer v.authMu.Unlock()
+	return v.Token
+}
+
+// reauthenticate handles a 403 returned for a request made with staleToken.
+// If the token has since been replaced by another request's re-login, or
+// still looks itself up fine (so the 403 was a real permission denial), it
+// does nothing; otherwise it logs in again with v.Auth. It reports whether
+// the request should be retried with the current token.
+func (v *VaultClient) reauthenticate(staleToken string) (bool, error) {
+	v.authMu.Lock()
+	defer v.authMu.Unlock()
+
+	if v.Token != staleToken {
+		return true, nil
+	}
+
+	url := fmt.Sprintf("%s/v1/auth/token/lookup-self", v.Address)
+	resp, err := v.sendAuth(func() (*http.Request, error) {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("X-Vault-Token", staleToken)
+		req.Header.Set("X-Vault-Namespace", v.Namespace)
+		return req, nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("token lookup failed: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return false, nil
+	}
+
+	if err := v.login(); err != nil {
+		return false, err
+	}
+	fmt.Fprintln(v.errOutput(), "Token expired; logged in again")
+	return true, nil
+}
+
+// authMaxAttempts caps the attempts sendAuth makes at one auth request, and
+// authRetryBaseDelay is its wait before the second, doubled for each later
+// attempt.
+const (
+	authMaxAttempts    = 4
+	authRetryBaseDelay = 500 * time.Millisecond
+)
+
+// sendAuth sends the auth request built by newReq, retrying with exponential
+// backoff while Vault is temporarily unavailable: the request failed in
+// transit, or Vault answered 429 or a 5xx status (sealed, standby, or
+// overloaded). Any other response, including the 400 or 403 of bad
+// credentials, is returned at once, as is ErrOperationTimeout. The wait never
+// runs past v.Deadline.
+func (v *VaultClient) sendAuth(newReq func() (*http.Request, error)) (*http.Response, error) {
+	return v.sendWithRetry(newReq, retryPolicy{
+		maxAttempts: authMaxAttempts,
+		baseDelay:   authRetryBaseDelay,
+		retryable:   authRetryable,
+		notify: func(req *http.Request, resp *http.Response, err error, delay time.Duration) {
+			reason := "request failed"
+			if err == nil {
+				reason = fmt.Sprintf("Vault returned %d", resp.StatusCode)
+			}
+			v.warnf("%s %s: %s; retrying in %s\n", req.Method, req.URL.Path, reason, delay)
+		},
+	})
+}
+
+// authRetryable reports whether an auth request that returned resp and err
+// failed for a reason that may go away on its own.
+func authRetryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, ErrOperationTimeout)
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
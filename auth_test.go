@@ -0,0 +1,240 @@
+package vaultsync
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// expiringVault serves a KV secret for the token "fresh" only, and hands out
+// "fresh" on AppRole login, recording every request.
+type expiringVault struct {
+	t        *testing.T
+	mu       sync.Mutex
+	requests []string
+	bodies   []string
+}
+
+func (e *expiringVault) roundTrip(r *http.Request) (*http.Response, error) {
+	token := r.Header.Get("X-Vault-Token")
+	var body string
+	if r.Body != nil {
+		raw, _ := io.ReadAll(r.Body)
+		body = string(raw)
+	}
+
+	e.mu.Lock()
+	e.requests = append(e.requests, r.Method+" "+r.URL.Path+" "+token)
+	e.bodies = append(e.bodies, body)
+	e.mu.Unlock()
+
+	switch {
+	case r.URL.Path == "/v1/auth/approle/login":
+		return jsonResponse(e.t, http.StatusOK, map[string]any{"auth": map[string]any{"client_token": "fresh"}})
+	case token != "fresh":
+		return textResponse(http.StatusForbidden, `{"errors":["permission denied"]}`), nil
+	case r.Method == http.MethodPost:
+		return jsonResponse(e.t, http.StatusOK, map[string]any{"data": map[string]any{"version": 2}})
+	default:
+		return jsonResponse(e.t, http.StatusOK, map[string]any{
+			"data": map[string]any{"data": map[string]any{"username": "alice"}, "metadata": map[string]any{"version": 1}},
+		})
+	}
+}
+
+func TestExpiredTokenLogsInAgainAndRetries(t *testing.T) {
+	t.Parallel()
+
+	vault := &expiringVault{t: t}
+	client := NewVaultClient("https://vault.example", "expired", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.Auth = AppRoleAuth{RoleID: "role", SecretID: "secret"}
+	client.client = &http.Client{Transport: roundTripFunc(vault.roundTrip)}
+
+	if err := client.PutSecretAt(NewSecretRef("kv", "app/db"), map[string]interface{}{"username": "bob"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		"POST /v1/kv/data/app/db expired",
+		"GET /v1/auth/token/lookup-self expired",
+		"POST /v1/auth/approle/login ",
+		"POST /v1/kv/data/app/db fresh",
+	}
+	if strings.Join(vault.requests, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected requests:\n%s\nwant:\n%s", strings.Join(vault.requests, "\n"), strings.Join(want, "\n"))
+	}
+	if vault.bodies[0] == "" || vault.bodies[3] != vault.bodies[0] {
+		t.Fatalf("expected the retry to resend the body %q, got %q", vault.bodies[0], vault.bodies[3])
+	}
+
+	var login map[string]any
+	if err := json.Unmarshal([]byte(vault.bodies[2]), &login); err != nil {
+		t.Fatalf("failed to parse login body: %v", err)
+	}
+	if login["role_id"] != "role" || login["secret_id"] != "secret" {
+		t.Fatalf("unexpected login body %v", login)
+	}
+
+	// Later requests use the new token straight away.
+	if _, err := client.GetSecretAt(NewSecretRef("kv", "app/db")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if last := vault.requests[len(vault.requests)-1]; last != "GET /v1/kv/data/app/db fresh" {
+		t.Fatalf("expected the next request to use the new token, got %q", last)
+	}
+}
+
+func TestPermissionDeniedWithValidTokenDoesNotLogIn(t *testing.T) {
+	t.Parallel()
+
+	var requests []string
+	client := NewVaultClient("https://vault.example", "valid", "")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.Auth = AppRoleAuth{RoleID: "role"}
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		requests = append(requests, r.URL.Path)
+		if r.URL.Path == "/v1/auth/token/lookup-self" {
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"ttl": 3600}})
+		}
+		return textResponse(http.StatusForbidden, `{"errors":["permission denied"]}`), nil
+	})}
+
+	_, err := client.GetSecretAt(NewSecretRef("kv", "other-team/db"))
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected the 403 to be returned, got %v", err)
+	}
+	if want := "/v1/kv/data/other-team/db,/v1/auth/token/lookup-self"; strings.Join(requests, ",") != want {
+		t.Fatalf("expected no login, got requests %v", requests)
+	}
+	if client.Token != "valid" {
+		t.Fatalf("expected the token to be kept, got %q", client.Token)
+	}
+}
+
+func TestKubernetesAuthLoginRequest(t *testing.T) {
+	t.Parallel()
+
+	jwtPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(jwtPath, []byte("eyJhbGciOi.jwt\n"), 0600); err != nil {
+		t.Fatalf("failed to write JWT: %v", err)
+	}
+
+	path, data, err := KubernetesAuth{Mount: "/k8s-prod/", Role: "vaultsync", JWTPath: jwtPath}.LoginRequest()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if path != "auth/k8s-prod/login" {
+		t.Fatalf("unexpected login path %q", path)
+	}
+	if data["role"] != "vaultsync" || data["jwt"] != "eyJhbGciOi.jwt" {
+		t.Fatalf("unexpected login data %v", data)
+	}
+}
+
+func TestAuthFromEnv(t *testing.T) {
+	t.Setenv("VAULT_ROLE_ID", "role")
+	t.Setenv("VAULT_SECRET_ID", "secret")
+	t.Setenv("VAULT_K8S_ROLE", "")
+
+	auth, err := AuthFromEnv("approle", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if auth != (AppRoleAuth{RoleID: "role", SecretID: "secret"}) {
+		t.Fatalf("unexpected auth %+v", auth)
+	}
+
+	if _, err := AuthFromEnv("kubernetes", ""); err == nil {
+		t.Fatal("expected error without VAULT_K8S_ROLE, got nil")
+	}
+	if _, err := AuthFromEnv("ldap", ""); err == nil {
+		t.Fatal("expected error for unsupported method, got nil")
+	}
+}
+
+func TestLoginRetriesTemporaryFailures(t *testing.T) {
+	t.Parallel()
+
+	var attempts int
+	var errOutput strings.Builder
+	client := NewVaultClient("https://vault.example", "", "team-a")
+	client.ErrOutput = &errOutput
+	client.retryDelay = time.Millisecond
+	client.Auth = AppRoleAuth{RoleID: "role", SecretID: "secret"}
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		attempts++
+		switch attempts {
+		case 1:
+			return nil, errors.New("connection reset by peer")
+		case 2:
+			return textResponse(http.StatusServiceUnavailable, `{"errors":["Vault is sealed"]}`), nil
+		default:
+			return jsonResponse(t, http.StatusOK, map[string]any{"auth": map[string]any{"client_token": "fresh"}})
+		}
+	})}
+
+	if err := client.Login(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if attempts != 3 || client.Token != "fresh" {
+		t.Fatalf("attempts = %d, token = %q; want 3 attempts and the fresh token", attempts, client.Token)
+	}
+	if !strings.Contains(errOutput.String(), "Vault returned 503; retrying in 2ms") {
+		t.Fatalf("expected retry warnings with a doubling delay, got %q", errOutput.String())
+	}
+}
+
+func TestLoginDoesNotRetryBadCredentials(t *testing.T) {
+	t.Parallel()
+
+	var attempts int
+	client := NewVaultClient("https://vault.example", "", "team-a")
+	client.ErrOutput = nil
+	client.retryDelay = time.Millisecond
+	client.Auth = AppRoleAuth{RoleID: "role", SecretID: "wrong"}
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		attempts++
+		return textResponse(http.StatusBadRequest, `{"errors":["invalid secret id"]}`), nil
+	})}
+
+	err := client.Login()
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected the 400 to be returned, got %v", err)
+	}
+	if attempts != 1 {
+		t.Fatalf("expected a single attempt, got %d", attempts)
+	}
+}
+
+func TestLoginGivesUpAfterMaxAttempts(t *testing.T) {
+	t.Parallel()
+
+	var attempts int
+	client := NewVaultClient("https://vault.example", "", "team-a")
+	client.ErrOutput = nil
+	client.retryDelay = time.Millisecond
+	client.Auth = AppRoleAuth{RoleID: "role", SecretID: "secret"}
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		attempts++
+		return nil, errors.New("no route to host")
+	})}
+
+	if err := client.Login(); err == nil || !strings.Contains(err.Error(), "no route to host") {
+		t.Fatalf("expected the transport error, got %v", err)
+	}
+	if attempts != authMaxAttempts {
+		t.Fatalf("attempts = %d, want %d", attempts, authMaxAttempts)
+	}
+}
@@ -0,0 +1,66 @@
+package vaultsync
+
+import "time"
+
+// batchProgress counts the secrets a pull or push has handled and, with
+// VaultClient.BatchSize set, ends a batch every BatchSize secrets: it prints
+// a progress line and waits BatchPause before the next batch starts. Dry
+// runs send no writes, so they do not pause.
+type batchProgress struct {
+	v *VaultClient
+	// total is the number of secrets to handle, or 0 when it is not known
+	// in advance, as in a streaming pull.
+	total  int
+	done   int
+	dryRun bool
+	// hold, when set, stops work running ahead in the background, such as
+	// listing folders, for the length of a pause; see folderLister.hold.
+	hold func() (release func())
+}
+
+func (v *VaultClient) newBatchProgress(total int, dryRun bool) *batchProgress {
+	return &batchProgress{v: v, total: total, dryRun: dryRun}
+}
+
+// batches splits pending into the waves BatchSize asks for, or returns it
+// as a single wave.
+func (v *VaultClient) batches(pending []pendingPush) [][]pendingPush {
+	if v.BatchSize <= 0 || len(pending) <= v.BatchSize {
+		return [][]pendingPush{pending}
+	}
+	var waves [][]pendingPush
+	for start := 0; start < len(pending); start += v.BatchSize {
+		waves = append(waves, pending[start:min(start+v.BatchSize, len(pending))])
+	}
+	return waves
+}
+
+// step records one more secret handled and reports whether it ended a batch.
+// The last secret of a known total ends the final batch without a pause.
+func (b *batchProgress) step() bool {
+	size := b.v.BatchSize
+	if size <= 0 {
+		return false
+	}
+	b.done++
+	last := b.total > 0 && b.done == b.total
+	if b.done%size != 0 && !last {
+		return false
+	}
+
+	pause := !last && b.v.BatchPause > 0 && !b.dryRun
+	if pause && b.hold != nil {
+		defer b.hold()()
+	}
+	batch := (b.done + size - 1) / size
+	if b.total > 0 {
+		batches := (b.total + size - 1) / size
+		b.v.printf("Batch %d/%d complete (%d of %d secrets)\n", batch, batches, b.done, b.total)
+	} else {
+		b.v.printf("Batch %d complete (%d so far)\n", batch, b.done)
+	}
+	if pause {
+		time.Sleep(b.v.BatchPause)
+	}
+	return true
+}
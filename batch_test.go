@@ -0,0 +1,193 @@
+package vaultsync
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPushInBatchesReportsProgress(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	for i := 1; i <= 5; i++ {
+		if err := os.WriteFile(filepath.Join(inputDir, fmt.Sprintf("s%d", i)), []byte("key: value\n"), 0644); err != nil {
+			t.Fatalf("failed to write fixture secret: %v", err)
+		}
+	}
+
+	var out strings.Builder
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = &out
+	client.BatchSize = 2
+	client.BatchPause = time.Millisecond
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return textResponse(http.StatusOK, ""), nil
+	})}
+
+	if err := client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var progress []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		if strings.HasPrefix(line, "Batch ") {
+			progress = append(progress, line)
+		}
+	}
+	want := []string{
+		"Batch 1/3 complete (2 of 5 secrets)",
+		"Batch 2/3 complete (4 of 5 secrets)",
+		"Batch 3/3 complete (5 of 5 secrets)",
+	}
+	if strings.Join(progress, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("progress = %q, want %q", progress, want)
+	}
+	if !strings.Contains(out.String(), "Pushing: kv/metadata/app/s2\nBatch 1/3 complete") {
+		t.Fatalf("expected the first batch to end after s2, got:\n%s", out.String())
+	}
+}
+
+func TestPullInBatchesWithoutKnownTotal(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	client := newFakeVault(t, moveTestSecrets).client()
+	client.Output = &out
+	client.BatchSize = 1
+
+	if err := client.PullSecretsToFilesDirectAt(NewSecretRef("kv", "old"), t.TempDir()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{"Batch 1 complete (1 so far)\n", "Batch 2 complete (2 so far)\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in output, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestDryRunPushInBatchesDoesNotPause(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	for i := 1; i <= 3; i++ {
+		if err := os.WriteFile(filepath.Join(inputDir, fmt.Sprintf("s%d", i)), []byte("key: value\n"), 0644); err != nil {
+			t.Fatalf("failed to write fixture secret: %v", err)
+		}
+	}
+
+	client := newFakeVault(t, nil).client()
+	client.BatchSize = 1
+	client.BatchPause = time.Hour
+
+	done := make(chan error, 1)
+	go func() { done <- client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), true) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("dry run paused between batches")
+	}
+}
+
+func TestDryRunPushDiffsOneBatchAtATime(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	secrets := map[string]map[string]any{}
+	for i := 1; i <= 6; i++ {
+		if err := os.WriteFile(filepath.Join(inputDir, fmt.Sprintf("s%d", i)), []byte("key: new\n"), 0644); err != nil {
+			t.Fatalf("failed to write fixture secret: %v", err)
+		}
+		secrets[fmt.Sprintf("kv/app/s%d", i)] = map[string]any{"key": "old"}
+	}
+
+	var out strings.Builder
+	var mu sync.Mutex
+	var events []string
+	vault := newFakeVault(t, secrets)
+	client := vault.client()
+	client.Output = &out
+	client.BatchSize = 2
+	client.DiffConcurrency = 4
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/data/") {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, fmt.Sprintf("read after %d batches", strings.Count(out.String(), "Batch ")))
+		}
+		return vault.roundTrip(r)
+	})}
+
+	if err := client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	counts := map[string]int{}
+	for _, event := range events {
+		counts[event]++
+	}
+	for batch := 0; batch < 3; batch++ {
+		if got := counts[fmt.Sprintf("read after %d batches", batch)]; got != 2 {
+			t.Errorf("reads before batch %d = %d, want 2 (events %v)", batch+1, got, events)
+		}
+	}
+}
+
+// timedWriter records when each "Batch" progress line is written.
+type timedWriter struct {
+	mu      sync.Mutex
+	batches []time.Time
+}
+
+func (w *timedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if bytes.HasPrefix(p, []byte("Batch ")) {
+		w.batches = append(w.batches, time.Now())
+	}
+	return len(p), nil
+}
+
+func TestPullInBatchesHoldsListingDuringPause(t *testing.T) {
+	t.Parallel()
+
+	const pause = 100 * time.Millisecond
+	var mu sync.Mutex
+	var requests []time.Time
+	vault := newWideTree(t)
+	vault.listDelay = 30 * time.Millisecond
+	out := &timedWriter{}
+	client := vault.client()
+	client.Output = out
+	client.ListConcurrency = 2
+	client.BatchSize = 2
+	client.BatchPause = pause
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		mu.Lock()
+		requests = append(requests, time.Now())
+		mu.Unlock()
+		return vault.roundTrip(r)
+	})}
+
+	if err := client.PullSecretsToFilesDirectAt(NewSecretRef("kv", "root"), t.TempDir()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(out.batches) < 2 {
+		t.Fatalf("expected several batches, got %d", len(out.batches))
+	}
+	for _, batch := range out.batches[:len(out.batches)-1] {
+		for _, request := range requests {
+			if request.After(batch) && request.Before(batch.Add(pause)) {
+				t.Fatalf("request sent %v into a batch pause", request.Sub(batch))
+			}
+		}
+	}
+}
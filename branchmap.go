@@ -0,0 +1,132 @@
+package vaultsync
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrBranchNotMapped is returned when the current git branch matches no rule
+// of a branch map.
+var ErrBranchNotMapped = errors.New("branch is not in the branch map")
+
+// BranchRule maps git branches to the push target they may write to.
+type BranchRule struct {
+	// Branch is a branch name or a path.Match pattern such as "release/*".
+	Branch string `yaml:"branch"`
+
+	Namespace string `yaml:"namespace"`
+	// KVEngine is the KV mount; empty means the default engine.
+	KVEngine string `yaml:"kv_engine"`
+	// Path is the Vault path prefix below KVEngine; empty allows the whole
+	// mount.
+	Path string `yaml:"path"`
+	// InputDir is the local directory pushed when the target is taken from
+	// the rule; empty means the command's default.
+	InputDir string `yaml:"input_dir"`
+}
+
+// BranchMap is an ordered list of branch rules; the first rule whose Branch
+// matches wins.
+type BranchMap []BranchRule
+
+type branchMapFile struct {
+	Branches BranchMap `yaml:"branches"`
+}
+
+// LoadBranchMap reads a branch map from a YAML file with a top-level
+// "branches" list of rules.
+func LoadBranchMap(filePath string) (BranchMap, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+
+	var file branchMapFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filePath, err)
+	}
+	if len(file.Branches) == 0 {
+		return nil, fmt.Errorf("%s does not contain any branches", filePath)
+	}
+
+	for i := range file.Branches {
+		if err := normalizeAndValidateBranchRule(&file.Branches[i]); err != nil {
+			return nil, fmt.Errorf("invalid branch entry %d in %s: %w", i+1, filePath, err)
+		}
+	}
+	return file.Branches, nil
+}
+
+func normalizeAndValidateBranchRule(rule *BranchRule) error {
+	rule.Branch = strings.TrimSpace(rule.Branch)
+	rule.Namespace = NormalizeNamespace(rule.Namespace)
+	rule.KVEngine = strings.Trim(strings.TrimSpace(rule.KVEngine), "/")
+	rule.Path = strings.Trim(strings.TrimSpace(rule.Path), "/")
+	rule.InputDir = strings.TrimSpace(rule.InputDir)
+
+	if rule.Branch == "" {
+		return fmt.Errorf("branch is required")
+	}
+	if _, err := path.Match(rule.Branch, ""); err != nil {
+		return fmt.Errorf("invalid branch pattern %q: %w", rule.Branch, err)
+	}
+	if rule.Namespace == "" {
+		return fmt.Errorf("namespace is required")
+	}
+	return nil
+}
+
+// Match returns the first rule matching branch, or ErrBranchNotMapped.
+func (m BranchMap) Match(branch string) (BranchRule, error) {
+	for _, rule := range m {
+		if matched, _ := path.Match(rule.Branch, branch); matched {
+			return rule, nil
+		}
+	}
+	return BranchRule{}, fmt.Errorf("%w: %s", ErrBranchNotMapped, branch)
+}
+
+// Allows reports whether the rule permits pushing to subPath of engine in
+// namespace. defaultEngine stands in for an empty KVEngine.
+func (r BranchRule) Allows(namespace, engine, subPath, defaultEngine string) bool {
+	ruleEngine := r.KVEngine
+	if ruleEngine == "" {
+		ruleEngine = defaultEngine
+	}
+	if NormalizeNamespace(namespace) != r.Namespace || strings.Trim(engine, "/") != ruleEngine {
+		return false
+	}
+
+	subPath = strings.Trim(subPath, "/")
+	return r.Path == "" || subPath == r.Path || strings.HasPrefix(subPath, r.Path+"/")
+}
+
+// CurrentGitBranch returns the branch checked out in the git work tree
+// containing dir. A detached HEAD, as in many CI checkouts, is an error
+// because it names no branch.
+func CurrentGitBranch(dir string) (string, error) {
+	cmd := exec.Command("git", "-C", dir, "symbolic-ref", "--quiet", "--short", "HEAD")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return "", fmt.Errorf("cannot determine git branch in %s: HEAD is detached", dir)
+		}
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("cannot determine git branch in %s: %w: %s", dir, err, message)
+		}
+		return "", fmt.Errorf("cannot determine git branch in %s: %w", dir, err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,119 @@
+package vaultsync
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func writeBranchMap(t *testing.T, contents string) string {
+	t.Helper()
+	filePath := filepath.Join(t.TempDir(), "branches.yaml")
+	if err := os.WriteFile(filePath, []byte(contents), 0644); err != nil {
+		t.Fatalf("failed to write branch map: %v", err)
+	}
+	return filePath
+}
+
+func TestBranchMapMatchesFirstRule(t *testing.T) {
+	t.Parallel()
+
+	branchMap, err := LoadBranchMap(writeBranchMap(t, `
+branches:
+  - branch: main
+    namespace: /prod/
+    path: /app/
+  - branch: release/*
+    namespace: staging
+    kv_engine: kv
+    input_dir: ./release
+  - branch: "*"
+    namespace: dev
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := map[string]BranchRule{
+		"main":          {Branch: "main", Namespace: "prod", Path: "app"},
+		"release/1.2":   {Branch: "release/*", Namespace: "staging", KVEngine: "kv", InputDir: "./release"},
+		"feature-login": {Branch: "*", Namespace: "dev"},
+	}
+	for branch, want := range tests {
+		rule, err := branchMap.Match(branch)
+		if err != nil {
+			t.Fatalf("Match(%q): unexpected error: %v", branch, err)
+		}
+		if rule != want {
+			t.Fatalf("Match(%q) = %+v, want %+v", branch, rule, want)
+		}
+	}
+
+	if _, err := branchMap.Match("feature/login"); !errors.Is(err, ErrBranchNotMapped) {
+		t.Fatalf("expected ErrBranchNotMapped for feature/login, got %v", err)
+	}
+}
+
+func TestLoadBranchMapRejectsInvalidRules(t *testing.T) {
+	t.Parallel()
+
+	for name, contents := range map[string]string{
+		"empty":             "branches: []\n",
+		"missing branch":    "branches:\n  - namespace: dev\n",
+		"missing namespace": "branches:\n  - branch: main\n",
+		"bad pattern":       "branches:\n  - branch: \"[main\"\n    namespace: dev\n",
+	} {
+		if _, err := LoadBranchMap(writeBranchMap(t, contents)); err == nil {
+			t.Fatalf("%s: expected error, got nil", name)
+		}
+	}
+}
+
+func TestBranchRuleAllows(t *testing.T) {
+	t.Parallel()
+
+	rule := BranchRule{Branch: "main", Namespace: "prod", Path: "app"}
+	tests := []struct {
+		namespace, engine, subPath string
+		want                       bool
+	}{
+		{"prod", "secret", "app", true},
+		{"prod/", "secret", "app/db", true},
+		{"prod", "secret", "application", false},
+		{"prod", "secret", "", false},
+		{"dev", "secret", "app", false},
+		{"prod", "kv", "app", false},
+	}
+	for _, tt := range tests {
+		if got := rule.Allows(tt.namespace, tt.engine, tt.subPath, "secret"); got != tt.want {
+			t.Fatalf("Allows(%q, %q, %q) = %v, want %v", tt.namespace, tt.engine, tt.subPath, got, tt.want)
+		}
+	}
+}
+
+func TestCurrentGitBranch(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	dir := t.TempDir()
+	if output, err := exec.Command("git", "-C", dir, "init", "--quiet", "--initial-branch=release/1.2").CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v: %s", err, output)
+	}
+
+	branch, err := CurrentGitBranch(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if branch != "release/1.2" {
+		t.Fatalf("expected branch release/1.2, got %q", branch)
+	}
+
+	if _, err := CurrentGitBranch(t.TempDir()); err == nil {
+		t.Fatal("expected error outside a git work tree, got nil")
+	}
+}
@@ -0,0 +1,199 @@
+package vaultsync
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrCapabilityMismatch is returned when the token's capabilities on a path
+// differ from the ones the caller requires.
+var ErrCapabilityMismatch = errors.New("token capabilities do not match")
+
+// ErrMissingCapability is returned by a push preflight when the token cannot
+// write some of the target secrets.
+var ErrMissingCapability = errors.New("token lacks write capability")
+
+// policyCapabilities are the capabilities a Vault policy can grant on a path.
+var policyCapabilities = []string{"create", "read", "update", "patch", "delete", "list", "sudo"}
+
+// ParseCapabilities parses a comma-separated capability list such as
+// "read,list", rejecting names Vault policies do not know.
+func ParseCapabilities(list string) ([]string, error) {
+	var capabilities []string
+	for _, capability := range strings.Split(list, ",") {
+		capability = strings.ToLower(strings.TrimSpace(capability))
+		if capability == "" {
+			continue
+		}
+		if !slices.Contains(policyCapabilities, capability) {
+			return nil, fmt.Errorf("unknown capability %q (expected one of %s)", capability, strings.Join(policyCapabilities, ", "))
+		}
+		if !slices.Contains(capabilities, capability) {
+			capabilities = append(capabilities, capability)
+		}
+	}
+	if len(capabilities) == 0 {
+		return nil, errors.New("no capabilities given")
+	}
+	return capabilities, nil
+}
+
+// CapabilitiesSelf returns the token's capabilities on each API path
+// (relative to /v1/), as reported by sys/capabilities-self. Paths the token
+// has no access to report ["deny"]; a root token reports ["root"].
+func (v *VaultClient) CapabilitiesSelf(paths []string) (map[string][]string, error) {
+	data, err := v.WriteRaw("sys/capabilities-self", map[string]interface{}{"paths": paths})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up token capabilities: %w", err)
+	}
+
+	capabilities := make(map[string][]string, len(paths))
+	for _, path := range paths {
+		values, ok := data[path].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("capabilities-self response has no entry for %s", path)
+		}
+		for _, value := range values {
+			if s, ok := value.(string); ok {
+				capabilities[path] = append(capabilities[path], s)
+			}
+		}
+	}
+	return capabilities, nil
+}
+
+// RequireCapabilitiesAt checks that the token's capabilities on the tree
+// below each ref are exactly required: a missing capability fails, and so
+// does any capability beyond them, so a read-only job can refuse to run with
+// a token that could also write or delete. A root token's "root"
+// capability always counts as unexpected.
+//
+// The probed paths are the ref's data path and the data and metadata paths
+// of its children ("kv/data/app/", "kv/metadata/app/"), which is what
+// policies for the subtree match. Every mismatch is reported in one error
+// wrapping ErrCapabilityMismatch.
+func (v *VaultClient) RequireCapabilitiesAt(required []string, refs ...SecretRef) error {
+	probes := make(map[SecretRef][]string, len(refs))
+	var paths []string
+	for _, ref := range refs {
+		for _, path := range []string{
+			v.kvAPIPath("data", ref),
+			v.kvAPIPath("data", ref) + "/",
+			v.kvAPIPath("metadata", ref) + "/",
+		} {
+			if !slices.Contains(probes[ref], path) {
+				probes[ref] = append(probes[ref], path)
+			}
+			if !slices.Contains(paths, path) {
+				paths = append(paths, path)
+			}
+		}
+	}
+
+	capabilities, err := v.CapabilitiesSelf(paths)
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	for _, ref := range refs {
+		var granted []string
+		for _, path := range probes[ref] {
+			for _, capability := range capabilities[path] {
+				if capability != "deny" && !slices.Contains(granted, capability) {
+					granted = append(granted, capability)
+				}
+			}
+		}
+
+		var missing, unexpected []string
+		for _, capability := range required {
+			if !slices.Contains(granted, capability) {
+				missing = append(missing, capability)
+			}
+		}
+		for _, capability := range granted {
+			if !slices.Contains(required, capability) {
+				unexpected = append(unexpected, capability)
+			}
+		}
+
+		var details []string
+		if len(unexpected) > 0 {
+			details = append(details, "has unexpected "+strings.Join(unexpected, ","))
+		}
+		if len(missing) > 0 {
+			details = append(details, "lacks "+strings.Join(missing, ","))
+		}
+		if len(details) > 0 {
+			problems = append(problems, fmt.Sprintf("%s: token %s", ref.MetadataPath(), strings.Join(details, " and ")))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w (required %s):\n  %s", ErrCapabilityMismatch, strings.Join(required, ","), strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// preflightPush checks that the token can perform every write in pending:
+// create or update on each secret's data path (patch with PushOptions.Patch
+// on KV v2, unless a MergeStrategy merges client-side), and create or update
+// on its metadata path when the file sets _options. DataOnly and MetadataOnly
+// drop the checks for the writes they skip. Every path lacking a capability
+// is reported in one error wrapping ErrMissingCapability.
+func (v *VaultClient) preflightPush(pending []pendingPush) error {
+	if len(pending) == 0 {
+		return nil
+	}
+
+	writeCapabilities := []string{"create", "update"}
+	if v.PushOptions.Patch && !v.isKVv1() && (v.PushOptions.MergeStrategy == "" || v.PushOptions.MergeStrategy == MergeDeep) {
+		writeCapabilities = []string{"patch"}
+	}
+
+	needs := make(map[string][]string)
+	var paths []string
+	need := func(path string, anyOf []string) {
+		if _, ok := needs[path]; !ok {
+			paths = append(paths, path)
+		}
+		needs[path] = anyOf
+	}
+	for _, push := range pending {
+		ref := secretRefFromMetadataPath(push.vaultPath)
+		if !v.PushOptions.MetadataOnly {
+			need(v.kvAPIPath("data", ref), writeCapabilities)
+		}
+		if push.options != nil && !v.PushOptions.DataOnly && !v.isKVv1() {
+			need(v.kvAPIPath("metadata", ref), []string{"create", "update"})
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	capabilities, err := v.CapabilitiesSelf(paths)
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	for _, path := range paths {
+		granted := capabilities[path]
+		if slices.Contains(granted, "root") || slices.ContainsFunc(needs[path], func(capability string) bool {
+			return slices.Contains(granted, capability)
+		}) {
+			continue
+		}
+		problems = append(problems, fmt.Sprintf("%s (needs %s, has %s)", path, strings.Join(needs[path], " or "), strings.Join(granted, ",")))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w on %d of %d paths, nothing was pushed:\n  %s", ErrMissingCapability, len(problems), len(paths), strings.Join(problems, "\n  "))
+	}
+	v.printf("Preflight: token can write all %d paths\n", len(paths))
+	return nil
+}
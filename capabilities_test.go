@@ -0,0 +1,97 @@
+package vaultsync
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// capabilitiesClient answers sys/capabilities-self from capabilities, keyed by
+// API path, and records the requested paths.
+func capabilitiesClient(t *testing.T, capabilities map[string][]string, requested *[]string) *VaultClient {
+	t.Helper()
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	var captured capturedRequest
+	capture := captureSingleRequest(t, &captured)
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if _, err := capture(r); err != nil {
+			return nil, err
+		}
+		if captured.method != http.MethodPost || captured.path != "/v1/sys/capabilities-self" {
+			t.Fatalf("unexpected request %s %s", captured.method, captured.path)
+		}
+
+		data := map[string]any{}
+		for _, path := range captured.body["paths"].([]interface{}) {
+			*requested = append(*requested, path.(string))
+			if caps, ok := capabilities[path.(string)]; ok {
+				data[path.(string)] = caps
+			} else {
+				data[path.(string)] = []string{"deny"}
+			}
+		}
+		return jsonResponse(t, http.StatusOK, map[string]any{"data": data})
+	})}
+	return client
+}
+
+func TestRequireCapabilitiesAtAcceptsExactSet(t *testing.T) {
+	t.Parallel()
+
+	var requested []string
+	client := capabilitiesClient(t, map[string][]string{
+		"kv/data/app/":     {"read"},
+		"kv/metadata/app/": {"list"},
+	}, &requested)
+
+	if err := client.RequireCapabilitiesAt([]string{"read", "list"}, NewSecretRef("kv", "app")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "kv/data/app,kv/data/app/,kv/metadata/app/"
+	if strings.Join(requested, ",") != want {
+		t.Fatalf("expected probes %s, got %v", want, requested)
+	}
+}
+
+func TestRequireCapabilitiesAtRejectsExtraAndMissingCapabilities(t *testing.T) {
+	t.Parallel()
+
+	var requested []string
+	client := capabilitiesClient(t, map[string][]string{
+		"kv/data/app/":     {"create", "read", "update"},
+		"kv/metadata/app/": {"list"},
+		"kv/data/ops/":     {"read"},
+	}, &requested)
+
+	err := client.RequireCapabilitiesAt([]string{"read", "list"}, NewSecretRef("kv", "app"), NewSecretRef("kv", "ops"))
+	if !errors.Is(err, ErrCapabilityMismatch) {
+		t.Fatalf("expected ErrCapabilityMismatch, got %v", err)
+	}
+	for _, want := range []string{
+		"kv/metadata/app: token has unexpected create,update",
+		"kv/metadata/ops: token lacks list",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in error, got:\n%v", want, err)
+		}
+	}
+}
+
+func TestParseCapabilities(t *testing.T) {
+	t.Parallel()
+
+	got, err := ParseCapabilities("Read, list,read")
+	if err != nil || strings.Join(got, ",") != "read,list" {
+		t.Fatalf("expected [read list], got %v (%v)", got, err)
+	}
+	for _, list := range []string{"read,write", "", " , "} {
+		if _, err := ParseCapabilities(list); err == nil {
+			t.Fatalf("expected error for %q", list)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package vaultsync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// checkpointHeader is the first line of a pull checkpoint file: the pulled
+// path and the listing taken when the pull started. Each following line is
+// the path of a secret that has been written to disk.
+type checkpointHeader struct {
+	Path    string   `json:"path"`
+	Secrets []string `json:"secrets"`
+}
+
+// pullWithCheckpoint pulls the secrets below basePath like pullSecretsToFiles,
+// recording progress in PullOptions.Checkpoint after every secret. The tree
+// is listed once up front and the listing is stored in the checkpoint, so a
+// resumed pull (PullOptions.Resume) fetches exactly the secrets the
+// interrupted one had left, even if Vault changed in between. The checkpoint
+// is removed once every secret has been pulled.
+func (v *VaultClient) pullWithCheckpoint(basePath, outputDir string, mirrorBasePath bool, fileExtension string) error {
+	header, done, err := v.openCheckpoint(basePath)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(v.PullOptions.Checkpoint, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open checkpoint: %w", err)
+	}
+	defer file.Close()
+
+	if len(done) > 0 {
+		v.printf("Resuming: %d of %d secrets already pulled\n", len(done), len(header.Secrets))
+	}
+
+	errs := &MultiError{}
+	progress := v.newBatchProgress(len(header.Secrets)-len(done), false)
+	for _, secretPath := range header.Secrets {
+		if errs.Len() > 0 && (v.FailFast || errors.Is(errs, ErrOperationTimeout)) {
+			break
+		}
+		if done[secretPath] {
+			continue
+		}
+
+		secretData, err := v.GetSecretAt(secretRefFromMetadataPath(secretPath))
+		switch {
+		case errors.Is(err, ErrSecretNotFound) && !v.FailFast:
+			// Deleted since the listing was taken; there is nothing left
+			// to pull, now or on a later resume.
+			v.warnf("secret %s no longer exists, skipping\n", secretPath)
+		case err != nil:
+			v.addFailure(errs, secretPath, fmt.Errorf("failed to get secret: %w", err))
+			progress.step()
+			continue
+		default:
+			_, err := v.writeSecretToFile(secretPath, secretData, basePath, outputDir, mirrorBasePath, fileExtension, nil)
+			var failed *secretError
+			if errors.As(err, &failed) {
+				v.addFailure(errs, secretPath, failed.err)
+				progress.step()
+				continue
+			}
+			if err != nil {
+				return errors.Join(fmt.Errorf("failed to write secret %s: %w", secretPath, err), errs.ErrorOrNil())
+			}
+		}
+		if _, err := fmt.Fprintln(file, secretPath); err != nil {
+			return fmt.Errorf("failed to update checkpoint: %w", err)
+		}
+		if progress.step() {
+			if err := file.Sync(); err != nil {
+				return fmt.Errorf("failed to update checkpoint: %w", err)
+			}
+		}
+	}
+
+	if errs.Len() > 0 {
+		return fmt.Errorf("failed to pull secrets (progress saved in %s): %w", v.PullOptions.Checkpoint, errs)
+	}
+
+	file.Close()
+	if err := os.Remove(v.PullOptions.Checkpoint); err != nil {
+		return fmt.Errorf("failed to remove checkpoint: %w", err)
+	}
+	return v.writePullManifest(outputDir, nil)
+}
+
+// openCheckpoint loads the checkpoint to resume from or, when not resuming,
+// lists the tree below basePath and starts a new checkpoint, replacing any
+// old one. It returns the listing and the secrets already pulled.
+func (v *VaultClient) openCheckpoint(basePath string) (checkpointHeader, map[string]bool, error) {
+	if v.PullOptions.Resume {
+		header, done, err := readCheckpoint(v.PullOptions.Checkpoint)
+		if err != nil {
+			return checkpointHeader{}, nil, err
+		}
+		if header.Path != basePath {
+			return checkpointHeader{}, nil, fmt.Errorf("checkpoint %s is for %s, not %s", v.PullOptions.Checkpoint, header.Path, basePath)
+		}
+		return header, done, nil
+	}
+
+	secrets, err := v.listSecretTree(basePath)
+	if err != nil {
+		return checkpointHeader{}, nil, err
+	}
+	header := checkpointHeader{Path: basePath, Secrets: secrets}
+
+	encoded, err := json.Marshal(header)
+	if err != nil {
+		return checkpointHeader{}, nil, fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+	// 0600: the listing names every secret in the tree
+	if err := os.WriteFile(v.PullOptions.Checkpoint, append(encoded, '\n'), 0600); err != nil {
+		return checkpointHeader{}, nil, fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return header, map[string]bool{}, nil
+}
+
+// readCheckpoint parses a checkpoint file. A final line without a newline
+// was cut short by the interruption and is ignored, so that secret is pulled
+// again.
+func readCheckpoint(path string) (checkpointHeader, map[string]bool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return checkpointHeader{}, nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	lines := strings.Split(string(content), "\n")
+	lines = lines[:len(lines)-1]
+	if len(lines) == 0 {
+		return checkpointHeader{}, nil, fmt.Errorf("checkpoint %s is empty", path)
+	}
+
+	var header checkpointHeader
+	if err := json.Unmarshal([]byte(lines[0]), &header); err != nil {
+		return checkpointHeader{}, nil, fmt.Errorf("checkpoint %s is corrupt: %w", path, err)
+	}
+
+	done := make(map[string]bool, len(lines)-1)
+	for _, line := range lines[1:] {
+		done[line] = true
+	}
+	return header, done, nil
+}
+
+// listSecretTree returns the paths of the secrets below metadataPath in walk
+// order, without reading any of them. Unlike walkSecrets, a folder that
+// cannot be listed fails the whole listing.
+func (v *VaultClient) listSecretTree(metadataPath string) ([]string, error) {
+	lister := v.newFolderLister()
+	defer lister.stop()
+	return v.listFolderTree(lister, lister.start(metadataPath))
+}
+
+func (v *VaultClient) listFolderTree(lister *folderLister, listing *folderListing) ([]string, error) {
+	keys, err := lister.wait(listing)
+	if err != nil {
+		return nil, err
+	}
+
+	var secrets []string
+	for _, key := range keys {
+		entry := ParseListKey(key)
+		if entry.Name == "" {
+			v.warnf("skipping invalid key %q listed under %s\n", key, listing.path)
+			continue
+		}
+
+		if !entry.IsFolder {
+			secrets = append(secrets, listing.path+"/"+key)
+			continue
+		}
+		if v.PullOptions.NoRecurse {
+			continue
+		}
+		nested, err := v.listFolderTree(lister, lister.folder(listing, key))
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, nested...)
+	}
+	return secrets, nil
+}
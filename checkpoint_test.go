@@ -0,0 +1,126 @@
+package vaultsync
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// checkpointTestTransport lists keys under kv/app and c under kv/app/team,
+// fails reads of the paths in failing, and records every secret read.
+type checkpointTestTransport struct {
+	t *testing.T
+
+	mu      sync.Mutex
+	keys    []string
+	failing map[string]bool
+	reads   []string
+}
+
+func (c *checkpointTestTransport) roundTrip(r *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/v1/kv/metadata/app":
+		return jsonResponse(c.t, http.StatusOK, map[string]any{"data": map[string]any{"keys": c.keys}})
+	case "/v1/kv/metadata/app/team":
+		return jsonResponse(c.t, http.StatusOK, map[string]any{"data": map[string]any{"keys": []string{"c"}}})
+	}
+
+	c.reads = append(c.reads, r.URL.Path)
+	if c.failing[r.URL.Path] {
+		return textResponse(http.StatusServiceUnavailable, "unavailable"), nil
+	}
+	if r.URL.Path == "/v1/kv/data/app/gone" {
+		return textResponse(http.StatusNotFound, ""), nil
+	}
+	return jsonResponse(c.t, http.StatusOK, map[string]any{"data": map[string]any{"data": map[string]any{"k": "v"}}})
+}
+
+func TestPullWithCheckpointResumesWhereItStopped(t *testing.T) {
+	t.Parallel()
+
+	transport := &checkpointTestTransport{
+		t:       t,
+		keys:    []string{"a", "b", "team/"},
+		failing: map[string]bool{"/v1/kv/data/app/b": true},
+	}
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: roundTripFunc(transport.roundTrip)}
+
+	outputDir := t.TempDir()
+	checkpoint := filepath.Join(t.TempDir(), "pull.state")
+	client.PullOptions.Checkpoint = checkpoint
+
+	err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), outputDir)
+	if err == nil || !strings.Contains(err.Error(), "progress saved in "+checkpoint) {
+		t.Fatalf("expected a failed pull that keeps its checkpoint, got %v", err)
+	}
+	if _, err := os.Stat(checkpoint); err != nil {
+		t.Fatalf("expected checkpoint to be kept: %v", err)
+	}
+
+	// The tree changes before the resume; the resumed pull must stick to
+	// the listing it started from.
+	transport.mu.Lock()
+	transport.keys = []string{"a", "b", "new", "team/"}
+	transport.failing = nil
+	transport.reads = nil
+	transport.mu.Unlock()
+
+	client.PullOptions.Resume = true
+	if err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), outputDir); err != nil {
+		t.Fatalf("unexpected error on resume: %v", err)
+	}
+
+	if strings.Join(transport.reads, ",") != "/v1/kv/data/app/b" {
+		t.Fatalf("expected only the failed secret to be read again, got %v", transport.reads)
+	}
+	for _, name := range []string{"a.yaml", "b.yaml", filepath.Join("team", "c.yaml")} {
+		if _, err := os.Stat(filepath.Join(outputDir, "app", name)); err != nil {
+			t.Fatalf("expected %s to be pulled: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(checkpoint); !os.IsNotExist(err) {
+		t.Fatalf("expected checkpoint to be removed after completing, stat err %v", err)
+	}
+}
+
+func TestPullWithCheckpointSkipsSecretsDeletedSinceListing(t *testing.T) {
+	t.Parallel()
+
+	transport := &checkpointTestTransport{t: t, keys: []string{"a", "gone"}}
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: roundTripFunc(transport.roundTrip)}
+	client.PullOptions.Checkpoint = filepath.Join(t.TempDir(), "pull.state")
+
+	if err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), t.TempDir()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestReadCheckpointIgnoresTruncatedLastLine(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "pull.state")
+	content := `{"path":"kv/metadata/app","secrets":["kv/metadata/app/a","kv/metadata/app/b"]}` + "\nkv/metadata/app/a\nkv/meta"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write checkpoint: %v", err)
+	}
+
+	header, done, err := readCheckpoint(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(header.Secrets) != 2 || len(done) != 1 || !done["kv/metadata/app/a"] {
+		t.Fatalf("unexpected checkpoint state %+v %v", header, done)
+	}
+}
@@ -0,0 +1,171 @@
+package vaultsync
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A value split by PushOptions.ChunkFields is stored as the keys
+// "<key>__chunk_1" to "<key>__chunk_<n>", next to a marker key
+// "<key>__chunks" holding n. The marker is what tells a PullOptions.JoinChunks
+// pull to join the chunks back into "<key>".
+const (
+	chunkCountSuffix = "__chunks"
+	chunkPartSuffix  = "__chunk_"
+)
+
+func chunkPartKey(key string, n int) string {
+	return key + chunkPartSuffix + strconv.Itoa(n)
+}
+
+// chunkValues returns a copy of secretData in which every string value of a
+// key in chunkSizes that is longer than the key's size in bytes is replaced
+// by numbered chunks of at most that size and a marker key. Chunks are only
+// cut between UTF-8 characters. Shorter values, and keys that are missing,
+// are left alone.
+func chunkValues(secretData map[string]interface{}, chunkSizes map[string]int) (map[string]interface{}, error) {
+	chunked := make(map[string]interface{}, len(secretData))
+	for key, value := range secretData {
+		chunked[key] = value
+	}
+
+	for key, size := range chunkSizes {
+		value, ok := secretData[key]
+		if !ok {
+			continue
+		}
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot chunk key %q: value is a %T, not a string", key, value)
+		}
+		if len(text) <= size {
+			continue
+		}
+		if size < utf8.UTFMax {
+			return nil, fmt.Errorf("cannot chunk key %q: chunk size %d is smaller than %d bytes", key, size, utf8.UTFMax)
+		}
+		if _, ok := secretData[key+chunkCountSuffix]; ok {
+			return nil, fmt.Errorf("cannot chunk key %q: the secret already has a %q key", key, key+chunkCountSuffix)
+		}
+
+		delete(chunked, key)
+		n := 0
+		for text != "" {
+			end := min(size, len(text))
+			for end < len(text) && !utf8.RuneStart(text[end]) {
+				end--
+			}
+			n++
+			if _, ok := secretData[chunkPartKey(key, n)]; ok {
+				return nil, fmt.Errorf("cannot chunk key %q: the secret already has a %q key", key, chunkPartKey(key, n))
+			}
+			chunked[chunkPartKey(key, n)] = text[:end]
+			text = text[end:]
+		}
+		chunked[key+chunkCountSuffix] = n
+	}
+	return chunked, nil
+}
+
+// joinChunkedValues returns secretData with every value chunkValues split
+// joined back under its original key. A marker whose chunks are not all
+// present as strings is reported as an error and left as it is.
+func joinChunkedValues(secretData map[string]interface{}) (map[string]interface{}, error) {
+	markers := chunkMarkers(secretData)
+	if len(markers) == 0 {
+		return secretData, nil
+	}
+
+	joined := make(map[string]interface{}, len(secretData))
+	for key, value := range secretData {
+		joined[key] = value
+	}
+	var problems []string
+	for _, marker := range markers {
+		key := strings.TrimSuffix(marker, chunkCountSuffix)
+		n, ok := chunkCount(secretData[marker])
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%q is not a chunk count", marker))
+			continue
+		}
+
+		var value strings.Builder
+		complete := true
+		for i := 1; i <= n && complete; i++ {
+			part, ok := secretData[chunkPartKey(key, i)].(string)
+			if !ok {
+				problems = append(problems, fmt.Sprintf("chunk %d of %d of %q is missing", i, n, key))
+				complete = false
+			}
+			value.WriteString(part)
+		}
+		if !complete {
+			continue
+		}
+		if _, ok := secretData[key]; ok {
+			problems = append(problems, fmt.Sprintf("%q is both a key and chunked", key))
+			continue
+		}
+
+		for i := 1; i <= n; i++ {
+			delete(joined, chunkPartKey(key, i))
+		}
+		delete(joined, marker)
+		joined[key] = value.String()
+	}
+	if len(problems) > 0 {
+		return joined, fmt.Errorf("cannot join chunked values: %s", strings.Join(problems, "; "))
+	}
+	return joined, nil
+}
+
+// chunkMarkers returns the marker keys of secretData, sorted.
+func chunkMarkers(secretData map[string]interface{}) []string {
+	var markers []string
+	for key := range secretData {
+		if strings.HasSuffix(key, chunkCountSuffix) && key != chunkCountSuffix {
+			markers = append(markers, key)
+		}
+	}
+	slices.Sort(markers)
+	return markers
+}
+
+// chunkCount reads a marker value, which is a number after a JSON round trip
+// through Vault.
+func chunkCount(value interface{}) (int, bool) {
+	switch typed := value.(type) {
+	case int:
+		return typed, typed > 0
+	case float64:
+		n := int(typed)
+		return n, n > 0 && float64(n) == typed
+	case json.Number:
+		n, err := strconv.Atoi(typed.String())
+		return n, err == nil && n > 0
+	}
+	return 0, false
+}
+
+// joinChunks is joinChunkedValues for a pulled secret when
+// PullOptions.JoinChunks is set; see warnJoinChunks.
+func (v *VaultClient) joinChunks(secretPath string, secretData map[string]interface{}) map[string]interface{} {
+	if !v.PullOptions.JoinChunks {
+		return secretData
+	}
+	return v.warnJoinChunks(secretPath, secretData)
+}
+
+// warnJoinChunks is joinChunkedValues warning about chunks that cannot be
+// joined instead of failing the secret.
+func (v *VaultClient) warnJoinChunks(secretPath string, secretData map[string]interface{}) map[string]interface{} {
+	joined, err := joinChunkedValues(secretData)
+	if err != nil {
+		v.warnf("%s: %v\n", secretPath, err)
+	}
+	return joined
+}
@@ -0,0 +1,186 @@
+package vaultsync
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChunkValuesRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	secretData := map[string]interface{}{"cert": "abcdefghij", "short": "abc", "other": "untouched"}
+	chunked, err := chunkValues(secretData, map[string]int{"cert": 4, "short": 4, "missing": 4})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]interface{}{
+		"cert__chunks": 3, "cert__chunk_1": "abcd", "cert__chunk_2": "efgh", "cert__chunk_3": "ij",
+		"short": "abc", "other": "untouched",
+	}
+	if !reflect.DeepEqual(chunked, want) {
+		t.Fatalf("unexpected chunks: %v", chunked)
+	}
+	if _, ok := secretData["cert"]; !ok {
+		t.Fatal("chunkValues modified its input")
+	}
+
+	// The marker comes back from Vault as a JSON number.
+	encoded, _ := json.Marshal(chunked)
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	joined, err := joinChunkedValues(decoded)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(joined, secretData) {
+		t.Fatalf("expected %v, got %v", secretData, joined)
+	}
+}
+
+func TestChunkValuesKeepsCharactersWhole(t *testing.T) {
+	t.Parallel()
+
+	value := strings.Repeat("é€", 10)
+	chunked, err := chunkValues(map[string]interface{}{"v": value}, map[string]int{"v": 6})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	n := chunked["v__chunks"].(int)
+	var joined strings.Builder
+	for i := 1; i <= n; i++ {
+		part := chunked[chunkPartKey("v", i)].(string)
+		if len(part) > 6 || !strings.HasPrefix(value[joined.Len():], part) || !json.Valid([]byte(`"`+part+`"`)) {
+			t.Fatalf("chunk %d is %q", i, part)
+		}
+		joined.WriteString(part)
+	}
+	if joined.String() != value {
+		t.Fatalf("chunks do not add up to the value: %q", joined.String())
+	}
+}
+
+func TestChunkValuesErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		data   map[string]interface{}
+		size   int
+		errMsg string
+	}{
+		{name: "not a string", data: map[string]interface{}{"v": []interface{}{"abcdef"}}, size: 4, errMsg: "not a string"},
+		{name: "size too small", data: map[string]interface{}{"v": "abcdef"}, size: 2, errMsg: "smaller than"},
+		{name: "marker taken", data: map[string]interface{}{"v": "abcdef", "v__chunks": 1}, size: 4, errMsg: "already has"},
+		{name: "chunk key taken", data: map[string]interface{}{"v": "abcdef", "v__chunk_2": "x"}, size: 4, errMsg: "already has"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := chunkValues(tt.data, map[string]int{"v": tt.size})
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Fatalf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestJoinChunkedValuesLeavesIncompleteChunks(t *testing.T) {
+	t.Parallel()
+
+	secretData := map[string]interface{}{"v__chunks": float64(2), "v__chunk_1": "ab", "other": "x"}
+	joined, err := joinChunkedValues(secretData)
+	if err == nil || !strings.Contains(err.Error(), `chunk 2 of 2 of "v" is missing`) {
+		t.Fatalf("expected a missing chunk error, got %v", err)
+	}
+	if !reflect.DeepEqual(joined, secretData) {
+		t.Fatalf("expected the data unchanged, got %v", joined)
+	}
+}
+
+func TestChunkFieldsPushAndPull(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inputDir, "tls.yaml"), []byte("cert: abcdefghij\nkey: k\n"), 0600); err != nil {
+		t.Fatalf("failed to write fixture secret: %v", err)
+	}
+
+	var stored map[string]interface{}
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.PushOptions.ChunkFields = map[string]int{"cert": 4}
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		switch {
+		case r.Method == http.MethodPost:
+			body, _ := io.ReadAll(r.Body)
+			var payload struct {
+				Data map[string]interface{} `json:"data"`
+			}
+			if err := json.Unmarshal(body, &payload); err != nil {
+				t.Errorf("invalid write payload: %v", err)
+			}
+			stored = payload.Data
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"version": 1}})
+		case r.URL.Path == "/v1/kv/metadata/app" && stored != nil:
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"keys": []string{"tls"}}})
+		case r.URL.Path == "/v1/kv/data/app/tls" && stored != nil:
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"data": stored}})
+		}
+		return textResponse(http.StatusNotFound, "not found"), nil
+	})}
+
+	if err := client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), false); err != nil {
+		t.Fatalf("unexpected push error: %v", err)
+	}
+	if stored["cert__chunks"] != float64(3) || stored["cert__chunk_3"] != "ij" || stored["cert"] != nil {
+		t.Fatalf("cert was not stored in chunks: %v", stored)
+	}
+
+	// Without JoinChunks the chunks are pulled as Vault stores them.
+	rawDir := t.TempDir()
+	if err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), rawDir); err != nil {
+		t.Fatalf("unexpected pull error: %v", err)
+	}
+	raw, err := os.ReadFile(filepath.Join(rawDir, "app", "tls.yaml"))
+	if err != nil {
+		t.Fatalf("failed to read pulled file: %v", err)
+	}
+	if !strings.Contains(string(raw), "cert__chunks: 3\n") {
+		t.Fatalf("expected the chunks unjoined, got %q", raw)
+	}
+
+	outputDir := t.TempDir()
+	client.PullOptions.JoinChunks = true
+	if err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), outputDir); err != nil {
+		t.Fatalf("unexpected pull error: %v", err)
+	}
+	pulled := filepath.Join(outputDir, "app", "tls.yaml")
+	content, err := os.ReadFile(pulled)
+	if err != nil {
+		t.Fatalf("failed to read pulled file: %v", err)
+	}
+	if want := "cert: abcdefghij\nkey: k\n"; string(content) != want {
+		t.Fatalf("expected %q, got %q", want, content)
+	}
+
+	// Compare joins the chunks to match either file.
+	for _, file := range []string{pulled, filepath.Join(rawDir, "app", "tls.yaml")} {
+		diff, err := client.CompareSecretToFileAt(NewSecretRef("kv", "app/tls"), file)
+		if err != nil {
+			t.Fatalf("unexpected compare error: %v", err)
+		}
+		if diff != "" {
+			t.Fatalf("expected %s to match, got diff:\n%s", file, diff)
+		}
+	}
+}
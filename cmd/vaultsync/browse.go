@@ -0,0 +1,297 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/kriipke/vaultsync"
+)
+
+// maskedValue replaces secret values in the browser until reveal is toggled.
+const maskedValue = "********"
+
+// browseModel is the full-screen explorer for the KV tree. Vault is only
+// contacted from commands, so Update stays free of I/O and every step can be
+// driven in tests by feeding messages to it.
+type browseModel struct {
+	client   *vaultsync.VaultClient
+	kvEngine string
+	cwd      string
+
+	entries []string
+	cursor  int
+
+	// secret is the path of the secret being viewed, or "" while the
+	// folder listing is shown.
+	secret string
+	keys   []string
+	data   map[string]interface{}
+	reveal bool
+
+	status  string
+	err     error
+	loading bool
+}
+
+// browseListedMsg carries the entries of a folder once it has been listed.
+type browseListedMsg struct {
+	dir     string
+	entries []string
+	err     error
+}
+
+// browseSecretMsg carries the data of a secret opened from the listing.
+type browseSecretMsg struct {
+	secret string
+	data   map[string]interface{}
+	err    error
+}
+
+// browsePulledMsg reports the outcome of a pull started from the browser.
+type browsePulledMsg struct {
+	target string
+	dir    string
+	err    error
+}
+
+func cmdBrowse(global globalOptions, args []string, stdout, stderr io.Writer) int {
+	return runBrowse(global, args, os.Stdin, stdout, stderr)
+}
+
+func runBrowse(global globalOptions, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	parsed, err := parseListArgs(args)
+	if err == nil && parsed.keys {
+		err = fmt.Errorf("--keys is not supported by browse")
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		fmt.Fprintln(stderr, "Usage: vaultsync [--kv-engine=name] browse <namespace> [path]")
+		return exitUsage
+	}
+
+	client, err := newClient(global, parsed.namespace, stdout, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitCodeFor(err)
+	}
+	// Pulls report through the status line; the client's own progress
+	// output would tear the full-screen view.
+	client.Output = io.Discard
+	client.ErrOutput = io.Discard
+
+	model := newBrowseModel(client, engineOr(parsed.kvEngine, global.kvEngine), parsed.subPath)
+	program := tea.NewProgram(model, tea.WithInput(stdin), tea.WithOutput(stdout), tea.WithAltScreen())
+	if _, err := program.Run(); err != nil {
+		fmt.Fprintf(stderr, "Browser failed: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+func newBrowseModel(client *vaultsync.VaultClient, kvEngine, subPath string) browseModel {
+	return browseModel{
+		client:   client,
+		kvEngine: kvEngine,
+		cwd:      strings.Trim(subPath, "/"),
+		loading:  true,
+	}
+}
+
+func (m browseModel) Init() tea.Cmd {
+	return m.list(m.cwd)
+}
+
+// list lists dir in the background.
+func (m browseModel) list(dir string) tea.Cmd {
+	return func() tea.Msg {
+		entries, err := m.client.ListSecretsAt(vaultsync.NewSecretRef(m.kvEngine, dir))
+		slices.Sort(entries)
+		return browseListedMsg{dir: dir, entries: entries, err: err}
+	}
+}
+
+// open reads the secret at secretPath in the background.
+func (m browseModel) open(secretPath string) tea.Cmd {
+	return func() tea.Msg {
+		data, err := m.client.GetSecretAt(vaultsync.NewSecretRef(m.kvEngine, secretPath))
+		return browseSecretMsg{secret: secretPath, data: data, err: err}
+	}
+}
+
+// pull pulls target to the default secrets directory in the background.
+func (m browseModel) pull(target string) tea.Cmd {
+	return func() tea.Msg {
+		err := m.client.PullSecretsToFilesAt(vaultsync.NewSecretRef(m.kvEngine, target), defaultSecretsDir)
+		return browsePulledMsg{target: target, dir: defaultSecretsDir, err: err}
+	}
+}
+
+// selected returns the entry under the cursor, or "" in an empty folder.
+func (m browseModel) selected() string {
+	if m.cursor < 0 || m.cursor >= len(m.entries) {
+		return ""
+	}
+	return m.entries[m.cursor]
+}
+
+// join returns name below the current path; ".." is the parent folder.
+func (m browseModel) join(name string) string {
+	return strings.Trim(path.Clean("/"+m.cwd+"/"+name), "/")
+}
+
+func (m browseModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case browseListedMsg:
+		m.loading = false
+		if msg.err != nil {
+			m.err = fmt.Errorf("cannot enter %s: %w", pathDesc(m.kvEngine, msg.dir), msg.err)
+			return m, nil
+		}
+		m.cwd, m.entries, m.cursor, m.err = msg.dir, msg.entries, 0, nil
+		return m, nil
+
+	case browseSecretMsg:
+		m.loading = false
+		if msg.err != nil {
+			m.err = msg.err
+			return m, nil
+		}
+		m.secret, m.data, m.err = msg.secret, msg.data, nil
+		m.keys = make([]string, 0, len(msg.data))
+		for key := range msg.data {
+			m.keys = append(m.keys, key)
+		}
+		slices.Sort(m.keys)
+		return m, nil
+
+	case browsePulledMsg:
+		m.loading = false
+		if msg.err != nil {
+			m.err = fmt.Errorf("pull of %s failed: %w", pathDesc(m.kvEngine, msg.target), msg.err)
+			return m, nil
+		}
+		m.err = nil
+		m.status = fmt.Sprintf("Pulled %s to %s", pathDesc(m.kvEngine, msg.target), msg.dir)
+		return m, nil
+
+	case tea.KeyMsg:
+		return m.handleKey(msg)
+	}
+	return m, nil
+}
+
+func (m browseModel) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "r":
+		m.reveal = !m.reveal
+		return m, nil
+	}
+	if m.loading {
+		return m, nil
+	}
+
+	if m.secret != "" {
+		switch msg.String() {
+		case "esc", "backspace", "left", "h":
+			m.secret, m.data, m.keys = "", nil, nil
+		}
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.cursor < len(m.entries)-1 {
+			m.cursor++
+		}
+	case "enter", "right", "l":
+		entry := m.selected()
+		if entry == "" {
+			return m, nil
+		}
+		m.loading, m.status = true, ""
+		if strings.HasSuffix(entry, "/") {
+			return m, m.list(m.join(entry))
+		}
+		return m, m.open(m.join(entry))
+	case "backspace", "left", "h":
+		if m.cwd == "" {
+			return m, nil
+		}
+		m.loading, m.status = true, ""
+		return m, m.list(m.join(".."))
+	case "p":
+		// A selected folder is pulled on its own; otherwise the folder
+		// being shown is.
+		target := m.cwd
+		if entry := m.selected(); strings.HasSuffix(entry, "/") {
+			target = m.join(entry)
+		}
+		m.loading = true
+		m.status = fmt.Sprintf("Pulling %s to %s...", pathDesc(m.kvEngine, target), defaultSecretsDir)
+		return m, m.pull(target)
+	}
+	return m, nil
+}
+
+func (m browseModel) View() string {
+	var b strings.Builder
+
+	if m.secret != "" {
+		fmt.Fprintf(&b, "%s\n\n", pathDesc(m.kvEngine, m.secret))
+		if len(m.keys) == 0 {
+			b.WriteString("  (no keys)\n")
+		}
+		for _, key := range m.keys {
+			value := maskedValue
+			if m.reveal {
+				value = fmt.Sprint(m.data[key])
+			}
+			fmt.Fprintf(&b, "  %s: %s\n", key, value)
+		}
+	} else {
+		fmt.Fprintf(&b, "%s/\n\n", pathDesc(m.kvEngine, m.cwd))
+		if len(m.entries) == 0 && !m.loading {
+			b.WriteString("  (empty)\n")
+		}
+		for i, entry := range m.entries {
+			cursor := " "
+			if i == m.cursor {
+				cursor = ">"
+			}
+			fmt.Fprintf(&b, "%s %s\n", cursor, entry)
+		}
+	}
+
+	b.WriteString("\n")
+	switch {
+	case m.err != nil:
+		fmt.Fprintf(&b, "Error: %v\n", m.err)
+	case m.loading && m.status == "":
+		b.WriteString("Loading...\n")
+	case m.status != "":
+		b.WriteString(m.status + "\n")
+	}
+
+	reveal := "reveal"
+	if m.reveal {
+		reveal = "mask"
+	}
+	if m.secret != "" {
+		fmt.Fprintf(&b, "esc back • r %s values • q quit\n", reveal)
+	} else {
+		fmt.Fprintf(&b, "↑/↓ move • enter open • backspace up • p pull • r %s values • q quit\n", reveal)
+	}
+	return b.String()
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/kriipke/vaultsync"
+)
+
+func newBrowseServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body any
+		switch {
+		case r.URL.Path == "/v1/kv/metadata" && r.URL.RawQuery == "list=true":
+			body = map[string]any{"data": map[string]any{"keys": []string{"app/"}}}
+		case r.URL.Path == "/v1/kv/metadata/app" && r.URL.RawQuery == "list=true":
+			body = map[string]any{"data": map[string]any{"keys": []string{"db"}}}
+		case r.URL.Path == "/v1/kv/data/app/db":
+			body = map[string]any{"data": map[string]any{"data": map[string]any{"password": "hunter2"}}}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newBrowseTestModel returns a browser on kv/ in server, after its initial
+// listing.
+func newBrowseTestModel(t *testing.T, server *httptest.Server) browseModel {
+	t.Helper()
+
+	client := vaultsync.NewVaultClient(server.URL, "token", "team-a")
+	client.Output = io.Discard
+	client.ErrOutput = io.Discard
+	model := newBrowseModel(client, "kv", "")
+	return runBrowseCmd(t, model, model.Init())
+}
+
+// runBrowseCmd runs cmd, feeding the messages it produces back into model
+// the way the bubbletea runtime would.
+func runBrowseCmd(t *testing.T, model browseModel, cmd tea.Cmd) browseModel {
+	t.Helper()
+
+	for cmd != nil {
+		msg := cmd()
+		if _, ok := msg.(tea.QuitMsg); ok {
+			return model
+		}
+		var next tea.Model
+		next, cmd = model.Update(msg)
+		model = next.(browseModel)
+	}
+	return model
+}
+
+// pressKeys sends each key to model and runs the commands they start.
+func pressKeys(t *testing.T, model browseModel, keys ...string) browseModel {
+	t.Helper()
+
+	for _, key := range keys {
+		var msg tea.KeyMsg
+		switch key {
+		case "enter":
+			msg = tea.KeyMsg{Type: tea.KeyEnter}
+		case "esc":
+			msg = tea.KeyMsg{Type: tea.KeyEsc}
+		case "backspace":
+			msg = tea.KeyMsg{Type: tea.KeyBackspace}
+		default:
+			msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+		}
+		next, cmd := model.Update(msg)
+		model = runBrowseCmd(t, next.(browseModel), cmd)
+	}
+	return model
+}
+
+func TestBrowseMasksValuesUntilRevealed(t *testing.T) {
+	t.Parallel()
+
+	model := newBrowseTestModel(t, newBrowseServer(t))
+	if view := model.View(); !strings.Contains(view, "> app/") {
+		t.Fatalf("expected root listing with app/ selected, got %q", view)
+	}
+
+	model = pressKeys(t, model, "enter", "enter")
+	if model.secret != "app/db" {
+		t.Fatalf("expected app/db to be open, got %q", model.secret)
+	}
+	if view := model.View(); !strings.Contains(view, "password: "+maskedValue) || strings.Contains(view, "hunter2") {
+		t.Fatalf("expected masked value, got %q", view)
+	}
+
+	model = pressKeys(t, model, "r")
+	if view := model.View(); !strings.Contains(view, "password: hunter2") {
+		t.Fatalf("expected revealed value, got %q", view)
+	}
+
+	model = pressKeys(t, model, "esc", "backspace")
+	if model.secret != "" || model.cwd != "" {
+		t.Fatalf("expected to be back at the root listing, got secret %q cwd %q", model.secret, model.cwd)
+	}
+}
+
+func TestBrowseReportsUnknownFolder(t *testing.T) {
+	t.Parallel()
+
+	model := newBrowseTestModel(t, newBrowseServer(t))
+	model = runBrowseCmd(t, model, model.list("missing"))
+
+	if model.err == nil || !strings.Contains(model.err.Error(), "cannot enter kv/missing") {
+		t.Fatalf("expected error for unknown folder, got %v", model.err)
+	}
+	if model.cwd != "" {
+		t.Fatalf("expected path to stay unchanged, got %q", model.cwd)
+	}
+}
+
+func TestBrowsePullsSelectedFolder(t *testing.T) {
+	t.Parallel()
+
+	model := newBrowseTestModel(t, newBrowseServer(t))
+	next, cmd := model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("p")})
+	model = next.(browseModel)
+	if !model.loading || !strings.Contains(model.status, "Pulling kv/app") {
+		t.Fatalf("expected a pull of the selected folder to start, got status %q", model.status)
+	}
+	if cmd == nil {
+		t.Fatal("expected a pull command")
+	}
+}
+
+func TestBrowseQuits(t *testing.T) {
+	t.Parallel()
+
+	model := newBrowseTestModel(t, newBrowseServer(t))
+	_, cmd := model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")})
+	if cmd == nil {
+		t.Fatal("expected a quit command")
+	}
+	if _, ok := cmd().(tea.QuitMsg); !ok {
+		t.Fatal("expected q to quit")
+	}
+}
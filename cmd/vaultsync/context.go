@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kriipke/vaultsync"
+)
+
+// applyContext selects the context named by --context, or else the contexts
+// file's current context, and fills in the global options it covers that
+// were not given on the command line (set holds the flags that were). The
+// address, token, and namespace are applied by newEnvClient. Without a
+// contexts file, only an explicit --context is an error.
+func applyContext(global *globalOptions, name string, set map[string]bool) error {
+	contextsPath, err := vaultsync.DefaultContextsPath()
+	if err != nil {
+		return err
+	}
+	file, err := vaultsync.LoadContexts(contextsPath)
+	if errors.Is(err, os.ErrNotExist) && name == "" {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if name == "" {
+		name = file.Current
+	}
+	if name == "" {
+		return nil
+	}
+	ctx, err := file.Context(name)
+	if err != nil {
+		return fmt.Errorf("%w in %s", err, contextsPath)
+	}
+	useContext(global, ctx, set)
+	return nil
+}
+
+// useContext selects ctx and fills in the global options it covers that were
+// not given on the command line.
+func useContext(global *globalOptions, ctx *vaultsync.VaultContext, set map[string]bool) {
+	global.context = ctx
+	if ctx.Engine != "" && !set["kv-engine"] {
+		global.kvEngine = ctx.Engine
+	}
+	if ctx.Auth.Method != "" && !set["reauth"] {
+		global.reauth = ctx.Auth.Method
+		if !set["auth-mount"] {
+			global.authMount = ctx.Auth.Mount
+		}
+	}
+}
+
+// cmdContext lists the contexts in the contexts file or switches the current
+// one.
+func cmdContext(args []string, stdout, stderr io.Writer) int {
+	usage := func() int {
+		fmt.Fprintln(stderr, "Usage: vaultsync context list | use <name>")
+		return exitUsage
+	}
+
+	contextsPath, err := vaultsync.DefaultContextsPath()
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitCodeFor(err)
+	}
+
+	switch {
+	case len(args) == 1 && args[0] == "list":
+		file, err := vaultsync.LoadContexts(contextsPath)
+		if err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return exitCodeFor(err)
+		}
+		if len(file.Contexts) == 0 {
+			fmt.Fprintf(stdout, "No contexts defined in %s\n", contextsPath)
+			return exitOK
+		}
+
+		tw := newTable(stdout)
+		fmt.Fprintln(tw, "CURRENT\tNAME\tADDRESS\tNAMESPACE\tENGINE")
+		for _, ctx := range file.Contexts {
+			current := ""
+			if ctx.Name == file.Current {
+				current = "*"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", current, ctx.Name, orDash(ctx.Address), orDash(ctx.Namespace), orDash(ctx.Engine))
+		}
+		tw.Flush()
+		return exitOK
+	case len(args) == 2 && args[0] == "use":
+		if err := vaultsync.SetCurrentContext(contextsPath, args[1]); err != nil {
+			fmt.Fprintf(stderr, "Failed to switch context: %v\n", err)
+			return exitCodeFor(err)
+		}
+		fmt.Fprintf(stdout, "Switched to context %q\n", args[1])
+		return exitOK
+	default:
+		return usage()
+	}
+}
+
+// orDash returns s, or "-" for an empty table cell.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
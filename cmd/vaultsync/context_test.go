@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setupContexts points HOME at a temporary directory holding a contexts file
+// whose "dev" context targets server.
+func setupContexts(t *testing.T, server *httptest.Server) {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("VAULT_ADDR", "")
+	t.Setenv("VAULT_TOKEN", "")
+
+	content := "contexts:\n" +
+		"  - name: dev\n    address: " + server.URL + "\n    namespace: org\n    engine: secret\n    token: ctx-token\n" +
+		"  - name: prod\n    address: https://vault.prod.example\n"
+	dir := filepath.Join(home, ".vaultsync")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatalf("failed to create contexts dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "contexts.yaml"), []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write contexts file: %v", err)
+	}
+}
+
+func TestContextSuppliesAddressTokenNamespaceAndEngine(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path+" "+r.Header.Get("X-Vault-Namespace")+" "+r.Header.Get("X-Vault-Token"))
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"keys": []string{"db"}}})
+	}))
+	t.Cleanup(server.Close)
+	setupContexts(t, server)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--context=dev", "list", "team", "app", "--format=plain"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr %q)", code, stderr.String())
+	}
+
+	// Flags and environment variables win over the context.
+	t.Setenv("VAULT_TOKEN", "env-token")
+	if code := run([]string{"--context=dev", "--kv-engine=kv", "list", "team", "app", "--format=plain"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr %q)", code, stderr.String())
+	}
+
+	want := []string{
+		"/v1/secret/metadata/app org/team ctx-token",
+		"/v1/kv/metadata/app org/team env-token",
+	}
+	if strings.Join(requests, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected requests:\n%s\nwant:\n%s", strings.Join(requests, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestContextUseSwitchesCurrentContext(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"keys": []string{"db"}}})
+	}))
+	t.Cleanup(server.Close)
+	setupContexts(t, server)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"context", "use", "dev"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr %q)", code, stderr.String())
+	}
+	if stdout.String() != "Switched to context \"dev\"\n" {
+		t.Fatalf("unexpected output %q", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"context", "list"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr %q)", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "*        dev") || !strings.Contains(stdout.String(), "prod") {
+		t.Fatalf("expected dev marked current, got:\n%s", stdout.String())
+	}
+
+	// The current context applies without --context.
+	if code := run([]string{"list", "team", "app"}, &stdout, &stderr); code != 0 || requests != 1 {
+		t.Fatalf("expected the current context to be used, got exit code %d, %d requests (stderr %q)", code, requests, stderr.String())
+	}
+
+	if code := run([]string{"context", "use", "stage"}, &stdout, &stderr); code != 1 {
+		t.Fatalf("expected exit code 1 for an unknown context, got %d", code)
+	}
+	stderr.Reset()
+	if code := run([]string{"--context=stage", "list", "team"}, &stdout, &stderr); code != exitUsage || !strings.Contains(stderr.String(), "context not found") {
+		t.Fatalf("expected a usage error and context not found, got %d (stderr %q)", code, stderr.String())
+	}
+}
+
+func TestSyncCopiesBetweenContexts(t *testing.T) {
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "src-token" {
+			t.Errorf("source got token %q", r.Header.Get("X-Vault-Token"))
+		}
+		switch r.URL.Path {
+		case "/v1/kv/metadata/app":
+			_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"keys": []string{"db"}}})
+		case "/v1/kv/data/app/db":
+			_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"data": map[string]any{"password": "hunter2"}}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(source.Close)
+
+	var written []string
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		written = append(written, r.URL.Path+" "+r.Header.Get("X-Vault-Namespace")+" "+r.Header.Get("X-Vault-Token")+" "+strings.TrimSpace(string(body)))
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"version": 1}})
+	}))
+	t.Cleanup(destination.Close)
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	// The contexts named by sync win over the environment.
+	t.Setenv("VAULT_ADDR", "https://vault.unused.example")
+	t.Setenv("VAULT_TOKEN", "env-token")
+	content := "current: src\ncontexts:\n" +
+		"  - name: src\n    address: " + source.URL + "\n    token: src-token\n" +
+		"  - name: dr\n    address: " + destination.URL + "\n    namespace: dr\n    token: dr-token\n"
+	dir := filepath.Join(home, ".vaultsync")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatalf("failed to create contexts dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "contexts.yaml"), []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write contexts file: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"sync", "src", "dr", "app", "--dry-run"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("dry run: expected exit code 0, got %d (stderr %q)", code, stderr.String())
+	}
+	if len(written) != 0 {
+		t.Fatalf("dry run wrote to the destination: %v", written)
+	}
+	if !strings.Contains(stdout.String(), "Would sync 1 secrets") {
+		t.Fatalf("dry run output missing summary:\n%s", stdout.String())
+	}
+
+	if code := run([]string{"sync", "src", "dr", "app"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr %q)", code, stderr.String())
+	}
+	want := []string{`/v1/kv/data/app/db dr dr-token {"data":{"password":"hunter2"}}`}
+	if strings.Join(written, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected writes:\n%s\nwant:\n%s", strings.Join(written, "\n"), strings.Join(want, "\n"))
+	}
+}
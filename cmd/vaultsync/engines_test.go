@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEnginesListsKVEnginesUnlessAll(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/sys/mounts" {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{
+				"kv/":       map[string]any{"type": "kv", "description": "app secrets", "options": map[string]any{"version": "2"}},
+				"database/": map[string]any{"type": "database", "description": "dynamic creds"},
+			},
+		})
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "token")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"engines", "ns"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr %q)", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "kv    kv    v2       app secrets") {
+		t.Fatalf("expected the kv engine row, got:\n%s", stdout.String())
+	}
+	if strings.Contains(stdout.String(), "database") {
+		t.Fatalf("expected non-KV engines to be hidden, got:\n%s", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"engines", "ns", "--all"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0 with --all, got %d", code)
+	}
+	if !strings.Contains(stdout.String(), "database") {
+		t.Fatalf("expected --all to include the database engine, got:\n%s", stdout.String())
+	}
+}
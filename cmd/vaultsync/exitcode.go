@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/kriipke/vaultsync"
+)
+
+// Exit codes shared by every command, so automation can tell what went wrong
+// without parsing messages.
+const (
+	exitOK = 0
+	// exitError is any failure without a more specific code.
+	exitError = 1
+	// exitPartial means the command ran to the end, but some secrets or
+	// files could not be handled.
+	exitPartial = 2
+	// exitAuth means Vault rejected the token, a login failed, or the token
+	// lacks the capabilities the command needs.
+	exitAuth = 3
+	// exitDrift means compare or verify found differences.
+	exitDrift = 4
+	// exitUsage means the command line was invalid.
+	exitUsage = 5
+)
+
+// errLoginFailed marks the failure of the up-front login of newClient.
+var errLoginFailed = errors.New("failed to log in")
+
+// exitCodeFor maps an error returned by a command to its exit code. Failures
+// collected by a vaultsync.MultiError are a partial failure, unless every one
+// of them is an auth failure.
+func exitCodeFor(err error) int {
+	var multi *vaultsync.MultiError
+	if errors.As(err, &multi) {
+		for _, failure := range multi.Errors() {
+			if !isAuthError(failure.Err) {
+				return exitPartial
+			}
+		}
+		return exitAuth
+	}
+	if isAuthError(err) {
+		return exitAuth
+	}
+	return exitError
+}
+
+// isAuthError reports whether err means Vault did not accept the client's
+// credentials or did not grant them the access the command needs.
+func isAuthError(err error) bool {
+	if errors.Is(err, errLoginFailed) ||
+		errors.Is(err, vaultsync.ErrCapabilityMismatch) ||
+		errors.Is(err, vaultsync.ErrMissingCapability) {
+		return true
+	}
+	var httpErr *vaultsync.HTTPError
+	return errors.As(err, &httpErr) &&
+		(httpErr.StatusCode == http.StatusUnauthorized || httpErr.StatusCode == http.StatusForbidden)
+}
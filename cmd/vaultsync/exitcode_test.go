@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/kriipke/vaultsync"
+)
+
+func TestExitCodeFor(t *testing.T) {
+	t.Parallel()
+
+	forbidden := &vaultsync.HTTPError{StatusCode: 403, Body: "permission denied"}
+
+	partial := &vaultsync.MultiError{}
+	partial.Add("kv/metadata/app/db", forbidden)
+	partial.Add("kv/metadata/app/api", errors.New("invalid YAML"))
+
+	allAuth := &vaultsync.MultiError{}
+	allAuth.Add("kv/metadata/app/db", forbidden)
+	allAuth.Add("kv/metadata/app/api", &vaultsync.HTTPError{StatusCode: 401, Body: "missing client token"})
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"generic error", errors.New("connection refused"), exitError},
+		{"server error", &vaultsync.HTTPError{StatusCode: 500, Body: "internal error"}, exitError},
+		{"forbidden", fmt.Errorf("failed to get secret: %w", forbidden), exitAuth},
+		{"login failed", fmt.Errorf("%w: %w", errLoginFailed, &vaultsync.HTTPError{StatusCode: 400, Body: "invalid role"}), exitAuth},
+		{"capability mismatch", fmt.Errorf("check: %w", vaultsync.ErrCapabilityMismatch), exitAuth},
+		{"partial failure", fmt.Errorf("failed to pull secrets: %w", partial), exitPartial},
+		{"partial failure of auth errors only", allAuth, exitAuth},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := exitCodeFor(tt.err); got != tt.want {
+				t.Fatalf("exitCodeFor(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
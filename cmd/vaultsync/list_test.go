@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestListFormats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/kv/metadata/app" {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{"keys": []string{"db", "team/"}},
+		})
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "token")
+
+	tests := []struct {
+		format  string
+		compact bool
+		want    string
+	}{
+		{format: "human", want: "Secrets at kv/app in namespace ns:\n  - db\n  - team/\n"},
+		{format: "plain", want: "db\nteam/\n"},
+		{format: "json", want: "[\n  \"db\",\n  \"team/\"\n]\n"},
+		{format: "json", compact: true, want: "[\"db\",\"team/\"]\n"},
+		// Not a terminal, so the table degrades to plain output.
+		{format: "table", want: "db\nteam/\n"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		argv := []string{"list", "ns", "app", "--format=" + tt.format}
+		if tt.compact {
+			argv = append([]string{"--compact-json"}, argv...)
+		}
+		if code := run(argv, &stdout, &stderr); code != 0 {
+			t.Fatalf("%s: expected exit code 0, got %d (stderr %q)", tt.format, code, stderr.String())
+		}
+		if stdout.String() != tt.want {
+			t.Fatalf("%s: expected output %q, got %q", tt.format, tt.want, stdout.String())
+		}
+	}
+}
+
+func TestListSortOrder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{"keys": []string{"zeta", "Beta/", "alpha", "app/"}},
+		})
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "token")
+
+	tests := []struct {
+		flags []string
+		want  string
+	}{
+		{want: "alpha\napp/\nBeta/\nzeta\n"},
+		{flags: []string{"--folders-first"}, want: "app/\nBeta/\nalpha\nzeta\n"},
+		{flags: []string{"--sort=none"}, want: "zeta\nBeta/\nalpha\napp/\n"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		argv := append([]string{"list", "ns", "--format=plain"}, tt.flags...)
+		if code := run(argv, &stdout, &stderr); code != 0 {
+			t.Fatalf("%v: expected exit code 0, got %d (stderr %q)", tt.flags, code, stderr.String())
+		}
+		if stdout.String() != tt.want {
+			t.Fatalf("%v: expected output %q, got %q", tt.flags, tt.want, stdout.String())
+		}
+	}
+}
+
+func TestPullSummaryOnly(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/kv/metadata/app":
+			_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"keys": []string{"db", "team/"}}})
+		case "/v1/kv/metadata/app/team":
+			_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"keys": []string{"api", "web"}}})
+		default:
+			_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"data": map[string]any{"password": "hunter2"}}})
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "token")
+
+	outputDir := t.TempDir()
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"pull", "ns", "app", outputDir, "--summary-only", "--with-sizes"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr %q)", code, stderr.String())
+	}
+	want := "Summary of kv/app in namespace ns:\n  Secrets: 3\n  Folders: 1\n  Size:    66B\n"
+	if stdout.String() != want {
+		t.Fatalf("expected output %q, got %q", want, stdout.String())
+	}
+	if entries, _ := os.ReadDir(outputDir); len(entries) != 0 {
+		t.Fatalf("expected nothing written, found %d entries", len(entries))
+	}
+}
+
+func TestListAutoNamespace(t *testing.T) {
+	var listNamespace string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/token/lookup-self":
+			if r.Header.Get("X-Vault-Namespace") != "" {
+				t.Errorf("expected the lookup in the root namespace, got %q", r.Header.Get("X-Vault-Namespace"))
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"namespace_path": "team-a/"}})
+		case "/v1/kv/metadata/app":
+			listNamespace = r.Header.Get("X-Vault-Namespace")
+			_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"keys": []string{"db"}}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "token")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--auto-namespace", "list", "app", "--format=plain"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("expected exit code %d, got %d (stderr %q)", exitOK, code, stderr.String())
+	}
+	if listNamespace != "team-a" {
+		t.Fatalf("expected the list in namespace team-a, got %q", listNamespace)
+	}
+	if stdout.String() != "db\n" {
+		t.Fatalf("expected output %q, got %q", "db\n", stdout.String())
+	}
+}
+
+func TestListAutoNamespaceFailsWithoutLookup(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/auth/token/lookup-self" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		http.Error(w, "permission denied", http.StatusForbidden)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "token")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--auto-namespace", "list", "app"}, &stdout, &stderr); code != exitAuth {
+		t.Fatalf("expected exit code %d, got %d (stderr %q)", exitAuth, code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "cannot determine the namespace of the token") {
+		t.Fatalf("expected a namespace error, got %q", stderr.String())
+	}
+}
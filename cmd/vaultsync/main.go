@@ -3,13 +3,21 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/kriipke/vaultsync"
+	"gopkg.in/yaml.v3"
 )
 
 // Populated at build time via -ldflags "-X main.version=... -X main.buildTime=...".
@@ -25,58 +33,386 @@ func main() {
 // run parses argv and dispatches to a command handler, returning the process
 // exit code. It is separated from main so it can be exercised in tests.
 func run(argv []string, stdout, stderr io.Writer) int {
+	var global globalOptions
+
 	fs := flag.NewFlagSet("vaultsync", flag.ContinueOnError)
 	fs.SetOutput(stderr)
-	kvEngine := fs.String("kv-engine", "kv", "Name of the KVv2 secret engine")
+	fs.StringVar(&global.kvEngine, "kv-engine", "kv", "Name of the KVv2 secret engine")
+	fs.BoolVar(&global.showIdentity, "show-identity", false, "Print the token's identity before running the command")
+	fs.BoolVar(&global.autoNamespace, "auto-namespace", false, "Take the namespace from the token (lookup-self) and leave out the namespace argument")
+	fs.StringVar(&global.dataSegment, "data-segment", "", "Path segment used in place of \"data\" in KV API URLs")
+	fs.StringVar(&global.metadataSegment, "metadata-segment", "", "Path segment used in place of \"metadata\" in KV API URLs")
+	fs.BoolVar(&global.maskValues, "mask-values", false, "Hide secret values in diffs (default when stdout is not a terminal)")
+	fs.BoolVar(&global.showValues, "show-values", false, "Show secret values in diffs even when stdout is not a terminal")
+	fs.IntVar(&global.kvVersion, "kv-version", 2, "KV engine version: 1 or 2")
+	fs.DurationVar(&global.opTimeout, "op-timeout", 0, "Upper bound on the whole command's time talking to Vault (e.g. 5m)")
+	fs.DurationVar(&global.waitForVault, "wait-for-vault", 0, "Wait up to this long for Vault to be unsealed and active before running (e.g. 2m)")
+	fs.DurationVar(&global.readTimeout, "timeout-per-secret", 0, "Skip a secret whose read takes longer than this (e.g. 10s)")
+	fs.IntVar(&global.listConcurrency, "list-concurrency", 1, "List up to this many folders of a tree at once")
+	fs.IntVar(&global.diffConcurrency, "diff-concurrency", 1, "Read and diff up to this many secrets of a dry-run push at once")
+	fs.IntVar(&global.batchSize, "batch-size", 0, "Pull or push in batches of this many secrets, with a progress line and a pause after each")
+	fs.DurationVar(&global.batchPause, "batch-pause", time.Second, "Pause between --batch-size batches, skipped on dry runs")
+	fs.BoolVar(&global.failFast, "fail-fast", false, "Abort on the first secret-level error instead of continuing")
+	fs.BoolVar(&global.revokeOnExit, "revoke-on-exit", false, "Revoke the leases of dynamic secrets read by the command when it finishes")
+	fs.StringVar(&global.reauth, "reauth", "", "Log in again with this auth method (approle or kubernetes) when the token expires")
+	fs.StringVar(&global.authMount, "auth-mount", "", "Mount path of the --reauth auth method (default: the method name)")
+	fs.BoolVar(&global.compactJSON, "compact-json", false, "Print JSON output and write vault-kv files on a single line instead of indented")
+	fs.StringVar(&global.userAgent, "user-agent", "vaultsync/"+version, "User-Agent header sent with every request")
+	fs.StringVar(&global.writeAddr, "write-addr", "", "Send writes to this Vault address (e.g. the replication primary) and reads to VAULT_ADDR")
+	fs.StringVar(&global.folderDetect, "folder-detect", vaultsync.FolderDetectSlash, "How list keys are recognized as folders: slash or probe")
+	var redactPatterns repeatedFlag
+	fs.Var(&redactPatterns, "redact-pattern", "Partially mask values matching this regular expression in diffs (repeatable)")
+	dropKeys := fs.String("drop-keys", "", "Comma-separated secret keys removed from every secret a pull writes")
+	warningsFile := fs.String("warnings-file", "", "Also append every warning and unreadable secret to this file, one per line")
+	contextName := fs.String("context", "", "Run against this context from the contexts file instead of the current one")
+	tlsPins := fs.String("tls-pin", "", "Accept only a Vault certificate with this fingerprint, sha256:<hex> (comma-separated for rotation)")
 	showVersion := fs.Bool("version", false, "Print version information and exit")
 	fs.BoolVar(showVersion, "v", false, "Print version information and exit (shorthand)")
 
 	if err := fs.Parse(argv); err != nil {
-		return 2
+		return exitUsage
+	}
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	global.setFlags = set
+	if fs.Arg(0) != "context" {
+		if err := applyContext(&global, *contextName, set); err != nil {
+			fmt.Fprintf(stderr, "--context: %v\n", err)
+			return exitUsage
+		}
+	}
+
+	if global.kvVersion != 1 && global.kvVersion != 2 {
+		fmt.Fprintln(stderr, "--kv-version must be 1 or 2")
+		return exitUsage
+	}
+	if global.folderDetect != vaultsync.FolderDetectSlash && global.folderDetect != vaultsync.FolderDetectProbe {
+		fmt.Fprintln(stderr, "--folder-detect must be slash or probe")
+		return exitUsage
+	}
+
+	global.dropKeys = splitList(*dropKeys)
+	for _, expr := range redactPatterns {
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			fmt.Fprintf(stderr, "--redact-pattern: %v\n", err)
+			return exitUsage
+		}
+		global.redactPatterns = append(global.redactPatterns, pattern)
+	}
+	global.tlsPins = splitList(*tlsPins)
+	for _, pin := range global.tlsPins {
+		if _, err := vaultsync.ParseTLSPin(pin); err != nil {
+			fmt.Fprintf(stderr, "--tls-pin: %v\n", err)
+			return exitUsage
+		}
+	}
+
+	if global.reauth != "" && global.reauth != "approle" && global.reauth != "kubernetes" {
+		fmt.Fprintln(stderr, "--reauth must be approle or kubernetes")
+		return exitUsage
+	}
+
+	if global.listConcurrency < 1 {
+		fmt.Fprintln(stderr, "--list-concurrency must be at least 1")
+		return exitUsage
+	}
+	if global.diffConcurrency < 1 {
+		fmt.Fprintln(stderr, "--diff-concurrency must be at least 1")
+		return exitUsage
+	}
+
+	if global.batchSize < 0 || global.batchPause < 0 {
+		fmt.Fprintln(stderr, "--batch-size and --batch-pause must not be negative")
+		return exitUsage
+	}
+
+	if global.maskValues && global.showValues {
+		fmt.Fprintln(stderr, "--mask-values and --show-values are mutually exclusive")
+		return exitUsage
 	}
 
 	if *showVersion {
 		printVersion(stdout)
-		return 0
+		return exitOK
 	}
 
 	rest := fs.Args()
 	if len(rest) == 0 {
 		printUsage(stdout)
-		return 1
+		return exitUsage
+	}
+
+	if *warningsFile != "" {
+		// Appended to, so the file collects the warnings of several runs.
+		f, err := os.OpenFile(*warningsFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			fmt.Fprintf(stderr, "--warnings-file: %v\n", err)
+			return exitError
+		}
+		defer f.Close()
+		global.warningLog = f
 	}
 
-	command := rest[0]
-	cmdArgs := rest[1:]
+	if global.autoNamespace && namespaceCommands[rest[0]] {
+		namespace, err := detectNamespace(global, stderr)
+		if err != nil {
+			fmt.Fprintf(stderr, "--auto-namespace: %v\n", err)
+			return exitCodeFor(err)
+		}
+		rest = append([]string{rest[0], namespace}, rest[1:]...)
+	}
+
+	if global.revokeOnExit {
+		global.clients = new([]*vaultsync.VaultClient)
+		code := runCommand(global, rest[0], rest[1:], stdout, stderr)
+		if !revokeLeases(*global.clients, stderr) && code == exitOK {
+			code = exitError
+		}
+		return code
+	}
+	return runCommand(global, rest[0], rest[1:], stdout, stderr)
+}
 
+// runCommand dispatches to the command named by command.
+func runCommand(global globalOptions, command string, cmdArgs []string, stdout, stderr io.Writer) int {
 	switch command {
 	case "version":
 		printVersion(stdout)
-		return 0
+		return exitOK
 	case "list":
-		return cmdList(*kvEngine, cmdArgs, stdout, stderr)
+		return cmdList(global, cmdArgs, stdout, stderr)
 	case "pull":
-		return cmdPull(*kvEngine, cmdArgs, stdout, stderr)
+		return cmdPull(global, cmdArgs, stdout, stderr)
 	case "push":
-		return cmdPush(*kvEngine, cmdArgs, stdout, stderr)
+		return cmdPush(global, cmdArgs, stdout, stderr)
+	case "compare":
+		return cmdCompare(global, cmdArgs, stdout, stderr)
+	case "redact":
+		return cmdRedact(cmdArgs, stdout, stderr)
+	case "lint":
+		return cmdLint(cmdArgs, stdout, stderr)
+	case "verify":
+		return cmdVerify(cmdArgs, stdout, stderr)
+	case "engines":
+		return cmdEngines(global, cmdArgs, stdout, stderr)
+	case "audit":
+		return cmdAudit(global, cmdArgs, stdout, stderr)
+	case "tree":
+		return cmdTree(global, cmdArgs, stdout, stderr)
+	case "move":
+		return cmdMove(global, cmdArgs, stdout, stderr)
+	case "patch":
+		return cmdPatch(global, cmdArgs, stdout, stderr)
+	case "browse":
+		return cmdBrowse(global, cmdArgs, stdout, stderr)
+	case "read":
+		return cmdRead(global, cmdArgs, stdout, stderr)
+	case "write":
+		return cmdWrite(global, cmdArgs, os.Stdin, stdout, stderr)
+	case "context":
+		return cmdContext(cmdArgs, stdout, stderr)
+	case "sync":
+		return cmdSync(global, cmdArgs, stdout, stderr)
 	default:
 		fmt.Fprintf(stderr, "Unknown command: %s\n", command)
 		printUsage(stderr)
-		return 1
+		return exitUsage
+	}
+}
+
+// globalOptions holds the flags accepted before the command name, which apply
+// to every command.
+type globalOptions struct {
+	kvEngine        string
+	showIdentity    bool
+	autoNamespace   bool
+	dataSegment     string
+	metadataSegment string
+	maskValues      bool
+	showValues      bool
+	opTimeout       time.Duration
+	waitForVault    time.Duration
+	readTimeout     time.Duration
+	kvVersion       int
+	failFast        bool
+	listConcurrency int
+	diffConcurrency int
+	batchSize       int
+	batchPause      time.Duration
+	tlsPins         []string
+	revokeOnExit    bool
+	reauth          string
+	authMount       string
+	compactJSON     bool
+	userAgent       string
+	writeAddr       string
+	folderDetect    string
+	dropKeys        []string
+	redactPatterns  []*regexp.Regexp
+	warningLog      io.Writer
+
+	// context is the context selected with --context or `context use`,
+	// whose address, token, and parent namespace newEnvClient falls back on.
+	context *vaultsync.VaultContext
+	// pinContext makes the context's address and token win over VAULT_ADDR
+	// and VAULT_TOKEN, for commands such as sync that name their contexts.
+	pinContext bool
+	// setFlags holds the global flags given on the command line.
+	setFlags map[string]bool
+
+	// clients collects the clients created by the command when
+	// revokeOnExit is set, so their leases can be revoked at the end.
+	clients *[]*vaultsync.VaultClient
+}
+
+// repeatedFlag collects every value of a flag that may be given more than
+// once.
+type repeatedFlag []string
+
+func (r *repeatedFlag) String() string {
+	return strings.Join(*r, ",")
+}
+
+func (r *repeatedFlag) Set(value string) error {
+	*r = append(*r, value)
+	return nil
+}
+
+// masksValues reports whether diffs written to stdout should hide secret
+// values. Masking is the default whenever stdout is not a terminal, so values
+// stay out of CI logs unless --show-values is given. --redact-pattern only
+// applies where values are shown.
+func (g globalOptions) masksValues(stdout io.Writer) bool {
+	if g.maskValues || g.showValues {
+		return g.maskValues
+	}
+	return !isTerminal(stdout)
+}
+
+// isTerminal reports whether w is a character device such as a TTY.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
 	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
 }
 
 func printUsage(w io.Writer) {
 	fmt.Fprintln(w, "Usage: vaultsync [--kv-engine=name] <command> [args...]")
 	fmt.Fprintln(w, "Commands:")
-	fmt.Fprintln(w, "  list <namespace> [path]                          List secret names")
+	fmt.Fprintln(w, "  list <namespace> [path] [--keys] [--format=f]    List secret names (or one secret's keys)")
 	fmt.Fprintln(w, "  pull <namespace> [path] [output-dir]             Pull secrets recursively to files")
 	fmt.Fprintln(w, "  push <namespace> [path] [input-dir] [--dry-run]  Push secrets from YAML files to Vault")
+	fmt.Fprintln(w, "  compare <namespace> <path> <file>                Diff one secret against a local YAML file")
+	fmt.Fprintln(w, "  engines <namespace> [--all]                      List KV engines to use with --kv-engine")
+	fmt.Fprintln(w, "  audit <namespace> [path]                         Report secrets with identical data")
+	fmt.Fprintln(w, "  tree <namespace> [path] [--format=f]             Show the hierarchy and key names, never values")
+	fmt.Fprintln(w, "  move <namespace> <src> <dst> [--delete-source]   Copy secrets under src to dst")
+	fmt.Fprintln(w, "  patch <namespace> <path> key=value...            Update single keys of one secret (--dry-run)")
+	fmt.Fprintln(w, "  browse <namespace> [path]                        Explore the secret tree interactively")
+	fmt.Fprintln(w, "  read <namespace> <api-path> [--format=json]      GET any API path (no KV rewriting)")
+	fmt.Fprintln(w, "  write <namespace> <api-path> key=value... | -    POST raw data to any API path")
+	fmt.Fprintln(w, "  redact <dir>                                     Replace values in pulled files with *** in place")
+	fmt.Fprintln(w, "  lint <dir> [--ext=e] [--max-value-size=s]        Check secret files for problems before a push")
+	fmt.Fprintln(w, "  verify <dir>                                     Check pulled files against the pull --manifest")
+	fmt.Fprintln(w, "  sync <src-context> <dst-context> <path>          Copy secrets between two clusters (--dry-run)")
+	fmt.Fprintln(w, "  context list | use <name>                        List contexts or switch the current one")
 	fmt.Fprintln(w, "  version                                          Print version information")
 	fmt.Fprintln(w, "")
 	fmt.Fprintln(w, "Flags:")
+	fmt.Fprintln(w, "  --context name       Use this context from ~/.vaultsync/contexts.yaml")
 	fmt.Fprintln(w, "  --kv-engine string   Name of the KVv2 secret engine (default \"kv\")")
+	fmt.Fprintln(w, "  --kv-version n       KV engine version, 1 or 2 (default 2)")
+	fmt.Fprintln(w, "  --auto-namespace     Take the namespace from the token and leave out the <namespace> argument")
+	fmt.Fprintln(w, "  --show-identity      Print the token's display name and entity ID first")
+	fmt.Fprintln(w, "  --data-segment s     Use s instead of \"data\" in KV API URLs (for rewriting gateways)")
+	fmt.Fprintln(w, "  --metadata-segment s Use s instead of \"metadata\" in KV API URLs")
+	fmt.Fprintln(w, "  --mask-values        Hide secret values in diffs (default when not a terminal)")
+	fmt.Fprintln(w, "  --show-values        Show secret values in diffs even when not a terminal")
+	fmt.Fprintln(w, "  --redact-pattern re  Show only the ends of values matching re in diffs (repeatable)")
+	fmt.Fprintln(w, "  --op-timeout d       Fail once the command has spent d talking to Vault (e.g. 5m)")
+	fmt.Fprintln(w, "  --wait-for-vault d   Wait up to d for Vault to be unsealed and active before running")
+	fmt.Fprintln(w, "  --timeout-per-secret d  Give up on a single secret read after d and move on")
+	fmt.Fprintln(w, "  --fail-fast          Stop at the first secret that fails instead of continuing")
+	fmt.Fprintln(w, "  --list-concurrency n List up to n folders of a tree at once (default 1)")
+	fmt.Fprintln(w, "  --diff-concurrency n Read and diff up to n secrets of a push --dry-run at once (default 1)")
+	fmt.Fprintln(w, "  --batch-size n       Pull or push n secrets at a time, printing progress after each batch")
+	fmt.Fprintln(w, "  --batch-pause d      Pause between batches, except on dry runs (default 1s)")
+	fmt.Fprintln(w, "  --revoke-on-exit     Revoke the leases of dynamic secrets read by the command at the end")
+	fmt.Fprintln(w, "  --reauth method      Log in again via approle or kubernetes when the token expires mid-run")
+	fmt.Fprintln(w, "  --auth-mount path    Mount path of the --reauth method (default: the method name)")
+	fmt.Fprintln(w, "  --compact-json       Print JSON output and vault-kv files on one line instead of indented")
+	fmt.Fprintln(w, "  --user-agent s       User-Agent sent with every request (default vaultsync/<version>)")
+	fmt.Fprintln(w, "  --write-addr url     Send writes to this address (e.g. the replication primary), reads to VAULT_ADDR")
+	fmt.Fprintln(w, "  --drop-keys keys     Remove these keys (exact names) from every secret a pull writes")
+	fmt.Fprintln(w, "  --warnings-file f    Also append warnings and unreadable secrets to f, one per line")
+	fmt.Fprintln(w, "  --folder-detect m    Tell folders from secrets by trailing slash (default) or probe (a LIST per key)")
 	fmt.Fprintln(w, "  --version            Print version information and exit")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "List flags:")
+	fmt.Fprintln(w, "  --sort name|none     Sort by name ignoring case (default), or keep Vault's order")
+	fmt.Fprintln(w, "  --folders-first      With --sort=name, list folders before secrets")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Pull/push flags:")
+	fmt.Fprintln(w, "  --explode            One file per secret key, under <secret>.yaml.d/")
+	fmt.Fprintln(w, "  --no-recurse         Only sync secrets directly at the path, not its subtree")
+	fmt.Fprintln(w, "  --strip-prefix p     Drop leading Vault path p from local paths (push re-adds it)")
+	fmt.Fprintln(w, "  --value-filter cmd   Pipe each value through shell command cmd (stdin to stdout)")
+	fmt.Fprintln(w, "  --preserve-empty     Keep secrets without keys: pull marks their files, push writes {}")
+	fmt.Fprintln(w, "  (output-dir and input-dir may be s3://bucket/prefix; credentials from AWS_* variables)")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Pull flags:")
+	fmt.Fprintln(w, "  --yaml-indent n      Spaces per YAML indentation level (2-9, default 4)")
+	fmt.Fprintln(w, "  --only-changed       Leave files whose content is unchanged untouched")
+	fmt.Fprintln(w, "  --gitignore          Write a .gitignore into the output directory ignoring the secrets")
+	fmt.Fprintln(w, "  --manifest           Write a SHA-256 manifest of the output directory, checked by verify")
+	fmt.Fprintln(w, "  --format f           yaml (default), vault-kv for `vault kv put -` JSON, toml, k8s-secret, or env-combined")
+	fmt.Fprintln(w, "  --env-separator s    Separator of env-combined variable names (default _)")
+	fmt.Fprintln(w, "  --env-keep-case      Do not uppercase env-combined variable names")
+	fmt.Fprintln(w, "  --k8s-namespace ns   metadata.namespace for k8s-secret manifests")
+	fmt.Fprintln(w, "  --k8s-name-template  Secret name template over {{.Path}} and {{.Name}}")
+	fmt.Fprintln(w, "  --template file      Render each secret through a Go text/template instead of YAML")
+	fmt.Fprintln(w, "  --paths-from file    Pull exactly the secret paths listed in file, without recursing")
+	fmt.Fprintln(w, "  --name-field f       Name each file after the secret's field f instead of its key")
+	fmt.Fprintln(w, "  --key-include globs  Only write the keys of each secret matching these globs")
+	fmt.Fprintln(w, "  --key-exclude globs  Leave out the keys of each secret matching these globs")
+	fmt.Fprintln(w, "  --join-chunks        Join values pushed with --chunk-field back into one key")
+	fmt.Fprintln(w, "  --checkpoint file    Record progress in file; rerun with --resume after an interruption")
+	fmt.Fprintln(w, "  --mirror             Delete local secret files that no longer exist in Vault (--dry-run to preview)")
+	fmt.Fprintln(w, "  --require-capabilities list  Refuse to pull unless the token has exactly these capabilities")
+	fmt.Fprintln(w, "  --summary table      Summarize the pulled secrets as a table (terminals only)")
+	fmt.Fprintln(w, "  --summary-only       Count the secrets and folders to pull without writing (--with-sizes reads each)")
+	fmt.Fprintln(w, "  --strict-paths       Reject data/metadata path segments instead of rewriting them (also list)")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Push flags:")
+	fmt.Fprintln(w, "  --expand-env         Substitute ${VAR} references in values from the environment")
+	fmt.Fprintln(w, "  --strict-env         Like --expand-env, but fail if a variable is unset")
+	fmt.Fprintln(w, "  --patch              Update only the keys present locally (KV PATCH)")
+	fmt.Fprintln(w, "  --merge-strategy s   With --patch, merge nested values: deep (default), replace, or append")
+	fmt.Fprintln(w, "  --only-new           Only create secrets that do not exist yet; never overwrite")
+	fmt.Fprintln(w, "  --changed-since d    Only push files modified within duration d (by mtime)")
+	fmt.Fprintln(w, "  --ext list           Push files with these extensions, e.g. yaml,json,none")
+	fmt.Fprintln(w, "  --follow-symlinks    Follow symlinked directories and symlinks leaving the input dir")
+	fmt.Fprintln(w, "  --max-secret-size n  Refuse secrets larger than n, e.g. 2MiB (default 1MiB, 0 = no limit)")
+	fmt.Fprintln(w, "  --format table       Summarize the pushed secrets as a table (terminals only)")
+	fmt.Fprintln(w, "  --preflight          Check write capability on every target path before pushing")
+	fmt.Fprintln(w, "  --branch-map file    Take the target from, or check it against, the current git branch's rule")
+	fmt.Fprintln(w, "  --plan-out file      With --dry-run, also save the diff to file (values masked unless --show-values)")
+	fmt.Fprintln(w, "  --ignore-fields keys Leave these keys or globs out of --dry-run diffs (they are still pushed)")
+	fmt.Fprintln(w, "  --data-only          Write only secret data; leave metadata alone (ignore _options)")
+	fmt.Fprintln(w, "  --metadata-only      Apply only _options metadata; write no new data version")
+	fmt.Fprintln(w, "  --stamp-metadata     Record who pushed each secret, when, and from which host in custom_metadata")
+	fmt.Fprintln(w, "  --namespace-from-path  Push each top-level dir of input-dir to the namespace it names")
+	fmt.Fprintln(w, "  --overlay env        Merge <name>.<env>.yaml onto <name>.yaml before pushing")
+	fmt.Fprintln(w, "  --interactive        Apply, skip, view, or edit each changed secret before it is written")
+	fmt.Fprintln(w, "  --chunk-field k:n    Push values of key k longer than n bytes as chunks (see pull --join-chunks)")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Exit codes:")
+	fmt.Fprintln(w, "  0 success, 1 error, 2 partial failure, 3 auth failure, 4 drift (compare, verify), 5 usage error")
 }
 
 func printVersion(w io.Writer) {
@@ -88,12 +424,14 @@ func printVersion(w io.Writer) {
 const defaultSecretsDir = "./secrets"
 
 // looksLikePath reports whether an argument should be treated as a filesystem
-// path (output/input directory) rather than a Vault sub-path.
+// path or object storage URI (output/input directory) rather than a Vault
+// sub-path.
 func looksLikePath(arg string) bool {
 	return strings.HasPrefix(arg, "./") ||
 		strings.HasPrefix(arg, "../") ||
 		strings.HasPrefix(arg, "/") ||
-		strings.HasPrefix(arg, "~")
+		strings.HasPrefix(arg, "~") ||
+		vaultsync.IsObjectStoreURI(arg)
 }
 
 // splitSubPathAndDir interprets the arguments following the namespace: a
@@ -113,6 +451,38 @@ func splitSubPathAndDir(rest []string) (subPath, dir string) {
 	return subPath, dir
 }
 
+// parseQualifiedTarget recognizes a leading "namespace:engine/path" argument
+// and splits it into its parts. ok is false when arg is a plain namespace.
+func parseQualifiedTarget(arg string) (namespace, kvEngine, subPath string, ok bool, err error) {
+	if !vaultsync.IsQualifiedPath(arg) {
+		return "", "", "", false, nil
+	}
+
+	namespace, ref, err := vaultsync.ParseQualifiedPath(arg)
+	if err != nil {
+		return "", "", "", false, err
+	}
+	return namespace, ref.Engine, ref.Path, true, nil
+}
+
+// checkStrictTarget applies --strict-paths to the target of a list or pull:
+// the first argument when it is a qualified target, or else subPath.
+func checkStrictTarget(first, subPath string) error {
+	if vaultsync.IsQualifiedPath(first) {
+		return vaultsync.CheckStrictPath(first)
+	}
+	return vaultsync.CheckStrictPath(subPath)
+}
+
+// engineOr returns the engine parsed from a qualified target, falling back to
+// the global --kv-engine value.
+func engineOr(parsed, fallback string) string {
+	if parsed != "" {
+		return parsed
+	}
+	return fallback
+}
+
 // pathDesc renders a human-readable description of the targeted KV path.
 func pathDesc(kvEngine, subPath string) string {
 	if subPath == "" {
@@ -121,162 +491,1878 @@ func pathDesc(kvEngine, subPath string) string {
 	return kvEngine + "/" + subPath
 }
 
-func newClient(namespace string, stdout, stderr io.Writer) (*vaultsync.VaultClient, error) {
-	client, err := vaultsync.NewVaultClientFromEnv(namespace)
+// parseInterspersed parses fs from args, allowing flags to appear before,
+// between, or after positional arguments, and returns the positionals in order.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func newClient(global globalOptions, namespace string, stdout, stderr io.Writer) (*vaultsync.VaultClient, error) {
+	client, err := newEnvClient(global, namespace)
 	if err != nil {
 		return nil, err
 	}
 	client.Output = stdout
 	client.ErrOutput = stderr
+	client.DataSegment = global.dataSegment
+	client.MetadataSegment = global.metadataSegment
+	client.MaskValues = global.masksValues(stdout)
+	client.RedactPatterns = global.redactPatterns
+	client.KVVersion = global.kvVersion
+	client.FailFast = global.failFast
+	client.ReadTimeout = global.readTimeout
+	client.ListConcurrency = global.listConcurrency
+	client.DiffConcurrency = global.diffConcurrency
+	client.BatchSize = global.batchSize
+	client.BatchPause = global.batchPause
+	client.UserAgent = global.userAgent
+	client.WriteAddress = global.writeAddr
+	client.FolderDetect = global.folderDetect
+	client.PullOptions.CompactJSON = global.compactJSON
+	client.PullOptions.DropKeys = global.dropKeys
+	client.WarningLog = global.warningLog
+	if len(global.tlsPins) > 0 {
+		if err := client.PinCertificates(global.tlsPins); err != nil {
+			return nil, err
+		}
+	}
+	if global.waitForVault > 0 {
+		if err := client.WaitForVault(global.waitForVault); err != nil {
+			return nil, err
+		}
+	}
+	if global.opTimeout > 0 {
+		client.Deadline = time.Now().Add(global.opTimeout)
+	}
+	if client.Auth != nil && client.Token == "" {
+		if err := client.Login(); err != nil {
+			return nil, fmt.Errorf("%w: %w", errLoginFailed, err)
+		}
+	}
+
+	if global.clients != nil {
+		*global.clients = append(*global.clients, client)
+	}
+
+	if global.showIdentity {
+		if err := printIdentity(client, stderr); err != nil {
+			return nil, err
+		}
+	}
 	return client, nil
 }
 
-func cmdList(kvEngine string, args []string, stdout, stderr io.Writer) int {
-	if len(args) < 1 {
-		fmt.Fprintln(stderr, "Usage: vaultsync [--kv-engine=name] list <namespace> [path]")
-		return 1
+// newEnvClient creates a client from the environment. With --reauth the
+// client keeps the auth method's parameters to log in again when its token
+// expires, and VAULT_TOKEN may be left unset to log in up front.
+func newEnvClient(global globalOptions, namespace string) (*vaultsync.VaultClient, error) {
+	if global.context == nil && global.reauth == "" {
+		return vaultsync.NewVaultClientFromEnv(namespace)
 	}
 
-	namespace := args[0]
-	subPath := ""
-	if len(args) > 1 {
-		subPath = args[1]
+	var auth vaultsync.AuthMethod
+	if global.reauth != "" {
+		var err error
+		if auth, err = vaultsync.AuthFromEnv(global.reauth, global.authMount); err != nil {
+			return nil, err
+		}
 	}
 
-	client, err := newClient(namespace, stdout, stderr)
-	if err != nil {
-		fmt.Fprintf(stderr, "%v\n", err)
-		return 1
+	// VAULT_ADDR and VAULT_TOKEN win over the selected context, unless it
+	// is pinned.
+	vaultAddr, vaultToken := os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN")
+	if ctx := global.context; ctx != nil {
+		namespace = vaultsync.JoinNamespace(ctx.Namespace, namespace)
+		if vaultAddr == "" || global.pinContext && ctx.Address != "" {
+			vaultAddr = ctx.Address
+		}
+		if vaultToken == "" || global.pinContext && ctx.Token != "" {
+			vaultToken = ctx.Token
+		}
+	}
+	if vaultAddr == "" {
+		return nil, fmt.Errorf("VAULT_ADDR environment variable is required")
+	}
+	if vaultToken == "" && auth == nil {
+		return nil, fmt.Errorf("VAULT_TOKEN environment variable is required")
 	}
 
-	ref := vaultsync.NewSecretRef(kvEngine, subPath)
-	secrets, err := client.ListSecretsAt(ref)
+	client := vaultsync.NewVaultClient(vaultAddr, vaultToken, namespace)
+	client.Auth = auth
+	return client, nil
+}
+
+// namespaceCommands are the commands whose first argument is the namespace,
+// which --auto-namespace fills in.
+var namespaceCommands = map[string]bool{
+	"list": true, "pull": true, "push": true, "compare": true, "engines": true, "audit": true, "tree": true,
+	"move": true, "patch": true, "browse": true, "read": true, "write": true,
+}
+
+// detectNamespace looks up the namespace of the token for --auto-namespace.
+// The lookup is made in the namespace of the selected context, if any, and
+// the result is returned relative to it, as the namespace argument is.
+func detectNamespace(global globalOptions, stderr io.Writer) (string, error) {
+	lookup := global
+	lookup.showIdentity = false
+	lookup.clients = nil
+	client, err := newClient(lookup, "", io.Discard, stderr)
 	if err != nil {
-		fmt.Fprintf(stderr, "Failed to list secrets: %v\n", err)
-		return 1
+		return "", err
+	}
+	namespace, err := client.TokenNamespace()
+	if err != nil {
+		return "", err
 	}
 
-	if len(secrets) == 0 {
-		fmt.Fprintln(stdout, "No secrets found at the specified path")
-		return 0
+	if global.context == nil || global.context.Namespace == "" {
+		return namespace, nil
 	}
+	parent := vaultsync.NormalizeNamespace(global.context.Namespace)
+	if namespace == parent {
+		return "", nil
+	}
+	if !strings.HasPrefix(namespace, parent+"/") {
+		return "", fmt.Errorf("%w: the token belongs to namespace %q, outside the context's namespace %q",
+			vaultsync.ErrNamespaceUnknown, namespace, parent)
+	}
+	return strings.TrimPrefix(namespace, parent+"/"), nil
+}
 
-	fmt.Fprintf(stdout, "Secrets at %s in namespace %s:\n", pathDesc(kvEngine, subPath), namespace)
-	for _, secret := range secrets {
-		fmt.Fprintf(stdout, "  - %s\n", secret)
+// revokeLeases revokes the leases taken by clients for --revoke-on-exit and
+// reports whether all of them were revoked.
+func revokeLeases(clients []*vaultsync.VaultClient, stderr io.Writer) bool {
+	ok := true
+	for _, client := range clients {
+		if err := client.RevokeLeases(); err != nil {
+			fmt.Fprintf(stderr, "Warning: %v\n", err)
+			ok = false
+		}
 	}
-	return 0
+	return ok
 }
 
-// pullArgs holds the parsed positional arguments for the pull command.
-type pullArgs struct {
+// printIdentity labels the run with the principal behind the token, so policy
+// tests can confirm they are exercising the intended entity.
+func printIdentity(client *vaultsync.VaultClient, w io.Writer) error {
+	info, err := client.LookupSelf()
+	if err != nil {
+		return fmt.Errorf("failed to look up token identity: %w", err)
+	}
+
+	entityID := info.EntityID
+	if entityID == "" {
+		entityID = "(none)"
+	}
+	fmt.Fprintf(w, "Identity: %s (entity %s, policies: %s)\n",
+		info.DisplayName, entityID, strings.Join(info.Policies, ", "))
+	return nil
+}
+
+// listArgs holds the parsed positional arguments and flags for the list command.
+type listArgs struct {
 	namespace string
+	kvEngine  string
 	subPath   string
-	outputDir string
+	keys      bool
+	format    string
+
+	// sort is "name" to print the listing sorted (see
+	// vaultsync.SortListing) or "none" for Vault's order; foldersFirst lists
+	// folders before secrets when sorting.
+	sort         string
+	foldersFirst bool
+
+	// strictPaths rejects paths with a "data" or "metadata" segment that
+	// lenient parsing would rewrite (see vaultsync.CheckStrictPath).
+	strictPaths bool
 }
 
-func parsePullArgs(args []string) (pullArgs, error) {
-	if len(args) < 1 {
-		return pullArgs{}, fmt.Errorf("namespace is required")
+func parseListArgs(args []string) (listArgs, error) {
+	parsed := listArgs{format: "human", sort: "name"}
+
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.BoolVar(&parsed.keys, "keys", false, "List the fields of a single secret without reading values")
+	fs.StringVar(&parsed.format, "format", parsed.format, "Output format: human, plain, json, or table")
+	fs.BoolVar(&parsed.strictPaths, "strict-paths", false, "Reject paths with a data or metadata segment instead of rewriting them")
+	fs.StringVar(&parsed.sort, "sort", parsed.sort, "Order of the listing: name or none")
+	fs.BoolVar(&parsed.foldersFirst, "folders-first", false, "With --sort=name, list folders before secrets")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return listArgs{}, err
 	}
 
-	parsed := pullArgs{namespace: args[0]}
-	parsed.subPath, parsed.outputDir = splitSubPathAndDir(args[1:])
-	if parsed.outputDir == "" {
-		parsed.outputDir = defaultSecretsDir
+	if len(positional) < 1 {
+		return listArgs{}, fmt.Errorf("namespace is required")
+	}
+
+	namespace, kvEngine, subPath, qualified, err := parseQualifiedTarget(positional[0])
+	switch {
+	case err != nil:
+		return listArgs{}, err
+	case qualified:
+		parsed.namespace, parsed.kvEngine, parsed.subPath = namespace, kvEngine, subPath
+	default:
+		parsed.namespace = positional[0]
+		if len(positional) > 1 {
+			parsed.subPath = positional[1]
+		}
+	}
+
+	if parsed.strictPaths {
+		if err := checkStrictTarget(positional[0], parsed.subPath); err != nil {
+			return listArgs{}, err
+		}
+	}
+
+	if parsed.keys && parsed.subPath == "" {
+		return listArgs{}, fmt.Errorf("--keys requires a secret path")
+	}
+
+	switch parsed.format {
+	case "human", "plain", "json", "table":
+	default:
+		return listArgs{}, fmt.Errorf("--format must be human, plain, json, or table")
+	}
+	switch {
+	case parsed.sort != "name" && parsed.sort != "none":
+		return listArgs{}, fmt.Errorf("--sort must be name or none")
+	case parsed.foldersFirst && parsed.sort != "name":
+		return listArgs{}, fmt.Errorf("--folders-first requires --sort=name")
 	}
 	return parsed, nil
 }
 
-func cmdPull(kvEngine string, args []string, stdout, stderr io.Writer) int {
-	parsed, err := parsePullArgs(args)
+func cmdList(global globalOptions, args []string, stdout, stderr io.Writer) int {
+	parsed, err := parseListArgs(args)
+	if errors.Is(err, vaultsync.ErrAmbiguousPath) {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitUsage
+	}
 	if err != nil {
-		fmt.Fprintln(stderr, "Usage: vaultsync [--kv-engine=name] pull <namespace> [path] [output-dir]")
-		return 1
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		fmt.Fprintln(stderr, "Usage: vaultsync [--kv-engine=name] list <namespace> [path] [--keys] [--format=human|plain|json|table]")
+		return exitUsage
 	}
 
-	client, err := newClient(parsed.namespace, stdout, stderr)
+	client, err := newClient(global, parsed.namespace, stdout, stderr)
 	if err != nil {
 		fmt.Fprintf(stderr, "%v\n", err)
-		return 1
+		return exitCodeFor(err)
 	}
 
+	kvEngine := engineOr(parsed.kvEngine, global.kvEngine)
 	ref := vaultsync.NewSecretRef(kvEngine, parsed.subPath)
-	fmt.Fprintf(stdout, "Pulling secrets from %s in namespace %s to %s...\n",
-		pathDesc(kvEngine, parsed.subPath), parsed.namespace, parsed.outputDir)
+	if parsed.keys {
+		return listSecretKeys(client, ref, kvEngine, global.compactJSON, parsed, stdout, stderr)
+	}
 
-	if err := client.PullSecretsToFilesAt(ref, parsed.outputDir); err != nil {
-		fmt.Fprintf(stderr, "Failed to pull secrets: %v\n", err)
-		return 1
+	secrets, err := client.ListSecretsAt(ref)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to list secrets: %v\n", err)
+		return exitCodeFor(err)
+	}
+	if parsed.sort == "name" {
+		vaultsync.SortListing(secrets, parsed.foldersFirst)
 	}
 
-	fmt.Fprintf(stdout, "Completed! Secrets saved to %s as YAML files\n", parsed.outputDir)
-	return 0
-}
+	if wantsTable(parsed.format, stdout) {
+		printListTable(stdout, "PATH\tTYPE", secrets, func(item string) string {
+			itemType := "secret"
+			if vaultsync.ParseListKey(item).IsFolder {
+				itemType = "folder"
+			}
+			return pathDesc(kvEngine, path.Join(parsed.subPath, item)) + "\t" + itemType
+		})
+		return exitOK
+	}
 
-// pushArgs holds the parsed positional arguments and flags for the push command.
-type pushArgs struct {
-	namespace string
-	subPath   string
-	inputDir  string
-	dryRun    bool
+	banner := fmt.Sprintf("Secrets at %s in namespace %s:", pathDesc(kvEngine, parsed.subPath), parsed.namespace)
+	return printList(stdout, stderr, parsed.format, global.compactJSON, banner, "No secrets found at the specified path", secrets)
 }
 
-func parsePushArgs(args []string) (pushArgs, error) {
-	var parsed pushArgs
-	var positional []string
+// printListTable prints items as the rows row renders under header, or the
+// usual empty-list message.
+func printListTable(stdout io.Writer, header string, items []string, row func(item string) string) {
+	if len(items) == 0 {
+		fmt.Fprintln(stdout, "Nothing found at the specified path")
+		return
+	}
+	tw := newTable(stdout)
+	fmt.Fprintln(tw, header)
+	for _, item := range items {
+		fmt.Fprintln(tw, row(item))
+	}
+	tw.Flush()
+}
 
-	for _, arg := range args {
-		if arg == "--dry-run" {
-			parsed.dryRun = true
-			continue
+// printList prints the result of a list command. The human format shows
+// banner and a bulleted list, or empty when there is nothing to show; plain
+// prints one item per line and json a JSON array, with nothing else, for
+// scripts, indented unless compact. table, when stdout is not a terminal, is
+// printed as plain.
+func printList(stdout, stderr io.Writer, format string, compact bool, banner, empty string, items []string) int {
+	switch format {
+	case "plain", "table":
+		for _, item := range items {
+			fmt.Fprintln(stdout, item)
+		}
+	case "json":
+		if items == nil {
+			items = []string{}
+		}
+		out, err := marshalJSON(items, compact)
+		if err != nil {
+			fmt.Fprintf(stderr, "Failed to format list: %v\n", err)
+			return exitCodeFor(err)
 		}
-		positional = append(positional, arg)
+		fmt.Fprintln(stdout, string(out))
+	default:
+		if len(items) == 0 {
+			fmt.Fprintln(stdout, empty)
+			return exitOK
+		}
+		fmt.Fprintln(stdout, banner)
+		for _, item := range items {
+			fmt.Fprintf(stdout, "  - %s\n", item)
+		}
+	}
+	return exitOK
+}
+
+// marshalJSON renders v for JSON output: indented for people by default, or
+// on a single line with --compact-json for line-oriented tools.
+func marshalJSON(v interface{}, compact bool) ([]byte, error) {
+	if compact {
+		return json.Marshal(v)
+	}
+	return json.MarshalIndent(v, "", "  ")
+}
+
+// listSecretKeys prints the field names of a single secret using the subkeys
+// endpoint, so no secret values are ever read.
+func listSecretKeys(client *vaultsync.VaultClient, ref vaultsync.SecretRef, kvEngine string, compact bool, parsed listArgs, stdout, stderr io.Writer) int {
+	subkeys, err := client.GetSubkeysAt(ref)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to list keys: %v\n", err)
+		return exitCodeFor(err)
+	}
+
+	keys := vaultsync.FlattenSubkeys(subkeys)
+	if wantsTable(parsed.format, stdout) {
+		secretPath := pathDesc(kvEngine, parsed.subPath)
+		printListTable(stdout, "PATH\tKEY", keys, func(key string) string {
+			return secretPath + "\t" + key
+		})
+		return exitOK
+	}
+	banner := fmt.Sprintf("Keys of %s in namespace %s:", pathDesc(kvEngine, parsed.subPath), parsed.namespace)
+	return printList(stdout, stderr, parsed.format, compact, banner, "No keys found in the specified secret", keys)
+}
+
+// envSeparatorPattern matches the --env-separator values that keep variable
+// names valid.
+var envSeparatorPattern = regexp.MustCompile(`^[A-Za-z0-9_]*$`)
+
+// pullArgs holds the parsed positional arguments and flags for the pull command.
+type pullArgs struct {
+	namespace   string
+	kvEngine    string
+	subPath     string
+	outputDir   string
+	explode     bool
+	noRecurse   bool
+	yamlIndent  int
+	onlyChanged bool
+	stripPrefix string
+	pathsFrom   string
+	template    string
+	gitignore   bool
+	manifest    bool
+	mirror      bool
+	dryRun      bool
+	checkpoint  string
+	resume      bool
+	valueFilter string
+	keyInclude  []string
+	keyExclude  []string
+	nameField   string
+
+	preserveEmpty bool
+	joinChunks    bool
+
+	// requireCapabilities, when set, is the exact capability set the token
+	// must have on the pulled paths.
+	requireCapabilities []string
+
+	format          string
+	k8sNamespace    string
+	k8sNameTemplate string
+	envSeparator    string
+	envKeepCase     bool
+
+	// summary is "table" to summarize the pull as a table on a terminal.
+	summary string
+	// summaryOnly counts the secrets and folders to pull instead of pulling
+	// them; withSizes also reads each secret to total their size.
+	summaryOnly bool
+	withSizes   bool
+
+	// strictPaths rejects paths, including those listed in the pathsFrom
+	// file, that lenient parsing would rewrite.
+	strictPaths bool
+}
+
+func parsePullArgs(args []string) (pullArgs, error) {
+	var parsed pullArgs
+
+	fs := flag.NewFlagSet("pull", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.BoolVar(&parsed.explode, "explode", false, "Write each secret key to its own file")
+	fs.BoolVar(&parsed.noRecurse, "no-recurse", false, "Only pull secrets directly at the path")
+	fs.IntVar(&parsed.yamlIndent, "yaml-indent", 0, "Spaces per YAML indentation level (2-9, default 4)")
+	fs.BoolVar(&parsed.onlyChanged, "only-changed", false, "Do not rewrite files whose content is unchanged")
+	fs.StringVar(&parsed.stripPrefix, "strip-prefix", "", "Leading part of the Vault path to drop from local paths")
+	fs.BoolVar(&parsed.gitignore, "gitignore", false, "Write a .gitignore into the output directory so secrets are not committed")
+	fs.BoolVar(&parsed.manifest, "manifest", false, "Write a SHA-256 manifest of the output directory for verify")
+	fs.StringVar(&parsed.template, "template", "", "Render each secret through this Go template file instead of YAML")
+	fs.StringVar(&parsed.pathsFrom, "paths-from", "", "File listing the secret paths to pull, one per line")
+	fs.StringVar(&parsed.valueFilter, "value-filter", "", "Shell command each value is piped through before it is written")
+	fs.BoolVar(&parsed.preserveEmpty, "preserve-empty", false, "Mark the files of secrets without keys as deliberately empty")
+	fs.BoolVar(&parsed.joinChunks, "join-chunks", false, "Join values pushed with --chunk-field back into their original key")
+	fs.StringVar(&parsed.nameField, "name-field", "", "Name each file after this field of the secret instead of its key")
+	keyInclude := fs.String("key-include", "", "Comma-separated globs of the secret keys to write, e.g. *_public")
+	keyExclude := fs.String("key-exclude", "", "Comma-separated globs of the secret keys to leave out")
+	fs.StringVar(&parsed.checkpoint, "checkpoint", "", "Record progress in this file so an interrupted pull can be resumed")
+	fs.BoolVar(&parsed.resume, "resume", false, "Continue the pull recorded in the --checkpoint file")
+	fs.BoolVar(&parsed.mirror, "mirror", false, "Delete local secret files in the pulled subtree that no longer exist in Vault")
+	fs.BoolVar(&parsed.dryRun, "dry-run", false, "With --mirror, list the files that would be deleted instead of deleting them")
+	requireCapabilities := fs.String("require-capabilities", "", "Refuse to pull unless the token has exactly these capabilities, e.g. read,list")
+	fs.StringVar(&parsed.format, "format", "yaml", "Output format: yaml, vault-kv, toml, k8s-secret, or env-combined")
+	fs.StringVar(&parsed.summary, "summary", "", "Summarize the pulled secrets as a table (\"table\")")
+	fs.BoolVar(&parsed.summaryOnly, "summary-only", false, "Count the secrets and folders that would be pulled without writing anything")
+	fs.BoolVar(&parsed.withSizes, "with-sizes", false, "With --summary-only, read every secret to total their size")
+	fs.StringVar(&parsed.k8sNamespace, "k8s-namespace", "", "metadata.namespace for k8s-secret manifests")
+	fs.StringVar(&parsed.k8sNameTemplate, "k8s-name-template", "", "Go template for k8s-secret names over .Path and .Name")
+	fs.BoolVar(&parsed.strictPaths, "strict-paths", false, "Reject paths with a data or metadata segment instead of rewriting them")
+	fs.StringVar(&parsed.envSeparator, "env-separator", "", "Separator joining the path and key in env-combined variable names (default _)")
+	fs.BoolVar(&parsed.envKeepCase, "env-keep-case", false, "Keep the case of env-combined variable names instead of uppercasing them")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return pullArgs{}, err
 	}
 
 	if len(positional) < 1 {
-		return pushArgs{}, fmt.Errorf("namespace is required")
+		return pullArgs{}, fmt.Errorf("namespace is required")
 	}
 
-	parsed.namespace = positional[0]
-	parsed.subPath, parsed.inputDir = splitSubPathAndDir(positional[1:])
-	if parsed.inputDir == "" {
-		parsed.inputDir = defaultSecretsDir
+	namespace, kvEngine, subPath, qualified, err := parseQualifiedTarget(positional[0])
+	switch {
+	case err != nil:
+		return pullArgs{}, err
+	case qualified:
+		parsed.namespace, parsed.kvEngine, parsed.subPath = namespace, kvEngine, subPath
+		if len(positional) > 1 {
+			parsed.outputDir = positional[1]
+		}
+	case parsed.pathsFrom != "":
+		// The listed paths replace the sub-path, so the only other
+		// argument is the output directory.
+		if len(positional) > 2 {
+			return pullArgs{}, fmt.Errorf("--paths-from takes no path argument")
+		}
+		parsed.namespace = positional[0]
+		if len(positional) > 1 {
+			parsed.outputDir = positional[1]
+		}
+	default:
+		parsed.namespace = positional[0]
+		parsed.subPath, parsed.outputDir = splitSubPathAndDir(positional[1:])
+	}
+	if parsed.outputDir == "" {
+		parsed.outputDir = defaultSecretsDir
+	}
+	if parsed.strictPaths {
+		if err := checkStrictTarget(positional[0], parsed.subPath); err != nil {
+			return pullArgs{}, err
+		}
+	}
+
+	if parsed.template != "" && (parsed.format != "yaml" || parsed.explode) {
+		return pullArgs{}, fmt.Errorf("--template cannot be combined with --format or --explode")
+	}
+
+	if parsed.pathsFrom != "" && (parsed.subPath != "" || parsed.stripPrefix != "" || parsed.mirror) {
+		return pullArgs{}, fmt.Errorf("--paths-from cannot be combined with a path, --strip-prefix, or --mirror")
+	}
+
+	if parsed.dryRun && !parsed.mirror {
+		return pullArgs{}, fmt.Errorf("--dry-run requires --mirror")
+	}
+
+	parsed.keyInclude = splitList(*keyInclude)
+	parsed.keyExclude = splitList(*keyExclude)
+
+	switch {
+	case parsed.resume && parsed.checkpoint == "":
+		return pullArgs{}, fmt.Errorf("--resume requires --checkpoint")
+	case parsed.checkpoint != "" && (parsed.mirror || parsed.pathsFrom != ""):
+		return pullArgs{}, fmt.Errorf("--checkpoint cannot be combined with --mirror or --paths-from")
+	case vaultsync.IsObjectStoreURI(parsed.outputDir) && (parsed.mirror || parsed.checkpoint != "" || parsed.explode || parsed.manifest || parsed.gitignore):
+		// These work on a local directory tree, which a bucket does not have.
+		return pullArgs{}, fmt.Errorf("an object storage output cannot be combined with --mirror, --checkpoint, --explode, --manifest, or --gitignore")
+	}
+
+	if parsed.yamlIndent != 0 && (parsed.yamlIndent < 2 || parsed.yamlIndent > 9) {
+		return pullArgs{}, fmt.Errorf("--yaml-indent must be between 2 and 9")
+	}
+
+	if *requireCapabilities != "" {
+		parsed.requireCapabilities, err = vaultsync.ParseCapabilities(*requireCapabilities)
+		if err != nil {
+			return pullArgs{}, fmt.Errorf("--require-capabilities: %w", err)
+		}
+	}
+
+	switch parsed.format {
+	case "yaml":
+		parsed.format = ""
+	case vaultsync.PullFormatK8sSecret, vaultsync.PullFormatVaultKV, vaultsync.PullFormatTOML:
+		if parsed.explode {
+			return pullArgs{}, fmt.Errorf("--format=%s cannot be combined with --explode", parsed.format)
+		}
+	case vaultsync.PullFormatEnvCombined:
+		if parsed.explode || parsed.mirror || parsed.checkpoint != "" || parsed.nameField != "" {
+			return pullArgs{}, fmt.Errorf("--format=%s cannot be combined with --explode, --mirror, --checkpoint, or --name-field", parsed.format)
+		}
+	default:
+		return pullArgs{}, fmt.Errorf("--format must be yaml, %s, %s, %s, or %s", vaultsync.PullFormatVaultKV, vaultsync.PullFormatTOML, vaultsync.PullFormatK8sSecret, vaultsync.PullFormatEnvCombined)
+	}
+	if (parsed.envSeparator != "" || parsed.envKeepCase) && parsed.format != vaultsync.PullFormatEnvCombined {
+		return pullArgs{}, fmt.Errorf("--env-separator and --env-keep-case require --format=%s", vaultsync.PullFormatEnvCombined)
+	}
+	if !envSeparatorPattern.MatchString(parsed.envSeparator) {
+		return pullArgs{}, fmt.Errorf("--env-separator may only contain letters, digits, and _")
+	}
+	if parsed.summary != "" && parsed.summary != "table" {
+		return pullArgs{}, fmt.Errorf("--summary must be table")
+	}
+	if parsed.withSizes && !parsed.summaryOnly {
+		return pullArgs{}, fmt.Errorf("--with-sizes requires --summary-only")
+	}
+	if parsed.summaryOnly && (parsed.pathsFrom != "" || parsed.mirror || parsed.checkpoint != "") {
+		return pullArgs{}, fmt.Errorf("--summary-only cannot be combined with --paths-from, --mirror, or --checkpoint")
 	}
 	return parsed, nil
 }
 
-func cmdPush(kvEngine string, args []string, stdout, stderr io.Writer) int {
-	parsed, err := parsePushArgs(args)
+func cmdPull(global globalOptions, args []string, stdout, stderr io.Writer) int {
+	parsed, err := parsePullArgs(args)
+	if errors.Is(err, vaultsync.ErrAmbiguousPath) {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitUsage
+	}
 	if err != nil {
-		fmt.Fprintln(stderr, "Usage: vaultsync [--kv-engine=name] push <namespace> [path] [input-dir] [--dry-run]")
-		return 1
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		fmt.Fprintln(stderr, "Usage: vaultsync [--kv-engine=name] pull <namespace> [path] [output-dir] [--explode]")
+		return exitUsage
 	}
+	if parsed.summaryOnly {
+		return summarizePull(global, parsed, stdout, stderr)
+	}
+	return runPull(global, parsed, stdout, stderr)
+}
 
-	client, err := newClient(parsed.namespace, stdout, stderr)
+// summarizePull prints how many secrets and folders a pull would fetch, and
+// with --with-sizes their total size, without writing any files.
+func summarizePull(global globalOptions, parsed pullArgs, stdout, stderr io.Writer) int {
+	client, err := newClient(global, parsed.namespace, stdout, stderr)
 	if err != nil {
 		fmt.Fprintf(stderr, "%v\n", err)
-		return 1
+		return exitCodeFor(err)
 	}
+	client.PullOptions.NoRecurse = parsed.noRecurse
 
-	ref := vaultsync.NewSecretRef(kvEngine, parsed.subPath)
-	if parsed.dryRun {
-		fmt.Fprintf(stdout, "DRY RUN: showing changes for push from %s to %s in namespace %s...\n",
-			parsed.inputDir, pathDesc(kvEngine, parsed.subPath), parsed.namespace)
-	} else {
-		fmt.Fprintf(stdout, "Pushing secrets from %s to %s in namespace %s...\n",
-			parsed.inputDir, pathDesc(kvEngine, parsed.subPath), parsed.namespace)
+	kvEngine := engineOr(parsed.kvEngine, global.kvEngine)
+	summary, err := client.SummarizeTreeAt(vaultsync.NewSecretRef(kvEngine, parsed.subPath), parsed.withSizes)
+	var readErrs *vaultsync.MultiError
+	if err != nil && !errors.As(err, &readErrs) {
+		fmt.Fprintf(stderr, "Failed to summarize secrets: %v\n", err)
+		return exitCodeFor(err)
 	}
 
-	if err := client.PushSecretsFromFilesAt(parsed.inputDir, ref, parsed.dryRun); err != nil {
-		fmt.Fprintf(stderr, "Push operation failed: %v\n", err)
-		return 1
+	fmt.Fprintf(stdout, "Summary of %s in namespace %s:\n", pathDesc(kvEngine, parsed.subPath), parsed.namespace)
+	fmt.Fprintf(stdout, "  Secrets: %d\n", summary.Secrets)
+	fmt.Fprintf(stdout, "  Folders: %d\n", summary.Folders)
+	if parsed.withSizes {
+		size := formatByteSize(int(summary.Size))
+		if summary.Unreadable > 0 {
+			size += fmt.Sprintf(" (%d secrets unreadable)", summary.Unreadable)
+		}
+		fmt.Fprintf(stdout, "  Size:    %s\n", size)
 	}
+	if readErrs != nil {
+		fmt.Fprintf(stderr, "Failed to read some secrets: %v\n", readErrs)
+		return exitCodeFor(readErrs)
+	}
+	return exitOK
+}
 
-	if parsed.dryRun {
-		fmt.Fprintln(stdout, "Dry run completed! Use without --dry-run to actually push changes.")
-	} else {
-		fmt.Fprintln(stdout, "Completed! Secrets have been pushed to Vault.")
+// runPull pulls the secrets described by parsed into parsed.outputDir.
+func runPull(global globalOptions, parsed pullArgs, stdout, stderr io.Writer) int {
+	client, err := newClient(global, parsed.namespace, stdout, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitCodeFor(err)
+	}
+	client.PullOptions.Explode = parsed.explode
+	client.PullOptions.NoRecurse = parsed.noRecurse
+	client.PullOptions.YAMLIndent = parsed.yamlIndent
+	client.PullOptions.OnlyChanged = parsed.onlyChanged
+	client.PullOptions.StripPrefix = parsed.stripPrefix
+	client.PullOptions.Format = parsed.format
+	client.PullOptions.K8sNamespace = parsed.k8sNamespace
+	client.PullOptions.K8sNameTemplate = parsed.k8sNameTemplate
+	client.PullOptions.EnvSeparator = parsed.envSeparator
+	client.PullOptions.EnvKeepCase = parsed.envKeepCase
+	client.PullOptions.Gitignore = parsed.gitignore
+	client.PullOptions.Manifest = parsed.manifest
+	client.PullOptions.Mirror = parsed.mirror
+	client.PullOptions.MirrorDryRun = parsed.dryRun
+	client.PullOptions.Checkpoint = parsed.checkpoint
+	client.PullOptions.Resume = parsed.resume
+	client.PullOptions.ValueFilter = parsed.valueFilter
+	client.PullOptions.PreserveEmpty = parsed.preserveEmpty
+	client.PullOptions.JoinChunks = parsed.joinChunks
+	client.PullOptions.NameField = parsed.nameField
+	client.PullOptions.KeyInclude = parsed.keyInclude
+	client.PullOptions.KeyExclude = parsed.keyExclude
+	if parsed.template != "" {
+		content, err := os.ReadFile(parsed.template)
+		if err != nil {
+			fmt.Fprintf(stderr, "Failed to read template: %v\n", err)
+			return exitCodeFor(err)
+		}
+		client.PullOptions.Template = string(content)
+		client.PullOptions.TemplateExtension = templateExtension(parsed.template)
+	}
+	outputDir, err := useObjectStorage(client, parsed.outputDir)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitCodeFor(err)
+	}
+
+	var table *resultTable
+	if wantsTable(parsed.summary, stdout) {
+		table = &resultTable{}
+		table.attach(client, "")
+	}
+
+	kvEngine := engineOr(parsed.kvEngine, global.kvEngine)
+	if parsed.pathsFrom != "" {
+		return pullPathList(client, kvEngine, parsed, outputDir, table, stdout, stderr)
+	}
+
+	ref := vaultsync.NewSecretRef(kvEngine, parsed.subPath)
+	if code := checkRequiredCapabilities(client, parsed.requireCapabilities, []vaultsync.SecretRef{ref}, stderr); code != exitOK {
+		return code
+	}
+	fmt.Fprintf(stdout, "Pulling secrets from %s in namespace %s to %s...\n",
+		pathDesc(kvEngine, parsed.subPath), parsed.namespace, parsed.outputDir)
+
+	err = client.PullSecretsToFilesAt(ref, outputDir)
+	if table != nil {
+		table.print(stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to pull secrets: %v\n", err)
+		return exitCodeFor(err)
+	}
+
+	fmt.Fprintf(stdout, "Completed! Secrets saved to %s\n", parsed.outputDir)
+	return exitOK
+}
+
+// templateExtension derives the extension of rendered files from a template
+// file name: "app.env.tmpl" renders to ".env" files. Without one, rendered
+// files keep the usual extension.
+func templateExtension(name string) string {
+	base := filepath.Base(name)
+	for _, suffix := range []string{".tmpl", ".tpl", ".gotmpl"} {
+		base = strings.TrimSuffix(base, suffix)
+	}
+	return filepath.Ext(base)
+}
+
+// pullPathList pulls exactly the secrets listed in the --paths-from file to
+// outputDir, parsed.outputDir as the client addresses it.
+func pullPathList(client *vaultsync.VaultClient, kvEngine string, parsed pullArgs, outputDir string, table *resultTable, stdout, stderr io.Writer) int {
+	paths, err := readPathList(parsed.pathsFrom)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to read %s: %v\n", parsed.pathsFrom, err)
+		return exitCodeFor(err)
+	}
+
+	refs := make([]vaultsync.SecretRef, len(paths))
+	for i, path := range paths {
+		if parsed.strictPaths {
+			if err := vaultsync.CheckStrictPath(path); err != nil {
+				fmt.Fprintf(stderr, "%s: %v\n", parsed.pathsFrom, err)
+				return exitCodeFor(err)
+			}
+		}
+		refs[i] = vaultsync.NewSecretRef(kvEngine, path)
+	}
+	if code := checkRequiredCapabilities(client, parsed.requireCapabilities, refs, stderr); code != exitOK {
+		return code
+	}
+
+	fmt.Fprintf(stdout, "Pulling %d secrets listed in %s from %s in namespace %s to %s...\n",
+		len(refs), parsed.pathsFrom, kvEngine, parsed.namespace, parsed.outputDir)
+
+	err = client.PullSecretListToFiles(refs, outputDir)
+	if table != nil {
+		table.print(stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to pull secrets: %v\n", err)
+		return exitCodeFor(err)
+	}
+
+	fmt.Fprintf(stdout, "Completed! Secrets saved to %s\n", parsed.outputDir)
+	return exitOK
+}
+
+// checkRequiredCapabilities enforces --require-capabilities on refs before
+// anything is pulled, reporting a mismatch on stderr, and returns the exit
+// code to stop with, or exitOK to go ahead.
+func checkRequiredCapabilities(client *vaultsync.VaultClient, required []string, refs []vaultsync.SecretRef, stderr io.Writer) int {
+	if len(required) == 0 {
+		return exitOK
+	}
+	if err := client.RequireCapabilitiesAt(required, refs...); err != nil {
+		fmt.Fprintf(stderr, "Refusing to pull: %v\n", err)
+		return exitCodeFor(err)
+	}
+	return exitOK
+}
+
+// readPathList reads secret paths from file, one per line, ignoring blank
+// lines and lines starting with "#".
+func readPathList(file string) ([]string, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		paths = append(paths, line)
+	}
+	return paths, nil
+}
+
+// pushArgs holds the parsed positional arguments and flags for the push command.
+type pushArgs struct {
+	namespace     string
+	kvEngine      string
+	subPath       string
+	inputDir      string
+	dryRun        bool
+	explode       bool
+	expandEnv     bool
+	strictEnv     bool
+	noRecurse     bool
+	followLinks   bool
+	patch         bool
+	mergeStrategy string
+	onlyNew       bool
+	preserveEmpty bool
+	extensions    []string
+	stripPrefix   string
+	changedSince  time.Duration
+	overlay       string
+	maxSize       int
+	preflight     bool
+	valueFilter   string
+	dataOnly      bool
+	metadataOnly  bool
+	stampMetadata bool
+	planOut       string
+	branchMap     string
+	format        string
+	ignoreFields  []string
+	interactive   bool
+	chunkFields   map[string]int
+
+	// namespaceFromPath takes the namespace from each top-level directory
+	// of inputDir instead of from the arguments.
+	namespaceFromPath bool
+}
+
+func parsePushArgs(args []string) (pushArgs, error) {
+	var parsed pushArgs
+
+	fs := flag.NewFlagSet("push", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.BoolVar(&parsed.dryRun, "dry-run", false, "Show changes without writing to Vault")
+	fs.BoolVar(&parsed.explode, "explode", false, "Reassemble per-key directories into secrets")
+	fs.BoolVar(&parsed.interactive, "interactive", false, "Ask whether to apply, skip, view, or edit each change before writing it")
+	fs.BoolVar(&parsed.expandEnv, "expand-env", false, "Substitute ${VAR} references in values from the environment")
+	fs.BoolVar(&parsed.strictEnv, "strict-env", false, "With --expand-env, fail on unset variables")
+	fs.BoolVar(&parsed.noRecurse, "no-recurse", false, "Only push files directly in the input directory")
+	fs.BoolVar(&parsed.followLinks, "follow-symlinks", false, "Follow symlinked directories, and symlinked files outside the input directory")
+	fs.BoolVar(&parsed.patch, "patch", false, "Update only the keys present locally via KV PATCH")
+	fs.StringVar(&parsed.mergeStrategy, "merge-strategy", "", "With --patch, how nested values combine: deep, replace, or append")
+	fs.BoolVar(&parsed.onlyNew, "only-new", false, "Create secrets that do not exist yet and skip existing ones")
+	fs.StringVar(&parsed.stripPrefix, "strip-prefix", "", "Leading part of the Vault path missing from local paths")
+	fs.DurationVar(&parsed.changedSince, "changed-since", 0, "Only push files modified within this duration (e.g. 1h)")
+	fs.StringVar(&parsed.overlay, "overlay", "", "Merge <name>.<overlay>.yaml onto each <name>.yaml before pushing")
+	fs.StringVar(&parsed.valueFilter, "value-filter", "", "Shell command each value is piped through before it is pushed")
+	fs.BoolVar(&parsed.preserveEmpty, "preserve-empty", false, "Push files without keys as empty secrets instead of skipping them")
+	fs.BoolVar(&parsed.preflight, "preflight", false, "Check the token can write every target path before pushing anything")
+	fs.StringVar(&parsed.planOut, "plan-out", "", "With --dry-run, also save the planned changes to this file")
+	fs.BoolVar(&parsed.dataOnly, "data-only", false, "Write only secret data and ignore _options blocks")
+	fs.BoolVar(&parsed.metadataOnly, "metadata-only", false, "Apply only _options blocks without writing a new data version")
+	fs.BoolVar(&parsed.stampMetadata, "stamp-metadata", false, "Record pushed_by, pushed_at, and source in each written secret's custom_metadata")
+	fs.StringVar(&parsed.format, "format", "", "Summarize the pushed secrets as a table (\"table\")")
+	fs.StringVar(&parsed.branchMap, "branch-map", "", "YAML file mapping git branches to the targets they may push to")
+	fs.BoolVar(&parsed.namespaceFromPath, "namespace-from-path", false, "Push each top-level directory of the input dir to the namespace it names")
+	maxSize := fs.String("max-secret-size", "", "Largest secret to push, e.g. 512KiB or 2MiB (0 for no limit, default 1MiB)")
+	ext := fs.String("ext", "", "Comma-separated file extensions to push (\"none\" for no extension)")
+	ignoreFields := fs.String("ignore-fields", "", "Comma-separated keys or globs left out of --dry-run diffs, e.g. last_rotated")
+	chunkFields := fs.String("chunk-field", "", "Comma-separated key:size pairs; longer values are pushed in chunks, e.g. cert:256KiB")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return pushArgs{}, err
+	}
+	if *ext != "" {
+		parsed.extensions = parseExtensions(*ext)
+	}
+	parsed.ignoreFields = splitList(*ignoreFields)
+	if *chunkFields != "" {
+		parsed.chunkFields, err = parseChunkFields(*chunkFields)
+		if err != nil {
+			return pushArgs{}, err
+		}
+		if parsed.patch {
+			return pushArgs{}, fmt.Errorf("--chunk-field cannot be combined with --patch")
+		}
+	}
+	if parsed.mergeStrategy != "" {
+		switch parsed.mergeStrategy {
+		case vaultsync.MergeDeep, vaultsync.MergeReplace, vaultsync.MergeAppend:
+		default:
+			return pushArgs{}, fmt.Errorf("--merge-strategy must be deep, replace, or append")
+		}
+		if !parsed.patch {
+			return pushArgs{}, fmt.Errorf("--merge-strategy requires --patch")
+		}
+	}
+	if parsed.dataOnly && parsed.metadataOnly {
+		return pushArgs{}, fmt.Errorf("--data-only and --metadata-only are mutually exclusive")
+	}
+	if parsed.stampMetadata && (parsed.dataOnly || parsed.metadataOnly) {
+		return pushArgs{}, fmt.Errorf("--stamp-metadata cannot be combined with --data-only or --metadata-only")
+	}
+	if parsed.onlyNew && (parsed.patch || parsed.metadataOnly) {
+		return pushArgs{}, fmt.Errorf("--only-new cannot be combined with --patch or --metadata-only")
+	}
+	if parsed.planOut != "" && !parsed.dryRun {
+		return pushArgs{}, fmt.Errorf("--plan-out requires --dry-run")
+	}
+	if parsed.interactive && (parsed.dryRun || parsed.onlyNew || parsed.metadataOnly || parsed.namespaceFromPath) {
+		return pushArgs{}, fmt.Errorf("--interactive cannot be combined with --dry-run, --only-new, --metadata-only, or --namespace-from-path")
+	}
+	if parsed.format != "" && parsed.format != "table" {
+		return pushArgs{}, fmt.Errorf("--format must be table")
+	}
+	if *maxSize != "" {
+		size, err := parseByteSize(*maxSize)
+		if err != nil {
+			return pushArgs{}, fmt.Errorf("invalid --max-secret-size: %w", err)
+		}
+		// 0 means no limit on the command line, while an unset flag keeps
+		// maxSize 0 for the default limit; -1 marks the former.
+		parsed.maxSize = size
+		if size == 0 {
+			parsed.maxSize = -1
+		}
+	}
+
+	if parsed.branchMap != "" && parsed.namespaceFromPath {
+		return pushArgs{}, fmt.Errorf("--branch-map cannot be combined with --namespace-from-path")
+	}
+
+	if parsed.namespaceFromPath {
+		if len(positional) > 2 {
+			return pushArgs{}, fmt.Errorf("too many arguments")
+		}
+		parsed.subPath, parsed.inputDir = splitSubPathAndDir(positional)
+		if parsed.inputDir == "" {
+			parsed.inputDir = defaultSecretsDir
+		}
+		if vaultsync.IsObjectStoreURI(parsed.inputDir) {
+			return pushArgs{}, fmt.Errorf("--namespace-from-path needs a local input directory")
+		}
+		return parsed, nil
+	}
+
+	if len(positional) < 1 {
+		if parsed.branchMap != "" {
+			// The target comes from the branch map.
+			return parsed, nil
+		}
+		return pushArgs{}, fmt.Errorf("namespace is required")
+	}
+
+	namespace, kvEngine, subPath, qualified, err := parseQualifiedTarget(positional[0])
+	switch {
+	case err != nil:
+		return pushArgs{}, err
+	case qualified:
+		parsed.namespace, parsed.kvEngine, parsed.subPath = namespace, kvEngine, subPath
+		if len(positional) > 1 {
+			parsed.inputDir = positional[1]
+		}
+	default:
+		parsed.namespace = positional[0]
+		parsed.subPath, parsed.inputDir = splitSubPathAndDir(positional[1:])
+	}
+	if parsed.inputDir == "" {
+		parsed.inputDir = defaultSecretsDir
+	}
+	if vaultsync.IsObjectStoreURI(parsed.inputDir) && (parsed.explode || parsed.changedSince > 0) {
+		// These work on a local directory tree, which a bucket does not have.
+		return pushArgs{}, fmt.Errorf("an object storage input cannot be combined with --explode or --changed-since")
+	}
+	return parsed, nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseExtensions splits a --ext value into PushOptions.Extensions. A leading
+// dot is optional, and "none" selects files without an extension.
+func parseExtensions(value string) []string {
+	var extensions []string
+	for _, ext := range strings.Split(value, ",") {
+		ext = strings.TrimSpace(ext)
+		switch {
+		case ext == "":
+			continue
+		case ext == "none":
+			extensions = append(extensions, "")
+		case strings.HasPrefix(ext, "."):
+			extensions = append(extensions, ext)
+		default:
+			extensions = append(extensions, "."+ext)
+		}
+	}
+	return extensions
+}
+
+// parseChunkFields parses a --chunk-field value such as "cert:256KiB,ca:64KiB"
+// into PushOptions.ChunkFields.
+func parseChunkFields(value string) (map[string]int, error) {
+	fields := make(map[string]int)
+	for _, item := range splitList(value) {
+		key, size, ok := strings.Cut(item, ":")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --chunk-field %q: want key:size", item)
+		}
+		n, err := parseByteSize(size)
+		if err != nil || n == 0 {
+			return nil, fmt.Errorf("invalid --chunk-field %q: size must be a positive size", item)
+		}
+		fields[key] = n
+	}
+	return fields, nil
+}
+
+// parseByteSize parses a size such as "4096", "512KiB", or "2MB". KB/KiB and
+// MB/MiB are all binary multiples, matching how Vault reports its limits.
+func parseByteSize(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	multiplier := 1
+	for _, unit := range []struct {
+		suffix string
+		size   int
+	}{
+		{"KiB", 1 << 10}, {"KB", 1 << 10}, {"K", 1 << 10},
+		{"MiB", 1 << 20}, {"MB", 1 << 20}, {"M", 1 << 20},
+		{"B", 1},
+	} {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSuffix(value, unit.suffix)
+			multiplier = unit.size
+			break
+		}
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q is not a size", value)
+	}
+	return n * multiplier, nil
+}
+
+func cmdPush(global globalOptions, args []string, stdout, stderr io.Writer) int {
+	parsed, err := parsePushArgs(args)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		fmt.Fprintln(stderr, "Usage: vaultsync [--kv-engine=name] push <namespace> [path] [input-dir] [--dry-run] [--explode]")
+		return exitUsage
+	}
+	if parsed.branchMap != "" {
+		if err := applyBranchMap(global, &parsed, stderr); err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return exitCodeFor(err)
+		}
+	}
+	if parsed.interactive && !(isTerminal(os.Stdin) && isTerminal(stdout)) {
+		fmt.Fprintln(stderr, "--interactive needs a terminal; push without it, or use --dry-run to preview the changes")
+		return exitUsage
+	}
+
+	kvEngine := engineOr(parsed.kvEngine, global.kvEngine)
+	ref := vaultsync.NewSecretRef(kvEngine, parsed.subPath)
+
+	var plan *os.File
+	if parsed.planOut != "" {
+		// 0600: with --show-values the plan holds secret values
+		plan, err = os.OpenFile(parsed.planOut, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			fmt.Fprintf(stderr, "Failed to create plan file: %v\n", err)
+			return exitCodeFor(err)
+		}
+		defer plan.Close()
+	}
+
+	var table *resultTable
+	if wantsTable(parsed.format, stdout) {
+		table = &resultTable{}
+	}
+
+	if parsed.namespaceFromPath {
+		return pushNamespaceDirs(global, parsed, ref, plan, table, stdout, stderr)
+	}
+
+	client, err := newPushClient(global, parsed, parsed.namespace, stdout, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitCodeFor(err)
+	}
+	inputDir, err := useObjectStorage(client, parsed.inputDir)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitCodeFor(err)
+	}
+	if table != nil {
+		table.attach(client, "")
+	}
+	if parsed.interactive {
+		client.PushOptions.Review = newPushReviewer(os.Stdin, stdout).review
+	}
+
+	if parsed.dryRun {
+		header := fmt.Sprintf("DRY RUN: showing changes for push from %s to %s in namespace %s...\n",
+			parsed.inputDir, pathDesc(kvEngine, parsed.subPath), parsed.namespace)
+		fmt.Fprint(stdout, header)
+		if plan != nil {
+			fmt.Fprint(plan, header)
+			client.PushOptions.PlanOutput = plan
+		}
+	} else {
+		fmt.Fprintf(stdout, "Pushing secrets from %s to %s in namespace %s...\n",
+			parsed.inputDir, pathDesc(kvEngine, parsed.subPath), parsed.namespace)
+	}
+
+	err = client.PushSecretsFromFilesAt(inputDir, ref, parsed.dryRun)
+	if table != nil {
+		table.print(stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Push operation failed: %v\n", err)
+		return exitCodeFor(err)
+	}
+	if !closePlan(plan, stderr) {
+		return exitError
+	}
+
+	if parsed.dryRun {
+		fmt.Fprintln(stdout, "Dry run completed! Use without --dry-run to actually push changes.")
+	} else {
+		fmt.Fprintln(stdout, "Completed! Secrets have been pushed to Vault.")
+	}
+	return exitOK
+}
+
+// applyBranchMap checks a push against the --branch-map rule for the git
+// branch checked out in the working directory. Without an explicit target the
+// rule supplies it, and an unmapped branch is an error; an explicit target
+// must lie within the rule, but may be used freely on unmapped branches.
+func applyBranchMap(global globalOptions, parsed *pushArgs, stderr io.Writer) error {
+	branchMap, err := vaultsync.LoadBranchMap(parsed.branchMap)
+	if err != nil {
+		return err
+	}
+	branch, err := vaultsync.CurrentGitBranch(".")
+	if err != nil {
+		return err
+	}
+
+	rule, err := branchMap.Match(branch)
+	explicit := parsed.inputDir != ""
+	switch {
+	case err != nil && explicit:
+		return nil
+	case err != nil:
+		return fmt.Errorf("%w; pass an explicit target to push from this branch", err)
+	case explicit:
+		kvEngine := engineOr(parsed.kvEngine, global.kvEngine)
+		if !rule.Allows(parsed.namespace, kvEngine, parsed.subPath, global.kvEngine) {
+			return fmt.Errorf("branch %s may only push to %s in namespace %s, not %s in namespace %s",
+				branch, pathDesc(engineOr(rule.KVEngine, global.kvEngine), rule.Path), rule.Namespace,
+				pathDesc(kvEngine, parsed.subPath), parsed.namespace)
+		}
+		return nil
+	}
+
+	parsed.namespace, parsed.kvEngine, parsed.subPath = rule.Namespace, rule.KVEngine, rule.Path
+	parsed.inputDir = rule.InputDir
+	if parsed.inputDir == "" {
+		parsed.inputDir = defaultSecretsDir
+	}
+	fmt.Fprintf(stderr, "Branch %s maps to %s in namespace %s\n",
+		branch, pathDesc(engineOr(rule.KVEngine, global.kvEngine), rule.Path), rule.Namespace)
+	return nil
+}
+
+// closePlan closes the --plan-out file, if any, reporting whether everything
+// written to it was saved.
+func closePlan(plan *os.File, stderr io.Writer) bool {
+	if plan == nil {
+		return true
+	}
+	if err := plan.Close(); err != nil {
+		fmt.Fprintf(stderr, "Failed to write plan file: %v\n", err)
+		return false
+	}
+	fmt.Fprintf(stderr, "Plan saved to %s\n", plan.Name())
+	return true
+}
+
+// newPushClient creates a client for namespace with the push flags applied.
+func newPushClient(global globalOptions, parsed pushArgs, namespace string, stdout, stderr io.Writer) (*vaultsync.VaultClient, error) {
+	client, err := newClient(global, namespace, stdout, stderr)
+	if err != nil {
+		return nil, err
+	}
+	client.PushOptions.Explode = parsed.explode
+	client.PushOptions.ExpandEnv = parsed.expandEnv || parsed.strictEnv
+	client.PushOptions.ExpandEnvStrict = parsed.strictEnv
+	client.PushOptions.NoRecurse = parsed.noRecurse
+	client.PushOptions.FollowSymlinks = parsed.followLinks
+	client.PushOptions.Patch = parsed.patch
+	client.PushOptions.MergeStrategy = parsed.mergeStrategy
+	client.PushOptions.OnlyNew = parsed.onlyNew
+	client.PushOptions.Extensions = parsed.extensions
+	client.PushOptions.StripPrefix = parsed.stripPrefix
+	client.PushOptions.Overlay = parsed.overlay
+	// Only push guards against oversized files; commands that copy or
+	// update secrets already in Vault leave the size to Vault.
+	switch {
+	case parsed.maxSize > 0:
+		client.PushOptions.MaxSecretSize = parsed.maxSize
+	case parsed.maxSize == 0:
+		client.PushOptions.MaxSecretSize = vaultsync.DefaultMaxSecretSize
+	}
+	client.PushOptions.ChunkFields = parsed.chunkFields
+	client.PushOptions.Preflight = parsed.preflight
+	client.PushOptions.ValueFilter = parsed.valueFilter
+	client.PushOptions.PreserveEmpty = parsed.preserveEmpty
+	client.PushOptions.DataOnly = parsed.dataOnly
+	client.PushOptions.MetadataOnly = parsed.metadataOnly
+	client.PushOptions.StampMetadata = parsed.stampMetadata
+	client.IgnoreFields = parsed.ignoreFields
+	if parsed.planOut != "" && !global.showValues {
+		// The plan is an artifact that gets shared; keep values out of it
+		// unless they were asked for explicitly.
+		client.PushOptions.MaskPlan = true
+	}
+	if parsed.changedSince > 0 {
+		client.PushOptions.ChangedSince = time.Now().Add(-parsed.changedSince)
+	}
+	return client, nil
+}
+
+// pushNamespaceDirs handles push --namespace-from-path, fanning out across the
+// namespaces named by the input directory's top-level directories.
+func pushNamespaceDirs(global globalOptions, parsed pushArgs, ref vaultsync.SecretRef, plan *os.File, table *resultTable, stdout, stderr io.Writer) int {
+	if parsed.dryRun {
+		header := fmt.Sprintf("DRY RUN: showing changes for push from %s to %s in each namespace directory...\n",
+			parsed.inputDir, pathDesc(ref.Engine, ref.Path))
+		fmt.Fprint(stdout, header)
+		if plan != nil {
+			fmt.Fprint(plan, header)
+		}
+	} else {
+		fmt.Fprintf(stdout, "Pushing secrets from %s to %s in each namespace directory...\n",
+			parsed.inputDir, pathDesc(ref.Engine, ref.Path))
+	}
+
+	factory := func(namespace string) (*vaultsync.VaultClient, error) {
+		client, err := newPushClient(global, parsed, namespace, stdout, stderr)
+		if err != nil {
+			return nil, err
+		}
+		if plan != nil {
+			fmt.Fprintf(plan, "Namespace %s:\n", namespace)
+			client.PushOptions.PlanOutput = plan
+		}
+		if table != nil {
+			table.attach(client, namespace)
+		}
+		return client, nil
+	}
+	err := vaultsync.RunPushNamespaceDirs(parsed.inputDir, ref, parsed.dryRun, factory)
+	if table != nil {
+		table.print(stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Push operation failed: %v\n", err)
+		return exitCodeFor(err)
+	}
+	if !closePlan(plan, stderr) {
+		return exitError
+	}
+
+	if parsed.dryRun {
+		fmt.Fprintln(stdout, "Dry run completed! Use without --dry-run to actually push changes.")
+	} else {
+		fmt.Fprintln(stdout, "Completed! Secrets have been pushed to Vault.")
+	}
+	return exitOK
+}
+
+// compareArgs holds the parsed positional arguments for the compare command.
+type compareArgs struct {
+	namespace string
+	kvEngine  string
+	subPath   string
+	file      string
+
+	ignoreFields []string
+}
+
+func parseCompareArgs(args []string) (compareArgs, error) {
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	ignoreFields := fs.String("ignore-fields", "", "Comma-separated keys or globs left out of the diff, e.g. last_rotated")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return compareArgs{}, err
+	}
+
+	if len(positional) < 1 {
+		return compareArgs{}, fmt.Errorf("namespace is required")
+	}
+
+	parsed := compareArgs{ignoreFields: splitList(*ignoreFields)}
+	namespace, kvEngine, subPath, qualified, err := parseQualifiedTarget(positional[0])
+	switch {
+	case err != nil:
+		return compareArgs{}, err
+	case qualified:
+		parsed.namespace, parsed.kvEngine, parsed.subPath = namespace, kvEngine, subPath
+		positional = positional[1:]
+	default:
+		if len(positional) < 2 {
+			return compareArgs{}, fmt.Errorf("secret path is required")
+		}
+		parsed.namespace, parsed.subPath = positional[0], positional[1]
+		positional = positional[2:]
+	}
+
+	if len(positional) != 1 {
+		return compareArgs{}, fmt.Errorf("exactly one file is required")
+	}
+	parsed.file = positional[0]
+
+	if parsed.subPath == "" {
+		return compareArgs{}, fmt.Errorf("secret path is required")
+	}
+	return parsed, nil
+}
+
+// cmdCompare diffs a single secret against a local file. It exits 0 when they
+// match and exitDrift when they differ; a failed comparison exits with the
+// code of its error.
+func cmdCompare(global globalOptions, args []string, stdout, stderr io.Writer) int {
+	parsed, err := parseCompareArgs(args)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		fmt.Fprintln(stderr, "Usage: vaultsync [--kv-engine=name] compare <namespace> <path> <file> [--ignore-fields=keys]")
+		return exitUsage
+	}
+
+	client, err := newClient(global, parsed.namespace, stdout, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitCodeFor(err)
+	}
+	client.IgnoreFields = parsed.ignoreFields
+
+	ref := vaultsync.NewSecretRef(engineOr(parsed.kvEngine, global.kvEngine), parsed.subPath)
+	diff, err := client.CompareSecretToFileAt(ref, parsed.file)
+	if err != nil {
+		fmt.Fprintf(stderr, "Compare failed: %v\n", err)
+		return exitCodeFor(err)
+	}
+
+	if diff == "" {
+		return exitOK
+	}
+
+	fmt.Fprint(stdout, diff)
+	return exitDrift
+}
+
+// cmdRedact scrubs the values from already-pulled files so their structure can
+// be shared. It works on local files only and needs no Vault access.
+func cmdRedact(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("redact", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	positional, err := parseInterspersed(fs, args)
+	if err == nil && len(positional) != 1 {
+		err = fmt.Errorf("exactly one directory is required")
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		fmt.Fprintln(stderr, "Usage: vaultsync redact <dir>")
+		return exitUsage
+	}
+
+	redacted, err := vaultsync.RedactSecretFiles(positional[0])
+	for _, file := range redacted {
+		fmt.Fprintf(stdout, "Redacted: %s\n", file)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Redact failed: %v\n", err)
+		return exitCodeFor(err)
+	}
+
+	fmt.Fprintf(stdout, "Completed! Redacted %d files in %s\n", len(redacted), positional[0])
+	return exitOK
+}
+
+// cmdLint checks a directory of secret files for problems before they are
+// pushed. Like redact it works on local files only and needs no Vault access.
+func cmdLint(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	ext := fs.String("ext", "", "Comma-separated file extensions to check (\"none\" for no extension)")
+	maxValueSize := fs.String("max-value-size", "", "Report string values larger than this, e.g. 4KiB (default 64KiB)")
+	followSymlinks := fs.Bool("follow-symlinks", false, "Follow symlinks as push --follow-symlinks does")
+
+	positional, err := parseInterspersed(fs, args)
+	if err == nil && len(positional) != 1 {
+		err = fmt.Errorf("exactly one directory is required")
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		fmt.Fprintln(stderr, "Usage: vaultsync lint <dir> [--ext=e1,e2] [--max-value-size=size] [--follow-symlinks]")
+		return exitUsage
+	}
+
+	options := vaultsync.LintOptions{FollowSymlinks: *followSymlinks}
+	if *ext != "" {
+		options.Extensions = parseExtensions(*ext)
+	}
+	if *maxValueSize != "" {
+		size, err := parseByteSize(*maxValueSize)
+		if err != nil || size <= 0 {
+			fmt.Fprintf(stderr, "Error: invalid --max-value-size %q\n", *maxValueSize)
+			fmt.Fprintln(stderr, "Usage: vaultsync lint <dir> [--ext=e1,e2] [--max-value-size=size] [--follow-symlinks]")
+			return exitUsage
+		}
+		options.MaxValueSize = size
+	}
+
+	issues, err := vaultsync.LintSecretFiles(positional[0], options)
+	for _, issue := range issues {
+		fmt.Fprintln(stdout, issue)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Lint failed: %v\n", err)
+		return exitCodeFor(err)
+	}
+	if len(issues) > 0 {
+		fmt.Fprintf(stderr, "Found %d problems in %s\n", len(issues), positional[0])
+		return exitError
+	}
+
+	fmt.Fprintf(stdout, "No problems found in %s\n", positional[0])
+	return exitOK
+}
+
+// cmdVerify checks a pulled directory against the manifest pull --manifest
+// wrote, listing every file that was changed, removed, or added since.
+func cmdVerify(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	positional, err := parseInterspersed(fs, args)
+	if err == nil && len(positional) != 1 {
+		err = fmt.Errorf("exactly one directory is required")
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		fmt.Fprintln(stderr, "Usage: vaultsync verify <dir>")
+		return exitUsage
+	}
+
+	mismatches, err := vaultsync.VerifyManifest(positional[0])
+	if err != nil {
+		fmt.Fprintf(stderr, "Verify failed: %v\n", err)
+		return exitCodeFor(err)
+	}
+	for _, mismatch := range mismatches {
+		fmt.Fprintln(stdout, mismatch)
+	}
+	if len(mismatches) > 0 {
+		fmt.Fprintf(stderr, "%d files in %s do not match the manifest\n", len(mismatches), positional[0])
+		return exitDrift
+	}
+
+	fmt.Fprintf(stdout, "All files in %s match the manifest\n", positional[0])
+	return exitOK
+}
+
+// enginesArgs holds the parsed positional arguments and flags for the engines
+// command.
+type enginesArgs struct {
+	namespace string
+	all       bool
+}
+
+func parseEnginesArgs(args []string) (enginesArgs, error) {
+	var parsed enginesArgs
+
+	fs := flag.NewFlagSet("engines", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.BoolVar(&parsed.all, "all", false, "List every secrets engine, not only KV")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return enginesArgs{}, err
+	}
+
+	if len(positional) != 1 {
+		return enginesArgs{}, fmt.Errorf("namespace is required")
+	}
+	parsed.namespace = positional[0]
+	return parsed, nil
+}
+
+// cmdEngines lists the secrets engines mounted in a namespace, by default only
+// the KV ones, so users can find the right --kv-engine value.
+func cmdEngines(global globalOptions, args []string, stdout, stderr io.Writer) int {
+	parsed, err := parseEnginesArgs(args)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		fmt.Fprintln(stderr, "Usage: vaultsync engines <namespace> [--all]")
+		return exitUsage
+	}
+
+	client, err := newClient(global, parsed.namespace, stdout, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitCodeFor(err)
+	}
+
+	mounts, err := client.ListMounts()
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to list engines: %v\n", err)
+		return exitCodeFor(err)
+	}
+
+	tw := newTable(stdout)
+	fmt.Fprintln(tw, "PATH\tTYPE\tVERSION\tDESCRIPTION")
+	shown := 0
+	for _, mount := range mounts {
+		if !parsed.all && !mount.IsKV() {
+			continue
+		}
+		version := "-"
+		if mount.KVVersion > 0 {
+			version = fmt.Sprintf("v%d", mount.KVVersion)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", mount.Path, mount.Type, version, mount.Description)
+		shown++
+	}
+
+	if shown == 0 {
+		fmt.Fprintf(stdout, "No KV engines found in namespace %s (use --all to list every engine)\n", parsed.namespace)
+		return exitOK
+	}
+	tw.Flush()
+	return exitOK
+}
+
+// auditArgs holds the parsed positional arguments for the audit command.
+type auditArgs struct {
+	namespace string
+	kvEngine  string
+	subPath   string
+}
+
+func parseAuditArgs(args []string) (auditArgs, error) {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return auditArgs{}, err
+	}
+
+	if len(positional) < 1 || len(positional) > 2 {
+		return auditArgs{}, fmt.Errorf("namespace and optional path are required")
+	}
+
+	var parsed auditArgs
+	namespace, kvEngine, subPath, qualified, err := parseQualifiedTarget(positional[0])
+	switch {
+	case err != nil:
+		return auditArgs{}, err
+	case qualified:
+		if len(positional) > 1 {
+			return auditArgs{}, fmt.Errorf("unexpected argument %q after qualified target", positional[1])
+		}
+		parsed.namespace, parsed.kvEngine, parsed.subPath = namespace, kvEngine, subPath
+	default:
+		parsed.namespace = positional[0]
+		if len(positional) > 1 {
+			parsed.subPath = positional[1]
+		}
+	}
+	return parsed, nil
+}
+
+// cmdAudit reports groups of secrets under a path that hold identical data,
+// to help find accidental copies. Nothing is written locally.
+func cmdAudit(global globalOptions, args []string, stdout, stderr io.Writer) int {
+	parsed, err := parseAuditArgs(args)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		fmt.Fprintln(stderr, "Usage: vaultsync [--kv-engine=name] audit <namespace> [path]")
+		return exitUsage
+	}
+
+	client, err := newClient(global, parsed.namespace, stdout, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitCodeFor(err)
+	}
+
+	kvEngine := engineOr(parsed.kvEngine, global.kvEngine)
+	groups, err := client.FindDuplicateSecretsAt(vaultsync.NewSecretRef(kvEngine, parsed.subPath))
+
+	if len(groups) == 0 {
+		fmt.Fprintf(stdout, "No duplicate secrets found under %s in namespace %s\n", pathDesc(kvEngine, parsed.subPath), parsed.namespace)
+	} else {
+		fmt.Fprintf(stdout, "Duplicate secrets under %s in namespace %s:\n", pathDesc(kvEngine, parsed.subPath), parsed.namespace)
+		for i, group := range groups {
+			fmt.Fprintf(stdout, "  Group %d (%d secrets, sha256 %s):\n", i+1, len(group.Paths), group.Hash[:12])
+			for _, path := range group.Paths {
+				fmt.Fprintf(stdout, "    - %s\n", path)
+			}
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "Audit incomplete: %v\n", err)
+		return exitCodeFor(err)
+	}
+	return exitOK
+}
+
+// moveArgs holds the parsed positional arguments and flags for the move command.
+type moveArgs struct {
+	namespace    string
+	srcEngine    string
+	src          string
+	dstEngine    string
+	dst          string
+	dryRun       bool
+	deleteSource bool
+}
+
+func parseMoveArgs(args []string) (moveArgs, error) {
+	var parsed moveArgs
+
+	fs := flag.NewFlagSet("move", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.BoolVar(&parsed.dryRun, "dry-run", false, "Show the planned moves without changing Vault")
+	fs.BoolVar(&parsed.deleteSource, "delete-source", false, "Delete the source secrets once all are copied")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return moveArgs{}, err
+	}
+
+	// Both paths may be qualified targets, in which case the namespace
+	// argument can be left out.
+	var src, dst string
+	switch len(positional) {
+	case 2:
+		if !vaultsync.IsQualifiedPath(positional[0]) || !vaultsync.IsQualifiedPath(positional[1]) {
+			return moveArgs{}, fmt.Errorf("namespace, source, and destination are required")
+		}
+		src, dst = positional[0], positional[1]
+	case 3:
+		parsed.namespace, src, dst = positional[0], positional[1], positional[2]
+	default:
+		return moveArgs{}, fmt.Errorf("namespace, source, and destination are required")
+	}
+
+	for i, target := range []struct {
+		arg          string
+		engine, path *string
+	}{{src, &parsed.srcEngine, &parsed.src}, {dst, &parsed.dstEngine, &parsed.dst}} {
+		namespace, kvEngine, subPath, qualified, err := parseQualifiedTarget(target.arg)
+		switch {
+		case err != nil:
+			return moveArgs{}, err
+		case !qualified:
+			*target.path = target.arg
+			continue
+		case i == 0 && len(positional) == 2:
+			parsed.namespace = namespace
+		case namespace != parsed.namespace:
+			// One client serves the whole move, so both ends must be in the
+			// same namespace.
+			return moveArgs{}, fmt.Errorf("%q is not in namespace %q; move cannot cross namespaces", target.arg, parsed.namespace)
+		}
+		*target.engine, *target.path = kvEngine, subPath
+	}
+	return parsed, nil
+}
+
+// cmdMove copies every secret below src to the same relative path below dst,
+// optionally deleting the originals. Either path may be a qualified
+// "namespace:engine/path" target, so secrets can move between engines.
+func cmdMove(global globalOptions, args []string, stdout, stderr io.Writer) int {
+	parsed, err := parseMoveArgs(args)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		fmt.Fprintln(stderr, "Usage: vaultsync [--kv-engine=name] move <namespace> <src> <dst> [--dry-run] [--delete-source]")
+		fmt.Fprintln(stderr, "       vaultsync move <ns:engine/src> <ns:engine/dst> [--dry-run] [--delete-source]")
+		return exitUsage
+	}
+
+	client, err := newClient(global, parsed.namespace, stdout, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitCodeFor(err)
+	}
+
+	src := vaultsync.NewSecretRef(engineOr(parsed.srcEngine, global.kvEngine), parsed.src)
+	dst := vaultsync.NewSecretRef(engineOr(parsed.dstEngine, global.kvEngine), parsed.dst)
+	if parsed.dryRun {
+		fmt.Fprintf(stdout, "DRY RUN: showing planned moves from %s to %s in namespace %s...\n",
+			pathDesc(src.Engine, src.Path), pathDesc(dst.Engine, dst.Path), parsed.namespace)
+	}
+
+	if err := client.MoveSecretsAt(src, dst, parsed.dryRun, parsed.deleteSource); err != nil {
+		fmt.Fprintf(stderr, "Move failed: %v\n", err)
+		return exitCodeFor(err)
+	}
+
+	if parsed.dryRun {
+		fmt.Fprintln(stdout, "Dry run completed! Use without --dry-run to actually move secrets.")
+	} else {
+		fmt.Fprintln(stdout, "Completed! Secrets have been moved.")
+	}
+	return exitOK
+}
+
+// readArgs holds the parsed positional arguments and flags for the read command.
+type readArgs struct {
+	namespace string
+	path      string
+	format    string
+}
+
+func parseReadArgs(args []string) (readArgs, error) {
+	parsed := readArgs{format: "yaml"}
+
+	fs := flag.NewFlagSet("read", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&parsed.format, "format", parsed.format, "Output format: yaml or json")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return readArgs{}, err
+	}
+
+	if len(positional) != 2 {
+		return readArgs{}, fmt.Errorf("namespace and path are required")
+	}
+	parsed.namespace, parsed.path = positional[0], positional[1]
+
+	if parsed.format != "yaml" && parsed.format != "json" {
+		return readArgs{}, fmt.Errorf("--format must be yaml or json")
+	}
+	return parsed, nil
+}
+
+// cmdRead prints the data returned by a plain GET on any API path, for dynamic
+// secrets and other non-KV endpoints.
+func cmdRead(global globalOptions, args []string, stdout, stderr io.Writer) int {
+	parsed, err := parseReadArgs(args)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		fmt.Fprintln(stderr, "Usage: vaultsync read <namespace> <api-path> [--format=yaml|json]")
+		return exitUsage
+	}
+
+	client, err := newClient(global, parsed.namespace, stdout, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitCodeFor(err)
+	}
+
+	data, err := client.ReadRaw(parsed.path)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to read %s: %v\n", parsed.path, err)
+		return exitCodeFor(err)
+	}
+
+	var out []byte
+	if parsed.format == "json" {
+		out, err = marshalJSON(data, global.compactJSON)
+		out = append(out, '\n')
+	} else {
+		out, err = yaml.Marshal(data)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to format response: %v\n", err)
+		return exitCodeFor(err)
+	}
+
+	stdout.Write(out)
+	return exitOK
+}
+
+// writeArgs holds the parsed positional arguments for the write command.
+type writeArgs struct {
+	namespace string
+	path      string
+	data      map[string]interface{}
+	fromStdin bool
+}
+
+func parseWriteArgs(args []string) (writeArgs, error) {
+	fs := flag.NewFlagSet("write", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return writeArgs{}, err
+	}
+
+	if len(positional) < 3 {
+		return writeArgs{}, fmt.Errorf("namespace, path, and data are required")
+	}
+
+	parsed := writeArgs{namespace: positional[0], path: positional[1]}
+	fields := positional[2:]
+	if len(fields) == 1 && fields[0] == "-" {
+		parsed.fromStdin = true
+		return parsed, nil
+	}
+
+	parsed.data = make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || key == "" {
+			return writeArgs{}, fmt.Errorf("expected key=value, got %q", field)
+		}
+		parsed.data[key] = value
+	}
+	return parsed, nil
+}
+
+// cmdWrite POSTs key=value pairs, or a JSON object read from stdin when the
+// only data argument is "-", to any API path without the KV data wrapper.
+func cmdWrite(global globalOptions, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	parsed, err := parseWriteArgs(args)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		fmt.Fprintln(stderr, "Usage: vaultsync write <namespace> <api-path> key=value... | -")
+		return exitUsage
+	}
+
+	if parsed.fromStdin {
+		if err := json.NewDecoder(stdin).Decode(&parsed.data); err != nil {
+			fmt.Fprintf(stderr, "Failed to parse JSON from stdin: %v\n", err)
+			return exitCodeFor(err)
+		}
+	}
+
+	client, err := newClient(global, parsed.namespace, stdout, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitCodeFor(err)
+	}
+
+	data, err := client.WriteRaw(parsed.path, parsed.data)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to write %s: %v\n", parsed.path, err)
+		return exitCodeFor(err)
+	}
+
+	if len(data) == 0 {
+		fmt.Fprintf(stdout, "Success! Data written to: %s\n", parsed.path)
+		return exitOK
+	}
+
+	out, err := yaml.Marshal(data)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to format response: %v\n", err)
+		return exitCodeFor(err)
 	}
-	return 0
+	stdout.Write(out)
+	return exitOK
 }
@@ -2,15 +2,22 @@ package main
 
 import (
 	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/kriipke/vaultsync"
 )
 
 func TestRunNoArgsPrintsUsage(t *testing.T) {
 	var stdout, stderr bytes.Buffer
 	code := run(nil, &stdout, &stderr)
-	if code != 1 {
-		t.Fatalf("expected exit code 1, got %d", code)
+	if code != exitUsage {
+		t.Fatalf("expected exit code %d, got %d", exitUsage, code)
 	}
 	if !strings.Contains(stdout.String(), "Usage: vaultsync") {
 		t.Fatalf("expected usage output, got %q", stdout.String())
@@ -20,8 +27,8 @@ func TestRunNoArgsPrintsUsage(t *testing.T) {
 func TestRunUnknownCommand(t *testing.T) {
 	var stdout, stderr bytes.Buffer
 	code := run([]string{"frobnicate"}, &stdout, &stderr)
-	if code != 1 {
-		t.Fatalf("expected exit code 1, got %d", code)
+	if code != exitUsage {
+		t.Fatalf("expected exit code %d, got %d", exitUsage, code)
 	}
 	if !strings.Contains(stderr.String(), "Unknown command: frobnicate") {
 		t.Fatalf("expected unknown command error, got %q", stderr.String())
@@ -31,8 +38,8 @@ func TestRunUnknownCommand(t *testing.T) {
 func TestRunListWithoutNamespace(t *testing.T) {
 	var stdout, stderr bytes.Buffer
 	code := run([]string{"list"}, &stdout, &stderr)
-	if code != 1 {
-		t.Fatalf("expected exit code 1, got %d", code)
+	if code != exitUsage {
+		t.Fatalf("expected exit code %d, got %d", exitUsage, code)
 	}
 	if !strings.Contains(stderr.String(), "list <namespace>") {
 		t.Fatalf("expected list usage, got %q", stderr.String())
@@ -84,6 +91,104 @@ func TestLooksLikePath(t *testing.T) {
 	}
 }
 
+func TestParseListArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    listArgs
+		wantErr bool
+	}{
+		{
+			name: "namespace and path",
+			args: []string{"ns", "app"},
+			want: listArgs{namespace: "ns", subPath: "app", format: "human", sort: "name"},
+		},
+		{
+			name: "keys flag with secret path",
+			args: []string{"ns", "--keys", "app/db"},
+			want: listArgs{namespace: "ns", subPath: "app/db", keys: true, format: "human", sort: "name"},
+		},
+		{
+			name: "qualified target",
+			args: []string{"myns:kv/metadata/app"},
+			want: listArgs{namespace: "myns", kvEngine: "kv", subPath: "app", format: "human", sort: "name"},
+		},
+		{
+			name: "strict paths accepts a metadata segment",
+			args: []string{"myns:kv/metadata/app", "--strict-paths"},
+			want: listArgs{namespace: "myns", kvEngine: "kv", subPath: "app", format: "human", sort: "name", strictPaths: true},
+		},
+		{
+			name:    "strict paths rejects a data segment",
+			args:    []string{"myns:kv/data/app", "--strict-paths"},
+			wantErr: true,
+		},
+		{
+			name:    "strict paths rejects a sub-path starting with data",
+			args:    []string{"ns", "data/app", "--strict-paths"},
+			wantErr: true,
+		},
+		{
+			name: "plain format",
+			args: []string{"ns", "app", "--format=plain"},
+			want: listArgs{namespace: "ns", subPath: "app", format: "plain", sort: "name"},
+		},
+		{
+			name: "folders first",
+			args: []string{"ns", "--folders-first"},
+			want: listArgs{namespace: "ns", format: "human", sort: "name", foldersFirst: true},
+		},
+		{
+			name: "unsorted",
+			args: []string{"ns", "--sort=none"},
+			want: listArgs{namespace: "ns", format: "human", sort: "none"},
+		},
+		{
+			name:    "unknown sort is an error",
+			args:    []string{"ns", "--sort=size"},
+			wantErr: true,
+		},
+		{
+			name:    "folders first without sorting is an error",
+			args:    []string{"ns", "--sort=none", "--folders-first"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown format is an error",
+			args:    []string{"ns", "--format=csv"},
+			wantErr: true,
+		},
+		{
+			name:    "keys flag without path is an error",
+			args:    []string{"ns", "--keys"},
+			wantErr: true,
+		},
+		{
+			name:    "no args is an error",
+			args:    nil,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseListArgs(tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("parseListArgs(%v) = %+v, want %+v", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestParsePullArgs(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -111,6 +216,181 @@ func TestParsePullArgs(t *testing.T) {
 			args: []string{"ns", "./out"},
 			want: pullArgs{namespace: "ns", subPath: "", outputDir: "./out"},
 		},
+		{
+			name: "explode flag after positionals",
+			args: []string{"ns", "app", "--explode"},
+			want: pullArgs{namespace: "ns", subPath: "app", outputDir: "./secrets", explode: true},
+		},
+		{
+			name: "qualified target with output dir",
+			args: []string{"myns:secrets/metadata/app", "./out"},
+			want: pullArgs{namespace: "myns", kvEngine: "secrets", subPath: "app", outputDir: "./out"},
+		},
+		{
+			name: "object storage output dir",
+			args: []string{"ns", "s3://backups/vault"},
+			want: pullArgs{namespace: "ns", outputDir: "s3://backups/vault"},
+		},
+		{
+			name:    "object storage output dir with mirror",
+			args:    []string{"ns", "app", "s3://backups/vault", "--mirror"},
+			wantErr: true,
+		},
+		{
+			name: "object storage output dir with only-changed",
+			args: []string{"ns", "s3://backups/vault", "--only-changed"},
+			want: pullArgs{namespace: "ns", outputDir: "s3://backups/vault", onlyChanged: true},
+		},
+		{
+			name:    "object storage output dir with explode",
+			args:    []string{"ns", "s3://backups/vault", "--explode"},
+			wantErr: true,
+		},
+		{
+			name: "yaml indent",
+			args: []string{"ns", "--yaml-indent=2"},
+			want: pullArgs{namespace: "ns", outputDir: "./secrets", yamlIndent: 2},
+		},
+		{
+			name:    "yaml indent out of range is an error",
+			args:    []string{"ns", "--yaml-indent", "12"},
+			wantErr: true,
+		},
+		{
+			name: "no-recurse flag before positionals",
+			args: []string{"--no-recurse", "ns", "app"},
+			want: pullArgs{namespace: "ns", subPath: "app", outputDir: "./secrets", noRecurse: true},
+		},
+		{
+			name: "k8s-secret format with namespace",
+			args: []string{"ns", "app", "--format=k8s-secret", "--k8s-namespace", "payments"},
+			want: pullArgs{namespace: "ns", subPath: "app", outputDir: "./secrets", format: "k8s-secret", k8sNamespace: "payments"},
+		},
+		{
+			name: "vault-kv format",
+			args: []string{"ns", "app", "--format=vault-kv"},
+			want: pullArgs{namespace: "ns", subPath: "app", outputDir: "./secrets", format: "vault-kv"},
+		},
+		{
+			name: "env-combined format with separator",
+			args: []string{"ns", "app", "--format=env-combined", "--env-separator=__", "--env-keep-case"},
+			want: pullArgs{namespace: "ns", subPath: "app", outputDir: "./secrets", format: "env-combined", envSeparator: "__", envKeepCase: true},
+		},
+		{
+			name:    "env-combined format with explode is an error",
+			args:    []string{"ns", "--format=env-combined", "--explode"},
+			wantErr: true,
+		},
+		{
+			name:    "env separator with invalid characters is an error",
+			args:    []string{"ns", "--format=env-combined", "--env-separator=-"},
+			wantErr: true,
+		},
+		{
+			name:    "env separator without env-combined is an error",
+			args:    []string{"ns", "--env-separator=__"},
+			wantErr: true,
+		},
+		{
+			name: "strip-prefix flag",
+			args: []string{"ns", "teams/platform/prod", "--strip-prefix=teams/platform"},
+			want: pullArgs{namespace: "ns", subPath: "teams/platform/prod", outputDir: "./secrets", stripPrefix: "teams/platform"},
+		},
+		{
+			name: "only-changed flag",
+			args: []string{"ns", "--only-changed"},
+			want: pullArgs{namespace: "ns", outputDir: "./secrets", onlyChanged: true},
+		},
+		{
+			name: "gitignore flag",
+			args: []string{"ns", "app", "--gitignore"},
+			want: pullArgs{namespace: "ns", subPath: "app", outputDir: "./secrets", gitignore: true},
+		},
+		{
+			name:    "unknown format is an error",
+			args:    []string{"ns", "--format=ini"},
+			wantErr: true,
+		},
+		{
+			name: "toml format",
+			args: []string{"ns", "app", "--format=toml"},
+			want: pullArgs{namespace: "ns", subPath: "app", outputDir: "./secrets", format: "toml"},
+		},
+		{
+			name:    "k8s-secret format with explode is an error",
+			args:    []string{"ns", "--format=k8s-secret", "--explode"},
+			wantErr: true,
+		},
+		{
+			name:    "template with k8s-secret format is an error",
+			args:    []string{"ns", "--template=app.env.tmpl", "--format=k8s-secret"},
+			wantErr: true,
+		},
+		{
+			name: "paths-from with output dir",
+			args: []string{"ns", "out", "--paths-from=list.txt"},
+			want: pullArgs{namespace: "ns", outputDir: "out", pathsFrom: "list.txt"},
+		},
+		{
+			name: "mirror with dry-run",
+			args: []string{"ns", "app", "--mirror", "--dry-run"},
+			want: pullArgs{namespace: "ns", subPath: "app", outputDir: "./secrets", mirror: true, dryRun: true},
+		},
+		{
+			name: "value filter",
+			args: []string{"ns", "--value-filter", "sops -d /dev/stdin"},
+			want: pullArgs{namespace: "ns", outputDir: "./secrets", valueFilter: "sops -d /dev/stdin"},
+		},
+		{
+			name: "key include and exclude globs",
+			args: []string{"ns", "--key-include=*_public,host", "--key-exclude", "legacy_*"},
+			want: pullArgs{namespace: "ns", outputDir: "./secrets", keyInclude: []string{"*_public", "host"}, keyExclude: []string{"legacy_*"}},
+		},
+		{
+			name: "resume from checkpoint",
+			args: []string{"ns", "app", "--checkpoint=pull.state", "--resume"},
+			want: pullArgs{namespace: "ns", subPath: "app", outputDir: "./secrets", checkpoint: "pull.state", resume: true},
+		},
+		{
+			name: "summary only with sizes",
+			args: []string{"ns", "app", "--summary-only", "--with-sizes"},
+			want: pullArgs{namespace: "ns", subPath: "app", outputDir: "./secrets", summaryOnly: true, withSizes: true},
+		},
+		{
+			name:    "with-sizes without summary-only is an error",
+			args:    []string{"ns", "--with-sizes"},
+			wantErr: true,
+		},
+		{
+			name:    "summary-only with mirror is an error",
+			args:    []string{"ns", "--summary-only", "--mirror"},
+			wantErr: true,
+		},
+		{
+			name:    "resume without checkpoint is an error",
+			args:    []string{"ns", "--resume"},
+			wantErr: true,
+		},
+		{
+			name:    "dry-run without mirror is an error",
+			args:    []string{"ns", "--dry-run"},
+			wantErr: true,
+		},
+		{
+			name: "require-capabilities list",
+			args: []string{"ns", "app", "--require-capabilities=read, list"},
+			want: pullArgs{namespace: "ns", subPath: "app", outputDir: "./secrets", requireCapabilities: []string{"read", "list"}},
+		},
+		{
+			name:    "unknown required capability is an error",
+			args:    []string{"ns", "--require-capabilities=read,write"},
+			wantErr: true,
+		},
+		{
+			name:    "paths-from with a path is an error",
+			args:    []string{"ns", "app", "./out", "--paths-from=list.txt"},
+			wantErr: true,
+		},
 		{
 			name:    "no args is an error",
 			args:    nil,
@@ -130,7 +410,7 @@ func TestParsePullArgs(t *testing.T) {
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
-			if got != tt.want {
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Fatalf("parsePullArgs(%v) = %+v, want %+v", tt.args, got, tt.want)
 			}
 		})
@@ -164,6 +444,166 @@ func TestParsePushArgs(t *testing.T) {
 			args: []string{"--dry-run", "ns", "app"},
 			want: pushArgs{namespace: "ns", subPath: "app", inputDir: "./secrets", dryRun: true},
 		},
+		{
+			name: "object storage input dir",
+			args: []string{"ns", "app", "s3://backups/vault"},
+			want: pushArgs{namespace: "ns", subPath: "app", inputDir: "s3://backups/vault"},
+		},
+		{
+			name:    "object storage input dir with explode",
+			args:    []string{"ns", "app", "s3://backups/vault", "--explode"},
+			wantErr: true,
+		},
+		{
+			name: "explode flag",
+			args: []string{"ns", "--explode", "app"},
+			want: pushArgs{namespace: "ns", subPath: "app", inputDir: "./secrets", explode: true},
+		},
+		{
+			name: "qualified target defaults input dir",
+			args: []string{"myns:kv/app", "--dry-run"},
+			want: pushArgs{namespace: "myns", kvEngine: "kv", subPath: "app", inputDir: "./secrets", dryRun: true},
+		},
+		{
+			name: "strict-env flag",
+			args: []string{"ns", "--strict-env"},
+			want: pushArgs{namespace: "ns", inputDir: "./secrets", strictEnv: true},
+		},
+		{
+			name: "strip-prefix flag",
+			args: []string{"ns", "teams/platform/prod", "--strip-prefix", "teams/platform"},
+			want: pushArgs{namespace: "ns", subPath: "teams/platform/prod", inputDir: "./secrets", stripPrefix: "teams/platform"},
+		},
+		{
+			name: "changed-since duration",
+			args: []string{"ns", "--changed-since=90m"},
+			want: pushArgs{namespace: "ns", inputDir: "./secrets", changedSince: 90 * time.Minute},
+		},
+		{
+			name: "max secret size",
+			args: []string{"ns", "--max-secret-size=2MiB"},
+			want: pushArgs{namespace: "ns", inputDir: "./secrets", maxSize: 2 << 20},
+		},
+		{
+			name: "preflight flag",
+			args: []string{"ns", "app", "--preflight"},
+			want: pushArgs{namespace: "ns", subPath: "app", inputDir: "./secrets", preflight: true},
+		},
+		{
+			name: "max secret size zero disables the limit",
+			args: []string{"ns", "--max-secret-size=0"},
+			want: pushArgs{namespace: "ns", inputDir: "./secrets", maxSize: -1},
+		},
+		{
+			name:    "invalid max secret size is an error",
+			args:    []string{"ns", "--max-secret-size=lots"},
+			wantErr: true,
+		},
+		{
+			name: "namespace from path with input dir",
+			args: []string{"--namespace-from-path", "./repo"},
+			want: pushArgs{inputDir: "./repo", namespaceFromPath: true},
+		},
+		{
+			name: "namespace from path with sub-path",
+			args: []string{"--namespace-from-path", "app", "repo"},
+			want: pushArgs{subPath: "app", inputDir: "repo", namespaceFromPath: true},
+		},
+		{
+			name: "overlay environment",
+			args: []string{"ns", "app", "--overlay=prod"},
+			want: pushArgs{namespace: "ns", subPath: "app", inputDir: "./secrets", overlay: "prod"},
+		},
+		{
+			name: "interactive",
+			args: []string{"ns", "app", "--interactive"},
+			want: pushArgs{namespace: "ns", subPath: "app", inputDir: "./secrets", interactive: true},
+		},
+		{
+			name:    "interactive with dry-run is an error",
+			args:    []string{"ns", "--interactive", "--dry-run"},
+			wantErr: true,
+		},
+		{
+			name:    "interactive with only-new is an error",
+			args:    []string{"ns", "--interactive", "--only-new"},
+			wantErr: true,
+		},
+		{
+			name: "chunk fields",
+			args: []string{"ns", "--chunk-field", "cert:256KiB, ca:4096"},
+			want: pushArgs{namespace: "ns", inputDir: "./secrets", chunkFields: map[string]int{"cert": 256 << 10, "ca": 4096}},
+		},
+		{
+			name:    "chunk field without size is an error",
+			args:    []string{"ns", "--chunk-field=cert"},
+			wantErr: true,
+		},
+		{
+			name:    "chunk field with patch is an error",
+			args:    []string{"ns", "--chunk-field=cert:1KiB", "--patch"},
+			wantErr: true,
+		},
+		{
+			name: "merge strategy",
+			args: []string{"ns", "--patch", "--merge-strategy=append"},
+			want: pushArgs{namespace: "ns", inputDir: "./secrets", patch: true, mergeStrategy: "append"},
+		},
+		{
+			name:    "merge strategy without patch is an error",
+			args:    []string{"ns", "--merge-strategy=replace"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown merge strategy is an error",
+			args:    []string{"ns", "--patch", "--merge-strategy=shallow"},
+			wantErr: true,
+		},
+		{
+			name: "stamp metadata",
+			args: []string{"ns", "--stamp-metadata"},
+			want: pushArgs{namespace: "ns", inputDir: "./secrets", stampMetadata: true},
+		},
+		{
+			name:    "stamp metadata with data-only is an error",
+			args:    []string{"ns", "--stamp-metadata", "--data-only"},
+			wantErr: true,
+		},
+		{
+			name: "ext list normalizes dots and none",
+			args: []string{"ns", "--ext", "yaml, .json,none"},
+			want: pushArgs{namespace: "ns", inputDir: "./secrets", extensions: []string{".yaml", ".json", ""}},
+		},
+		{
+			name: "plan-out with dry-run",
+			args: []string{"ns", "--dry-run", "--plan-out=plan.diff"},
+			want: pushArgs{namespace: "ns", inputDir: "./secrets", dryRun: true, planOut: "plan.diff"},
+		},
+		{
+			name: "branch-map without target",
+			args: []string{"--branch-map", "branches.yaml", "--dry-run"},
+			want: pushArgs{dryRun: true, branchMap: "branches.yaml"},
+		},
+		{
+			name: "branch-map with explicit target",
+			args: []string{"ns", "app", "--branch-map=branches.yaml"},
+			want: pushArgs{namespace: "ns", subPath: "app", inputDir: "./secrets", branchMap: "branches.yaml"},
+		},
+		{
+			name:    "branch-map with namespace from path is an error",
+			args:    []string{"--namespace-from-path", "--branch-map=branches.yaml"},
+			wantErr: true,
+		},
+		{
+			name:    "plan-out without dry-run is an error",
+			args:    []string{"ns", "--plan-out=plan.diff"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown flag is an error",
+			args:    []string{"ns", "--bogus"},
+			wantErr: true,
+		},
 		{
 			name:    "no positional args is an error",
 			args:    []string{"--dry-run"},
@@ -183,22 +623,598 @@ func TestParsePushArgs(t *testing.T) {
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
-			if got != tt.want {
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Fatalf("parsePushArgs(%v) = %+v, want %+v", tt.args, got, tt.want)
 			}
 		})
 	}
 }
 
-func TestGlobalKVEngineFlagParsed(t *testing.T) {
-	// --kv-engine before the command should be consumed, leaving the command
-	// usage to fire (namespace missing) rather than an "unknown command".
-	var stdout, stderr bytes.Buffer
-	code := run([]string{"--kv-engine=secrets", "list"}, &stdout, &stderr)
-	if code != 1 {
-		t.Fatalf("expected exit code 1, got %d", code)
+func TestParseCompareArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    compareArgs
+		wantErr bool
+	}{
+		{
+			name: "namespace path and file",
+			args: []string{"ns", "app/db", "db.yaml"},
+			want: compareArgs{namespace: "ns", subPath: "app/db", file: "db.yaml"},
+		},
+		{
+			name: "qualified target and file",
+			args: []string{"ns:kv/app/db", "db.yaml"},
+			want: compareArgs{namespace: "ns", kvEngine: "kv", subPath: "app/db", file: "db.yaml"},
+		},
+		{
+			name: "ignore fields",
+			args: []string{"ns", "app/db", "db.yaml", "--ignore-fields=last_rotated, *_at"},
+			want: compareArgs{namespace: "ns", subPath: "app/db", file: "db.yaml", ignoreFields: []string{"last_rotated", "*_at"}},
+		},
+		{
+			name:    "missing file is an error",
+			args:    []string{"ns", "app/db"},
+			wantErr: true,
+		},
+		{
+			name:    "engine-only qualified target is an error",
+			args:    []string{"ns:kv", "db.yaml"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCompareArgs(tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("parseCompareArgs(%v) = %+v, want %+v", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunCompareUsageErrorExitsUsage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"compare", "ns"}, &stdout, &stderr)
+	if code != exitUsage {
+		t.Fatalf("expected exit code %d, got %d", exitUsage, code)
+	}
+	if !strings.Contains(stderr.String(), "compare <namespace> <path> <file>") {
+		t.Fatalf("expected compare usage, got %q", stderr.String())
+	}
+}
+
+func TestRunPullInvalidEnvSeparatorReportsReason(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"pull", "ns", "--format=env-combined", "--env-separator=-"}, &stdout, &stderr)
+	if code != exitUsage {
+		t.Fatalf("expected exit code %d, got %d", exitUsage, code)
+	}
+	if !strings.Contains(stderr.String(), "--env-separator may only contain letters, digits, and _") {
+		t.Fatalf("expected the separator error, got %q", stderr.String())
+	}
+}
+
+func TestRunLocalCommandsReportUsageErrors(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"verify"}, "exactly one directory is required"},
+		{[]string{"verify", "a", "b"}, "exactly one directory is required"},
+		{[]string{"lint", ".", "--max-value-size=lots"}, `invalid --max-value-size "lots"`},
+		{[]string{"redact", ".", "--force"}, "flag provided but not defined: -force"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := run(tt.args, &stdout, &stderr); code != exitUsage {
+			t.Fatalf("run(%v) exit code = %d, want %d", tt.args, code, exitUsage)
+		}
+		if !strings.Contains(stderr.String(), tt.want) {
+			t.Fatalf("run(%v) stderr = %q, want it to contain %q", tt.args, stderr.String(), tt.want)
+		}
+	}
+}
+
+func TestGlobalKVEngineFlagParsed(t *testing.T) {
+	// --kv-engine before the command should be consumed, leaving the command
+	// usage to fire (namespace missing) rather than an "unknown command".
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"--kv-engine=secrets", "list"}, &stdout, &stderr)
+	if code != exitUsage {
+		t.Fatalf("expected exit code %d, got %d", exitUsage, code)
 	}
 	if !strings.Contains(stderr.String(), "list <namespace>") {
 		t.Fatalf("expected list usage after global flag, got %q", stderr.String())
 	}
 }
+
+func TestGlobalShowIdentityFlagParsed(t *testing.T) {
+	t.Setenv("VAULT_ADDR", "")
+	t.Setenv("VAULT_TOKEN", "")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"--show-identity", "list", "ns"}, &stdout, &stderr)
+	if code != 1 {
+		t.Fatalf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "VAULT_ADDR") {
+		t.Fatalf("expected the command to run past flag parsing, got %q", stderr.String())
+	}
+}
+
+func TestRedactPatternFlagRejectsInvalidRegexp(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"--redact-pattern=^tok_", "--redact-pattern=(", "list", "ns"}, &stdout, &stderr)
+	if code != exitUsage {
+		t.Fatalf("expected exit code %d, got %d", exitUsage, code)
+	}
+	if !strings.Contains(stderr.String(), "--redact-pattern: error parsing regexp") {
+		t.Fatalf("unexpected stderr: %q", stderr.String())
+	}
+}
+
+func TestMasksValuesDefaultsToNonTerminal(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	tests := []struct {
+		name   string
+		global globalOptions
+		want   bool
+	}{
+		{name: "non-terminal default", global: globalOptions{}, want: true},
+		{name: "show override", global: globalOptions{showValues: true}, want: false},
+		{name: "explicit mask", global: globalOptions{maskValues: true}, want: true},
+		{name: "redact patterns", global: globalOptions{redactPatterns: []*regexp.Regexp{regexp.MustCompile("x")}}, want: true},
+		{name: "show with redact patterns", global: globalOptions{showValues: true, redactPatterns: []*regexp.Regexp{regexp.MustCompile("x")}}, want: false},
+		{name: "explicit mask with redact patterns", global: globalOptions{maskValues: true, redactPatterns: []*regexp.Regexp{regexp.MustCompile("x")}}, want: true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.global.masksValues(&buf); got != tt.want {
+			t.Fatalf("%s: expected masksValues %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestMaskAndShowValuesAreMutuallyExclusive(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"--mask-values", "--show-values", "list", "ns"}, &stdout, &stderr)
+	if code != exitUsage {
+		t.Fatalf("expected exit code %d, got %d", exitUsage, code)
+	}
+	if !strings.Contains(stderr.String(), "mutually exclusive") {
+		t.Fatalf("expected conflict error, got %q", stderr.String())
+	}
+}
+
+func TestGlobalOpTimeoutRejectsInvalidDuration(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"--op-timeout=soon", "list", "ns"}, &stdout, &stderr)
+	if code != exitUsage {
+		t.Fatalf("expected exit code %d, got %d", exitUsage, code)
+	}
+}
+
+func TestParseReadArgs(t *testing.T) {
+	t.Parallel()
+
+	got, err := parseReadArgs([]string{"ns", "database/creds/readonly", "--format=json"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := readArgs{namespace: "ns", path: "database/creds/readonly", format: "json"}
+	if got != want {
+		t.Fatalf("parseReadArgs = %+v, want %+v", got, want)
+	}
+
+	if _, err := parseReadArgs([]string{"ns"}); err == nil {
+		t.Fatal("expected error when path is missing")
+	}
+	if _, err := parseReadArgs([]string{"ns", "sys/mounts", "--format=xml"}); err == nil {
+		t.Fatal("expected error for unknown format")
+	}
+}
+
+func TestParseWriteArgs(t *testing.T) {
+	t.Parallel()
+
+	got, err := parseWriteArgs([]string{"ns", "database/roles/ro", "db_name=postgres", "sql=a=b"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := writeArgs{
+		namespace: "ns",
+		path:      "database/roles/ro",
+		data:      map[string]interface{}{"db_name": "postgres", "sql": "a=b"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseWriteArgs = %+v, want %+v", got, want)
+	}
+
+	got, err = parseWriteArgs([]string{"ns", "sys/policy/x", "-"})
+	if err != nil || !got.fromStdin {
+		t.Fatalf("expected stdin mode, got %+v, %v", got, err)
+	}
+
+	if _, err := parseWriteArgs([]string{"ns", "sys/policy/x", "novalue"}); err == nil {
+		t.Fatal("expected error for argument without '='")
+	}
+	if _, err := parseWriteArgs([]string{"ns", "sys/policy/x"}); err == nil {
+		t.Fatal("expected error when no data is given")
+	}
+}
+
+func TestWriteRejectsInvalidStdinJSON(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := cmdWrite(globalOptions{}, []string{"ns", "sys/x", "-"}, strings.NewReader("not json"), &stdout, &stderr)
+	if code != 1 {
+		t.Fatalf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "Failed to parse JSON from stdin") {
+		t.Fatalf("expected JSON error, got %q", stderr.String())
+	}
+}
+
+func TestGlobalKVVersionRejectsUnknownVersion(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"--kv-version=3", "list", "ns"}, &stdout, &stderr)
+	if code != exitUsage {
+		t.Fatalf("expected exit code %d, got %d", exitUsage, code)
+	}
+	if !strings.Contains(stderr.String(), "--kv-version") {
+		t.Fatalf("expected kv-version error, got %q", stderr.String())
+	}
+}
+
+func TestParseMoveArgs(t *testing.T) {
+	t.Parallel()
+
+	got, err := parseMoveArgs([]string{"ns", "old/app", "new/app", "--dry-run", "--delete-source"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := moveArgs{namespace: "ns", src: "old/app", dst: "new/app", dryRun: true, deleteSource: true}
+	if got != want {
+		t.Fatalf("parseMoveArgs = %+v, want %+v", got, want)
+	}
+
+	if _, err := parseMoveArgs([]string{"ns", "old/app"}); err == nil {
+		t.Fatal("expected error when destination is missing")
+	}
+}
+
+func TestParseMoveArgsQualifiedTargets(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    moveArgs
+		wantErr bool
+	}{
+		{
+			name: "both qualified",
+			args: []string{"ns:kv/old/app", "ns:kv2/app"},
+			want: moveArgs{namespace: "ns", srcEngine: "kv", src: "old/app", dstEngine: "kv2", dst: "app"},
+		},
+		{
+			name: "namespace with qualified destination",
+			args: []string{"ns", "old/app", "ns:kv2/app"},
+			want: moveArgs{namespace: "ns", src: "old/app", dstEngine: "kv2", dst: "app"},
+		},
+		{
+			name:    "other namespace",
+			args:    []string{"ns:kv/old/app", "other:kv/app"},
+			wantErr: true,
+		},
+		{
+			name:    "namespace argument mismatch",
+			args:    []string{"ns", "other:kv/old/app", "new/app"},
+			wantErr: true,
+		},
+		{
+			name:    "two unqualified paths",
+			args:    []string{"old/app", "new/app"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseMoveArgs(tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("parseMoveArgs = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAuditArgs(t *testing.T) {
+	t.Parallel()
+
+	got, err := parseAuditArgs([]string{"ns", "app"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := (auditArgs{namespace: "ns", subPath: "app"}); got != want {
+		t.Fatalf("parseAuditArgs = %+v, want %+v", got, want)
+	}
+
+	got, err = parseAuditArgs([]string{"ns:secrets/app"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := (auditArgs{namespace: "ns", kvEngine: "secrets", subPath: "app"}); got != want {
+		t.Fatalf("parseAuditArgs = %+v, want %+v", got, want)
+	}
+
+	if _, err := parseAuditArgs(nil); err == nil {
+		t.Fatal("expected error when namespace is missing")
+	}
+}
+
+func TestReadPathListSkipsBlankAndCommentLines(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "paths.txt")
+	if err := os.WriteFile(file, []byte("# prod secrets\napp/db\n\n  app/api  \n"), 0600); err != nil {
+		t.Fatalf("failed to write path list: %v", err)
+	}
+
+	paths, err := readPathList(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(paths, []string{"app/db", "app/api"}) {
+		t.Fatalf("unexpected paths %v", paths)
+	}
+}
+
+func TestTemplateExtension(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"app.env.tmpl":          ".env",
+		"templates/db.json.tpl": ".json",
+		"config.tmpl":           "",
+		"plain.conf":            ".conf",
+	}
+	for name, want := range tests {
+		if got := templateExtension(name); got != want {
+			t.Fatalf("templateExtension(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestRunRedactRewritesPulledFiles(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "db.yaml")
+	if err := os.WriteFile(file, []byte("password: hunter2\n"), 0600); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"redact", dir}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr %q)", code, stderr.String())
+	}
+
+	content, _ := os.ReadFile(file)
+	if strings.Contains(string(content), "hunter2") {
+		t.Fatalf("expected the value to be redacted, got %q", content)
+	}
+	if !strings.Contains(stdout.String(), "Redacted: "+file) {
+		t.Fatalf("expected the file to be reported, got %q", stdout.String())
+	}
+}
+
+func TestRunVerifyReportsTamperedFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "db.yaml")
+	if err := os.WriteFile(file, []byte("password: hunter2\n"), 0600); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+	if err := vaultsync.WriteManifest(dir); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"verify", dir}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr %q)", code, stderr.String())
+	}
+
+	if err := os.WriteFile(file, []byte("password: tampered\n"), 0600); err != nil {
+		t.Fatalf("failed to tamper with fixture: %v", err)
+	}
+	stdout.Reset()
+	if code := run([]string{"verify", dir}, &stdout, &stderr); code != exitDrift {
+		t.Fatalf("expected exit code %d, got %d", exitDrift, code)
+	}
+	if stdout.String() != "db.yaml: content does not match the manifest\n" {
+		t.Fatalf("unexpected output %q", stdout.String())
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]int{
+		"4096":   4096,
+		"512KiB": 512 << 10,
+		"2MB":    2 << 20,
+		"1M":     1 << 20,
+		"10B":    10,
+	}
+	for in, want := range tests {
+		got, err := parseByteSize(in)
+		if err != nil || got != want {
+			t.Fatalf("parseByteSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "-1", "MiB", "1GB"} {
+		if _, err := parseByteSize(in); err == nil {
+			t.Fatalf("expected error for %q", in)
+		}
+	}
+}
+
+func TestRunLintReportsProblemsAndFails(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "db.yaml"), []byte("password: pw\n"), 0600); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"lint", dir}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0 for a clean tree, got %d (stderr %q)", code, stderr.String())
+	}
+
+	empty := filepath.Join(dir, "empty.yaml")
+	if err := os.WriteFile(empty, nil, 0600); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	if code := run([]string{"lint", dir}, &stdout, &stderr); code != 1 {
+		t.Fatalf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stdout.String(), empty+": file is empty") {
+		t.Fatalf("expected the empty file to be reported, got %q", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "Found 1 problems") {
+		t.Fatalf("expected a summary on stderr, got %q", stderr.String())
+	}
+}
+
+func TestParsePatchArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    patchArgs
+		wantErr bool
+	}{
+		{
+			name: "namespace, path, and keys",
+			args: []string{"ns", "app/db", "password=new", "user=app", "--dry-run"},
+			want: patchArgs{namespace: "ns", path: "app/db", data: map[string]interface{}{"password": "new", "user": "app"}, dryRun: true},
+		},
+		{
+			name: "qualified target",
+			args: []string{"ns:secrets/app/db", "password=a=b"},
+			want: patchArgs{namespace: "ns", kvEngine: "secrets", path: "app/db", data: map[string]interface{}{"password": "a=b"}},
+		},
+		{
+			name: "empty value",
+			args: []string{"ns", "app/db", "token="},
+			want: patchArgs{namespace: "ns", path: "app/db", data: map[string]interface{}{"token": ""}},
+		},
+		{
+			name:    "no keys is an error",
+			args:    []string{"ns", "app/db"},
+			wantErr: true,
+		},
+		{
+			name:    "field without = is an error",
+			args:    []string{"ns", "app/db", "password"},
+			wantErr: true,
+		},
+		{
+			name:    "repeated key is an error",
+			args:    []string{"ns", "app/db", "a=1", "a=2"},
+			wantErr: true,
+		},
+		{
+			name:    "qualified target without path is an error",
+			args:    []string{"ns:kv", "a=1"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePatchArgs(tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("parsePatchArgs(%v) = %+v, want %+v", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunNegativeBatchSizeIsUsageError(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"--batch-size=-1", "list", "ns"}, &stdout, &stderr)
+	if code != exitUsage {
+		t.Fatalf("expected exit code %d, got %d", exitUsage, code)
+	}
+	if !strings.Contains(stderr.String(), "--batch-size") {
+		t.Fatalf("expected a --batch-size error, got %q", stderr.String())
+	}
+}
+
+func TestMaxSecretSizeDefaultsOnlyForPush(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("VAULT_ADDR", "https://vault.unused.example")
+	t.Setenv("VAULT_TOKEN", "token")
+
+	var stdout, stderr bytes.Buffer
+	client, err := newClient(globalOptions{}, "ns", &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("newClient: %v", err)
+	}
+	if client.PushOptions.MaxSecretSize != 0 {
+		t.Fatalf("expected other commands to write without a size limit, got %d", client.PushOptions.MaxSecretSize)
+	}
+
+	tests := []struct {
+		maxSize int
+		want    int
+	}{
+		{0, vaultsync.DefaultMaxSecretSize},
+		{2048, 2048},
+		{-1, 0},
+	}
+	for _, tt := range tests {
+		client, err := newPushClient(globalOptions{}, pushArgs{maxSize: tt.maxSize}, "ns", &stdout, &stderr)
+		if err != nil {
+			t.Fatalf("newPushClient: %v", err)
+		}
+		if client.PushOptions.MaxSecretSize != tt.want {
+			t.Fatalf("maxSize %d: MaxSecretSize = %d, want %d", tt.maxSize, client.PushOptions.MaxSecretSize, tt.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/kriipke/vaultsync"
+	"github.com/kriipke/vaultsync/s3store"
+)
+
+// openObjectStore opens the store named by an object storage URI and returns
+// it with the key prefix the URI points at. Only s3:// is supported so far;
+// S3 credentials and region are read from the environment as described at
+// s3store.NewFromEnv.
+func openObjectStore(uri string) (vaultsync.ObjectStore, string, error) {
+	scheme, bucket, prefix, err := vaultsync.ParseObjectStoreURI(uri)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch scheme {
+	case "s3":
+		store, err := s3store.NewFromEnv(bucket)
+		if err != nil {
+			return nil, "", err
+		}
+		return store, prefix, nil
+	default:
+		return nil, "", fmt.Errorf("%w: %s:// (supported: s3://)", vaultsync.ErrUnsupportedObjectStore, scheme)
+	}
+}
+
+// useObjectStorage points client at the object storage location dir, when
+// dir is one, and returns the directory to hand to the client: the key
+// prefix within the bucket. Local directories are returned as they are.
+func useObjectStorage(client *vaultsync.VaultClient, dir string) (string, error) {
+	if !vaultsync.IsObjectStoreURI(dir) {
+		return dir, nil
+	}
+	store, prefix, err := openObjectStore(dir)
+	if err != nil {
+		return "", err
+	}
+	client.Storage = vaultsync.NewObjectStorage(store)
+	return prefix, nil
+}
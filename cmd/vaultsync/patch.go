@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/kriipke/vaultsync"
+)
+
+// patchArgs holds the parsed positional arguments and flags for the patch
+// command.
+type patchArgs struct {
+	namespace string
+	kvEngine  string
+	path      string
+	data      map[string]interface{}
+	dryRun    bool
+}
+
+func parsePatchArgs(args []string) (patchArgs, error) {
+	var parsed patchArgs
+
+	fs := flag.NewFlagSet("patch", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.BoolVar(&parsed.dryRun, "dry-run", false, "Show the diff the patch would make without writing it")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return patchArgs{}, err
+	}
+	if len(positional) < 1 {
+		return patchArgs{}, fmt.Errorf("namespace is required")
+	}
+
+	namespace, kvEngine, subPath, qualified, err := parseQualifiedTarget(positional[0])
+	var fields []string
+	switch {
+	case err != nil:
+		return patchArgs{}, err
+	case qualified:
+		parsed.namespace, parsed.kvEngine, parsed.path = namespace, kvEngine, subPath
+		fields = positional[1:]
+	case len(positional) < 2:
+		return patchArgs{}, fmt.Errorf("secret path is required")
+	default:
+		parsed.namespace, parsed.path = positional[0], strings.Trim(positional[1], "/")
+		fields = positional[2:]
+	}
+	if parsed.path == "" {
+		return patchArgs{}, fmt.Errorf("secret path is required")
+	}
+	if len(fields) == 0 {
+		return patchArgs{}, fmt.Errorf("at least one key=value is required")
+	}
+
+	parsed.data = make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || key == "" {
+			return patchArgs{}, fmt.Errorf("expected key=value, got %q", field)
+		}
+		if _, dup := parsed.data[key]; dup {
+			return patchArgs{}, fmt.Errorf("key %q given more than once", key)
+		}
+		parsed.data[key] = value
+	}
+	return parsed, nil
+}
+
+// cmdPatch updates single keys of one secret in place, the fast path for
+// rotating a password without a pull, edit, and push.
+func cmdPatch(global globalOptions, args []string, stdout, stderr io.Writer) int {
+	parsed, err := parsePatchArgs(args)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		fmt.Fprintln(stderr, "Usage: vaultsync [--kv-engine=name] patch <namespace> <path> key=value... [--dry-run]")
+		return exitUsage
+	}
+
+	client, err := newClient(global, parsed.namespace, stdout, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitCodeFor(err)
+	}
+
+	ref := vaultsync.NewSecretRef(engineOr(parsed.kvEngine, global.kvEngine), parsed.path)
+	keys := make([]string, 0, len(parsed.data))
+	for key := range parsed.data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if parsed.dryRun {
+		fmt.Fprintf(stdout, "DRY RUN: showing the patch of %s in %s in namespace %s...\n",
+			strings.Join(keys, ", "), pathDesc(ref.Engine, ref.Path), parsed.namespace)
+	}
+	if err := client.PatchKeysAt(ref, parsed.data, parsed.dryRun); err != nil {
+		fmt.Fprintf(stderr, "Failed to patch %s: %v\n", pathDesc(ref.Engine, ref.Path), err)
+		return exitCodeFor(err)
+	}
+
+	if parsed.dryRun {
+		fmt.Fprintln(stdout, "Dry run completed! Use without --dry-run to apply the patch.")
+	} else {
+		fmt.Fprintf(stdout, "Completed! Updated %s in %s\n", strings.Join(keys, ", "), pathDesc(ref.Engine, ref.Path))
+	}
+	return exitOK
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/kriipke/vaultsync"
+	"gopkg.in/yaml.v3"
+)
+
+// errPushStopped ends an --interactive push when the user quits.
+var errPushStopped = errors.New("push stopped at the user's request")
+
+// pushReviewer asks, for each change of an --interactive push, whether to
+// apply it.
+type pushReviewer struct {
+	in  *bufio.Reader
+	out io.Writer
+	// edit opens filePath in the user's editor and waits for it to exit.
+	edit func(filePath string) error
+}
+
+func newPushReviewer(in io.Reader, out io.Writer) *pushReviewer {
+	return &pushReviewer{in: bufio.NewReader(in), out: out, edit: runEditor}
+}
+
+// review is a vaultsync.PushOptions.Review that prompts until the change is
+// applied, skipped, or the push is stopped.
+func (r *pushReviewer) review(change vaultsync.PushReview) (map[string]interface{}, bool, error) {
+	added, removed := diffStat(change.Diff)
+	for {
+		fmt.Fprintf(r.out, "%s (+%d -%d): [a]pply, [s]kip, [v]iew diff, [e]dit, [q]uit? ", change.Path, added, removed)
+		line, err := r.in.ReadString('\n')
+		if err != nil && line == "" {
+			fmt.Fprintln(r.out)
+			return nil, false, errPushStopped
+		}
+
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "a", "apply":
+			return change.Data, true, nil
+		case "s", "skip":
+			return nil, false, nil
+		case "v", "view":
+			fmt.Fprint(r.out, change.Diff)
+		case "e", "edit":
+			edited, err := r.editData(change.Data)
+			if err != nil {
+				fmt.Fprintf(r.out, "Edit failed: %v\n", err)
+				continue
+			}
+			return edited, true, nil
+		case "q", "quit":
+			return nil, false, errPushStopped
+		default:
+			fmt.Fprintln(r.out, "Please answer a, s, v, e, or q.")
+		}
+	}
+}
+
+// editData opens data as YAML in the user's editor and returns what was
+// saved. The temporary file is only readable by the user and is removed
+// afterwards.
+func (r *pushReviewer) editData(data map[string]interface{}) (map[string]interface{}, error) {
+	content, err := yaml.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp("", "vaultsync-edit-*.yaml")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.edit(f.Name()); err != nil {
+		return nil, err
+	}
+	content, err = os.ReadFile(f.Name())
+	if err != nil {
+		return nil, err
+	}
+	var edited map[string]interface{}
+	if err := yaml.Unmarshal(content, &edited); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+	if len(edited) == 0 {
+		return nil, errors.New("the edited secret has no keys")
+	}
+	return edited, nil
+}
+
+// runEditor opens filePath in $VISUAL or $EDITOR, falling back to vi.
+func runEditor(filePath string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	args := append(strings.Fields(editor), filePath)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor %s: %w", args[0], err)
+	}
+	return nil
+}
+
+// diffStat counts the lines a unified diff adds and removes.
+func diffStat(diff string) (added, removed int) {
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		case strings.HasPrefix(line, "+"):
+			added++
+		case strings.HasPrefix(line, "-"):
+			removed++
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kriipke/vaultsync"
+)
+
+func TestPushReviewer(t *testing.T) {
+	t.Parallel()
+
+	change := vaultsync.PushReview{
+		Path: "kv/app/db",
+		Diff: "--- a/kv/app/db\n+++ b/kv/app/db\n-username: alice\n+username: bob\n+password: secret\n",
+		Data: map[string]interface{}{"username": "bob", "password": "secret"},
+	}
+	edited := map[string]interface{}{"username": "carol"}
+
+	tests := []struct {
+		name      string
+		input     string
+		want      map[string]interface{}
+		wantApply bool
+		wantErr   error
+		wantOut   string
+	}{
+		{name: "apply", input: "a\n", want: change.Data, wantApply: true, wantOut: "kv/app/db (+2 -1): "},
+		{name: "skip", input: "s\n"},
+		{name: "view then apply", input: "v\nA\n", want: change.Data, wantApply: true, wantOut: "+password: secret\n"},
+		{name: "unknown answer asks again", input: "x\ns\n", wantOut: "Please answer a, s, v, e, or q."},
+		{name: "edit", input: "e\n", want: edited, wantApply: true},
+		{name: "quit", input: "q\n", wantErr: errPushStopped},
+		{name: "end of input", input: "", wantErr: errPushStopped},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var out bytes.Buffer
+			reviewer := &pushReviewer{
+				in:  bufio.NewReader(strings.NewReader(tt.input)),
+				out: &out,
+				edit: func(filePath string) error {
+					return os.WriteFile(filePath, []byte("username: carol\n"), 0600)
+				},
+			}
+			got, apply, err := reviewer.review(change)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if apply != tt.wantApply || !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, %v; want %v, %v", got, apply, tt.want, tt.wantApply)
+			}
+			if !strings.Contains(out.String(), tt.wantOut) {
+				t.Fatalf("output %q does not contain %q", out.String(), tt.wantOut)
+			}
+		})
+	}
+}
+
+func TestPushReviewerRejectsEmptyEdit(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	reviewer := &pushReviewer{
+		in:  bufio.NewReader(strings.NewReader("e\ns\n")),
+		out: &out,
+		edit: func(filePath string) error {
+			return os.WriteFile(filePath, nil, 0600)
+		},
+	}
+	_, apply, err := reviewer.review(vaultsync.PushReview{Path: "kv/app/db", Data: map[string]interface{}{"a": "b"}})
+	if err != nil || apply {
+		t.Fatalf("expected the change to be skipped after a failed edit, got apply=%v err=%v", apply, err)
+	}
+	if !strings.Contains(out.String(), "Edit failed: the edited secret has no keys") {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/kriipke/vaultsync"
+)
+
+// syncArgs holds the parsed positional arguments and flags for the sync
+// command.
+type syncArgs struct {
+	srcContext string
+	dstContext string
+	path       string
+	dryRun     bool
+}
+
+func parseSyncArgs(args []string) (syncArgs, error) {
+	var parsed syncArgs
+
+	fs := flag.NewFlagSet("sync", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.BoolVar(&parsed.dryRun, "dry-run", false, "Diff against the destination without changing it")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return syncArgs{}, err
+	}
+
+	if len(positional) != 3 {
+		return syncArgs{}, fmt.Errorf("source context, destination context, and path are required")
+	}
+	parsed.srcContext, parsed.dstContext, parsed.path = positional[0], positional[1], positional[2]
+	return parsed, nil
+}
+
+// cmdSync copies every secret below a path from the cluster of one context to
+// the same path in the cluster of another, without staging them on disk.
+func cmdSync(global globalOptions, args []string, stdout, stderr io.Writer) int {
+	parsed, err := parseSyncArgs(args)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		fmt.Fprintln(stderr, "Usage: vaultsync sync <src-context> <dst-context> <[namespace:engine/]path> [--dry-run]")
+		return exitUsage
+	}
+	if parsed.srcContext == parsed.dstContext {
+		fmt.Fprintln(stderr, "sync: source and destination contexts must differ")
+		return exitUsage
+	}
+	if global.writeAddr != "" {
+		fmt.Fprintln(stderr, "--write-addr cannot be used with sync: each context names its own cluster")
+		return exitUsage
+	}
+
+	namespace, engine, subPath, qualified, err := parseQualifiedTarget(parsed.path)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitUsage
+	}
+	if !qualified {
+		subPath = parsed.path
+	}
+
+	contextsPath, err := vaultsync.DefaultContextsPath()
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitCodeFor(err)
+	}
+	file, err := vaultsync.LoadContexts(contextsPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitCodeFor(err)
+	}
+
+	src, srcRef, err := newContextClient(global, file, parsed.srcContext, namespace, engine, subPath, stdout, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", parsed.srcContext, err)
+		return exitCodeFor(err)
+	}
+	dst, dstRef, err := newContextClient(global, file, parsed.dstContext, namespace, engine, subPath, stdout, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", parsed.dstContext, err)
+		return exitCodeFor(err)
+	}
+
+	if parsed.dryRun {
+		fmt.Fprintf(stdout, "DRY RUN: showing changes to %s in %s from %s in %s...\n",
+			pathDesc(dstRef.Engine, dstRef.Path), parsed.dstContext, pathDesc(srcRef.Engine, srcRef.Path), parsed.srcContext)
+	}
+	if err := src.SyncSecretsTo(dst, srcRef, dstRef, parsed.dryRun); err != nil {
+		fmt.Fprintf(stderr, "Sync failed: %v\n", err)
+		return exitCodeFor(err)
+	}
+
+	if parsed.dryRun {
+		fmt.Fprintln(stdout, "Dry run completed! Use without --dry-run to actually sync secrets.")
+	} else {
+		fmt.Fprintln(stdout, "Completed! Secrets have been synced.")
+	}
+	return exitOK
+}
+
+// newContextClient returns a client for the cluster of the context called
+// name, with the context's address and token taking precedence over
+// VAULT_ADDR and VAULT_TOKEN, and the ref of subPath in the given engine, or
+// else in the context's engine.
+func newContextClient(global globalOptions, file *vaultsync.ContextsFile, name, namespace, engine, subPath string, stdout, stderr io.Writer) (*vaultsync.VaultClient, vaultsync.SecretRef, error) {
+	ctx, err := file.Context(name)
+	if err != nil {
+		return nil, vaultsync.SecretRef{}, err
+	}
+	if ctx.Address == "" {
+		return nil, vaultsync.SecretRef{}, errors.New("context has no address")
+	}
+
+	// Drop what applyContext took from the current context.
+	if !global.setFlags["kv-engine"] {
+		global.kvEngine = vaultsync.DefaultKVEngine
+	}
+	if !global.setFlags["reauth"] {
+		global.reauth = ""
+		if !global.setFlags["auth-mount"] {
+			global.authMount = ""
+		}
+	}
+	useContext(&global, ctx, global.setFlags)
+	global.pinContext = true
+
+	client, err := newClient(global, namespace, stdout, stderr)
+	if err != nil {
+		return nil, vaultsync.SecretRef{}, err
+	}
+	return client, vaultsync.NewSecretRef(engineOr(engine, global.kvEngine), subPath), nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/kriipke/vaultsync"
+)
+
+// newTable returns a tabwriter laid out like every table the CLI prints:
+// upper-case headers, columns separated by at least two spaces.
+func newTable(w io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+}
+
+// wantsTable reports whether format asks for a table that can be shown on
+// stdout. Tables are for people at a terminal; elsewhere the command falls
+// back to its plain output so scripts and logs are unaffected.
+func wantsTable(format string, stdout io.Writer) bool {
+	return format == "table" && isTerminal(stdout)
+}
+
+// resultTable collects the per-secret results of a pull or push and prints
+// them as one table instead of the usual line per secret.
+type resultTable struct {
+	results []vaultsync.SecretResult
+}
+
+// attach routes client's results into the table and silences its per-secret
+// output, which the table replaces. Warnings still go to stderr. A non-empty
+// namespace is prefixed to each path, for pushes that span namespaces.
+func (t *resultTable) attach(client *vaultsync.VaultClient, namespace string) {
+	client.Output = nil
+	client.OnResult = func(result vaultsync.SecretResult) {
+		if namespace != "" && result.Path != "" {
+			result.Path = namespace + ":" + result.Path
+		}
+		t.results = append(t.results, result)
+	}
+}
+
+// print writes the PATH, STATUS, VERSION, and SIZE columns shared by the pull
+// and push tables. Files deleted by a mirroring pull show their local path.
+func (t *resultTable) print(w io.Writer) {
+	if len(t.results) == 0 {
+		fmt.Fprintln(w, "No secrets processed")
+		return
+	}
+
+	tw := newTable(w)
+	fmt.Fprintln(tw, "PATH\tSTATUS\tVERSION\tSIZE")
+	for _, result := range t.results {
+		version, size := "-", "-"
+		if result.Version > 0 {
+			version = fmt.Sprintf("v%d", result.Version)
+		}
+		if result.Size > 0 {
+			size = formatByteSize(result.Size)
+		}
+		secretPath := result.Path
+		if secretPath == "" {
+			secretPath = result.File
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", secretPath, result.Action, version, size)
+	}
+	tw.Flush()
+}
+
+// formatByteSize renders n bytes in the binary units --max-secret-size uses.
+func formatByteSize(n int) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fMiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fKiB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%dB", n)
+	}
+}
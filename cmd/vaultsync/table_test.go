@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kriipke/vaultsync"
+)
+
+func TestResultTablePrintsAlignedColumns(t *testing.T) {
+	t.Parallel()
+
+	client := vaultsync.NewVaultClient("https://vault.example", "token", "team-a")
+	table := &resultTable{}
+	table.attach(client, "team-a")
+	if client.Output != nil {
+		t.Fatal("expected attach to silence per-secret output")
+	}
+
+	for _, result := range []vaultsync.SecretResult{
+		{Path: "kv/app/db", Action: "pushed", Version: 4, Size: 1536},
+		{Path: "kv/app/api-key", Action: "unchanged", Version: 2, Size: 20},
+		{Path: "kv/app/legacy", Action: "skipped"},
+		{Action: "deleted", File: "secrets/app/old.yaml"},
+	} {
+		client.OnResult(result)
+	}
+
+	var out strings.Builder
+	table.print(&out)
+
+	want := "" +
+		"PATH                   STATUS     VERSION  SIZE\n" +
+		"team-a:kv/app/db       pushed     v4       1.5KiB\n" +
+		"team-a:kv/app/api-key  unchanged  v2       20B\n" +
+		"team-a:kv/app/legacy   skipped    -        -\n" +
+		"secrets/app/old.yaml   deleted    -        -\n"
+	if out.String() != want {
+		t.Fatalf("unexpected table:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestResultTableWithoutResults(t *testing.T) {
+	t.Parallel()
+
+	var out strings.Builder
+	(&resultTable{}).print(&out)
+	if out.String() != "No secrets processed\n" {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+}
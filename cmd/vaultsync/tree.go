@@ -0,0 +1,144 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/kriipke/vaultsync"
+)
+
+// treeArgs holds the parsed positional arguments and flags for the tree
+// command.
+type treeArgs struct {
+	namespace string
+	kvEngine  string
+	subPath   string
+	format    string
+}
+
+func parseTreeArgs(args []string) (treeArgs, error) {
+	parsed := treeArgs{format: "human"}
+
+	fs := flag.NewFlagSet("tree", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&parsed.format, "format", parsed.format, "Output format: human or json")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return treeArgs{}, err
+	}
+	if len(positional) < 1 || len(positional) > 2 {
+		return treeArgs{}, fmt.Errorf("namespace and optional path are required")
+	}
+
+	namespace, kvEngine, subPath, qualified, err := parseQualifiedTarget(positional[0])
+	switch {
+	case err != nil:
+		return treeArgs{}, err
+	case qualified:
+		if len(positional) > 1 {
+			return treeArgs{}, fmt.Errorf("unexpected argument %q after qualified target", positional[1])
+		}
+		parsed.namespace, parsed.kvEngine, parsed.subPath = namespace, kvEngine, subPath
+	default:
+		parsed.namespace = positional[0]
+		if len(positional) > 1 {
+			parsed.subPath = strings.Trim(positional[1], "/")
+		}
+	}
+
+	if parsed.format != "human" && parsed.format != "json" {
+		return treeArgs{}, fmt.Errorf("--format must be human or json")
+	}
+	return parsed, nil
+}
+
+// treeEntry is one secret of the tree command's JSON output.
+type treeEntry struct {
+	Path       string   `json:"path"`
+	Keys       []string `json:"keys"`
+	Unreadable bool     `json:"unreadable,omitempty"`
+}
+
+// cmdTree prints the hierarchy below a path with the key names of every
+// secret, read from the subkeys endpoint so that no value is ever fetched.
+func cmdTree(global globalOptions, args []string, stdout, stderr io.Writer) int {
+	parsed, err := parseTreeArgs(args)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		fmt.Fprintln(stderr, "Usage: vaultsync [--kv-engine=name] tree <namespace> [path] [--format=human|json]")
+		return exitUsage
+	}
+
+	client, err := newClient(global, parsed.namespace, stdout, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitCodeFor(err)
+	}
+
+	kvEngine := engineOr(parsed.kvEngine, global.kvEngine)
+	tree, err := client.SecretTreeAt(vaultsync.NewSecretRef(kvEngine, parsed.subPath))
+	if tree == nil && err != nil {
+		fmt.Fprintf(stderr, "Failed to read the tree: %v\n", err)
+		return exitCodeFor(err)
+	}
+
+	if parsed.format == "json" {
+		entries := make([]treeEntry, 0, len(tree))
+		for _, secret := range tree {
+			keys := secret.Keys
+			if keys == nil {
+				keys = []string{}
+			}
+			entries = append(entries, treeEntry{Path: secret.Path, Keys: keys, Unreadable: secret.Unreadable})
+		}
+		out, jsonErr := marshalJSON(entries, global.compactJSON)
+		if jsonErr != nil {
+			fmt.Fprintf(stderr, "Failed to format tree: %v\n", jsonErr)
+			return exitCodeFor(jsonErr)
+		}
+		fmt.Fprintln(stdout, string(out))
+	} else if len(tree) == 0 {
+		fmt.Fprintln(stdout, "No secrets found under the specified path")
+	} else {
+		fmt.Fprintf(stdout, "Tree of %s in namespace %s:\n", pathDesc(kvEngine, parsed.subPath), parsed.namespace)
+		printTree(stdout, pathDesc(kvEngine, parsed.subPath), tree)
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to read the keys of some secrets: %v\n", err)
+		return exitCodeFor(err)
+	}
+	return exitOK
+}
+
+// printTree prints tree, which is in walk order, as an indented hierarchy
+// relative to base: each folder once, as "name/", and each secret as
+// "name: key, key".
+func printTree(w io.Writer, base string, tree []vaultsync.SecretKeys) {
+	var folders []string
+	for _, secret := range tree {
+		parts := strings.Split(strings.TrimPrefix(secret.Path, base+"/"), "/")
+		dirs, name := parts[:len(parts)-1], parts[len(parts)-1]
+
+		common := 0
+		for common < len(dirs) && common < len(folders) && dirs[common] == folders[common] {
+			common++
+		}
+		for depth := common; depth < len(dirs); depth++ {
+			fmt.Fprintf(w, "%s%s/\n", strings.Repeat("  ", depth+1), dirs[depth])
+		}
+		folders = dirs
+
+		keys := strings.Join(secret.Keys, ", ")
+		switch {
+		case secret.Unreadable:
+			keys = "(keys unreadable)"
+		case len(secret.Keys) == 0:
+			keys = "(no keys)"
+		}
+		fmt.Fprintf(w, "%s%s: %s\n", strings.Repeat("  ", len(dirs)+1), name, keys)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTreeShowsHierarchyAndKeys(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var data map[string]any
+		switch r.URL.Path {
+		case "/v1/kv/metadata/app":
+			data = map[string]any{"keys": []string{"api", "db/"}}
+		case "/v1/kv/metadata/app/db":
+			data = map[string]any{"keys": []string{"primary", "replicas/"}}
+		case "/v1/kv/metadata/app/db/replicas":
+			data = map[string]any{"keys": []string{"east"}}
+		case "/v1/kv/subkeys/app/api":
+			data = map[string]any{"subkeys": map[string]any{"token": nil}}
+		case "/v1/kv/subkeys/app/db/primary":
+			data = map[string]any{"subkeys": map[string]any{"user": nil, "password": nil}}
+		case "/v1/kv/subkeys/app/db/replicas/east":
+			data = map[string]any{"subkeys": map[string]any{}}
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "token")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"tree", "ns", "app"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("expected exit code %d, got %d (stderr %q)", exitOK, code, stderr.String())
+	}
+	want := strings.Join([]string{
+		"Tree of kv/app in namespace ns:",
+		"  api: token",
+		"  db/",
+		"    primary: password, user",
+		"    replicas/",
+		"      east: (no keys)",
+		"",
+	}, "\n")
+	if stdout.String() != want {
+		t.Fatalf("expected output %q, got %q", want, stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"--compact-json", "tree", "ns", "app", "--format=json"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("expected exit code %d, got %d (stderr %q)", exitOK, code, stderr.String())
+	}
+	wantJSON := `[{"path":"kv/app/api","keys":["token"]},{"path":"kv/app/db/primary","keys":["password","user"]},{"path":"kv/app/db/replicas/east","keys":[]}]` + "\n"
+	if stdout.String() != wantJSON {
+		t.Fatalf("expected output %q, got %q", wantJSON, stdout.String())
+	}
+}
+
+func TestParseTreeArgs(t *testing.T) {
+	t.Parallel()
+
+	parsed, err := parseTreeArgs([]string{"ns", "/app/db/", "--format", "json"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if parsed.namespace != "ns" || parsed.subPath != "app/db" || parsed.format != "json" {
+		t.Fatalf("unexpected parse result: %+v", parsed)
+	}
+
+	for _, args := range [][]string{{}, {"ns", "app", "extra"}, {"ns", "--format=table"}} {
+		if _, err := parseTreeArgs(args); err == nil {
+			t.Fatalf("expected an error for %q", args)
+		}
+	}
+}
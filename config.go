@@ -18,6 +18,40 @@ type SyncTarget struct {
 type VaultSyncConfig struct {
 	RootDir string       `yaml:"root_dir"`
 	Syncs   []SyncTarget `yaml:"syncs"`
+
+	// BaseNamespace, when set, is the parent namespace each sync target's
+	// namespace is relative to: with base_namespace "org", a target in
+	// namespace "team-a/dev" syncs with the child namespace "org/team-a/dev".
+	BaseNamespace string `yaml:"base_namespace"`
+
+	// ParallelNamespaces bounds how many sync targets RunPullAll/RunPushAll
+	// process at once. Zero or one runs them one after another.
+	ParallelNamespaces int `yaml:"parallel_namespaces"`
+
+	// KVVersion is the KV engine version (1 or 2) assumed for every sync
+	// target, and KVVersions overrides it for Vault paths under a given
+	// prefix ("engine" or "engine/sub/path"); the longest matching prefix
+	// wins. Zero leaves the client's own setting, which defaults to KV v2.
+	KVVersion  int            `yaml:"kv_version"`
+	KVVersions map[string]int `yaml:"kv_versions"`
+}
+
+// KVVersionFor returns the KV engine version configured for vaultPath, given
+// as "engine/sub/path", or 0 when the config does not say.
+func (c *VaultSyncConfig) KVVersionFor(vaultPath string) int {
+	vaultPath = strings.Trim(vaultPath, "/")
+
+	version, matched := c.KVVersion, -1
+	for prefix, prefixVersion := range c.KVVersions {
+		prefix = strings.Trim(prefix, "/")
+		if vaultPath != prefix && !strings.HasPrefix(vaultPath, prefix+"/") {
+			continue
+		}
+		if len(prefix) > matched {
+			version, matched = prefixVersion, len(prefix)
+		}
+	}
+	return version
 }
 
 const secretsDirName = "secrets"
@@ -52,6 +86,12 @@ func LoadVaultSyncConfig() (*VaultSyncConfig, error) {
 		return nil, fmt.Errorf("%s does not contain any sync entries", configPath)
 	}
 
+	if config.ParallelNamespaces > 1 {
+		if err := validateDisjointLocalPaths(config.Syncs); err != nil {
+			return nil, err
+		}
+	}
+
 	return config, nil
 }
 
@@ -79,6 +119,21 @@ func parseVaultSyncConfig(data []byte) (*VaultSyncConfig, error) {
 }
 
 func normalizeAndValidateConfig(config *VaultSyncConfig) error {
+	if config.ParallelNamespaces < 0 {
+		return fmt.Errorf("parallel_namespaces must not be negative, got %d", config.ParallelNamespaces)
+	}
+
+	if err := validateKVVersion(config.KVVersion); err != nil {
+		return fmt.Errorf("kv_version: %w", err)
+	}
+	for prefix, version := range config.KVVersions {
+		if err := validateKVVersion(version); err != nil {
+			return fmt.Errorf("kv_versions[%q]: %w", prefix, err)
+		}
+	}
+
+	config.BaseNamespace = NormalizeNamespace(config.BaseNamespace)
+
 	config.RootDir = strings.TrimSpace(config.RootDir)
 	if config.RootDir == "" {
 		return nil
@@ -97,8 +152,31 @@ func normalizeAndValidateConfig(config *VaultSyncConfig) error {
 	return nil
 }
 
+// validateDisjointLocalPaths checks that no two sync targets share a
+// local_path or have one nested in the other, as targets run concurrently
+// with parallel_namespaces would otherwise write the same files at once.
+func validateDisjointLocalPaths(syncs []SyncTarget) error {
+	for i := range syncs {
+		for j := i + 1; j < len(syncs); j++ {
+			a, b := filepath.Clean(syncs[i].LocalPath), filepath.Clean(syncs[j].LocalPath)
+			if filePathWithin(a, b) || filePathWithin(b, a) {
+				return fmt.Errorf("sync entries %d and %d write to overlapping local paths %s and %s; give each its own directory to use parallel_namespaces",
+					i+1, j+1, syncs[i].LocalPath, syncs[j].LocalPath)
+			}
+		}
+	}
+	return nil
+}
+
+func validateKVVersion(version int) error {
+	if version != 0 && version != 1 && version != 2 {
+		return fmt.Errorf("must be 1 or 2, got %d", version)
+	}
+	return nil
+}
+
 func normalizeAndValidateSyncTarget(sync *SyncTarget, rootDir string) error {
-	sync.Namespace = strings.TrimSpace(sync.Namespace)
+	sync.Namespace = NormalizeNamespace(sync.Namespace)
 	sync.VaultPath = strings.Trim(strings.TrimSpace(sync.VaultPath), "/")
 	sync.LocalPath = strings.TrimSpace(sync.LocalPath)
 
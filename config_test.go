@@ -118,6 +118,46 @@ func TestNormalizeAndValidateConfigRootDir(t *testing.T) {
 	}
 }
 
+func TestNormalizeAndValidateConfigRejectsNegativeParallelism(t *testing.T) {
+	t.Parallel()
+
+	config := &VaultSyncConfig{ParallelNamespaces: -1}
+	if err := normalizeAndValidateConfig(config); err == nil {
+		t.Fatal("expected error for negative parallel_namespaces, got nil")
+	}
+}
+
+func TestValidateDisjointLocalPaths(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		paths   []string
+		wantErr bool
+	}{
+		{name: "separate directories", paths: []string{"/srv/dev", "/srv/qa", "/srv/dev-2"}},
+		{name: "same directory", paths: []string{"/srv/dev", "/srv/qa", "/srv/dev/"}, wantErr: true},
+		{name: "nested directory", paths: []string{"/srv/dev", "/srv/dev/app"}, wantErr: true},
+		{name: "parent directory", paths: []string{"/srv/dev/app", "/srv/./dev"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var syncs []SyncTarget
+			for _, path := range tt.paths {
+				syncs = append(syncs, SyncTarget{Namespace: "team-a", LocalPath: path})
+			}
+			err := validateDisjointLocalPaths(syncs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateDisjointLocalPaths(%v) error = %v, wantErr %v", tt.paths, err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestNormalizeAndValidateSyncTarget(t *testing.T) {
 	t.Parallel()
 
@@ -264,3 +304,40 @@ func TestDefaultConfigPath(t *testing.T) {
 		t.Fatalf("unexpected config path suffix: %q", got)
 	}
 }
+
+func TestKVVersionForPicksLongestPrefix(t *testing.T) {
+	t.Parallel()
+
+	config := &VaultSyncConfig{
+		KVVersion: 2,
+		KVVersions: map[string]int{
+			"legacy":         1,
+			"kv/old-app":     1,
+			"kv/old-app/new": 2,
+		},
+	}
+
+	tests := map[string]int{
+		"legacy/app":         1,
+		"kv/old-app":         1,
+		"kv/old-app/db":      1,
+		"kv/old-app/new/db":  2,
+		"kv/old-application": 2,
+		"kv/other":           2,
+		"legacyish/app":      2,
+	}
+	for path, want := range tests {
+		if got := config.KVVersionFor(path); got != want {
+			t.Errorf("KVVersionFor(%q) = %d, want %d", path, got, want)
+		}
+	}
+}
+
+func TestNormalizeAndValidateConfigRejectsUnknownKVVersion(t *testing.T) {
+	t.Parallel()
+
+	config := &VaultSyncConfig{KVVersions: map[string]int{"legacy": 3}}
+	if err := normalizeAndValidateConfig(config); err == nil {
+		t.Fatal("expected error for kv version 3, got nil")
+	}
+}
@@ -0,0 +1,169 @@
+package vaultsync
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrContextNotFound is returned when a context name is not defined in the
+// contexts file.
+var ErrContextNotFound = errors.New("context not found")
+
+// VaultContext is a named Vault cluster to run commands against, like a
+// kubectl context. Empty fields leave the corresponding setting to the
+// environment and flags.
+type VaultContext struct {
+	Name    string `yaml:"name"`
+	Address string `yaml:"address"`
+	// Namespace is the parent namespace the namespace given to a command is
+	// relative to, as with VaultSyncConfig.BaseNamespace.
+	Namespace string `yaml:"namespace"`
+	// Engine is the KV mount used when --kv-engine is not given.
+	Engine string `yaml:"engine"`
+	// Token is used when VAULT_TOKEN is not set. Prefer Auth, which keeps
+	// long-lived credentials out of the file.
+	Token string      `yaml:"token"`
+	Auth  ContextAuth `yaml:"auth"`
+}
+
+// ContextAuth names the auth method ("approle" or "kubernetes") a context
+// logs in with, as --reauth and --auth-mount do. Its credentials still come
+// from the environment (see AuthFromEnv).
+type ContextAuth struct {
+	Method string `yaml:"method"`
+	Mount  string `yaml:"mount"`
+}
+
+// ContextsFile is the contents of the contexts file: the contexts, and the
+// one selected by `context use` when no --context is given.
+type ContextsFile struct {
+	Current  string         `yaml:"current"`
+	Contexts []VaultContext `yaml:"contexts"`
+}
+
+// DefaultContextsPath returns the contexts file, ~/.vaultsync/contexts.yaml.
+func DefaultContextsPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine home directory: %w", err)
+	}
+
+	return filepath.Join(home, ".vaultsync", "contexts.yaml"), nil
+}
+
+// LoadContexts reads and validates the contexts file at filePath. A missing
+// file is reported with an error wrapping os.ErrNotExist.
+func LoadContexts(filePath string) (*ContextsFile, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+
+	var file ContextsFile
+	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filePath, err)
+	}
+
+	seen := make(map[string]bool)
+	for i := range file.Contexts {
+		if err := normalizeAndValidateContext(&file.Contexts[i]); err != nil {
+			return nil, fmt.Errorf("invalid context %d in %s: %w", i+1, filePath, err)
+		}
+		name := file.Contexts[i].Name
+		if seen[name] {
+			return nil, fmt.Errorf("%s defines context %q more than once", filePath, name)
+		}
+		seen[name] = true
+	}
+	file.Current = strings.TrimSpace(file.Current)
+
+	return &file, nil
+}
+
+func normalizeAndValidateContext(ctx *VaultContext) error {
+	ctx.Name = strings.TrimSpace(ctx.Name)
+	ctx.Address = strings.TrimSuffix(strings.TrimSpace(ctx.Address), "/")
+	ctx.Namespace = NormalizeNamespace(ctx.Namespace)
+	ctx.Engine = strings.Trim(strings.TrimSpace(ctx.Engine), "/")
+
+	if ctx.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	switch ctx.Auth.Method {
+	case "", "approle", "kubernetes":
+	default:
+		return fmt.Errorf("context %q: auth method must be approle or kubernetes, got %q", ctx.Name, ctx.Auth.Method)
+	}
+	return nil
+}
+
+// Context returns the context called name.
+func (f *ContextsFile) Context(name string) (*VaultContext, error) {
+	for i := range f.Contexts {
+		if f.Contexts[i].Name == name {
+			return &f.Contexts[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrContextNotFound, name)
+}
+
+// SetCurrentContext makes name the current context of the contexts file at
+// filePath. Only the top-level "current" key is rewritten; the contexts and
+// their comments are kept.
+func SetCurrentContext(filePath, name string) error {
+	file, err := LoadContexts(filePath)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Context(name); err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", filePath, err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("%s is not a mapping", filePath)
+	}
+
+	root := doc.Content[0]
+	updated := false
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "current" {
+			root.Content[i+1] = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name}
+			updated = true
+		}
+	}
+	if !updated {
+		root.Content = append([]*yaml.Node{
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: "current"},
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: name},
+		}, root.Content...)
+	}
+
+	out, err := yaml.Marshal(&doc)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", filePath, err)
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", filePath, err)
+	}
+	if err := os.WriteFile(filePath, out, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filePath, err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package vaultsync
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeContextsFile(t *testing.T, content string) string {
+	t.Helper()
+
+	filePath := filepath.Join(t.TempDir(), "contexts.yaml")
+	if err := os.WriteFile(filePath, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write contexts file: %v", err)
+	}
+	return filePath
+}
+
+func TestLoadContexts(t *testing.T) {
+	t.Parallel()
+
+	filePath := writeContextsFile(t, `current: dev
+contexts:
+  - name: dev
+    address: https://vault.dev.example/
+    namespace: /org/dev/
+    engine: kv/
+  - name: prod
+    address: https://vault.prod.example
+    auth:
+      method: approle
+      mount: ci-approle
+`)
+
+	file, err := LoadContexts(filePath)
+	if err != nil {
+		t.Fatalf("LoadContexts() error = %v", err)
+	}
+	if file.Current != "dev" || len(file.Contexts) != 2 {
+		t.Fatalf("unexpected contexts file: %+v", file)
+	}
+
+	dev, err := file.Context("dev")
+	if err != nil {
+		t.Fatalf("Context(dev) error = %v", err)
+	}
+	if dev.Address != "https://vault.dev.example" || dev.Namespace != "org/dev" || dev.Engine != "kv" {
+		t.Fatalf("expected normalized dev context, got %+v", dev)
+	}
+	prod, _ := file.Context("prod")
+	if prod.Auth != (ContextAuth{Method: "approle", Mount: "ci-approle"}) {
+		t.Fatalf("unexpected prod auth: %+v", prod.Auth)
+	}
+
+	if _, err := file.Context("stage"); !errors.Is(err, ErrContextNotFound) {
+		t.Fatalf("expected ErrContextNotFound, got %v", err)
+	}
+}
+
+func TestLoadContextsRejectsInvalidFiles(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"missing name":   "contexts:\n  - address: https://vault.example\n",
+		"duplicate name": "contexts:\n  - name: dev\n  - name: dev\n",
+		"unknown auth":   "contexts:\n  - name: dev\n    auth:\n      method: userpass\n",
+		"unknown field":  "contexts:\n  - name: dev\n    adress: https://vault.example\n",
+	}
+	for name, content := range tests {
+		if _, err := LoadContexts(writeContextsFile(t, content)); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+
+	_, err := LoadContexts(filepath.Join(t.TempDir(), "missing.yaml"))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected os.ErrNotExist for a missing file, got %v", err)
+	}
+}
+
+func TestSetCurrentContext(t *testing.T) {
+	t.Parallel()
+
+	filePath := writeContextsFile(t, "# clusters I work with\ncontexts:\n  - name: dev\n  - name: prod # careful\n")
+
+	if err := SetCurrentContext(filePath, "prod"); err != nil {
+		t.Fatalf("SetCurrentContext() error = %v", err)
+	}
+	if err := SetCurrentContext(filePath, "dev"); err != nil {
+		t.Fatalf("SetCurrentContext() error = %v", err)
+	}
+
+	file, err := LoadContexts(filePath)
+	if err != nil {
+		t.Fatalf("LoadContexts() error = %v", err)
+	}
+	if file.Current != "dev" || len(file.Contexts) != 2 {
+		t.Fatalf("unexpected contexts file after switching: %+v", file)
+	}
+	content, _ := os.ReadFile(filePath)
+	if !strings.Contains(string(content), "# clusters I work with") || !strings.Contains(string(content), "# careful") {
+		t.Fatalf("expected comments to be kept, got:\n%s", content)
+	}
+
+	if err := SetCurrentContext(filePath, "stage"); !errors.Is(err, ErrContextNotFound) {
+		t.Fatalf("expected ErrContextNotFound, got %v", err)
+	}
+}
@@ -50,6 +50,31 @@ func TestGenerateUnifiedDiffIdenticalContentProducesNoDiff(t *testing.T) {
 	}
 }
 
+func TestGenerateUnifiedDiffIgnoresTrailingNewlineDifference(t *testing.T) {
+	if diff := generateUnifiedDiff("a: 1\nb: 2", "a: 1\nb: 2\n", "kv/app"); diff != "" {
+		t.Errorf("expected empty diff when only the trailing newline differs, got:\n%s", diff)
+	}
+}
+
+func TestGenerateUnifiedDiffHunkCountsExcludeTrailingNewline(t *testing.T) {
+	diff := generateUnifiedDiff("a: 1\nb: 2\n", "a: 1\nb: 3\n", "kv/app")
+
+	if !strings.Contains(diff, "@@ -1,2 +1,2 @@\n") {
+		t.Errorf("expected a 2-line hunk on both sides, got:\n%s", diff)
+	}
+	if strings.Contains(diff, "\n+\n") || strings.Contains(diff, "\n-\n") || strings.Contains(diff, "\n \n") {
+		t.Errorf("expected no phantom empty line, got:\n%s", diff)
+	}
+}
+
+func TestGenerateNewFileDiffHasHunkAndNoPhantomLine(t *testing.T) {
+	diff := generateNewFileDiff("a: 1\nb: 2\n", "kv/app")
+
+	if !strings.HasSuffix(diff, "@@ -0,0 +1,2 @@\n+a: 1\n+b: 2\n") {
+		t.Errorf("expected a single hunk adding two lines, got:\n%s", diff)
+	}
+}
+
 // difftastic (installed as `difft`) cannot consume a unified diff on stdin, so
 // it must not be advertised as an auto-detected pipe tool.
 func TestDetectDiffToolDoesNotAdvertiseDifftastic(t *testing.T) {
@@ -0,0 +1,93 @@
+package vaultsync
+
+import (
+	"regexp"
+	"strings"
+)
+
+// redactKeepRunes is how many characters of a value matching a
+// RedactPatterns expression stay visible at each end. Values too short to
+// hide at least as much as they show are masked completely.
+const redactKeepRunes = 4
+
+// redactString partially masks value when it matches one of patterns and
+// reports whether it did.
+func redactString(value string, patterns []*regexp.Regexp) (string, bool) {
+	matched := false
+	for _, pattern := range patterns {
+		if pattern.MatchString(value) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return value, false
+	}
+
+	runes := []rune(value)
+	if len(runes) < 3*redactKeepRunes {
+		return maskedSecretValue, true
+	}
+	head := string(runes[:redactKeepRunes])
+	tail := string(runes[len(runes)-redactKeepRunes:])
+	return strings.ReplaceAll(head+maskedSecretValue+tail, "\n", `\n`), true
+}
+
+// redactValue applies redactString to value and to every string nested in it.
+func redactValue(value interface{}, patterns []*regexp.Regexp) interface{} {
+	switch typed := value.(type) {
+	case string:
+		redacted, _ := redactString(typed, patterns)
+		return redacted
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(typed))
+		for key, nested := range typed {
+			redacted[key] = redactValue(nested, patterns)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(typed))
+		for i, nested := range typed {
+			redacted[i] = redactValue(nested, patterns)
+		}
+		return redacted
+	}
+	return value
+}
+
+// redactSecretValues returns copies of existing and updated with the values
+// matching patterns partially masked, for VaultClient.RedactPatterns. A
+// changed value whose redacted form is the same as before is marked
+// " (changed)", so a diff of the results still shows every changed key.
+// Nested maps are compared key by key.
+func redactSecretValues(existing, updated map[string]interface{}, patterns []*regexp.Regexp) (map[string]interface{}, map[string]interface{}) {
+	redactedExisting := make(map[string]interface{}, len(existing))
+	redactedUpdated := make(map[string]interface{}, len(updated))
+
+	for key, value := range existing {
+		redactedExisting[key] = redactValue(value, patterns)
+	}
+
+	for key, value := range updated {
+		redactedUpdated[key] = redactValue(value, patterns)
+		oldValue, ok := existing[key]
+		if !ok {
+			continue
+		}
+
+		oldMap, oldIsMap := oldValue.(map[string]interface{})
+		newMap, newIsMap := value.(map[string]interface{})
+		switch {
+		case oldIsMap && newIsMap:
+			redactedExisting[key], redactedUpdated[key] = redactSecretValues(oldMap, newMap, patterns)
+		case !sameYAML(oldValue, value) && sameYAML(redactedExisting[key], redactedUpdated[key]):
+			if redacted, ok := redactedUpdated[key].(string); ok {
+				redactedUpdated[key] = redacted + " (changed)"
+			} else {
+				redactedUpdated[key] = changedSecretValue
+			}
+		}
+	}
+
+	return redactedExisting, redactedUpdated
+}
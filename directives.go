@@ -0,0 +1,110 @@
+package vaultsync
+
+import (
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// directivePrefix introduces a push directive in a secret file's leading
+// comments, e.g. "# vaultsync: skip".
+const directivePrefix = "vaultsync:"
+
+// emptySecretFile is the file a PullOptions.PreserveEmpty pull writes for a
+// secret without keys: an empty mapping marked with the "empty" directive, so
+// the file reads as a deliberate placeholder rather than a truncated one.
+const emptySecretFile = "# " + directivePrefix + " empty\n{}\n"
+
+// fileDirectives are the per-file push settings read from "# vaultsync: ..."
+// comments at the top of a YAML secret file.
+type fileDirectives struct {
+	// Skip excludes the file from push.
+	Skip bool
+
+	// CASRequired pushes the secret with check-and-set and marks it
+	// cas_required in its metadata, as if _options.cas_required were true.
+	CASRequired bool
+
+	// Empty marks a file written for a secret without keys. It only
+	// documents the file; PushOptions.PreserveEmpty decides whether the
+	// secret is pushed.
+	Empty bool
+
+	// Unknown lists unrecognized directive names so they can be reported.
+	Unknown []string
+}
+
+// parseFileDirectives reads push directives from the comments above the first
+// key of a YAML document. Several directives may share a line, separated by
+// commas or spaces. Content that is not valid YAML has no directives.
+func parseFileDirectives(content []byte) fileDirectives {
+	var directives fileDirectives
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return directives
+	}
+
+	// Depending on blank lines, yaml.v3 attaches leading comments to the
+	// document, the top-level mapping, or the mapping's first key.
+	comments := []string{doc.HeadComment}
+	if len(doc.Content) > 0 {
+		root := doc.Content[0]
+		comments = append(comments, root.HeadComment)
+		if root.Kind == yaml.MappingNode && len(root.Content) > 0 {
+			comments = append(comments, root.Content[0].HeadComment)
+		}
+	}
+
+	for _, comment := range comments {
+		for _, line := range strings.Split(comment, "\n") {
+			line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "#"))
+			rest, ok := strings.CutPrefix(line, directivePrefix)
+			if !ok {
+				continue
+			}
+
+			for _, name := range strings.FieldsFunc(rest, func(r rune) bool { return r == ',' || r == ' ' }) {
+				switch name {
+				case "skip":
+					directives.Skip = true
+				case "cas", "cas_required":
+					directives.CASRequired = true
+				case "empty":
+					directives.Empty = true
+				default:
+					directives.Unknown = append(directives.Unknown, name)
+				}
+			}
+		}
+	}
+
+	return directives
+}
+
+// apply merges directives into the options parsed from the file's _options
+// block and returns the result.
+func (d fileDirectives) apply(options *SecretOptions) *SecretOptions {
+	if !d.CASRequired {
+		return options
+	}
+
+	merged := SecretOptions{}
+	if options != nil {
+		merged = *options
+	}
+	casRequired := true
+	merged.CASRequired = &casRequired
+	return &merged
+}
+
+// merge combines the directives of a base file and its overlay; a directive
+// set in either applies.
+func (d fileDirectives) merge(other fileDirectives) fileDirectives {
+	return fileDirectives{
+		Skip:        d.Skip || other.Skip,
+		CASRequired: d.CASRequired || other.CASRequired,
+		Unknown:     append(slices.Clip(d.Unknown), other.Unknown...),
+	}
+}
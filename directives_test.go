@@ -0,0 +1,162 @@
+package vaultsync
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFileDirectives(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		content string
+		want    fileDirectives
+	}{
+		{
+			name:    "no comments",
+			content: "username: alice\n",
+			want:    fileDirectives{},
+		},
+		{
+			name:    "skip directly above first key",
+			content: "# vaultsync: skip\nusername: alice\n",
+			want:    fileDirectives{Skip: true},
+		},
+		{
+			name:    "separated by blank line",
+			content: "# Database credentials\n# vaultsync: cas_required\n\nusername: alice\n",
+			want:    fileDirectives{CASRequired: true},
+		},
+		{
+			name:    "several directives and an unknown one",
+			content: "# vaultsync: cas, frobnicate\nusername: alice\n",
+			want:    fileDirectives{CASRequired: true, Unknown: []string{"frobnicate"}},
+		},
+		{
+			name:    "comments below the first key are ignored",
+			content: "username: alice\n# vaultsync: skip\npassword: x\n",
+			want:    fileDirectives{},
+		},
+	}
+
+	for _, tt := range tests {
+		got := parseFileDirectives([]byte(tt.content))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: parseFileDirectives() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPushSecretsFromFilesHonorsDirectives(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	fixtures := map[string]string{
+		"skipped": "# vaultsync: skip\nusername: alice\n",
+		"guarded": "# vaultsync: cas_required\nusername: bob\n",
+	}
+	for name, content := range fixtures {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write fixture %s: %v", name, err)
+		}
+	}
+
+	var writes []string
+	var writeBody, metadataBody map[string]interface{}
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Method == http.MethodGet {
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{
+					"data":     map[string]any{"username": "old"},
+					"metadata": map[string]any{"version": 3},
+				},
+			})
+		}
+
+		writes = append(writes, r.URL.Path)
+		body, _ := io.ReadAll(r.Body)
+		var parsed map[string]interface{}
+		if err := json.Unmarshal(body, &parsed); err != nil {
+			t.Fatalf("failed to parse body %q: %v", body, err)
+		}
+		if strings.Contains(r.URL.Path, "/metadata/") {
+			metadataBody = parsed
+		} else {
+			writeBody = parsed
+		}
+		return textResponse(http.StatusOK, ""), nil
+	})}
+
+	if err := client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"/v1/kv/data/app/guarded", "/v1/kv/metadata/app/guarded"}
+	if !reflect.DeepEqual(writes, want) {
+		t.Fatalf("expected only the guarded secret to be written, got %v", writes)
+	}
+
+	options, _ := writeBody["options"].(map[string]interface{})
+	if options["cas"] != float64(3) {
+		t.Fatalf("expected check-and-set against current version, got %#v", writeBody)
+	}
+	if metadataBody["cas_required"] != true {
+		t.Fatalf("expected cas_required metadata, got %#v", metadataBody)
+	}
+}
+
+func TestPushSecretsFromFilesDataOnlyHonorsCASDirective(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inputDir, "guarded"), []byte("# vaultsync: cas\nusername: bob\n"), 0o600); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	var writes []string
+	var writeBody map[string]interface{}
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.PushOptions.DataOnly = true
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Method == http.MethodGet {
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{
+					"data":     map[string]any{"username": "old"},
+					"metadata": map[string]any{"version": 3},
+				},
+			})
+		}
+
+		writes = append(writes, r.URL.Path)
+		if err := json.NewDecoder(r.Body).Decode(&writeBody); err != nil {
+			t.Fatalf("failed to parse body: %v", err)
+		}
+		return textResponse(http.StatusOK, ""), nil
+	})}
+
+	if err := client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := []string{"/v1/kv/data/app/guarded"}; !reflect.DeepEqual(writes, want) {
+		t.Fatalf("expected only the data write, got %v", writes)
+	}
+	options, _ := writeBody["options"].(map[string]interface{})
+	if options["cas"] != float64(3) {
+		t.Fatalf("expected check-and-set against current version, got %#v", writeBody)
+	}
+}
@@ -0,0 +1,234 @@
+package vaultsync
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// PullFormatEnvCombined flattens every pulled secret into a single dotenv
+// file, EnvCombinedFileName, with one KEY=VALUE line per secret key, as read
+// by `docker run --env-file`.
+const PullFormatEnvCombined = "env-combined"
+
+// EnvCombinedFileName is the file a PullFormatEnvCombined pull writes into
+// its output directory.
+const EnvCombinedFileName = "secrets.env"
+
+// defaultEnvSeparator joins the path segments and key of a variable name.
+const defaultEnvSeparator = "_"
+
+// ErrEnvNameCollision is returned when two secret keys of an env-combined
+// pull map to the same variable name.
+var ErrEnvNameCollision = errors.New("environment variable name collision")
+
+var envNameInvalidPattern = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// validateEnvSeparator checks that separator, when set, only has characters
+// allowed in variable names, so the names it joins stay valid.
+func validateEnvSeparator(separator string) error {
+	if envNameInvalidPattern.MatchString(separator) {
+		return fmt.Errorf("invalid env separator %q (only letters, digits, and _ are allowed)", separator)
+	}
+	return nil
+}
+
+// envCombined collects the variables of an env-combined pull.
+type envCombined struct {
+	separator string
+	keepCase  bool
+	// values and sources map each variable name to its value and to the
+	// "secret key" it came from, for reporting collisions.
+	values     map[string]string
+	sources    map[string]string
+	collisions []string
+}
+
+func newEnvCombined(opts PullOptions) *envCombined {
+	separator := opts.EnvSeparator
+	if separator == "" {
+		separator = defaultEnvSeparator
+	}
+	return &envCombined{
+		separator: separator,
+		keepCase:  opts.EnvKeepCase,
+		values:    make(map[string]string),
+		sources:   make(map[string]string),
+	}
+}
+
+// envVarName derives the variable name of key in the secret at relativePath,
+// e.g. "app/db" and "password" become APP_DB_PASSWORD: the path segments and
+// key are joined with the separator, characters that are not valid in a
+// variable name become underscores, and the result is uppercased unless
+// keepCase is set.
+func (e *envCombined) envVarName(relativePath, key string) string {
+	parts := append(strings.Split(relativePath, "/"), key)
+	for i, part := range parts {
+		parts[i] = envNameInvalidPattern.ReplaceAllString(part, "_")
+	}
+	name := strings.Join(parts, e.separator)
+	if !e.keepCase {
+		name = strings.ToUpper(name)
+	}
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// add records the keys of the secret at relativePath. A name already taken by
+// another key is recorded as a collision rather than overwriting it.
+func (e *envCombined) add(secretPath, relativePath string, secretData map[string]interface{}) error {
+	keys := make([]string, 0, len(secretData))
+	for key := range secretData {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value, err := envValue(secretData[key])
+		if err != nil {
+			return fmt.Errorf("key %q: %w", key, err)
+		}
+
+		source := displayPath(secretPath) + " " + key
+		name := e.envVarName(relativePath, key)
+		if other, ok := e.sources[name]; ok {
+			e.collisions = append(e.collisions, fmt.Sprintf("%s and %s both map to %s", other, source, name))
+			continue
+		}
+		e.sources[name] = source
+		e.values[name] = value
+	}
+	return nil
+}
+
+// render returns the dotenv file, sorted by variable name, or an error
+// listing every collision.
+func (e *envCombined) render() ([]byte, error) {
+	if len(e.collisions) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrEnvNameCollision, strings.Join(e.collisions, "; "))
+	}
+
+	names := make([]string, 0, len(e.values))
+	for name := range e.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&buf, "%s=%s\n", name, e.values[name])
+	}
+	return buf.Bytes(), nil
+}
+
+// envValue renders a secret value for a dotenv line. Env files have no
+// quoting, so strings are written as they are and other values as JSON;
+// values spanning lines cannot be represented.
+func envValue(value interface{}) (string, error) {
+	var text string
+	switch v := value.(type) {
+	case string:
+		text = v
+	case nil:
+		text = ""
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode value: %w", err)
+		}
+		text = string(encoded)
+	}
+	if strings.ContainsAny(text, "\r\n") {
+		return "", fmt.Errorf("multi-line values cannot be written to an env file")
+	}
+	return text, nil
+}
+
+// pullEnvCombined walks the secrets below basePath into a single dotenv file
+// in outputDir. Nothing is written unless every secret was read, so the file
+// never silently lacks variables.
+func (v *VaultClient) pullEnvCombined(basePath, outputDir string) error {
+	if err := validateEnvSeparator(v.PullOptions.EnvSeparator); err != nil {
+		return err
+	}
+	env := newEnvCombined(v.PullOptions)
+	var secretPaths []string
+	fetchErr, addErr := v.walkSecrets(basePath, func(secretPath string, secretData map[string]interface{}) error {
+		relativePath := strings.TrimPrefix(strings.TrimPrefix(secretPath, basePath), "/")
+		if relativePath == "" {
+			return fmt.Errorf("cannot determine variable names for secret %s", secretPath)
+		}
+		added, err := v.addEnvSecret(env, secretPath, relativePath, secretData)
+		if err != nil {
+			return fmt.Errorf("failed to add secret %s: %w", secretPath, err)
+		}
+		if added {
+			secretPaths = append(secretPaths, secretPath)
+		}
+		return nil
+	})
+	if addErr != nil {
+		return errors.Join(addErr, fetchErr)
+	}
+	if fetchErr != nil {
+		return fmt.Errorf("failed to pull secrets, not writing %s: %w", EnvCombinedFileName, fetchErr)
+	}
+
+	if err := v.writeEnvCombined(env, outputDir, secretPaths); err != nil {
+		return err
+	}
+	return v.writePullManifest(outputDir, nil)
+}
+
+// addEnvSecret joins chunked values, applies key selection and the value
+// filter to a secret, and adds what is left to env. Secrets left without
+// keys are skipped.
+func (v *VaultClient) addEnvSecret(env *envCombined, secretPath, relativePath string, secretData map[string]interface{}) (bool, error) {
+	secretData = v.joinChunks(secretPath, secretData)
+	if v.PullOptions.selectsKeys() {
+		secretData = v.PullOptions.selectKeys(secretData)
+		if len(secretData) == 0 {
+			v.printf("Skipping: %s (no selected keys)\n", secretPath)
+			v.report(SecretResult{Path: displayPath(secretPath), Action: "skipped"})
+			return false, nil
+		}
+	}
+	if v.PullOptions.ValueFilter != "" && secretData != nil {
+		filtered, err := filterValues(v.PullOptions.ValueFilter, secretData)
+		if err != nil {
+			return false, err
+		}
+		secretData = filtered
+	}
+	return true, env.add(secretPath, relativePath, secretData)
+}
+
+// writeEnvCombined writes env to EnvCombinedFileName in outputDir and reports
+// each secret that went into it.
+func (v *VaultClient) writeEnvCombined(env *envCombined, outputDir string, secretPaths []string) error {
+	content, err := env.render()
+	if err != nil {
+		return err
+	}
+
+	filePath := filepath.Join(outputDir, EnvCombinedFileName)
+	written, err := writeSecretFile(v.storage(), filePath, content, v.PullOptions.OnlyChanged)
+	if err != nil {
+		return err
+	}
+	if written {
+		v.printf("Written: %s\n", filePath)
+	}
+	for _, secretPath := range secretPaths {
+		v.report(SecretResult{Path: displayPath(secretPath), Action: writtenAction(written), File: filePath})
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package vaultsync
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEnvCombinedVarNames(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		opts         PullOptions
+		relativePath string
+		key          string
+		want         string
+	}{
+		{name: "uppercased and joined", relativePath: "app/db", key: "password", want: "APP_DB_PASSWORD"},
+		{name: "invalid characters", relativePath: "db-main", key: "conn.url", want: "DB_MAIN_CONN_URL"},
+		{name: "leading digit", relativePath: "1st", key: "key", want: "_1ST_KEY"},
+		{name: "custom separator", opts: PullOptions{EnvSeparator: "__"}, relativePath: "app/db", key: "user", want: "APP__DB__USER"},
+		{name: "keep case", opts: PullOptions{EnvKeepCase: true}, relativePath: "App/db", key: "user", want: "App_db_user"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := newEnvCombined(tt.opts).envVarName(tt.relativePath, tt.key); got != tt.want {
+				t.Fatalf("envVarName(%q, %q) = %q, want %q", tt.relativePath, tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvCombinedRendersSortedValues(t *testing.T) {
+	t.Parallel()
+
+	env := newEnvCombined(PullOptions{})
+	if err := env.add("kv/metadata/app/db", "db", map[string]interface{}{
+		"password": "p=w d",
+		"port":     5432,
+		"opts":     map[string]interface{}{"ssl": true},
+	}); err != nil {
+		t.Fatalf("add() error = %v", err)
+	}
+
+	content, err := env.render()
+	if err != nil {
+		t.Fatalf("render() error = %v", err)
+	}
+	want := "DB_OPTS={\"ssl\":true}\nDB_PASSWORD=p=w d\nDB_PORT=5432\n"
+	if string(content) != want {
+		t.Fatalf("render() = %q, want %q", content, want)
+	}
+}
+
+func TestEnvCombinedRejectsMultilineValues(t *testing.T) {
+	t.Parallel()
+
+	env := newEnvCombined(PullOptions{})
+	err := env.add("kv/metadata/app/tls", "tls", map[string]interface{}{"cert": "line1\nline2"})
+	if err == nil || !strings.Contains(err.Error(), "multi-line") {
+		t.Fatalf("add() error = %v, want a multi-line error", err)
+	}
+}
+
+func TestValidateEnvSeparator(t *testing.T) {
+	t.Parallel()
+
+	for _, separator := range []string{"", "_", "__", "X1"} {
+		if err := validateEnvSeparator(separator); err != nil {
+			t.Errorf("validateEnvSeparator(%q) = %v, want nil", separator, err)
+		}
+	}
+	for _, separator := range []string{"-", ".", "=", " ", "_\n"} {
+		if err := validateEnvSeparator(separator); err == nil {
+			t.Errorf("validateEnvSeparator(%q) = nil, want an error", separator)
+		}
+	}
+}
+
+func TestPullEnvCombinedWritesOneFile(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.PullOptions.Format = PullFormatEnvCombined
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		switch r.URL.Path {
+		case "/v1/kv/metadata/app":
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{"keys": []string{"db", "api/"}},
+			})
+		case "/v1/kv/metadata/app/api":
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{"keys": []string{"stripe"}},
+			})
+		case "/v1/kv/data/app/db":
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{"data": map[string]any{"password": "pw"}},
+			})
+		case "/v1/kv/data/app/api/stripe":
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{"data": map[string]any{"key": "sk"}},
+			})
+		}
+		t.Fatalf("unexpected request %s", r.URL.Path)
+		return nil, nil
+	})}
+
+	outputDir := t.TempDir()
+	if err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), outputDir); err != nil {
+		t.Fatalf("PullSecretsToFilesAt() error = %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, EnvCombinedFileName))
+	if err != nil {
+		t.Fatalf("expected %s to be written: %v", EnvCombinedFileName, err)
+	}
+	if want := "API_STRIPE_KEY=sk\nDB_PASSWORD=pw\n"; string(content) != want {
+		t.Fatalf("%s = %q, want %q", EnvCombinedFileName, content, want)
+	}
+	entries, err := os.ReadDir(outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("output directory has %d entries, want only %s", len(entries), EnvCombinedFileName)
+	}
+}
+
+func TestPullEnvCombinedReportsCollisions(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.PullOptions.Format = PullFormatEnvCombined
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		switch r.URL.Path {
+		case "/v1/kv/metadata/app":
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{"keys": []string{"db-main", "db_main"}},
+			})
+		case "/v1/kv/data/app/db-main", "/v1/kv/data/app/db_main":
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{"data": map[string]any{"user": "u"}},
+			})
+		}
+		t.Fatalf("unexpected request %s", r.URL.Path)
+		return nil, nil
+	})}
+
+	outputDir := t.TempDir()
+	err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), outputDir)
+	if !errors.Is(err, ErrEnvNameCollision) {
+		t.Fatalf("PullSecretsToFilesAt() error = %v, want ErrEnvNameCollision", err)
+	}
+	if !strings.Contains(err.Error(), "kv/app/db-main user and kv/app/db_main user both map to DB_MAIN_USER") {
+		t.Fatalf("error %q does not name the colliding keys", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, EnvCombinedFileName)); !os.IsNotExist(err) {
+		t.Fatalf("expected no env file after a collision, stat error = %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package vaultsync
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// expandEnvValues returns a copy of secretData with ${VAR} and $VAR references
+// in every string value (including those nested in maps and lists) replaced by
+// the value of the environment variable. "$$" expands to a literal "$". When
+// strict is set, a reference to an unset variable is an error rather than an
+// empty substitution.
+func expandEnvValues(secretData map[string]interface{}, strict bool) (map[string]interface{}, error) {
+	missing := make(map[string]bool)
+
+	mapping := func(name string) string {
+		if name == "$" {
+			return "$"
+		}
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			missing[name] = true
+		}
+		return value
+	}
+
+	expanded := expandEnvValue(secretData, mapping).(map[string]interface{})
+
+	if strict && len(missing) > 0 {
+		names := make([]string, 0, len(missing))
+		for name := range missing {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return nil, fmt.Errorf("unset environment variables: %s", strings.Join(names, ", "))
+	}
+
+	return expanded, nil
+}
+
+func expandEnvValue(value interface{}, mapping func(string) string) interface{} {
+	switch typed := value.(type) {
+	case string:
+		return os.Expand(typed, mapping)
+	case map[string]interface{}:
+		expanded := make(map[string]interface{}, len(typed))
+		for key, nested := range typed {
+			expanded[key] = expandEnvValue(nested, mapping)
+		}
+		return expanded
+	case []interface{}:
+		expanded := make([]interface{}, len(typed))
+		for i, nested := range typed {
+			expanded[i] = expandEnvValue(nested, mapping)
+		}
+		return expanded
+	default:
+		return value
+	}
+}
@@ -0,0 +1,85 @@
+package vaultsync
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandEnvValuesSubstitutesNestedStrings(t *testing.T) {
+	t.Setenv("VAULTSYNC_TEST_PASSWORD", "s3cr3t")
+
+	got, err := expandEnvValues(map[string]interface{}{
+		"password": "${VAULTSYNC_TEST_PASSWORD}",
+		"price":    "$$5",
+		"port":     5432,
+		"nested":   map[string]interface{}{"dsn": "user:$VAULTSYNC_TEST_PASSWORD@db"},
+		"list":     []interface{}{"${VAULTSYNC_TEST_PASSWORD}"},
+	}, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got["password"] != "s3cr3t" {
+		t.Fatalf("expected expanded password, got %#v", got["password"])
+	}
+	if got["price"] != "$5" {
+		t.Fatalf("expected $$ to escape a literal $, got %#v", got["price"])
+	}
+	if got["port"] != 5432 {
+		t.Fatalf("expected non-string values untouched, got %#v", got["port"])
+	}
+	if nested := got["nested"].(map[string]interface{}); nested["dsn"] != "user:s3cr3t@db" {
+		t.Fatalf("expected nested string expanded, got %#v", nested["dsn"])
+	}
+	if list := got["list"].([]interface{}); list[0] != "s3cr3t" {
+		t.Fatalf("expected list element expanded, got %#v", list[0])
+	}
+}
+
+func TestExpandEnvValuesStrictRejectsUnsetVariables(t *testing.T) {
+	t.Setenv("VAULTSYNC_TEST_UNSET", "")
+	os.Unsetenv("VAULTSYNC_TEST_UNSET")
+
+	data := map[string]interface{}{"token": "${VAULTSYNC_TEST_UNSET}"}
+
+	if _, err := expandEnvValues(data, true); err == nil || !strings.Contains(err.Error(), "VAULTSYNC_TEST_UNSET") {
+		t.Fatalf("expected strict mode to name the unset variable, got %v", err)
+	}
+
+	got, err := expandEnvValues(data, false)
+	if err != nil {
+		t.Fatalf("expected lenient mode to succeed, got %v", err)
+	}
+	if got["token"] != "" {
+		t.Fatalf("expected unset variable to expand to empty, got %#v", got["token"])
+	}
+}
+
+func TestPushSecretsFromFilesExpandEnvSendsExpandedValues(t *testing.T) {
+	t.Setenv("VAULTSYNC_TEST_USER", "alice")
+
+	inputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inputDir, "db"), []byte("username: ${VAULTSYNC_TEST_USER}\n"), 0644); err != nil {
+		t.Fatalf("failed to write fixture secret: %v", err)
+	}
+
+	var captured capturedRequest
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.PushOptions.ExpandEnv = true
+	client.client = &http.Client{Transport: captureSingleRequest(t, &captured)}
+
+	if err := client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, ok := captured.body["data"].(map[string]interface{})
+	if !ok || data["username"] != "alice" {
+		t.Fatalf("expected expanded username in payload, got %#v", captured.body)
+	}
+}
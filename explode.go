@@ -0,0 +1,148 @@
+package vaultsync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// explodedSecretSuffix is appended to the secret's file name to form the
+// directory that holds an exploded secret, e.g. app/db.yaml.d/password. The
+// suffix keeps an exploded secret distinct from a Vault folder of the same
+// name.
+const explodedSecretSuffix = ".d"
+
+// validateExplodedKey rejects secret keys that cannot be used verbatim as a
+// file name inside an exploded secret directory.
+func validateExplodedKey(key string) error {
+	switch {
+	case key == "", key == ".", key == "..":
+		return fmt.Errorf("key %q cannot be used as a file name", key)
+	case strings.ContainsAny(key, `/\`):
+		return fmt.Errorf("key %q contains a path separator", key)
+	}
+	return nil
+}
+
+// writeExplodedSecret writes each key of secretData into its own file under
+// dir. Each file holds the YAML encoding of that key's value (indented per
+// options.YAMLIndent, see marshalYAML) so types survive the round trip. Key
+// files left over from a previous pull whose key no longer exists are removed
+// so a later push does not resurrect them. With options.OnlyChanged, key files
+// that already hold the right content are not rewritten. changed reports
+// whether any file was written or removed.
+func writeExplodedSecret(dir string, secretData map[string]interface{}, options PullOptions) (changed bool, err error) {
+	for key := range secretData {
+		if err := validateExplodedKey(key); err != nil {
+			return false, fmt.Errorf("cannot explode secret into %s: %w", dir, err)
+		}
+	}
+
+	// 0700: secret directories must not be world/group-accessible
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return false, fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+	for _, entry := range entries {
+		if _, ok := secretData[entry.Name()]; ok || !entry.Type().IsRegular() {
+			continue
+		}
+		stalePath := filepath.Join(dir, entry.Name())
+		if err := os.Remove(stalePath); err != nil {
+			return false, fmt.Errorf("failed to remove stale key file %s: %w", stalePath, err)
+		}
+		changed = true
+	}
+
+	keys := make([]string, 0, len(secretData))
+	for key := range secretData {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		valueData, err := marshalYAML(secretData[key], options.YAMLIndent)
+		if err != nil {
+			return false, fmt.Errorf("failed to convert key %s to YAML: %w", key, err)
+		}
+
+		written, err := writeSecretFile(localStorage{}, filepath.Join(dir, key), valueData, options.OnlyChanged)
+		if err != nil {
+			return false, err
+		}
+		changed = changed || written
+	}
+
+	return changed, nil
+}
+
+// modifiedSince reports whether the exploded secret directory dir, or any key
+// file in it, was modified after since. The directory's own mtime covers keys
+// that were added or removed; edits only touch the key files.
+func modifiedSince(dir string, since time.Time) (bool, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat %s: %w", dir, err)
+	}
+	if info.ModTime().After(since) {
+		return true, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		entryInfo, err := entry.Info()
+		if err != nil {
+			return false, fmt.Errorf("failed to stat %s: %w", filepath.Join(dir, entry.Name()), err)
+		}
+		if entryInfo.ModTime().After(since) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// readExplodedSecret reassembles a secret from the per-key files in dir. Only
+// regular files directly inside dir are treated as keys; hidden files and
+// subdirectories are ignored.
+func readExplodedSecret(dir string) (map[string]interface{}, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+
+	secretData := make(map[string]interface{}, len(entries))
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		keyPath := filepath.Join(dir, entry.Name())
+		valueData, err := os.ReadFile(keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file %s: %w", keyPath, err)
+		}
+
+		var value interface{}
+		if err := yaml.Unmarshal(valueData, &value); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML in %s: %w", keyPath, err)
+		}
+		secretData[entry.Name()] = value
+	}
+
+	return secretData, nil
+}
@@ -0,0 +1,153 @@
+package vaultsync
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPullSecretsToFilesExplodeWritesOneFilePerKey(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.PullOptions.Explode = true
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/kv/metadata/app" && r.URL.RawQuery == "list=true":
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{"keys": []string{"db"}},
+			})
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/kv/data/app/db":
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{
+					"data": map[string]any{"username": "alice", "port": 5432},
+				},
+			})
+		default:
+			return textResponse(http.StatusNotFound, "not found"), nil
+		}
+	})}
+
+	outputDir := t.TempDir()
+	secretDir := filepath.Join(outputDir, "app", "db.yaml"+explodedSecretSuffix)
+
+	// A key file from an earlier pull whose key has since been removed.
+	if err := os.MkdirAll(secretDir, 0o700); err != nil {
+		t.Fatalf("failed to create exploded dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(secretDir, "removed"), []byte("old\n"), 0o600); err != nil {
+		t.Fatalf("failed to seed stale key file: %v", err)
+	}
+
+	if err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), outputDir); err != nil {
+		t.Fatalf("expected pull to succeed, got %v", err)
+	}
+
+	username, err := os.ReadFile(filepath.Join(secretDir, "username"))
+	if err != nil {
+		t.Fatalf("expected per-key file, got %v", err)
+	}
+	if strings.TrimSpace(string(username)) != "alice" {
+		t.Fatalf("expected username value, got %q", username)
+	}
+
+	if _, err := os.Stat(filepath.Join(secretDir, "port")); err != nil {
+		t.Fatalf("expected port key file, got %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(secretDir, "removed")); !os.IsNotExist(err) {
+		t.Fatalf("expected stale key file to be removed, got %v", err)
+	}
+}
+
+func TestPushSecretsFromFilesExplodeReassemblesSecret(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	secretDir := filepath.Join(inputDir, "app", "db.yaml"+explodedSecretSuffix)
+	if err := os.MkdirAll(secretDir, 0o700); err != nil {
+		t.Fatalf("failed to create exploded dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(secretDir, "username"), []byte("alice\n"), 0o600); err != nil {
+		t.Fatalf("failed to write key file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(secretDir, "port"), []byte("5432\n"), 0o600); err != nil {
+		t.Fatalf("failed to write key file: %v", err)
+	}
+
+	var captured capturedRequest
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.PushOptions.Explode = true
+	client.client = &http.Client{Transport: captureSingleRequest(t, &captured)}
+
+	if err := client.PushSecretsFromFilesAt(inputDir, NewSecretRef("kv", "app"), false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if captured.path != "/v1/kv/data/app/db" {
+		t.Fatalf("expected exploded dir to map to its secret path, got %q", captured.path)
+	}
+
+	data, ok := captured.body["data"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected body wrapped in data field, got %#v", captured.body)
+	}
+
+	if data["username"] != "alice" {
+		t.Fatalf("expected username from key file, got %#v", data)
+	}
+
+	// Values are YAML-decoded, so numeric key files keep their type.
+	if data["port"] != float64(5432) {
+		t.Fatalf("expected numeric port from key file, got %#v", data["port"])
+	}
+}
+
+func TestWriteExplodedSecretRejectsKeysWithPathSeparators(t *testing.T) {
+	t.Parallel()
+
+	_, err := writeExplodedSecret(filepath.Join(t.TempDir(), "db.d"), map[string]interface{}{"a/b": "x"}, PullOptions{})
+	if err == nil {
+		t.Fatal("expected error for key containing a path separator, got nil")
+	}
+}
+
+func TestModifiedSinceConsidersKeyFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "db.yaml"+explodedSecretSuffix)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatalf("failed to create exploded dir: %v", err)
+	}
+	keyPath := filepath.Join(dir, "password")
+	if err := os.WriteFile(keyPath, []byte("x\n"), 0o600); err != nil {
+		t.Fatalf("failed to write key file: %v", err)
+	}
+
+	past := time.Now().Add(-2 * time.Hour)
+	for _, p := range []string{keyPath, dir} {
+		if err := os.Chtimes(p, past, past); err != nil {
+			t.Fatalf("failed to age %s: %v", p, err)
+		}
+	}
+
+	since := time.Now().Add(-time.Hour)
+	if modified, err := modifiedSince(dir, since); err != nil || modified {
+		t.Fatalf("expected untouched secret to be unmodified, got %v, %v", modified, err)
+	}
+
+	if err := os.Chtimes(keyPath, time.Now(), time.Now()); err != nil {
+		t.Fatalf("failed to touch key file: %v", err)
+	}
+	if modified, err := modifiedSince(dir, since); err != nil || !modified {
+		t.Fatalf("expected edited key file to mark secret modified, got %v, %v", modified, err)
+	}
+}
@@ -0,0 +1,181 @@
+package vaultsync
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeVault is an in-memory Vault serving the KV v2 API to a VaultClient in
+// tests. Secrets are keyed by engine and path, e.g. "kv/app/db"; folders are
+// whatever lies between. Writes and deletes are applied and also recorded by
+// request path, so a test can check both what was sent and what is left.
+type fakeVault struct {
+	t *testing.T
+
+	mu       sync.Mutex
+	secrets  map[string]map[string]any
+	versions map[string]int
+
+	// fail answers requests for these paths, e.g. "/v1/kv/data/app/db",
+	// with the status instead.
+	fail map[string]int
+	// noValues fails the test when a secret value is read.
+	noValues bool
+	// listDelay slows LIST requests down so that overlapping ones show up
+	// in maxListsInFlight.
+	listDelay        time.Duration
+	listsInFlight    int
+	maxListsInFlight int
+
+	writes  map[string]map[string]any
+	deletes []string
+}
+
+// newFakeVault returns a fakeVault holding secrets, all at version 1.
+func newFakeVault(t *testing.T, secrets map[string]map[string]any) *fakeVault {
+	t.Helper()
+
+	f := &fakeVault{
+		t:        t,
+		secrets:  make(map[string]map[string]any, len(secrets)),
+		versions: make(map[string]int, len(secrets)),
+		fail:     make(map[string]int),
+		writes:   make(map[string]map[string]any),
+	}
+	for name, data := range secrets {
+		f.secrets[name] = data
+		f.versions[name] = 1
+	}
+	return f
+}
+
+// client returns a quiet client for namespace "team-a" talking to f.
+func (f *fakeVault) client() *VaultClient {
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: roundTripFunc(f.roundTrip)}
+	return client
+}
+
+func (f *fakeVault) roundTrip(r *http.Request) (*http.Response, error) {
+	list := r.Method == http.MethodGet && r.URL.Query().Get("list") == "true"
+	if list && f.listDelay > 0 {
+		f.mu.Lock()
+		f.listsInFlight++
+		f.maxListsInFlight = max(f.maxListsInFlight, f.listsInFlight)
+		f.mu.Unlock()
+		time.Sleep(f.listDelay)
+		defer func() {
+			f.mu.Lock()
+			f.listsInFlight--
+			f.mu.Unlock()
+		}()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if status, ok := f.fail[r.URL.Path]; ok {
+		return textResponse(status, "permission denied"), nil
+	}
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/v1/"), "/", 3)
+	if len(parts) < 2 {
+		f.t.Fatalf("unexpected request %s %s", r.Method, r.URL)
+		return nil, nil
+	}
+	segment, name := parts[1], parts[0]
+	if len(parts) == 3 && strings.Trim(parts[2], "/") != "" {
+		name += "/" + strings.Trim(parts[2], "/")
+	}
+	data, exists := f.secrets[name]
+
+	switch {
+	case list:
+		keys := f.list(name)
+		if len(keys) == 0 {
+			return textResponse(http.StatusNotFound, `{"errors":[]}`), nil
+		}
+		return jsonResponse(f.t, http.StatusOK, map[string]any{"data": map[string]any{"keys": keys}})
+	case r.Method == http.MethodGet && !exists:
+		return textResponse(http.StatusNotFound, `{"errors":[]}`), nil
+	case r.Method == http.MethodGet && segment == "data":
+		if f.noValues {
+			f.t.Errorf("secret value read: %s %s", r.Method, r.URL)
+		}
+		return jsonResponse(f.t, http.StatusOK, map[string]any{"data": map[string]any{
+			"data":     data,
+			"metadata": map[string]any{"version": f.versions[name]},
+		}})
+	case r.Method == http.MethodGet && segment == "subkeys":
+		return jsonResponse(f.t, http.StatusOK, map[string]any{"data": map[string]any{"subkeys": fakeSubkeys(data)}})
+	case r.Method == http.MethodGet && segment == "metadata":
+		return jsonResponse(f.t, http.StatusOK, map[string]any{"data": map[string]any{"current_version": f.versions[name]}})
+	case (r.Method == http.MethodPost || r.Method == http.MethodPut) && segment == "data":
+		var body struct {
+			Data map[string]any `json:"data"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			f.t.Fatalf("failed to parse request body: %v", err)
+		}
+		f.writes[r.URL.Path] = body.Data
+		f.secrets[name] = body.Data
+		f.versions[name]++
+		return jsonResponse(f.t, http.StatusOK, map[string]any{"data": map[string]any{"version": f.versions[name]}})
+	case r.Method == http.MethodDelete:
+		f.deletes = append(f.deletes, r.URL.Path)
+		delete(f.secrets, name)
+		return textResponse(http.StatusNoContent, ""), nil
+	}
+
+	f.t.Fatalf("unexpected request %s %s", r.Method, r.URL)
+	return nil, nil
+}
+
+// listOverlap returns the most LIST requests that were in flight at once,
+// counted while listDelay is set.
+func (f *fakeVault) listOverlap() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.maxListsInFlight
+}
+
+// list returns the sorted keys directly below folder, with subfolders ending
+// in "/".
+func (f *fakeVault) list(folder string) []string {
+	var keys []string
+	for name := range f.secrets {
+		rest, ok := strings.CutPrefix(name, folder+"/")
+		if !ok {
+			continue
+		}
+		key := rest
+		if first, _, nested := strings.Cut(rest, "/"); nested {
+			key = first + "/"
+		}
+		if !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// fakeSubkeys mirrors the subkeys endpoint: data with every leaf value
+// replaced by null.
+func fakeSubkeys(data map[string]any) map[string]any {
+	subkeys := make(map[string]any, len(data))
+	for key, value := range data {
+		if nested, ok := value.(map[string]any); ok {
+			subkeys[key] = fakeSubkeys(nested)
+		} else {
+			subkeys[key] = nil
+		}
+	}
+	return subkeys
+}
@@ -0,0 +1,76 @@
+package vaultsync
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// filterValues returns a copy of secretData in which every string value, at
+// any depth, is replaced by the output of the shell command filter run with
+// the value on stdin. The key being filtered is passed in VAULTSYNC_KEY as a
+// dotted path ("db.password", "hosts[0]"). Other values pass through
+// unchanged.
+func filterValues(filter string, secretData map[string]interface{}) (map[string]interface{}, error) {
+	filtered, err := filterValue(filter, "", secretData)
+	if err != nil {
+		return nil, err
+	}
+	return filtered.(map[string]interface{}), nil
+}
+
+func filterValue(filter, key string, value interface{}) (interface{}, error) {
+	switch typed := value.(type) {
+	case string:
+		return runValueFilter(filter, key, typed)
+	case map[string]interface{}:
+		filtered := make(map[string]interface{}, len(typed))
+		for name, nested := range typed {
+			nestedKey := name
+			if key != "" {
+				nestedKey = key + "." + name
+			}
+			result, err := filterValue(filter, nestedKey, nested)
+			if err != nil {
+				return nil, err
+			}
+			filtered[name] = result
+		}
+		return filtered, nil
+	case []interface{}:
+		filtered := make([]interface{}, len(typed))
+		for i, nested := range typed {
+			result, err := filterValue(filter, key+"["+strconv.Itoa(i)+"]", nested)
+			if err != nil {
+				return nil, err
+			}
+			filtered[i] = result
+		}
+		return filtered, nil
+	default:
+		return value, nil
+	}
+}
+
+// runValueFilter pipes value through filter and returns its stdout verbatim.
+// The error names the key and carries the command's stderr, never the value.
+func runValueFilter(filter, key, value string) (string, error) {
+	cmd := exec.Command("sh", "-c", filter)
+	cmd.Stdin = strings.NewReader(value)
+	cmd.Env = append(os.Environ(), "VAULTSYNC_KEY="+key)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("value filter failed for key %q: %w: %s", key, err, message)
+		}
+		return "", fmt.Errorf("value filter failed for key %q: %w", key, err)
+	}
+	return stdout.String(), nil
+}
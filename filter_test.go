@@ -0,0 +1,128 @@
+package vaultsync
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFilterValuesPipesEveryStringValue(t *testing.T) {
+	t.Parallel()
+
+	data := map[string]interface{}{
+		"password": "secret",
+		"port":     5432,
+		"replica":  map[string]interface{}{"host": "db2"},
+		"hosts":    []interface{}{"a", "b"},
+	}
+
+	filtered, err := filterValues(`printf '%s=' "$VAULTSYNC_KEY"; tr a-z A-Z`, data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"password": "password=SECRET",
+		"port":     5432,
+		"replica":  map[string]interface{}{"host": "replica.host=DB2"},
+		"hosts":    []interface{}{"hosts[0]=A", "hosts[1]=B"},
+	}
+	if !reflect.DeepEqual(filtered, want) {
+		t.Fatalf("expected %#v, got %#v", want, filtered)
+	}
+	if data["password"] != "secret" {
+		t.Fatalf("expected the input to be left unchanged, got %#v", data)
+	}
+}
+
+func TestFilterValuesReportsFailingCommandWithoutValue(t *testing.T) {
+	t.Parallel()
+
+	_, err := filterValues("echo 'bad key' >&2; exit 3", map[string]interface{}{"password": "hunter2"})
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	for _, want := range []string{`key "password"`, "exit status 3", "bad key"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in error, got %v", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "hunter2") {
+		t.Fatalf("expected the value to stay out of the error, got %v", err)
+	}
+}
+
+func TestPushSecretsFromFilesAppliesValueFilter(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(inputDir, "app"), 0755); err != nil {
+		t.Fatalf("failed to create fixture dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "app", "db.yaml"), []byte("password: secret\n"), 0644); err != nil {
+		t.Fatalf("failed to write fixture secret: %v", err)
+	}
+
+	var captured capturedRequest
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.PushOptions.ValueFilter = "tr a-z A-Z"
+	client.client = &http.Client{Transport: captureSingleRequest(t, &captured)}
+
+	if err := client.PushSecretsFromFilesAt(inputDir, NewSecretRef("kv", "app"), false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, _ := captured.body["data"].(map[string]interface{})
+	if data["password"] != "SECRET" {
+		t.Fatalf("expected the filtered value to be pushed, got %#v", captured.body)
+	}
+}
+
+func TestPullSecretsToFilesCollectsValueFilterFailures(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	// The filter fails on the value "bad" only.
+	client.PullOptions.ValueFilter = `v=$(cat); [ "$v" != bad ] && printf '%s' "$v"`
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		switch r.URL.Path {
+		case "/v1/kv/metadata/app":
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{"keys": []string{"a", "b"}},
+			})
+		case "/v1/kv/data/app/a":
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{"data": map[string]any{"password": "bad"}},
+			})
+		case "/v1/kv/data/app/b":
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{"data": map[string]any{"password": "good"}},
+			})
+		}
+		t.Fatalf("unexpected request %s", r.URL.Path)
+		return nil, nil
+	})}
+
+	outputDir := t.TempDir()
+	err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), outputDir)
+	var multi *MultiError
+	if !errors.As(err, &multi) || multi.Len() != 1 || !strings.Contains(err.Error(), "kv/metadata/app/a") {
+		t.Fatalf("expected the filter failure of app/a to be collected, got %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "app", "b.yaml"))
+	if err != nil || string(content) != "password: good\n" {
+		t.Fatalf("expected app/b to be written after the failure, got %q, %v", content, err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "app", "a.yaml")); !os.IsNotExist(err) {
+		t.Fatalf("expected app/a not to be written, stat err %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package vaultsync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// gitignoreContent ignores everything in a pull's output directory except the
+// .gitignore itself, so the guard can be committed while secrets cannot.
+const gitignoreContent = `# Written by vaultsync: pulled secrets are plaintext and must not be committed.
+*
+!.gitignore
+`
+
+// guardOutputDir protects a pull's output directory from being committed to
+// git. With PullOptions.Gitignore it writes a .gitignore there unless one
+// exists already; otherwise it warns when the directory lies inside a git
+// work tree and has no .gitignore of its own.
+func (v *VaultClient) guardOutputDir(outputDir string) error {
+	gitignore := filepath.Join(outputDir, ".gitignore")
+	if _, err := os.Stat(gitignore); err == nil {
+		return nil
+	}
+
+	if v.PullOptions.Gitignore {
+		if err := os.WriteFile(gitignore, []byte(gitignoreContent), 0600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", gitignore, err)
+		}
+		v.printf("Written: %s\n", gitignore)
+		return nil
+	}
+
+	if root := findGitRoot(outputDir); root != "" {
+		v.warnf("output directory %s is inside the git repository %s; pulled secrets are plaintext and could be committed\n", outputDir, root)
+	}
+	return nil
+}
+
+// findGitRoot returns the nearest directory at or above dir containing a .git
+// entry (a directory, or a file for worktrees and submodules), or "" if dir
+// is not inside a git work tree.
+func findGitRoot(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,84 @@
+package vaultsync
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gitignoreTestSecrets is kv/app holding a single secret.
+var gitignoreTestSecrets = map[string]map[string]any{
+	"kv/app/db": {"password": "s3cret"},
+}
+
+func TestPullSecretsToFilesWritesGitignore(t *testing.T) {
+	t.Parallel()
+
+	client := newFakeVault(t, gitignoreTestSecrets).client()
+	client.PullOptions.Gitignore = true
+
+	outputDir := t.TempDir()
+	if err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), outputDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, ".gitignore"))
+	if err != nil {
+		t.Fatalf("expected a .gitignore: %v", err)
+	}
+	if string(content) != gitignoreContent {
+		t.Fatalf("unexpected .gitignore content %q", content)
+	}
+}
+
+func TestPullSecretsToFilesKeepsExistingGitignore(t *testing.T) {
+	t.Parallel()
+
+	client := newFakeVault(t, gitignoreTestSecrets).client()
+	client.PullOptions.Gitignore = true
+
+	outputDir := t.TempDir()
+	gitignore := filepath.Join(outputDir, ".gitignore")
+	if err := os.WriteFile(gitignore, []byte("*.yaml\n"), 0644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	if err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), outputDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if content, _ := os.ReadFile(gitignore); string(content) != "*.yaml\n" {
+		t.Fatalf("expected the existing .gitignore to be kept, got %q", content)
+	}
+}
+
+func TestPullSecretsToFilesWarnsInsideGitRepository(t *testing.T) {
+	t.Parallel()
+
+	var errOut bytes.Buffer
+	client := newFakeVault(t, gitignoreTestSecrets).client()
+	client.ErrOutput = &errOut
+
+	repo := t.TempDir()
+	if err := os.Mkdir(filepath.Join(repo, ".git"), 0755); err != nil {
+		t.Fatalf("failed to create fake repository: %v", err)
+	}
+
+	if err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), filepath.Join(repo, "secrets")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(errOut.String(), "inside the git repository "+repo) {
+		t.Fatalf("expected a git repository warning, got %q", errOut.String())
+	}
+}
+
+func TestFindGitRootOutsideRepository(t *testing.T) {
+	t.Parallel()
+
+	if root := findGitRoot(t.TempDir()); root != "" {
+		t.Fatalf("expected no git root for a temp dir, got %q", root)
+	}
+}
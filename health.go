@@ -0,0 +1,91 @@
+package vaultsync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ErrVaultNotReady is returned by WaitForVault when Vault is still not
+// unsealed and active at the end of the wait.
+var ErrVaultNotReady = errors.New("vault is not ready")
+
+// healthPollInterval is the time WaitForVault waits between polls.
+const healthPollInterval = 2 * time.Second
+
+// vaultHealth is the body of a sys/health response.
+type vaultHealth struct {
+	Initialized bool `json:"initialized"`
+	Sealed      bool `json:"sealed"`
+	Standby     bool `json:"standby"`
+}
+
+// WaitForVault polls sys/health until Vault reports that it is initialized,
+// unsealed, and active, so a command started alongside Vault does not race
+// its unsealing. Each poll that finds Vault not ready yet, including one that
+// cannot reach it, is logged as an INFO line to ErrOutput. When timeout
+// elapses first it fails with ErrVaultNotReady and the last state seen.
+func (v *VaultClient) WaitForVault(timeout time.Duration) error {
+	interval := healthPollInterval
+	if v.retryDelay > 0 {
+		interval = v.retryDelay
+	}
+	deadline := time.Now().Add(timeout)
+
+	for {
+		state, err := v.checkHealth(time.Until(deadline))
+		if err != nil {
+			return err
+		}
+		if state == "" {
+			return nil
+		}
+		if !time.Now().Add(interval).Before(deadline) {
+			return fmt.Errorf("%w: %s after waiting %s: %s", ErrVaultNotReady, v.Address, timeout, state)
+		}
+		fmt.Fprintf(v.errOutput(), "INFO: waiting for Vault at %s: %s\n", v.Address, state)
+		time.Sleep(interval)
+	}
+}
+
+// checkHealth makes one sys/health request, giving up after timeout, and
+// describes why Vault is not ready, or returns "" when it is. Failing to
+// reach Vault is a state to wait out, not an error.
+func (v *VaultClient) checkHealth(timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// sys/health is unauthenticated and only served in the root namespace.
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/v1/sys/health", v.Address), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := v.send(req)
+	if errors.Is(err, ErrOperationTimeout) {
+		return "", err
+	}
+	if err != nil {
+		return fmt.Sprintf("unreachable (%v)", err), nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return "", nil
+	}
+	var health vaultHealth
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		return fmt.Sprintf("status %d", resp.StatusCode), nil
+	}
+	switch {
+	case !health.Initialized:
+		return "not initialized", nil
+	case health.Sealed:
+		return "sealed", nil
+	case health.Standby:
+		return "standby", nil
+	}
+	return fmt.Sprintf("status %d", resp.StatusCode), nil
+}
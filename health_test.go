@@ -0,0 +1,67 @@
+package vaultsync
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaitForVaultPollsUntilUnsealed(t *testing.T) {
+	t.Parallel()
+
+	responses := []func() (*http.Response, error){
+		func() (*http.Response, error) { return nil, errors.New("connection refused") },
+		func() (*http.Response, error) {
+			return jsonResponse(t, http.StatusServiceUnavailable, map[string]any{"initialized": true, "sealed": true})
+		},
+		func() (*http.Response, error) {
+			return jsonResponse(t, http.StatusTooManyRequests, map[string]any{"initialized": true, "standby": true})
+		},
+		func() (*http.Response, error) {
+			return jsonResponse(t, http.StatusOK, map[string]any{"initialized": true})
+		},
+	}
+	var errOut bytes.Buffer
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.ErrOutput = &errOut
+	client.retryDelay = time.Millisecond
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path != "/v1/sys/health" || r.Header.Get("X-Vault-Namespace") != "" {
+			t.Errorf("unexpected request %s with namespace %q", r.URL.Path, r.Header.Get("X-Vault-Namespace"))
+		}
+		next := responses[0]
+		responses = responses[1:]
+		return next()
+	})}
+
+	if err := client.WaitForVault(time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(responses) != 0 {
+		t.Fatalf("stopped polling with %d responses left", len(responses))
+	}
+	for _, want := range []string{"INFO: waiting for Vault at https://vault.example: unreachable", ": sealed\n", ": standby\n"} {
+		if !strings.Contains(errOut.String(), want) {
+			t.Errorf("log %q does not contain %q", errOut.String(), want)
+		}
+	}
+}
+
+func TestWaitForVaultTimesOutWhileSealed(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.ErrOutput = nil
+	client.retryDelay = 5 * time.Millisecond
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return jsonResponse(t, http.StatusServiceUnavailable, map[string]any{"initialized": true, "sealed": true})
+	})}
+
+	err := client.WaitForVault(20 * time.Millisecond)
+	if !errors.Is(err, ErrVaultNotReady) || !strings.HasSuffix(err.Error(), ": sealed") {
+		t.Fatalf("expected a not ready error for a sealed Vault, got %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+package vaultsync
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// PullFormatK8sSecret renders each pulled secret as a Kubernetes Secret
+// manifest instead of plain YAML.
+const PullFormatK8sSecret = "k8s-secret"
+
+// defaultK8sNameTemplate names a Kubernetes Secret after the last segment of
+// its Vault path.
+const defaultK8sNameTemplate = "{{.Name}}"
+
+// k8sSecretNameData is what a K8sNameTemplate is executed against.
+type k8sSecretNameData struct {
+	// Path is the secret's path below the pulled path, e.g. "team/db".
+	Path string
+	// Name is the last segment of Path, e.g. "db".
+	Name string
+}
+
+type k8sSecretManifest struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   k8sObjectMeta     `yaml:"metadata"`
+	Type       string            `yaml:"type"`
+	Data       map[string]string `yaml:"data"`
+}
+
+type k8sObjectMeta struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+var (
+	k8sSecretKeyPattern   = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+	k8sNameInvalidPattern = regexp.MustCompile(`[^a-z0-9.-]+`)
+)
+
+// validate rejects unknown formats and format options that cannot work, so a
+// pull fails before anything is written.
+func (o PullOptions) validate() error {
+	if o.Checkpoint != "" && o.Mirror {
+		return fmt.Errorf("a checkpoint cannot be combined with mirroring")
+	}
+	if o.Resume && o.Checkpoint == "" {
+		return fmt.Errorf("resuming requires a checkpoint file")
+	}
+	if o.Checkpoint != "" && o.NameField != "" {
+		return fmt.Errorf("a checkpoint cannot be combined with a name field")
+	}
+	if err := validateKeyPatterns(o.KeyInclude); err != nil {
+		return err
+	}
+	if err := validateKeyPatterns(o.KeyExclude); err != nil {
+		return err
+	}
+
+	if o.Template != "" {
+		if o.Format != "" || o.Explode {
+			return fmt.Errorf("a template cannot be combined with a format or explode")
+		}
+		_, err := parseSecretTemplate(o.Template)
+		return err
+	}
+
+	switch o.Format {
+	case "":
+		return nil
+	case PullFormatK8sSecret:
+		if o.Explode {
+			return fmt.Errorf("format %s cannot be combined with explode", PullFormatK8sSecret)
+		}
+		_, err := parseK8sNameTemplate(o.K8sNameTemplate)
+		return err
+	case PullFormatVaultKV, PullFormatTOML:
+		if o.Explode {
+			return fmt.Errorf("format %s cannot be combined with explode", o.Format)
+		}
+		return nil
+	case PullFormatEnvCombined:
+		if o.Explode || o.Mirror || o.Checkpoint != "" || o.NameField != "" {
+			return fmt.Errorf("format %s cannot be combined with explode, mirroring, a checkpoint, or a name field", PullFormatEnvCombined)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown pull format %q", o.Format)
+	}
+}
+
+// parseK8sNameTemplate parses a Secret naming template, falling back to the
+// default when text is empty.
+func parseK8sNameTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = defaultK8sNameTemplate
+	}
+	tmpl, err := template.New("k8s-name").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Kubernetes name template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// sanitizeK8sName turns name into a valid DNS subdomain name as required for
+// Secret names: lowercase alphanumerics, '-' and '.', starting and ending with
+// an alphanumeric, at most 253 characters.
+func sanitizeK8sName(name string) (string, error) {
+	sanitized := k8sNameInvalidPattern.ReplaceAllString(strings.ToLower(name), "-")
+	if len(sanitized) > 253 {
+		sanitized = sanitized[:253]
+	}
+	sanitized = strings.Trim(sanitized, "-.")
+	if sanitized == "" {
+		return "", fmt.Errorf("cannot derive a Kubernetes Secret name from %q", name)
+	}
+	return sanitized, nil
+}
+
+// renderK8sSecret renders secretData as an Opaque Kubernetes Secret manifest.
+// Values are base64-encoded under data as the Secret API requires; values that
+// are not strings are JSON-encoded first.
+func renderK8sSecret(relativePath string, secretData map[string]interface{}, options PullOptions) ([]byte, error) {
+	tmpl, err := parseK8sNameTemplate(options.K8sNameTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	var name strings.Builder
+	nameData := k8sSecretNameData{Path: relativePath, Name: path.Base(relativePath)}
+	if err := tmpl.Execute(&name, nameData); err != nil {
+		return nil, fmt.Errorf("failed to render Kubernetes Secret name: %w", err)
+	}
+
+	secretName, err := sanitizeK8sName(name.String())
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]string, len(secretData))
+	for key, value := range secretData {
+		if !k8sSecretKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("key %q is not a valid Kubernetes Secret key", key)
+		}
+
+		raw, ok := value.(string)
+		if !ok {
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode key %s: %w", key, err)
+			}
+			raw = string(encoded)
+		}
+		data[key] = base64.StdEncoding.EncodeToString([]byte(raw))
+	}
+
+	manifest := k8sSecretManifest{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata:   k8sObjectMeta{Name: secretName, Namespace: options.K8sNamespace},
+		Type:       "Opaque",
+		Data:       data,
+	}
+	return marshalYAML(manifest, options.YAMLIndent)
+}
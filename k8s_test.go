@@ -0,0 +1,93 @@
+package vaultsync
+
+import (
+	"encoding/base64"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestRenderK8sSecret(t *testing.T) {
+	t.Parallel()
+
+	out, err := renderK8sSecret("app/DB_Creds", map[string]interface{}{
+		"username": "alice",
+		"port":     5432,
+	}, PullOptions{Format: PullFormatK8sSecret, K8sNamespace: "payments"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var manifest k8sSecretManifest
+	if err := yaml.Unmarshal(out, &manifest); err != nil {
+		t.Fatalf("manifest is not valid YAML: %v\n%s", err, out)
+	}
+
+	if manifest.APIVersion != "v1" || manifest.Kind != "Secret" || manifest.Type != "Opaque" {
+		t.Fatalf("unexpected manifest header: %+v", manifest)
+	}
+	if manifest.Metadata.Name != "db-creds" || manifest.Metadata.Namespace != "payments" {
+		t.Fatalf("unexpected metadata: %+v", manifest.Metadata)
+	}
+
+	for key, want := range map[string]string{"username": "alice", "port": "5432"} {
+		decoded, err := base64.StdEncoding.DecodeString(manifest.Data[key])
+		if err != nil || string(decoded) != want {
+			t.Fatalf("expected %s to decode to %q, got %q (%v)", key, want, decoded, err)
+		}
+	}
+}
+
+func TestRenderK8sSecretNameTemplate(t *testing.T) {
+	t.Parallel()
+
+	out, err := renderK8sSecret("team/app/db", map[string]interface{}{"k": "v"},
+		PullOptions{K8sNameTemplate: "vault-{{.Path}}"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var manifest k8sSecretManifest
+	if err := yaml.Unmarshal(out, &manifest); err != nil {
+		t.Fatalf("manifest is not valid YAML: %v", err)
+	}
+	if manifest.Metadata.Name != "vault-team-app-db" {
+		t.Fatalf("expected templated name, got %q", manifest.Metadata.Name)
+	}
+	if manifest.Metadata.Namespace != "" {
+		t.Fatalf("expected namespace to be omitted, got %q", manifest.Metadata.Namespace)
+	}
+}
+
+func TestRenderK8sSecretRejectsInvalidKey(t *testing.T) {
+	t.Parallel()
+
+	if _, err := renderK8sSecret("db", map[string]interface{}{"bad key": "v"}, PullOptions{}); err == nil {
+		t.Fatal("expected error for key with a space, got nil")
+	}
+}
+
+func TestPullSecretsToFilesK8sFormatRejectsBadTemplateUpFront(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.PullOptions.Format = PullFormatK8sSecret
+	client.PullOptions.K8sNameTemplate = "{{.Name"
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		t.Fatalf("unexpected request %s", r.URL.Path)
+		return nil, nil
+	})}
+
+	outputDir := filepath.Join(t.TempDir(), "out")
+	if err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), outputDir); err == nil {
+		t.Fatal("expected template error, got nil")
+	}
+	if _, err := os.Stat(outputDir); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing to be written, got %v", err)
+	}
+}
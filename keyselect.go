@@ -0,0 +1,57 @@
+package vaultsync
+
+import (
+	"fmt"
+	"path"
+	"slices"
+)
+
+// selectKeys returns the top-level keys of secretData that match at least one
+// of include (every key when include is empty) and none of exclude. Patterns
+// use path.Match syntax, e.g. "*_public".
+func selectKeys(secretData map[string]interface{}, include, exclude []string) map[string]interface{} {
+	selected := make(map[string]interface{}, len(secretData))
+	for key, value := range secretData {
+		if len(include) > 0 && !matchesAnyKey(include, key) {
+			continue
+		}
+		if matchesAnyKey(exclude, key) {
+			continue
+		}
+		selected[key] = value
+	}
+	return selected
+}
+
+// selectsKeys reports whether o leaves any keys of pulled secrets out.
+func (o PullOptions) selectsKeys() bool {
+	return len(o.KeyInclude) > 0 || len(o.KeyExclude) > 0 || len(o.DropKeys) > 0
+}
+
+// selectKeys returns the keys of secretData a pull writes under o's
+// KeyInclude, KeyExclude, and DropKeys.
+func (o PullOptions) selectKeys(secretData map[string]interface{}) map[string]interface{} {
+	selected := selectKeys(secretData, o.KeyInclude, o.KeyExclude)
+	for _, key := range o.DropKeys {
+		delete(selected, key)
+	}
+	return selected
+}
+
+func matchesAnyKey(patterns []string, key string) bool {
+	return slices.ContainsFunc(patterns, func(pattern string) bool {
+		matched, _ := path.Match(pattern, key)
+		return matched
+	})
+}
+
+// validateKeyPatterns rejects malformed key selection globs up front, since
+// path.Match only reports them when a key happens to reach the bad part.
+func validateKeyPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid key pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
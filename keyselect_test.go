@@ -0,0 +1,127 @@
+package vaultsync
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSelectKeys(t *testing.T) {
+	t.Parallel()
+
+	data := map[string]interface{}{
+		"api_public":    "pk",
+		"api_private":   "sk",
+		"legacy_public": "old",
+		"host":          "db",
+	}
+
+	tests := []struct {
+		name             string
+		include, exclude []string
+		want             []string
+	}{
+		{name: "include only", include: []string{"*_public"}, want: []string{"api_public", "legacy_public"}},
+		{name: "exclude only", exclude: []string{"*_private"}, want: []string{"api_public", "host", "legacy_public"}},
+		{name: "exclude wins over include", include: []string{"*_public", "host"}, exclude: []string{"legacy_*"}, want: []string{"api_public", "host"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected := selectKeys(data, tt.include, tt.exclude)
+			var got []string
+			for key := range selected {
+				got = append(got, key)
+			}
+			slices.Sort(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected keys %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPullSecretsToFilesWritesOnlySelectedKeys(t *testing.T) {
+	t.Parallel()
+
+	var out strings.Builder
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = &out
+	client.ErrOutput = nil
+	client.PullOptions.KeyInclude = []string{"*_public"}
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		switch r.URL.Path {
+		case "/v1/kv/metadata/app":
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"keys": []string{"api", "db"}}})
+		case "/v1/kv/data/app/api":
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"data": map[string]any{"key_public": "pk", "key_private": "sk"}}})
+		default:
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"data": map[string]any{"password": "pw"}}})
+		}
+	})}
+
+	outputDir := t.TempDir()
+	if err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), outputDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "app", "api.yaml"))
+	if err != nil {
+		t.Fatalf("failed to read pulled file: %v", err)
+	}
+	if string(content) != "key_public: pk\n" {
+		t.Fatalf("expected only the public key, got %q", content)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "app", "db.yaml")); !os.IsNotExist(err) {
+		t.Fatalf("expected a secret without selected keys not to be written, stat err %v", err)
+	}
+	if !strings.Contains(out.String(), "Skipping: kv/metadata/app/db (no selected keys)") {
+		t.Fatalf("expected the skipped secret to be reported, got:\n%s", out.String())
+	}
+}
+
+func TestPullSecretsToFilesRejectsInvalidKeyPattern(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.PullOptions.KeyExclude = []string{"[a-"}
+	if err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), t.TempDir()); err == nil {
+		t.Fatalf("expected an invalid pattern error")
+	}
+}
+
+func TestPullSecretsToFilesDropsKeys(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = nil
+	client.ErrOutput = nil
+	// Dropped keys are names, not globs, and are applied after KeyExclude.
+	client.PullOptions.KeyExclude = []string{"legacy_*"}
+	client.PullOptions.DropKeys = []string{"_rotated_by", "owner*"}
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path == "/v1/kv/metadata/app" {
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"keys": []string{"api"}}})
+		}
+		return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"data": map[string]any{
+			"token": "t", "_rotated_by": "ci", "owner_team": "web", "legacy_token": "old",
+		}}})
+	})}
+
+	outputDir := t.TempDir()
+	if err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), outputDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "app", "api.yaml"))
+	if err != nil {
+		t.Fatalf("failed to read pulled file: %v", err)
+	}
+	if want := "owner_team: web\ntoken: t\n"; string(content) != want {
+		t.Fatalf("expected %q, got %q", want, content)
+	}
+}
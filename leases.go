@@ -0,0 +1,48 @@
+package vaultsync
+
+import (
+	"fmt"
+)
+
+// trackLease remembers the lease of a response so RevokeLeases can revoke it.
+// KV responses carry no lease, so an empty leaseID is ignored.
+func (v *VaultClient) trackLease(leaseID string) {
+	if leaseID == "" {
+		return
+	}
+	v.leasesMu.Lock()
+	defer v.leasesMu.Unlock()
+	v.leases = append(v.leases, leaseID)
+}
+
+// Leases returns the IDs of the leases created by the client's reads and
+// writes that have not been revoked yet, oldest first. Dynamic secrets such
+// as database credentials come with a lease; KV secrets do not.
+func (v *VaultClient) Leases() []string {
+	v.leasesMu.Lock()
+	defer v.leasesMu.Unlock()
+	return append([]string(nil), v.leases...)
+}
+
+// RevokeLeases revokes every lease returned by Leases through
+// sys/leases/revoke, so the short-lived credentials fetched by a run do not
+// outlive it. Leases that fail to revoke are kept for a retry and reported
+// together in the returned error. With no leases it makes no requests.
+func (v *VaultClient) RevokeLeases() error {
+	var failed []string
+	errs := &MultiError{}
+	for _, leaseID := range v.Leases() {
+		if _, err := v.WriteRaw("sys/leases/revoke", map[string]interface{}{"lease_id": leaseID}); err != nil {
+			failed = append(failed, leaseID)
+			errs.Add(leaseID, fmt.Errorf("failed to revoke lease: %w", err))
+			continue
+		}
+		// stderr, so revoking does not mix into output such as read's JSON
+		fmt.Fprintf(v.errOutput(), "Revoked lease: %s\n", leaseID)
+	}
+
+	v.leasesMu.Lock()
+	v.leases = failed
+	v.leasesMu.Unlock()
+	return errs.ErrorOrNil()
+}
@@ -0,0 +1,71 @@
+package vaultsync
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRevokeLeasesRevokesTrackedLeases(t *testing.T) {
+	t.Parallel()
+
+	var revoked []string
+	failRevoke := true
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		switch r.URL.Path {
+		case "/v1/database/creds/readonly":
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"lease_id": "database/creds/readonly/abc",
+				"data":     map[string]any{"username": "v-user"},
+			})
+		case "/v1/kv/data/app":
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"lease_id": "",
+				"data":     map[string]any{"data": map[string]any{"k": "v"}},
+			})
+		case "/v1/sys/leases/revoke":
+			body, _ := io.ReadAll(r.Body)
+			if failRevoke {
+				return textResponse(http.StatusInternalServerError, "boom"), nil
+			}
+			revoked = append(revoked, string(body))
+			return textResponse(http.StatusNoContent, ""), nil
+		}
+		t.Fatalf("unexpected request %s", r.URL.Path)
+		return nil, nil
+	})}
+
+	if _, err := client.ReadRaw("database/creds/readonly"); err != nil {
+		t.Fatalf("ReadRaw() error = %v", err)
+	}
+	if _, err := client.ReadRaw("kv/data/app"); err != nil {
+		t.Fatalf("ReadRaw() error = %v", err)
+	}
+	if got, want := client.Leases(), []string{"database/creds/readonly/abc"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Leases() = %v, want %v", got, want)
+	}
+
+	if err := client.RevokeLeases(); err == nil || !strings.Contains(err.Error(), "database/creds/readonly/abc") {
+		t.Fatalf("RevokeLeases() error = %v, want the failed lease named", err)
+	}
+	if len(client.Leases()) != 1 {
+		t.Fatalf("expected the failed lease to be kept for a retry, got %v", client.Leases())
+	}
+
+	failRevoke = false
+	if err := client.RevokeLeases(); err != nil {
+		t.Fatalf("RevokeLeases() error = %v", err)
+	}
+	if want := []string{`{"lease_id":"database/creds/readonly/abc"}`}; !reflect.DeepEqual(revoked, want) {
+		t.Fatalf("revoke requests = %v, want %v", revoked, want)
+	}
+	if len(client.Leases()) != 0 {
+		t.Fatalf("expected no leases left, got %v", client.Leases())
+	}
+}
@@ -0,0 +1,249 @@
+package vaultsync
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultLintMaxValueSize is the value size above which lint reports a value
+// as suspiciously large, unless LintOptions.MaxValueSize overrides it.
+const DefaultLintMaxValueSize = 64 * 1024
+
+// LintOptions configures LintSecretFiles.
+type LintOptions struct {
+	// Extensions selects the secret files like PushOptions.Extensions; empty
+	// means *.yaml.
+	Extensions []string
+
+	// MaxValueSize is the length in bytes above which a string value is
+	// reported. Zero uses DefaultLintMaxValueSize.
+	MaxValueSize int
+
+	// FollowSymlinks follows symlinks like PushOptions.FollowSymlinks.
+	FollowSymlinks bool
+}
+
+// LintIssue is one problem found in a secret file.
+type LintIssue struct {
+	File    string
+	Message string
+}
+
+func (i LintIssue) String() string {
+	return i.File + ": " + i.Message
+}
+
+// LintSecretFiles checks the secret files below dir for mistakes a push would
+// carry into Vault, without contacting Vault: files that do not parse or hold
+// something other than a map of keys to values, empty secrets, invalid
+// _options blocks or sidecar files, keys with leading or trailing whitespace,
+// string values longer than MaxValueSize, and files that map to the same
+// Vault path, e.g. db.yaml next to db.yaml.d/ or db.yaml and db.json with
+// several Extensions.
+// Files are selected and mapped to Vault paths exactly as push does; files
+// marked "# vaultsync: skip" are not checked, and files marked
+// "# vaultsync: empty" may hold no keys. Exploded secret directories are
+// always read as exploded.
+//
+// Issues are returned in walk order. The error is reserved for failures to
+// walk dir itself.
+func LintSecretFiles(dir string, options LintOptions) ([]LintIssue, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to access %s: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	maxValueSize := options.MaxValueSize
+	if maxValueSize == 0 {
+		maxValueSize = DefaultLintMaxValueSize
+	}
+
+	var issues []LintIssue
+	report := func(file, format string, args ...interface{}) {
+		issues = append(issues, LintIssue{File: file, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// seen maps each secret path to the first file that produced it.
+	seen := make(map[string]string)
+	checkPath := func(file, secretFile, extension string) {
+		secretPath, err := secretPathForFile(dir, secretFile, extension)
+		if err != nil {
+			report(file, "%v", err)
+			return
+		}
+		if first, ok := seen[secretPath]; ok {
+			report(file, "maps to the same Vault path %q as %s", secretPath, first)
+			return
+		}
+		seen[secretPath] = file
+	}
+
+	err = walkInputTree(dir, options.FollowSymlinks, func(string, string) {}, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if filePath == dir || !strings.HasSuffix(filePath, explodedSecretSuffix) {
+				return nil
+			}
+			secretFile := strings.TrimSuffix(filePath, explodedSecretSuffix)
+			extension, ok := matchSecretExtension(secretFile, ".yaml", options.Extensions)
+			if !ok {
+				return nil
+			}
+
+			checkPath(filePath, secretFile, extension)
+			secretData, err := readExplodedSecret(filePath)
+			if err != nil {
+				report(filePath, "%v", err)
+				return filepath.SkipDir
+			}
+			if len(secretData) == 0 {
+				report(filePath, "exploded secret has no keys")
+			}
+			lintSecretData(filePath, secretData, maxValueSize, report)
+			return filepath.SkipDir
+		}
+
+		if isSidecarFile(filePath) {
+			if _, err := readSidecarOptions(localStorage{}, filePath); err != nil {
+				report(filePath, "%v", err)
+			}
+			return nil
+		}
+
+		extension, ok := matchSecretExtension(filePath, ".yaml", options.Extensions)
+		if !ok || info.Name() == ".gitignore" {
+			return nil
+		}
+
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			report(filePath, "%v", err)
+			return nil
+		}
+		directives := parseFileDirectives(content)
+		if directives.Skip {
+			return nil
+		}
+
+		checkPath(filePath, filePath, extension)
+		secretData, problem := decodeLintDocument(filePath, content)
+		if problem == lintNoKeys && directives.Empty {
+			// A placeholder written by a PreserveEmpty pull.
+			return nil
+		}
+		if problem != "" {
+			report(filePath, "%s", problem)
+			return nil
+		}
+		lintSecretData(filePath, secretData, maxValueSize, report)
+		return nil
+	})
+	if err != nil {
+		return issues, fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+	return issues, nil
+}
+
+// lintNoKeys is the problem reported for a secret file without keys, unless
+// it is marked "# vaultsync: empty".
+const lintNoKeys = "secret has no keys"
+
+// decodeLintDocument decodes a secret file as parseSecretFile would and
+// describes why it cannot be pushed, or returns the decoded map and "".
+func decodeLintDocument(filePath string, content []byte) (map[string]interface{}, string) {
+	if len(bytes.TrimSpace(content)) == 0 {
+		return nil, "file is empty"
+	}
+
+	var document interface{}
+	if strings.HasSuffix(filePath, tomlExtension) {
+		// Every TOML document is a table.
+		document = map[string]interface{}{}
+	} else if bytes.HasPrefix(bytes.TrimSpace(content), []byte("{")) {
+		if err := json.Unmarshal(content, &document); err != nil {
+			return nil, fmt.Sprintf("invalid JSON: %v", err)
+		}
+	} else if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Sprintf("invalid YAML: %v", err)
+	}
+
+	switch document.(type) {
+	case nil:
+		return nil, "file has no content besides comments"
+	case map[string]interface{}, map[interface{}]interface{}:
+	case []interface{}:
+		return nil, "document is a list, not a map of keys to values"
+	default:
+		return nil, fmt.Sprintf("document is a single %T value, not a map of keys to values", document)
+	}
+
+	secretData, err := parseSecretFile(filePath, content)
+	if err != nil {
+		return nil, err.Error()
+	}
+	if len(secretData) == 0 {
+		return nil, lintNoKeys
+	}
+	return secretData, ""
+}
+
+// lintSecretData reports invalid _options, keys with surrounding whitespace,
+// and oversized string values in one secret.
+func lintSecretData(file string, secretData map[string]interface{}, maxValueSize int, report func(file, format string, args ...interface{})) {
+	if _, _, err := extractSecretOptions(secretData); err != nil {
+		report(file, "%v", err)
+	}
+
+	keys := make([]string, 0, len(secretData))
+	for key := range secretData {
+		if key != secretOptionsKey {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		lintValue(file, key, key, secretData[key], maxValueSize, report)
+	}
+}
+
+// lintValue checks value, found under the dotted path key, and for maps and
+// lists everything below it. name is the map key value is stored under, or
+// "" for a list item.
+func lintValue(file, key, name string, value interface{}, maxValueSize int, report func(file, format string, args ...interface{})) {
+	if name != strings.TrimSpace(name) {
+		report(file, "key %q has leading or trailing whitespace", key)
+	}
+
+	switch typed := value.(type) {
+	case string:
+		if len(typed) > maxValueSize {
+			report(file, "value of key %q is %d bytes, over the %d byte limit", key, len(typed), maxValueSize)
+		}
+	case map[string]interface{}:
+		nested := make([]string, 0, len(typed))
+		for name := range typed {
+			nested = append(nested, name)
+		}
+		slices.Sort(nested)
+		for _, name := range nested {
+			lintValue(file, key+"."+name, name, typed[name], maxValueSize, report)
+		}
+	case []interface{}:
+		for i, item := range typed {
+			lintValue(file, fmt.Sprintf("%s[%d]", key, i), "", item, maxValueSize, report)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package vaultsync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeLintFixtures(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatalf("failed to create fixture dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("failed to write fixture: %v", err)
+		}
+	}
+}
+
+func TestLintSecretFilesReportsProblems(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeLintFixtures(t, dir, map[string]string{
+		"app/good.yaml":               "password: pw\nnested:\n    user: admin\n",
+		"app/empty.yaml":              "\n",
+		"app/comments.yaml":           "# nothing here\n",
+		"app/list.yaml":               "- a\n- b\n",
+		"app/broken.yaml":             "key: [unclosed\n",
+		"app/spaces.yaml":             "'token ': x\nnested:\n    ' user': admin\n",
+		"app/big.yaml":                "cert: " + strings.Repeat("x", 20) + "\n",
+		"app/db.yaml":                 "password: pw\n",
+		"app/db.yaml.d/password":      "pw\n",
+		"app/db.yaml.meta":            "bogus: 1\n",
+		"app/good.yaml.meta":          "max_versions: 2\n",
+		"app/options.yaml":            "_options:\n    bogus: 1\nkey: value\n",
+		"app/skipped.yaml":            "# vaultsync: skip\n' padded': x\n",
+		"app/notes.txt":               "- not a secret file\n",
+		"app/.gitignore":              "*\n",
+		"app/nested/ok.yaml":          "a: b\n",
+		"app/nested/emptykeys.yaml":   "{}\n",
+		"app/nested/placeholder.yaml": emptySecretFile,
+	})
+
+	issues, err := LintSecretFiles(dir, LintOptions{MaxValueSize: 10})
+	if err != nil {
+		t.Fatalf("LintSecretFiles() error = %v", err)
+	}
+
+	got := make(map[string][]string)
+	for _, issue := range issues {
+		rel, _ := filepath.Rel(dir, issue.File)
+		got[rel] = append(got[rel], issue.Message)
+	}
+
+	want := map[string]string{
+		"app/empty.yaml":            "file is empty",
+		"app/comments.yaml":         "no content besides comments",
+		"app/list.yaml":             "document is a list",
+		"app/broken.yaml":           "invalid YAML",
+		"app/big.yaml":              `value of key "cert" is 20 bytes, over the 10 byte limit`,
+		"app/db.yaml.d":             `maps to the same Vault path "app/db" as ` + filepath.Join(dir, "app/db.yaml"),
+		"app/db.yaml.meta":          "bogus",
+		"app/options.yaml":          "bogus",
+		"app/nested/emptykeys.yaml": "secret has no keys",
+	}
+	for file, fragment := range want {
+		if len(got[file]) != 1 || !strings.Contains(got[file][0], fragment) {
+			t.Fatalf("issues for %s = %q, want one containing %q", file, got[file], fragment)
+		}
+	}
+	if spaces := got["app/spaces.yaml"]; len(spaces) != 2 ||
+		!strings.Contains(spaces[0], `"nested. user"`) || !strings.Contains(spaces[1], `"token "`) {
+		t.Fatalf("issues for app/spaces.yaml = %q, want both padded keys", spaces)
+	}
+	if len(got) != len(want)+1 {
+		t.Fatalf("unexpected issues: %q", got)
+	}
+}
+
+func TestLintSecretFilesHonorsExtensions(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeLintFixtures(t, dir, map[string]string{
+		"db.yaml": "password: pw\n",
+		"db.json": `{"password": "pw"}`,
+	})
+
+	issues, err := LintSecretFiles(dir, LintOptions{})
+	if err != nil || len(issues) != 0 {
+		t.Fatalf("LintSecretFiles() = %v, %v; want no issues for *.yaml only", issues, err)
+	}
+
+	issues, err = LintSecretFiles(dir, LintOptions{Extensions: []string{".yaml", ".json"}})
+	if err != nil {
+		t.Fatalf("LintSecretFiles() error = %v", err)
+	}
+	if len(issues) != 1 || !strings.Contains(issues[0].Message, `same Vault path "db"`) {
+		t.Fatalf("LintSecretFiles() issues = %v, want one duplicate path", issues)
+	}
+}
@@ -0,0 +1,295 @@
+package vaultsync
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Values of VaultClient.FolderDetect.
+const (
+	FolderDetectSlash = "slash"
+	FolderDetectProbe = "probe"
+)
+
+// errListingStopped marks listings abandoned because their walk ended.
+var errListingStopped = errors.New("listing stopped")
+
+// folderLister lists the folders of a tree walk. With ListConcurrency above
+// one it lists ahead of the walk: as soon as a folder is listed, listing of
+// each of its subfolders starts in the background, with at most
+// ListConcurrency requests in flight, so sibling folders are listed
+// concurrently while the walk itself still visits them one by one in sorted
+// order. Otherwise each folder is listed only when the walk reaches it.
+type folderLister struct {
+	v *VaultClient
+
+	// sem bounds the listing requests in flight; nil lists serially.
+	sem chan struct{}
+	// stopped is closed when the walk ends, so listings it will never use
+	// are not started.
+	stopped chan struct{}
+}
+
+// folderListing is the listing of one folder, sorted. In concurrent mode done
+// is closed once keys and err are set, and folders holds the listings started
+// for its subfolders, by key.
+type folderListing struct {
+	path    string
+	done    chan struct{}
+	keys    []string
+	err     error
+	folders map[string]*folderListing
+}
+
+// newFolderLister returns a lister for one walk; the caller must call stop
+// when the walk ends.
+func (v *VaultClient) newFolderLister() *folderLister {
+	lister := &folderLister{v: v, stopped: make(chan struct{})}
+	if v.ListConcurrency > 1 {
+		lister.sem = make(chan struct{}, v.ListConcurrency)
+	}
+	return lister
+}
+
+func (l *folderLister) stop() {
+	close(l.stopped)
+}
+
+// start begins listing path, in the background in concurrent mode.
+func (l *folderLister) start(path string) *folderListing {
+	listing := &folderListing{path: path}
+	if l.sem == nil {
+		return listing
+	}
+
+	listing.done = make(chan struct{})
+	go func() {
+		defer close(listing.done)
+
+		select {
+		case <-l.stopped:
+			listing.err = errListingStopped
+			return
+		default:
+		}
+		select {
+		case <-l.stopped:
+			listing.err = errListingStopped
+			return
+		case l.sem <- struct{}{}:
+		}
+		listing.keys, listing.err = l.list(path)
+		<-l.sem
+
+		if listing.err != nil || l.v.PullOptions.NoRecurse {
+			return
+		}
+		listing.folders = make(map[string]*folderListing)
+		for _, key := range listing.keys {
+			if ParseListKey(key).IsFolder {
+				listing.folders[key] = l.start(path + "/" + strings.TrimSuffix(key, "/"))
+			}
+		}
+	}()
+	return listing
+}
+
+// wait returns the sorted keys of listing, listing it now in serial mode.
+func (l *folderLister) wait(listing *folderListing) ([]string, error) {
+	if listing.done == nil {
+		listing.keys, listing.err = l.list(listing.path)
+	} else {
+		<-listing.done
+	}
+	return listing.keys, listing.err
+}
+
+// hold waits for the listings in flight and keeps new ones from starting
+// until release is called, so a batch pause also pauses listing ahead.
+func (l *folderLister) hold() (release func()) {
+	if l.sem == nil {
+		return func() {}
+	}
+	for i := 0; i < cap(l.sem); i++ {
+		l.sem <- struct{}{}
+	}
+	return func() {
+		for i := 0; i < cap(l.sem); i++ {
+			<-l.sem
+		}
+	}
+}
+
+// folder returns the listing of the subfolder key of listing, starting it if
+// it was not listed ahead.
+func (l *folderLister) folder(listing *folderListing, key string) *folderListing {
+	if child, ok := listing.folders[key]; ok {
+		return child
+	}
+	return l.start(listing.path + "/" + strings.TrimSuffix(key, "/"))
+}
+
+func (l *folderLister) list(path string) ([]string, error) {
+	keys, err := l.v.ListSecretsAt(secretRefFromMetadataPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+// probeFolders finds the folders among keys listed below ref whose trailing
+// slash was stripped, e.g. by a proxy normalizing paths, by listing each key
+// without one: a key that can be listed is a folder and gets its slash back,
+// and one Vault answers with 404 is a secret. A folder is then read as a
+// secret too, unless it was listed twice, and a key that is both is kept as
+// both. Up to ListConcurrency keys are probed at a time.
+func (v *VaultClient) probeFolders(ref SecretRef, keys []string) ([]string, error) {
+	type probe struct {
+		folder, secret bool
+		err            error
+	}
+	listed := make(map[string]int, len(keys))
+	for _, key := range keys {
+		listed[key]++
+	}
+	var unprobed []string
+	probes := make(map[string]*probe, len(keys))
+	for _, key := range keys {
+		entry := ParseListKey(key)
+		if entry.Name != "" && !entry.IsFolder && probes[key] == nil {
+			unprobed = append(unprobed, key)
+			probes[key] = &probe{}
+		}
+	}
+
+	forEachConcurrently(v.ListConcurrency, len(unprobed), func(i int) {
+		key := unprobed[i]
+		result := probes[key]
+		child := NewSecretRef(ref.Engine, ref.Path+"/"+key)
+		_, status, err := v.listKeys(child)
+		switch {
+		case err == nil:
+			result.folder = true
+		case status == http.StatusNotFound:
+			return
+		default:
+			result.err = fmt.Errorf("failed to probe %s: %w", child.MetadataPath(), err)
+			return
+		}
+
+		if listed[key] > 1 {
+			result.secret = true
+			return
+		}
+		err = v.getJSON(v.kvAPIPath("metadata", child), &struct{}{})
+		var httpErr *HTTPError
+		switch {
+		case err == nil:
+			result.secret = true
+		case errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound:
+		default:
+			result.err = fmt.Errorf("failed to probe %s: %w", child.MetadataPath(), err)
+		}
+	})
+
+	probed := make([]string, 0, len(keys))
+	emitted := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		result, ok := probes[key]
+		if !ok {
+			probed = append(probed, key)
+			continue
+		}
+		if result.err != nil {
+			return nil, result.err
+		}
+		if !result.folder {
+			probed = append(probed, key)
+			continue
+		}
+		if emitted[key] {
+			continue
+		}
+		emitted[key] = true
+		if result.secret {
+			probed = append(probed, key)
+		}
+		probed = append(probed, key+"/")
+	}
+	return probed, nil
+}
+
+// SortListing sorts the keys of a folder listing by name, ignoring case, for
+// output that is the same on every run whatever order Vault returned the keys
+// in. Keys differing only in case keep a fixed byte order. With foldersFirst,
+// folders (keys ending in "/") come before secrets.
+func SortListing(keys []string, foldersFirst bool) {
+	slices.SortFunc(keys, func(a, b string) int {
+		if foldersFirst {
+			aFolder, bFolder := ParseListKey(a).IsFolder, ParseListKey(b).IsFolder
+			if aFolder != bFolder {
+				if aFolder {
+					return -1
+				}
+				return 1
+			}
+		}
+		if c := strings.Compare(strings.ToLower(a), strings.ToLower(b)); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+}
+
+// ListEntry is one key of a folder listing.
+type ListEntry struct {
+	// Name is the key without the trailing slash Vault marks folders with.
+	Name     string
+	IsFolder bool
+}
+
+// Key returns the entry as Vault lists it, with the trailing slash of a
+// folder.
+func (e ListEntry) Key() string {
+	if e.IsFolder {
+		return e.Name + "/"
+	}
+	return e.Name
+}
+
+// ParseListKey classifies a key returned by a Vault LIST: keys ending in "/"
+// are folders. Keys naming nothing, such as "" or a lone "/", which odd list
+// responses can contain, yield an entry with an empty Name.
+func ParseListKey(key string) ListEntry {
+	if strings.Trim(key, "/") == "" {
+		return ListEntry{}
+	}
+	if name, ok := strings.CutSuffix(key, "/"); ok {
+		return ListEntry{Name: name, IsFolder: true}
+	}
+	return ListEntry{Name: key}
+}
+
+// ListSecretsTypedAt is ListSecretsAt with each key classified as a folder or
+// a secret. Invalid keys are skipped with a warning.
+func (v *VaultClient) ListSecretsTypedAt(ref SecretRef) ([]ListEntry, error) {
+	keys, err := v.ListSecretsAt(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]ListEntry, 0, len(keys))
+	for _, key := range keys {
+		entry := ParseListKey(key)
+		if entry.Name == "" {
+			v.warnf("skipping invalid key %q listed under %s\n", key, ref.MetadataPath())
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,278 @@
+package vaultsync
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newWideTree serves kv/root with folders f0..f5, each holding one secret
+// and one nested folder with another secret. List requests are slowed down
+// so that overlapping ones can be observed.
+func newWideTree(t *testing.T) *fakeVault {
+	t.Helper()
+
+	secrets := make(map[string]map[string]any)
+	for i := 0; i < 6; i++ {
+		folder := fmt.Sprintf("kv/root/f%d", i)
+		secrets[folder+"/secret"] = map[string]any{"path": folder + "/secret"}
+		secrets[folder+"/nested/deep"] = map[string]any{"path": folder + "/nested/deep"}
+	}
+	vault := newFakeVault(t, secrets)
+	vault.listDelay = 10 * time.Millisecond
+	return vault
+}
+
+func TestWalkSecretsListConcurrencyKeepsOrder(t *testing.T) {
+	t.Parallel()
+
+	walk := func(concurrency int) ([]string, int) {
+		vault := newWideTree(t)
+		client := vault.client()
+		client.ListConcurrency = concurrency
+
+		var visited []string
+		fetchErr, visitErr := client.walkSecrets("kv/metadata/root", func(fullPath string, _ map[string]interface{}) error {
+			visited = append(visited, fullPath)
+			return nil
+		})
+		if fetchErr != nil || visitErr != nil {
+			t.Fatalf("walkSecrets() = %v, %v", fetchErr, visitErr)
+		}
+		return visited, vault.listOverlap()
+	}
+
+	serial, serialInFlight := walk(0)
+	concurrent, concurrentInFlight := walk(3)
+
+	if len(serial) != 12 || serial[0] != "kv/metadata/root/f0/nested/deep" {
+		t.Fatalf("unexpected serial walk %v", serial)
+	}
+	if !reflect.DeepEqual(concurrent, serial) {
+		t.Fatalf("concurrent walk order %v differs from serial %v", concurrent, serial)
+	}
+	if serialInFlight != 1 {
+		t.Fatalf("serial walk had %d list requests in flight", serialInFlight)
+	}
+	if concurrentInFlight < 2 || concurrentInFlight > 3 {
+		t.Fatalf("concurrent walk had %d list requests in flight, want 2-3", concurrentInFlight)
+	}
+}
+
+func TestListSecretTreeListConcurrency(t *testing.T) {
+	t.Parallel()
+
+	client := newWideTree(t).client()
+	client.ListConcurrency = 4
+
+	secrets, err := client.listSecretTree("kv/metadata/root")
+	if err != nil {
+		t.Fatalf("listSecretTree() error = %v", err)
+	}
+	if len(secrets) != 12 || secrets[0] != "kv/metadata/root/f0/nested/deep" || secrets[11] != "kv/metadata/root/f5/secret" {
+		t.Fatalf("unexpected listing %v", secrets)
+	}
+}
+
+func TestSortListing(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		foldersFirst bool
+		want         []string
+	}{
+		{
+			name: "by name ignoring case",
+			want: []string{"api", "App", "app", "db/", "Zeta", "zeta/"},
+		},
+		{
+			name:         "folders first",
+			foldersFirst: true,
+			want:         []string{"db/", "zeta/", "api", "App", "app", "Zeta"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			keys := []string{"zeta/", "app", "Zeta", "db/", "App", "api"}
+			SortListing(keys, tt.foldersFirst)
+			if !reflect.DeepEqual(keys, tt.want) {
+				t.Errorf("SortListing = %v, want %v", keys, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseListKey(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]ListEntry{
+		"db":     {Name: "db"},
+		"team/":  {Name: "team", IsFolder: true},
+		"":       {},
+		"/":      {},
+		"a b/":   {Name: "a b", IsFolder: true},
+		"v1.yml": {Name: "v1.yml"},
+	}
+	for key, want := range tests {
+		got := ParseListKey(key)
+		if got != want {
+			t.Errorf("ParseListKey(%q) = %+v, want %+v", key, got, want)
+		}
+		if want.Name != "" && got.Key() != key {
+			t.Errorf("ParseListKey(%q).Key() = %q", key, got.Key())
+		}
+	}
+}
+
+func TestListSecretsTypedAt(t *testing.T) {
+	t.Parallel()
+
+	var warnings strings.Builder
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = nil
+	client.ErrOutput = &warnings
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path != "/v1/kv/metadata/app" || r.URL.RawQuery != "list=true" {
+			return textResponse(http.StatusNotFound, "not found"), nil
+		}
+		return jsonResponse(t, http.StatusOK, map[string]any{
+			"data": map[string]any{"keys": []string{"db", "team/", "/"}},
+		})
+	})}
+
+	entries, err := client.ListSecretsTypedAt(NewSecretRef("kv", "app"))
+	if err != nil {
+		t.Fatalf("ListSecretsTypedAt: %v", err)
+	}
+	want := []ListEntry{{Name: "db"}, {Name: "team", IsFolder: true}}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("entries = %+v, want %+v", entries, want)
+	}
+	if !strings.Contains(warnings.String(), `skipping invalid key "/"`) {
+		t.Errorf("warnings = %q, want the invalid key reported", warnings.String())
+	}
+}
+
+func TestFolderDetectProbe(t *testing.T) {
+	t.Parallel()
+
+	// The proxy in front of this Vault strips the trailing slash of folders.
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.FolderDetect = FolderDetectProbe
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		switch {
+		case r.URL.Path == "/v1/kv/metadata/app" && r.URL.RawQuery == "list=true":
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"keys": []string{"db", "team"}}})
+		case r.URL.Path == "/v1/kv/metadata/app/team" && r.URL.RawQuery == "list=true":
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"keys": []string{"api"}}})
+		case r.URL.Path == "/v1/kv/data/app/db" || r.URL.Path == "/v1/kv/data/app/team/api":
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"data": map[string]any{"k": "v"}}})
+		default:
+			return textResponse(http.StatusNotFound, `{"errors":[]}`), nil
+		}
+	})}
+
+	keys, err := client.ListSecretsAt(NewSecretRef("kv", "app"))
+	if err != nil {
+		t.Fatalf("ListSecretsAt: %v", err)
+	}
+	if want := []string{"db", "team/"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+
+	var visited []string
+	fetchErr, visitErr := client.walkSecrets("kv/metadata/app", func(secretPath string, _ map[string]interface{}) error {
+		visited = append(visited, secretPath)
+		return nil
+	})
+	if fetchErr != nil || visitErr != nil {
+		t.Fatalf("walkSecrets: %v, %v", fetchErr, visitErr)
+	}
+	if want := []string{"kv/metadata/app/db", "kv/metadata/app/team/api"}; !reflect.DeepEqual(visited, want) {
+		t.Errorf("visited = %v, want %v", visited, want)
+	}
+}
+
+func TestFolderDetectProbeReportsProbeFailures(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.FolderDetect = FolderDetectProbe
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path == "/v1/kv/metadata/app" {
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"keys": []string{"db"}}})
+		}
+		return textResponse(http.StatusForbidden, "permission denied"), nil
+	})}
+
+	_, err := client.ListSecretsAt(NewSecretRef("kv", "app"))
+	if err == nil || !strings.Contains(err.Error(), "failed to probe kv/metadata/app/db") {
+		t.Fatalf("error = %v, want the failed probe reported", err)
+	}
+}
+
+func TestFolderDetectProbeKeepsSecretsThatAreFolders(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var probes []string
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.FolderDetect = FolderDetectProbe
+	client.ListConcurrency = 4
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		mu.Lock()
+		probes = append(probes, r.URL.String())
+		mu.Unlock()
+		switch {
+		case r.URL.Path == "/v1/kv/metadata/app" && r.URL.RawQuery == "list=true":
+			// "shared" is listed twice, as secret and folder; "team" once.
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"keys": []string{"db", "shared", "shared", "team"}}})
+		case r.URL.RawQuery == "list=true" && (r.URL.Path == "/v1/kv/metadata/app/shared" || r.URL.Path == "/v1/kv/metadata/app/team"):
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"keys": []string{"api"}}})
+		case r.URL.Path == "/v1/kv/metadata/app/team" && r.URL.RawQuery == "":
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"current_version": 1}})
+		default:
+			return textResponse(http.StatusNotFound, `{"errors":[]}`), nil
+		}
+	})}
+
+	keys, err := client.ListSecretsAt(NewSecretRef("kv", "app"))
+	if err != nil {
+		t.Fatalf("ListSecretsAt: %v", err)
+	}
+	if want := []string{"db", "shared", "shared/", "team", "team/"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+	for _, probe := range probes {
+		if probe == "https://vault.example/v1/kv/metadata/app/shared" {
+			t.Errorf("read %s although it was listed as both", probe)
+		}
+	}
+}
+
+func TestListSecretsAtReportsStatus(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return textResponse(http.StatusForbidden, "permission denied"), nil
+	})}
+
+	_, err := client.ListSecretsAt(NewSecretRef("kv", "app"))
+	if err == nil || err.Error() != "HTTP 403: permission denied" {
+		t.Fatalf("error = %v, want HTTP 403: permission denied", err)
+	}
+}
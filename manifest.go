@@ -0,0 +1,156 @@
+package vaultsync
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ManifestFileName is the integrity manifest a pull with PullOptions.Manifest
+// writes into its output directory. Each line holds the SHA-256 of one file
+// and its path relative to the directory, in the format of sha256sum, so
+// `sha256sum -c` can check it too.
+const ManifestFileName = ".vaultsync.sha256"
+
+// ManifestMismatch is one file that does not match the manifest.
+type ManifestMismatch struct {
+	File    string
+	Problem string
+}
+
+func (m ManifestMismatch) String() string {
+	return m.File + ": " + m.Problem
+}
+
+// WriteManifest records the SHA-256 of every file below dir in dir's
+// manifest, replacing any previous one. Hidden files and directories, such as
+// the manifest itself and .gitignore, are left out.
+func WriteManifest(dir string) error {
+	sums, err := hashManifestFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	paths := make([]string, 0, len(sums))
+	for path := range sums {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+
+	var buf bytes.Buffer
+	for _, path := range paths {
+		fmt.Fprintf(&buf, "%s  %s\n", sums[path], path)
+	}
+
+	manifestPath := filepath.Join(dir, ManifestFileName)
+	if err := os.WriteFile(manifestPath, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", manifestPath, err)
+	}
+	return nil
+}
+
+// VerifyManifest checks the files below dir against dir's manifest and
+// returns every mismatch, sorted by file: files whose content changed,
+// files that are missing, and files the manifest does not list.
+func VerifyManifest(dir string) ([]ManifestMismatch, error) {
+	manifestPath := filepath.Join(dir, ManifestFileName)
+	want, err := readManifest(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	got, err := hashManifestFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var mismatches []ManifestMismatch
+	for path, sum := range want {
+		switch actual, ok := got[path]; {
+		case !ok:
+			mismatches = append(mismatches, ManifestMismatch{File: path, Problem: "missing"})
+		case actual != sum:
+			mismatches = append(mismatches, ManifestMismatch{File: path, Problem: "content does not match the manifest"})
+		}
+	}
+	for path := range got {
+		if _, ok := want[path]; !ok {
+			mismatches = append(mismatches, ManifestMismatch{File: path, Problem: "not in the manifest"})
+		}
+	}
+	slices.SortFunc(mismatches, func(a, b ManifestMismatch) int {
+		return strings.Compare(a.File, b.File)
+	})
+	return mismatches, nil
+}
+
+// hashManifestFiles returns the hex SHA-256 of each non-hidden file below
+// dir, keyed by its slash-separated path relative to dir. A symlink or other
+// special file, which a pull never writes, is an error.
+func hashManifestFiles(dir string) (map[string]string, error) {
+	sums := make(map[string]string)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		if strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("%s is not a regular file", path)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		sum := sha256.Sum256(content)
+		sums[filepath.ToSlash(rel)] = hex.EncodeToString(sum[:])
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash files in %s: %w", dir, err)
+	}
+	return sums, nil
+}
+
+// readManifest parses the sha256sum-style manifest at manifestPath.
+func readManifest(manifestPath string) (map[string]string, error) {
+	file, err := os.Open(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	defer file.Close()
+
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		sum, path, ok := strings.Cut(scanner.Text(), "  ")
+		if _, err := hex.DecodeString(sum); !ok || err != nil || len(sum) != sha256.Size*2 || path == "" {
+			return nil, fmt.Errorf("%s:%d: malformed manifest line", manifestPath, lineNo)
+		}
+		sums[path] = sum
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	return sums, nil
+}
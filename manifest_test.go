@@ -0,0 +1,104 @@
+package vaultsync
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPullSecretsToFilesWritesManifest(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.PullOptions.Manifest = true
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Query().Get("list") == "true" {
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"keys": []string{"db"}}})
+		}
+		return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"data": map[string]any{"password": "s3cret"}}})
+	})}
+
+	outputDir := t.TempDir()
+	if err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), outputDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, ManifestFileName))
+	if err != nil {
+		t.Fatalf("expected a manifest: %v", err)
+	}
+	// The SHA-256 of "password: s3cret\n".
+	want := "cf8600a8a9f24e2aba3a857070eb98b408f5c23e8e7f8d05506013d8d95e608d  app/db.yaml\n"
+	if string(content) != want {
+		t.Fatalf("manifest = %q, want %q", content, want)
+	}
+
+	mismatches, err := VerifyManifest(outputDir)
+	if err != nil || len(mismatches) != 0 {
+		t.Fatalf("expected a freshly pulled directory to verify, got %v, %v", mismatches, err)
+	}
+}
+
+func TestVerifyManifestReportsMismatches(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeLintFixtures(t, dir, map[string]string{
+		"app/db.yaml":             "password: pw\n",
+		"app/cache.yaml":          "host: redis\n",
+		"app/api.yaml.d/token":    "abc\n",
+		"app/removed.yaml":        "gone: soon\n",
+		".gitignore":              "*\n",
+		".hidden/ignored.yaml":    "x: y\n",
+		"app/unchanged-too.yaml":  "a: b\n",
+		"app/api.yaml.d/username": "svc\n",
+	})
+	if err := WriteManifest(dir); err != nil {
+		t.Fatalf("WriteManifest() error = %v", err)
+	}
+
+	writeLintFixtures(t, dir, map[string]string{
+		"app/db.yaml":          "password: tampered\n",
+		"app/api.yaml.d/token": "xyz\n",
+		"app/new.yaml":         "added: later\n",
+		".gitignore":           "changed\n",
+	})
+	if err := os.Remove(filepath.Join(dir, "app", "removed.yaml")); err != nil {
+		t.Fatalf("failed to remove fixture: %v", err)
+	}
+
+	mismatches, err := VerifyManifest(dir)
+	if err != nil {
+		t.Fatalf("VerifyManifest() error = %v", err)
+	}
+	want := []ManifestMismatch{
+		{File: "app/api.yaml.d/token", Problem: "content does not match the manifest"},
+		{File: "app/db.yaml", Problem: "content does not match the manifest"},
+		{File: "app/new.yaml", Problem: "not in the manifest"},
+		{File: "app/removed.yaml", Problem: "missing"},
+	}
+	if !reflect.DeepEqual(mismatches, want) {
+		t.Fatalf("mismatches = %v, want %v", mismatches, want)
+	}
+}
+
+func TestVerifyManifestRejectsMissingOrMalformedManifest(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if _, err := VerifyManifest(dir); err == nil {
+		t.Fatal("expected an error without a manifest")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ManifestFileName), []byte("not-a-sum db.yaml\n"), 0600); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+	if _, err := VerifyManifest(dir); err == nil || !strings.Contains(err.Error(), "malformed manifest line") {
+		t.Fatalf("expected a malformed manifest error, got %v", err)
+	}
+}
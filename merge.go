@@ -0,0 +1,93 @@
+package vaultsync
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Merge strategies for PushOptions.MergeStrategy, deciding how a Patch push
+// combines a local value with the value already in Vault when either is a map
+// or a list. In every strategy a local key set to null removes the key, and
+// keys only present in Vault are kept.
+const (
+	// MergeDeep merges maps recursively and lets a local list replace the
+	// one in Vault, as a JSON merge patch does. It is the default.
+	MergeDeep = "deep"
+	// MergeReplace replaces the value of each local key wholesale, maps
+	// included.
+	MergeReplace = "replace"
+	// MergeAppend merges maps recursively like MergeDeep and appends the
+	// items of a local list to the list in Vault.
+	MergeAppend = "append"
+)
+
+// validateMergeStrategy checks that strategy is one of the merge strategies,
+// or "" for the default.
+func validateMergeStrategy(strategy string) error {
+	switch strategy {
+	case "", MergeDeep, MergeReplace, MergeAppend:
+		return nil
+	}
+	return fmt.Errorf("unknown merge strategy %q (want %s, %s, or %s)", strategy, MergeDeep, MergeReplace, MergeAppend)
+}
+
+// mergeSecretData returns what merging local into existing with strategy
+// leaves, without modifying either.
+func mergeSecretData(existing, local map[string]interface{}, strategy string) map[string]interface{} {
+	merged := make(map[string]interface{}, len(existing)+len(local))
+	for key, value := range existing {
+		merged[key] = value
+	}
+
+	for key, value := range local {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		if strategy == MergeReplace {
+			merged[key] = value
+			continue
+		}
+
+		switch localValue := value.(type) {
+		case map[string]interface{}:
+			existingMap, _ := merged[key].(map[string]interface{})
+			merged[key] = mergeSecretData(existingMap, localValue, strategy)
+		case []interface{}:
+			existingList, ok := merged[key].([]interface{})
+			if strategy != MergeAppend || !ok {
+				merged[key] = value
+				continue
+			}
+			appended := make([]interface{}, 0, len(existingList)+len(localValue))
+			merged[key] = append(append(appended, existingList...), localValue...)
+		default:
+			merged[key] = value
+		}
+	}
+
+	return merged
+}
+
+// mergeSecret writes secretData merged into the secret at ref with strategy.
+// MergeDeep is sent as a PATCH (see PatchSecretAt). The other strategies have
+// no server-side equivalent, so the secret is read and merged here; on KV v2
+// the write uses check-and-set against the version read, so a change made in
+// between fails the write instead of being lost. With cas, for a secret that
+// requires check-and-set, MergeDeep is merged here the same way.
+func (v *VaultClient) mergeSecret(ref SecretRef, secretData map[string]interface{}, strategy string, cas bool) error {
+	if (strategy == "" || strategy == MergeDeep) && !cas {
+		return v.PatchSecretAt(ref, secretData)
+	}
+
+	existing, version, err := v.GetSecretWithVersionAt(ref)
+	if err != nil && !errors.Is(err, ErrSecretNotFound) {
+		return fmt.Errorf("failed to read secret for merge: %w", err)
+	}
+	merged := mergeSecretData(existing, secretData, strategy)
+	if v.isKVv1() && !cas {
+		return v.PutSecretAt(ref, merged)
+	}
+	_, err = v.putSecret(ref, merged, &version)
+	return err
+}
@@ -0,0 +1,164 @@
+package vaultsync
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMergeSecretData(t *testing.T) {
+	t.Parallel()
+
+	existing := map[string]interface{}{
+		"keep":  "a",
+		"db":    map[string]interface{}{"host": "old", "port": 5432},
+		"hosts": []interface{}{"a", "b"},
+	}
+	local := map[string]interface{}{
+		"db":    map[string]interface{}{"host": "new"},
+		"hosts": []interface{}{"c"},
+		"keep":  nil,
+	}
+
+	tests := []struct {
+		strategy string
+		want     map[string]interface{}
+	}{
+		{MergeDeep, map[string]interface{}{
+			"db":    map[string]interface{}{"host": "new", "port": 5432},
+			"hosts": []interface{}{"c"},
+		}},
+		{"", map[string]interface{}{
+			"db":    map[string]interface{}{"host": "new", "port": 5432},
+			"hosts": []interface{}{"c"},
+		}},
+		{MergeReplace, map[string]interface{}{
+			"db":    map[string]interface{}{"host": "new"},
+			"hosts": []interface{}{"c"},
+		}},
+		{MergeAppend, map[string]interface{}{
+			"db":    map[string]interface{}{"host": "new", "port": 5432},
+			"hosts": []interface{}{"a", "b", "c"},
+		}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.strategy, func(t *testing.T) {
+			t.Parallel()
+			got := mergeSecretData(existing, local, tt.strategy)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("merged = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	if !reflect.DeepEqual(existing["hosts"], []interface{}{"a", "b"}) {
+		t.Fatalf("expected existing to be left unmodified, got %#v", existing)
+	}
+}
+
+func TestPushMergeStrategyWritesWithCheckAndSet(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inputDir, "db"), []byte("hosts: [c]\n"), 0644); err != nil {
+		t.Fatalf("failed to write fixture secret: %v", err)
+	}
+
+	var written map[string]interface{}
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = io.Discard
+	client.PushOptions.Patch = true
+	client.PushOptions.MergeStrategy = MergeAppend
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		switch r.Method {
+		case http.MethodGet:
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{
+				"data":     map[string]any{"hosts": []string{"a", "b"}},
+				"metadata": map[string]any{"version": 7},
+			}})
+		case http.MethodPost:
+			if err := json.NewDecoder(r.Body).Decode(&written); err != nil {
+				t.Fatalf("failed to parse request body: %v", err)
+			}
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"version": 8}})
+		}
+		t.Fatalf("unexpected request %s %s", r.Method, r.URL)
+		return nil, nil
+	})}
+
+	if err := client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]interface{}{
+		"data":    map[string]interface{}{"hosts": []interface{}{"a", "b", "c"}},
+		"options": map[string]interface{}{"cas": float64(7)},
+	}
+	if !reflect.DeepEqual(written, want) {
+		t.Fatalf("written = %#v, want %#v", written, want)
+	}
+}
+
+func TestPushPatchHonorsCASDirective(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inputDir, "db"), []byte("# vaultsync: cas\npassword: new\n"), 0644); err != nil {
+		t.Fatalf("failed to write fixture secret: %v", err)
+	}
+
+	var written map[string]interface{}
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = io.Discard
+	client.PushOptions.Patch = true
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		switch r.Method {
+		case http.MethodGet:
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{
+				"data":     map[string]any{"username": "bob", "password": "old"},
+				"metadata": map[string]any{"version": 4},
+			}})
+		case http.MethodPost:
+			if r.URL.Path != "/v1/kv/data/app/db" {
+				return textResponse(http.StatusNoContent, ""), nil
+			}
+			if err := json.NewDecoder(r.Body).Decode(&written); err != nil {
+				t.Fatalf("failed to parse request body: %v", err)
+			}
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"version": 5}})
+		}
+		t.Fatalf("unexpected request %s %s", r.Method, r.URL)
+		return nil, nil
+	})}
+
+	if err := client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]interface{}{
+		"data":    map[string]interface{}{"username": "bob", "password": "new"},
+		"options": map[string]interface{}{"cas": float64(4)},
+	}
+	if !reflect.DeepEqual(written, want) {
+		t.Fatalf("written = %#v, want %#v", written, want)
+	}
+}
+
+func TestPushMergeStrategyRequiresPatch(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.PushOptions.MergeStrategy = MergeReplace
+	if err := client.PushSecretsFromFilesDirectAt(t.TempDir(), NewSecretRef("kv", "app"), false); err == nil {
+		t.Fatal("expected an error for a merge strategy without patch")
+	}
+
+	client.PushOptions.Patch = true
+	client.PushOptions.MergeStrategy = "shallow"
+	if err := client.PushSecretsFromFilesDirectAt(t.TempDir(), NewSecretRef("kv", "app"), false); err == nil {
+		t.Fatal("expected an error for an unknown merge strategy")
+	}
+}
@@ -0,0 +1,124 @@
+package vaultsync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// deleteExtraFiles removes the secret files below targetDir that a mirroring
+// pull did not write, so secrets deleted from Vault disappear locally too.
+// Only files ending in extension, which must not be empty, and, with
+// PullOptions.Explode, exploded secret directories are considered; other
+// files, hidden files such as .gitignore, and anything outside targetDir are
+// never touched. With NoRecurse only targetDir itself is examined.
+func (v *VaultClient) deleteExtraFiles(targetDir, extension string, written map[string]bool) error {
+	if _, err := os.Stat(targetDir); os.IsNotExist(err) {
+		return nil
+	}
+
+	var extra []string
+	err := filepath.Walk(targetDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == targetDir {
+			return nil
+		}
+
+		if strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.IsDir() {
+			isExploded := strings.HasSuffix(info.Name(), extension+explodedSecretSuffix)
+			switch {
+			case written[path]:
+				return filepath.SkipDir
+			case v.PullOptions.Explode && isExploded:
+				extra = append(extra, path)
+				return filepath.SkipDir
+			case v.PullOptions.NoRecurse:
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// Keep the sidecar of a secret that was written.
+		if written[strings.TrimSuffix(path, secretSidecarSuffix)] {
+			return nil
+		}
+		if !written[path] && strings.HasSuffix(info.Name(), extension) {
+			extra = append(extra, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan %s for extra files: %w", targetDir, err)
+	}
+
+	for _, path := range extra {
+		if v.PullOptions.MirrorDryRun {
+			v.printf("Would delete: %s\n", path)
+			v.showFileDeletionDiff(path)
+			v.report(SecretResult{Action: "would delete", File: path})
+			continue
+		}
+		v.printf("Deleting: %s\n", path)
+		if err := os.RemoveAll(path); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", path, err)
+		}
+		v.report(SecretResult{Action: "deleted", File: path})
+	}
+
+	if v.PullOptions.MirrorDryRun {
+		v.printf("Would delete %d files no longer in Vault\n", len(extra))
+	} else if len(extra) > 0 {
+		v.printf("Deleted %d files no longer in Vault\n", len(extra))
+	}
+	return nil
+}
+
+// showFileDeletionDiff prints the content a mirror would delete from path, a
+// secret file or exploded secret directory, as a removed-file diff of its
+// parsed data, masked or redacted like any other diff. A file that does not
+// parse is shown as it is, unless values are masked or redacted.
+func (v *VaultClient) showFileDeletionDiff(path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+
+	var diffOutput string
+	switch {
+	case info.IsDir():
+		data, err := readExplodedSecret(path)
+		if err != nil {
+			return
+		}
+		if diffOutput, err = v.deletionDiff(path, data); err != nil {
+			return
+		}
+	default:
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return
+		}
+		data, err := parseSecretFile(path, content)
+		if err != nil {
+			if v.MaskValues || len(v.RedactPatterns) > 0 {
+				return
+			}
+			diffOutput = generateDeletedFileDiff(string(content), path)
+			break
+		}
+		if diffOutput, err = v.deletionDiff(path, data); err != nil {
+			return
+		}
+	}
+	outputDiff(diffOutput, v.output(), v.errOutput())
+}
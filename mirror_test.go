@@ -0,0 +1,217 @@
+package vaultsync
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// mirrorTestSecrets is kv/app holding only the secret "db".
+var mirrorTestSecrets = map[string]map[string]any{
+	"kv/app/db": {"password": "pw"},
+}
+
+// writeMirrorFixtures lays out a previous pull of kv/app with a stale secret
+// plus files a mirror must leave alone, and returns the paths by name.
+func writeMirrorFixtures(t *testing.T, outputDir string) map[string]string {
+	t.Helper()
+
+	paths := map[string]string{
+		"stale":      filepath.Join(outputDir, "app", "old.yaml"),
+		"staleNest":  filepath.Join(outputDir, "app", "team", "gone.yaml"),
+		"notes":      filepath.Join(outputDir, "app", "README.md"),
+		"gitignore":  filepath.Join(outputDir, "app", ".gitignore"),
+		"outsideSub": filepath.Join(outputDir, "other", "keep.yaml"),
+	}
+	for _, path := range paths {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatalf("failed to create fixture dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("key: value\n"), 0600); err != nil {
+			t.Fatalf("failed to write fixture: %v", err)
+		}
+	}
+	return paths
+}
+
+func TestPullSecretsToFilesMirrorDeletesExtraFiles(t *testing.T) {
+	t.Parallel()
+
+	var out strings.Builder
+	client := newFakeVault(t, mirrorTestSecrets).client()
+	client.Output = &out
+	client.PullOptions.Mirror = true
+	outputDir := t.TempDir()
+	paths := writeMirrorFixtures(t, outputDir)
+
+	if err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), outputDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, name := range []string{"stale", "staleNest"} {
+		if _, err := os.Stat(paths[name]); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be deleted, stat err %v", paths[name], err)
+		}
+		if !strings.Contains(out.String(), "Deleting: "+paths[name]) {
+			t.Fatalf("expected deletion of %s to be reported, got:\n%s", paths[name], out.String())
+		}
+	}
+	for _, name := range []string{"notes", "gitignore", "outsideSub"} {
+		if _, err := os.Stat(paths[name]); err != nil {
+			t.Fatalf("expected %s to be kept: %v", paths[name], err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "app", "db.yaml")); err != nil {
+		t.Fatalf("expected the pulled secret to be written: %v", err)
+	}
+}
+
+func TestPullSecretsToFilesMirrorDryRunOnlyLists(t *testing.T) {
+	disableExternalDiffTools(t)
+
+	var out strings.Builder
+	client := newFakeVault(t, mirrorTestSecrets).client()
+	client.Output = &out
+	client.PullOptions.Mirror = true
+	client.PullOptions.MirrorDryRun = true
+	outputDir := t.TempDir()
+	paths := writeMirrorFixtures(t, outputDir)
+
+	if err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), outputDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := os.Stat(paths["stale"]); err != nil {
+		t.Fatalf("expected dry run to keep %s: %v", paths["stale"], err)
+	}
+	for _, want := range []string{
+		"Would delete: " + paths["stale"] + "\n",
+		"--- a/" + paths["stale"] + "\n+++ /dev/null\n@@ -1,1 +0,0 @@\n-key: value\n",
+		"Would delete 2 files no longer in Vault\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in output, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestPullSecretsToFilesMirrorDryRunMasksDeletedValues(t *testing.T) {
+	disableExternalDiffTools(t)
+
+	var out strings.Builder
+	client := newFakeVault(t, mirrorTestSecrets).client()
+	client.Output = &out
+	client.PullOptions.Mirror = true
+	client.PullOptions.MirrorDryRun = true
+	client.MaskValues = true
+	outputDir := t.TempDir()
+	paths := writeMirrorFixtures(t, outputDir)
+
+	if err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), outputDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "Would delete: "+paths["stale"]) {
+		t.Fatalf("expected planned deletion to be reported, got:\n%s", out.String())
+	}
+	if strings.Contains(out.String(), "-key: value") || !strings.Contains(out.String(), "-key: '********'") {
+		t.Fatalf("expected masked values in the deletion diff, got:\n%s", out.String())
+	}
+}
+
+func TestPullSecretsToFilesMirrorDryRunRedactsDeletedValues(t *testing.T) {
+	disableExternalDiffTools(t)
+
+	var out strings.Builder
+	client := newFakeVault(t, mirrorTestSecrets).client()
+	client.Output = &out
+	client.PullOptions.Mirror = true
+	client.PullOptions.MirrorDryRun = true
+	client.RedactPatterns = []*regexp.Regexp{regexp.MustCompile("^ghp_")}
+	outputDir := t.TempDir()
+	paths := writeMirrorFixtures(t, outputDir)
+	if err := os.WriteFile(paths["stale"], []byte("token: ghp_0123456789abcdef\nuser: admin\n"), 0600); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	if err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), outputDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if strings.Contains(out.String(), "ghp_0123456789abcdef") || !strings.Contains(out.String(), "-token: ghp_********cdef") {
+		t.Fatalf("expected the matching value redacted in the deletion diff, got:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "-user: admin") {
+		t.Fatalf("expected other values shown in the deletion diff, got:\n%s", out.String())
+	}
+}
+
+func TestPullSecretsToFilesMirrorKeepsFilesWhenAFetchFails(t *testing.T) {
+	t.Parallel()
+
+	vault := newFakeVault(t, map[string]map[string]any{
+		"kv/app/db":  {"password": "pw"},
+		"kv/app/old": {"password": "pw"},
+	})
+	vault.fail["/v1/kv/data/app/db"] = http.StatusForbidden
+	vault.fail["/v1/kv/data/app/old"] = http.StatusForbidden
+	client := vault.client()
+	client.PullOptions.Mirror = true
+
+	outputDir := t.TempDir()
+	paths := writeMirrorFixtures(t, outputDir)
+
+	if err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), outputDir); err == nil {
+		t.Fatalf("expected the fetch failure to be reported")
+	}
+	if _, err := os.Stat(paths["staleNest"]); err != nil {
+		t.Fatalf("expected no deletions after a failed fetch: %v", err)
+	}
+}
+
+func TestPullSecretsToFilesMirrorKeepsFilesOfSkippedSecrets(t *testing.T) {
+	t.Parallel()
+
+	var out strings.Builder
+	client := newFakeVault(t, mirrorTestSecrets).client()
+	client.Output = &out
+	client.PullOptions.Mirror = true
+	client.PullOptions.KeyInclude = []string{"username"}
+	outputDir := t.TempDir()
+	dbFile := filepath.Join(outputDir, "app", "db.yaml")
+	if err := os.MkdirAll(filepath.Dir(dbFile), 0700); err != nil {
+		t.Fatalf("failed to create fixture dir: %v", err)
+	}
+	if err := os.WriteFile(dbFile, []byte("password: old\n"), 0600); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	if err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), outputDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// db has no selected keys, but it still exists in Vault.
+	if _, err := os.Stat(dbFile); err != nil {
+		t.Fatalf("expected the file of a skipped secret to be kept: %v", err)
+	}
+}
+
+func TestPullSecretsToFilesMirrorRequiresExtension(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.PullOptions.Mirror = true
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		t.Fatalf("unexpected request %s", r.URL.Path)
+		return nil, nil
+	})}
+
+	if err := client.PullSecretsToFilesDirectAt(NewSecretRef("kv", "app"), t.TempDir()); err == nil || !strings.Contains(err.Error(), "extension") {
+		t.Fatalf("expected mirroring without an extension to be refused, got %v", err)
+	}
+}
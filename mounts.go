@@ -0,0 +1,114 @@
+package vaultsync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// MountInfo describes a secrets engine mounted in the client's namespace.
+type MountInfo struct {
+	// Path is the mount path without its trailing slash, e.g. "kv".
+	Path        string
+	Type        string
+	Description string
+	// KVVersion is 1 or 2 for KV (and legacy "generic") engines, 0 for
+	// everything else.
+	KVVersion int
+}
+
+// IsKV reports whether the mount is a KV secrets engine that vaultsync can
+// sync with.
+func (m MountInfo) IsKV() bool {
+	return m.Type == "kv" || m.Type == "generic"
+}
+
+type vaultMount struct {
+	Type        string            `json:"type"`
+	Description string            `json:"description"`
+	Options     map[string]string `json:"options"`
+}
+
+// ListMounts returns the secrets engines mounted in the client's namespace,
+// sorted by path. It reads sys/mounts, which needs a privileged policy; when
+// that is denied it falls back to sys/internal/ui/mounts, which lists the
+// mounts the token has some access to.
+func (v *VaultClient) ListMounts() ([]MountInfo, error) {
+	var resp struct {
+		Data map[string]vaultMount `json:"data"`
+	}
+	err := v.getJSON("sys/mounts", &resp)
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusForbidden {
+		var uiResp struct {
+			Data struct {
+				Secret map[string]vaultMount `json:"secret"`
+			} `json:"data"`
+		}
+		err = v.getJSON("sys/internal/ui/mounts", &uiResp)
+		resp.Data = uiResp.Data.Secret
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	mounts := make([]MountInfo, 0, len(resp.Data))
+	for path, mount := range resp.Data {
+		info := MountInfo{
+			Path:        strings.TrimSuffix(path, "/"),
+			Type:        mount.Type,
+			Description: mount.Description,
+		}
+		if info.IsKV() {
+			info.KVVersion = 1
+			if version, err := strconv.Atoi(mount.Options["version"]); err == nil && version > 0 {
+				info.KVVersion = version
+			}
+		}
+		mounts = append(mounts, info)
+	}
+	slices.SortFunc(mounts, func(a, b MountInfo) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return mounts, nil
+}
+
+// getJSON GETs an API path (relative to /v1/) and decodes the JSON response
+// into out.
+func (v *VaultClient) getJSON(path string, out interface{}) error {
+	url := fmt.Sprintf("%s/v1/%s", v.Address, path)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("X-Vault-Token", v.token())
+	req.Header.Set("X-Vault-Namespace", v.Namespace)
+
+	resp, err := v.do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	v.reportWarnings(path, body)
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	return nil
+}
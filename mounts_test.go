@@ -0,0 +1,79 @@
+package vaultsync
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestListMountsReportsKVVersions(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path != "/v1/sys/mounts" {
+			t.Fatalf("unexpected request %s", r.URL.Path)
+		}
+		return jsonResponse(t, http.StatusOK, map[string]any{
+			"data": map[string]any{
+				"secret/":   map[string]any{"type": "kv", "description": "team secrets", "options": map[string]any{"version": "2"}},
+				"legacy/":   map[string]any{"type": "generic", "description": "", "options": nil},
+				"database/": map[string]any{"type": "database", "description": "dynamic creds"},
+			},
+		})
+	})}
+
+	mounts, err := client.ListMounts()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []MountInfo{
+		{Path: "database", Type: "database", Description: "dynamic creds"},
+		{Path: "legacy", Type: "generic", KVVersion: 1},
+		{Path: "secret", Type: "kv", Description: "team secrets", KVVersion: 2},
+	}
+	if len(mounts) != len(want) {
+		t.Fatalf("expected %d mounts, got %+v", len(want), mounts)
+	}
+	for i := range want {
+		if mounts[i] != want[i] {
+			t.Fatalf("mount %d = %+v, want %+v", i, mounts[i], want[i])
+		}
+	}
+	if mounts[0].IsKV() || !mounts[1].IsKV() {
+		t.Fatalf("unexpected IsKV results for %+v", mounts)
+	}
+}
+
+func TestListMountsFallsBackToUIMountsWhenDenied(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		switch r.URL.Path {
+		case "/v1/sys/mounts":
+			return textResponse(http.StatusForbidden, "permission denied"), nil
+		case "/v1/sys/internal/ui/mounts":
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{
+					"secret": map[string]any{"kv/": map[string]any{"type": "kv", "options": map[string]any{"version": "2"}}},
+					"auth":   map[string]any{"token/": map[string]any{"type": "token"}},
+				},
+			})
+		}
+		t.Fatalf("unexpected request %s", r.URL.Path)
+		return nil, nil
+	})}
+
+	mounts, err := client.ListMounts()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(mounts) != 1 || mounts[0].Path != "kv" || mounts[0].KVVersion != 2 {
+		t.Fatalf("expected only the kv secrets mount, got %+v", mounts)
+	}
+}
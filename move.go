@@ -0,0 +1,132 @@
+package vaultsync
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// MoveSecretsAt copies every secret below src to the same relative path below
+// dst, which may be in another engine. With deleteSource, the source secrets
+// are deleted once all of them have been copied; if any secret could not be
+// read or written, nothing is deleted. With dryRun, the planned moves are
+// printed, each secret that would be deleted is shown as a removed-file diff
+// of its current content, and Vault is not modified. Either way a final line
+// counts the secrets written and deleted.
+//
+// Only the current version of each secret is copied; the source's version
+// history and metadata stay behind.
+func (v *VaultClient) MoveSecretsAt(src, dst SecretRef, dryRun, deleteSource bool) error {
+	if src.Engine == dst.Engine && (pathWithin(dst.Path, src.Path) || pathWithin(src.Path, dst.Path)) {
+		return fmt.Errorf("cannot move %s to %s: source and destination overlap", src.MetadataPath(), dst.MetadataPath())
+	}
+
+	var moved []SecretRef
+	// planned holds the content of each source secret a dry run would
+	// delete, for its diff.
+	var planned []map[string]interface{}
+	fetchErr, visitErr := v.walkSecrets(src.MetadataPath(), func(fullPath string, secretData map[string]interface{}) error {
+		relativePath := strings.TrimPrefix(fullPath, src.MetadataPath()+"/")
+		target := NewSecretRef(dst.Engine, dst.Path+"/"+relativePath)
+
+		if dryRun {
+			v.printf("Would move: %s -> %s\n", fullPath, target.MetadataPath())
+			moved = append(moved, secretRefFromMetadataPath(fullPath))
+			planned = append(planned, secretData)
+			return nil
+		}
+
+		v.printf("Moving: %s -> %s\n", fullPath, target.MetadataPath())
+		if err := v.PutSecretAt(target, secretData); err != nil {
+			return fmt.Errorf("failed to write %s: %w", target.MetadataPath(), err)
+		}
+		moved = append(moved, secretRefFromMetadataPath(fullPath))
+		return nil
+	})
+	if visitErr != nil {
+		return visitErr
+	}
+	if fetchErr != nil {
+		if deleteSource && !dryRun {
+			return fmt.Errorf("%w (source secrets were not deleted)", fetchErr)
+		}
+		return fetchErr
+	}
+
+	if !deleteSource {
+		v.printMoveSummary(dryRun, len(moved), 0)
+		return nil
+	}
+	if dryRun {
+		for i, ref := range moved {
+			v.printf("Would delete: %s\n", ref.MetadataPath())
+			diffOutput, err := v.deletionDiff(ref.MetadataPath(), planned[i])
+			if err != nil {
+				return err
+			}
+			outputDiff(diffOutput, v.output(), v.errOutput())
+			v.report(SecretResult{Path: displayPath(ref.MetadataPath()), Action: "would delete"})
+		}
+		v.printMoveSummary(dryRun, len(moved), len(moved))
+		return nil
+	}
+
+	for i, ref := range moved {
+		v.printf("Deleting: %s\n", ref.MetadataPath())
+		if err := v.DeleteSecretAt(ref); err != nil {
+			v.printMoveSummary(dryRun, len(moved), i)
+			return fmt.Errorf("failed to delete %s: %w", ref.MetadataPath(), err)
+		}
+		v.report(SecretResult{Path: displayPath(ref.MetadataPath()), Action: "deleted"})
+	}
+	v.printMoveSummary(dryRun, len(moved), len(moved))
+	return nil
+}
+
+// printMoveSummary counts the secrets a move wrote and deleted, or would have.
+func (v *VaultClient) printMoveSummary(dryRun bool, written, deleted int) {
+	if dryRun {
+		v.printf("Would write %d secrets and delete %d\n", written, deleted)
+		return
+	}
+	v.printf("Wrote %d secrets and deleted %d\n", written, deleted)
+}
+
+// DeleteSecretAt permanently deletes the secret at ref. On KV v2 this removes
+// its metadata and every version, so it no longer appears in listings.
+func (v *VaultClient) DeleteSecretAt(ref SecretRef) error {
+	url := v.kvURL("metadata", ref)
+
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("X-Vault-Token", v.token())
+	req.Header.Set("X-Vault-Namespace", v.Namespace)
+
+	resp, err := v.do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		httpErr := &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+		if resp.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("%w: %s", ErrSecretNotFound, httpErr)
+		}
+		return httpErr
+	}
+
+	v.reportWarnings(ref.MetadataPath(), body)
+	return nil
+}
+
+// pathWithin reports whether path is base or lies below it. Every path lies
+// within the empty (engine root) base.
+func pathWithin(path, base string) bool {
+	return base == "" || path == base || strings.HasPrefix(path, base+"/")
+}
@@ -0,0 +1,166 @@
+package vaultsync
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// moveTestSecrets is a small tree under kv/old whose secrets hold their
+// own name.
+var moveTestSecrets = map[string]map[string]any{
+	"kv/old/db":       {"name": "db"},
+	"kv/old/team/api": {"name": "api"},
+}
+
+func TestMoveSecretsAtPreservesSubtree(t *testing.T) {
+	t.Parallel()
+
+	vault := newFakeVault(t, moveTestSecrets)
+	client := vault.client()
+
+	if err := client.MoveSecretsAt(NewSecretRef("kv", "old"), NewSecretRef("kv", "new/place"), false, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(vault.writes) != 2 {
+		t.Fatalf("expected 2 writes, got %v", vault.writes)
+	}
+	if got := vault.writes["/v1/kv/data/new/place/db"]["name"]; got != "db" {
+		t.Fatalf("expected db to be copied, got %v", vault.writes)
+	}
+	if got := vault.writes["/v1/kv/data/new/place/team/api"]["name"]; got != "api" {
+		t.Fatalf("expected team/api to be copied, got %v", vault.writes)
+	}
+	if len(vault.deletes) != 0 {
+		t.Fatalf("expected no deletes without deleteSource, got %v", vault.deletes)
+	}
+}
+
+func TestMoveSecretsAtDeletesSourceAfterCopying(t *testing.T) {
+	t.Parallel()
+
+	vault := newFakeVault(t, moveTestSecrets)
+	client := vault.client()
+
+	if err := client.MoveSecretsAt(NewSecretRef("kv", "old"), NewSecretRef("archive", ""), false, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, ok := vault.writes["/v1/archive/data/team/api"]; !ok {
+		t.Fatalf("expected team/api to be copied into the archive engine, got %v", vault.writes)
+	}
+	want := []string{"/v1/kv/metadata/old/db", "/v1/kv/metadata/old/team/api"}
+	if strings.Join(vault.deletes, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected deletes %v, got %v", want, vault.deletes)
+	}
+}
+
+func TestMoveSecretsAtKeepsSourceWhenACopyFails(t *testing.T) {
+	t.Parallel()
+
+	vault := newFakeVault(t, moveTestSecrets)
+	client := vault.client()
+	vault.fail["/v1/kv/data/old/team/api"] = http.StatusForbidden
+
+	err := client.MoveSecretsAt(NewSecretRef("kv", "old"), NewSecretRef("kv", "new"), false, true)
+	if err == nil || !strings.Contains(err.Error(), "not deleted") {
+		t.Fatalf("expected an error reporting kept sources, got %v", err)
+	}
+	if len(vault.deletes) != 0 {
+		t.Fatalf("expected no deletes after a failed copy, got %v", vault.deletes)
+	}
+}
+
+func TestMoveSecretsAtDryRunOnlyPrintsPlan(t *testing.T) {
+	disableExternalDiffTools(t)
+
+	vault := newFakeVault(t, moveTestSecrets)
+	var out bytes.Buffer
+	client := vault.client()
+	client.Output = &out
+
+	if err := client.MoveSecretsAt(NewSecretRef("kv", "old"), NewSecretRef("kv", "new"), true, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(vault.writes) != 0 || len(vault.deletes) != 0 {
+		t.Fatalf("expected no changes in dry run, got writes %v deletes %v", vault.writes, vault.deletes)
+	}
+	for _, want := range []string{
+		"Would move: kv/metadata/old/db -> kv/metadata/new/db",
+		"Would move: kv/metadata/old/team/api -> kv/metadata/new/team/api",
+		"Would delete: kv/metadata/old/db\ndiff --git a/kv/metadata/old/db b/kv/metadata/old/db\ndeleted file mode 100644\n",
+		"--- a/kv/metadata/old/db\n+++ /dev/null\n@@ -1,1 +0,0 @@\n-name: db\n",
+		"Would delete: kv/metadata/old/team/api\n",
+		"Would write 2 secrets and delete 2\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in output, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestMoveSecretsAtDryRunMasksDeletedValues(t *testing.T) {
+	disableExternalDiffTools(t)
+
+	vault := newFakeVault(t, moveTestSecrets)
+	var out bytes.Buffer
+	client := vault.client()
+	client.Output = &out
+	client.MaskValues = true
+
+	if err := client.MoveSecretsAt(NewSecretRef("kv", "old"), NewSecretRef("kv", "new"), true, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(vault.deletes) != 0 {
+		t.Fatalf("expected no deletes in dry run, got %v", vault.deletes)
+	}
+	if !strings.Contains(out.String(), "-name: '********'\n") || strings.Contains(out.String(), "-name: db") {
+		t.Fatalf("expected masked values in the deletion diff, got:\n%s", out.String())
+	}
+}
+
+func TestMoveSecretsAtCountsWritesAndDeletes(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	client := newFakeVault(t, moveTestSecrets).client()
+	client.Output = &out
+
+	if err := client.MoveSecretsAt(NewSecretRef("kv", "old"), NewSecretRef("kv", "new"), false, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "Wrote 2 secrets and deleted 0\n") {
+		t.Fatalf("expected a summary counting writes and deletes, got:\n%s", out.String())
+	}
+}
+
+func TestMoveSecretsAtRejectsOverlappingPaths(t *testing.T) {
+	t.Parallel()
+
+	client := newFakeVault(t, moveTestSecrets).client()
+
+	for _, dst := range []string{"old", "old/sub", ""} {
+		if err := client.MoveSecretsAt(NewSecretRef("kv", "old"), NewSecretRef("kv", dst), false, false); err == nil {
+			t.Fatalf("expected overlap error moving kv/old to kv/%s", dst)
+		}
+	}
+}
+
+func TestDeleteSecretAtReportsMissingSecret(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return textResponse(http.StatusNotFound, ""), nil
+	})}
+
+	if err := client.DeleteSecretAt(NewSecretRef("kv", "app/db")); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("expected ErrSecretNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package vaultsync
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// multiErrorDetails is how many errors MultiError lists before summarizing
+// the rest as a count.
+const multiErrorDetails = 10
+
+// PathError is one failure collected by a MultiError: the secret, file, or
+// other target it belongs to, and what went wrong.
+type PathError struct {
+	Path string
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError collects the failures of a run that continues past them, such as
+// a pull that skips unreadable secrets, and reports them together at the end.
+// It is safe for concurrent use. errors.Is and errors.As see every collected
+// error.
+type MultiError struct {
+	mu   sync.Mutex
+	errs []*PathError
+}
+
+// Add records err for path, in the order added. A nil err is ignored, and a
+// MultiError is flattened into this one.
+func (m *MultiError) Add(path string, err error) {
+	if err == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if other, ok := err.(*MultiError); ok && path == "" {
+		m.errs = append(m.errs, other.Errors()...)
+		return
+	}
+	m.errs = append(m.errs, &PathError{Path: path, Err: err})
+}
+
+// Len returns the number of errors collected so far.
+func (m *MultiError) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.errs)
+}
+
+// Errors returns the collected errors in the order they were added.
+func (m *MultiError) Errors() []*PathError {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*PathError(nil), m.errs...)
+}
+
+// ErrorOrNil returns m when it holds errors and nil otherwise, so a run can
+// return its MultiError without handing callers a non-nil empty error.
+func (m *MultiError) ErrorOrNil() error {
+	if m == nil || m.Len() == 0 {
+		return nil
+	}
+	return m
+}
+
+// Error renders a single error as is, and several as a count followed by the
+// first multiErrorDetails of them, one per line.
+func (m *MultiError) Error() string {
+	errs := m.Errors()
+	switch len(errs) {
+	case 0:
+		return "no errors"
+	case 1:
+		return errs[0].Error()
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d errors:", len(errs))
+	for i, err := range errs {
+		if i == multiErrorDetails {
+			fmt.Fprintf(&b, "\n  ... and %d more", len(errs)-multiErrorDetails)
+			break
+		}
+		b.WriteString("\n  ")
+		b.WriteString(strings.ReplaceAll(err.Error(), "\n", "\n    "))
+	}
+	return b.String()
+}
+
+// Unwrap returns the collected errors for errors.Is and errors.As.
+func (m *MultiError) Unwrap() []error {
+	errs := m.Errors()
+	unwrapped := make([]error, len(errs))
+	for i, err := range errs {
+		unwrapped[i] = err
+	}
+	return unwrapped
+}
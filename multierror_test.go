@@ -0,0 +1,81 @@
+package vaultsync
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMultiErrorCollectsConcurrently(t *testing.T) {
+	t.Parallel()
+
+	errs := &MultiError{}
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs.Add(fmt.Sprintf("kv/app/%d", i), errors.New("boom"))
+		}(i)
+	}
+	wg.Wait()
+
+	if errs.Len() != 50 {
+		t.Fatalf("Len() = %d, want 50", errs.Len())
+	}
+}
+
+func TestMultiErrorRendering(t *testing.T) {
+	t.Parallel()
+
+	var empty MultiError
+	if err := empty.ErrorOrNil(); err != nil {
+		t.Fatalf("ErrorOrNil() of an empty MultiError = %v, want nil", err)
+	}
+
+	single := &MultiError{}
+	single.Add("kv/app/db", errors.New("boom"))
+	if got, want := single.Error(), "kv/app/db: boom"; got != want {
+		t.Fatalf("Error() = %q, want %q", got, want)
+	}
+
+	many := &MultiError{}
+	for i := 0; i < multiErrorDetails+3; i++ {
+		many.Add(fmt.Sprintf("kv/app/%d", i), errors.New("boom"))
+	}
+	got := many.Error()
+	if !strings.HasPrefix(got, fmt.Sprintf("%d errors:\n  kv/app/0: boom\n", multiErrorDetails+3)) {
+		t.Fatalf("Error() does not start with the count and first error:\n%s", got)
+	}
+	if !strings.HasSuffix(got, "\n  ... and 3 more") {
+		t.Fatalf("Error() does not summarize the rest:\n%s", got)
+	}
+	if strings.Contains(got, fmt.Sprintf("kv/app/%d:", multiErrorDetails)) {
+		t.Fatalf("Error() lists more than %d errors:\n%s", multiErrorDetails, got)
+	}
+}
+
+func TestMultiErrorUnwrap(t *testing.T) {
+	t.Parallel()
+
+	errs := &MultiError{}
+	errs.Add("kv/app/a", errors.New("boom"))
+	errs.Add("kv/app/b", fmt.Errorf("failed to get secret: %w", ErrOperationTimeout))
+
+	if !errors.Is(errs, ErrOperationTimeout) {
+		t.Fatal("errors.Is() does not find a wrapped sentinel")
+	}
+	wrapped := fmt.Errorf("failed to pull secrets: %w", errs)
+	var pathErr *PathError
+	if !errors.As(wrapped, &pathErr) || pathErr.Path != "kv/app/a" {
+		t.Fatalf("errors.As() = %+v, want the first PathError", pathErr)
+	}
+
+	flat := &MultiError{}
+	flat.Add("", errs)
+	if flat.Len() != 2 {
+		t.Fatalf("adding a MultiError without a path gave %d errors, want it flattened to 2", flat.Len())
+	}
+}
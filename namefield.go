@@ -0,0 +1,43 @@
+package vaultsync
+
+import (
+	"errors"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ErrFileNameCollision is returned when two pulled secrets would be written
+// to the same local file.
+var ErrFileNameCollision = errors.New("file name collision")
+
+// nameFromField returns relativePath with its last segment replaced by the
+// value of the secret's PullOptions.NameField, so a secret stored as
+// "apps/7f3a" with id "billing" is written as "apps/billing". Strings and
+// numbers are used as they are; a missing field keeps the key, and a value
+// that cannot be a file name keeps it with a warning.
+func (v *VaultClient) nameFromField(secretPath, relativePath string, secretData map[string]interface{}) string {
+	value, ok := secretData[v.PullOptions.NameField]
+	if !ok || value == nil {
+		return relativePath
+	}
+
+	var name string
+	switch typed := value.(type) {
+	case string:
+		name = strings.TrimSpace(typed)
+	case float64:
+		name = strconv.FormatFloat(typed, 'f', -1, 64)
+	case int:
+		name = strconv.Itoa(typed)
+	}
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\\x00") {
+		v.warnf("%s: field %q is not usable as a file name, using the key\n", secretPath, v.PullOptions.NameField)
+		return relativePath
+	}
+
+	if dir := path.Dir(relativePath); dir != "." {
+		return dir + "/" + name
+	}
+	return name
+}
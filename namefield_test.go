@@ -0,0 +1,59 @@
+package vaultsync
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPullSecretsToFilesNameField(t *testing.T) {
+	t.Parallel()
+
+	client := newFakeVault(t, map[string]map[string]any{
+		"kv/app/7f3a":  {"id": "billing", "url": "https://billing"},
+		"kv/app/9c1d":  {"id": float64(42)},
+		"kv/app/plain": {"url": "https://plain"},
+		"kv/app/bad":   {"id": "../escape"},
+	}).client()
+	client.PullOptions.NameField = "id"
+
+	outputDir := t.TempDir()
+	if err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), outputDir); err != nil {
+		t.Fatalf("PullSecretsToFilesAt() error = %v", err)
+	}
+
+	for _, name := range []string{"billing.yaml", "42.yaml", "plain.yaml", "bad.yaml"} {
+		if _, err := os.Stat(filepath.Join(outputDir, "app", name)); err != nil {
+			t.Fatalf("expected %s to be written: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "app", "7f3a.yaml")); !os.IsNotExist(err) {
+		t.Fatalf("expected no file named after the key, got %v", err)
+	}
+}
+
+func TestPullSecretsToFilesNameFieldCollision(t *testing.T) {
+	t.Parallel()
+
+	client := newFakeVault(t, map[string]map[string]any{
+		"kv/app/a": {"id": "same", "note": "1"},
+		"kv/app/b": {"id": "same", "note": "2"},
+	}).client()
+	client.PullOptions.NameField = "id"
+
+	outputDir := t.TempDir()
+	err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), outputDir)
+	if !errors.Is(err, ErrFileNameCollision) {
+		t.Fatalf("PullSecretsToFilesAt() error = %v, want ErrFileNameCollision", err)
+	}
+	if !strings.Contains(err.Error(), "kv/metadata/app/a and kv/metadata/app/b") {
+		t.Fatalf("expected both secrets to be named, got %v", err)
+	}
+
+	content, _ := os.ReadFile(filepath.Join(outputDir, "app", "same.yaml"))
+	if !strings.Contains(string(content), `note: "1"`) {
+		t.Fatalf("expected the first secret to be kept, got %q", content)
+	}
+}
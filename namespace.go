@@ -0,0 +1,24 @@
+package vaultsync
+
+import "strings"
+
+// NormalizeNamespace returns namespace in the form sent in X-Vault-Namespace:
+// nested Vault Enterprise namespaces joined by single slashes, with no
+// leading or trailing slash, so "parent/child/", "/parent//child" and
+// "parent/child" all name the same child namespace. The root namespace is "".
+func NormalizeNamespace(namespace string) string {
+	var segments []string
+	for _, segment := range strings.Split(strings.TrimSpace(namespace), "/") {
+		if segment = strings.TrimSpace(segment); segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// JoinNamespace resolves child relative to parent, e.g. "parent" and
+// "team-a/dev" give "parent/team-a/dev". An empty parent leaves child as
+// given and an empty child names parent itself.
+func JoinNamespace(parent, child string) string {
+	return NormalizeNamespace(parent + "/" + child)
+}
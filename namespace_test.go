@@ -0,0 +1,60 @@
+package vaultsync
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestNormalizeNamespace(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"":                   "",
+		"/":                  "",
+		"team-a":             "team-a",
+		"team-a/":            "team-a",
+		"parent/child":       "parent/child",
+		"/parent//child/":    "parent/child",
+		" parent / child / ": "parent/child",
+	}
+	for in, want := range tests {
+		if got := NormalizeNamespace(in); got != want {
+			t.Errorf("NormalizeNamespace(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestJoinNamespace(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		parent, child, want string
+	}{
+		{parent: "parent", child: "child", want: "parent/child"},
+		{parent: "parent/", child: "/team-a/dev/", want: "parent/team-a/dev"},
+		{parent: "", child: "child", want: "child"},
+		{parent: "parent", child: "", want: "parent"},
+	}
+	for _, tt := range tests {
+		if got := JoinNamespace(tt.parent, tt.child); got != tt.want {
+			t.Errorf("JoinNamespace(%q, %q) = %q, want %q", tt.parent, tt.child, got, tt.want)
+		}
+	}
+}
+
+func TestNestedNamespaceHeaderIsNormalized(t *testing.T) {
+	t.Parallel()
+
+	var captured capturedRequest
+	client := NewVaultClient("https://vault.example", "token", "parent/child/")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: captureSingleRequest(t, &captured)}
+
+	if err := client.PutSecretAt(NewSecretRef("kv", "app/db"), map[string]interface{}{"k": "v"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if captured.namespace != "parent/child" {
+		t.Fatalf("expected X-Vault-Namespace parent/child, got %q", captured.namespace)
+	}
+}
@@ -0,0 +1,128 @@
+package vaultsync
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ErrUnsupportedObjectStore is returned for an object storage URI whose
+// scheme has no ObjectStore implementation yet.
+var ErrUnsupportedObjectStore = errors.New("unsupported object storage")
+
+// ObjectStore is remote storage, such as an S3 bucket, that a pull can write
+// to and a push read from in place of a local directory (see
+// NewObjectStorage). Keys are slash-separated and relative to the bucket.
+// The s3store package implements it for S3.
+type ObjectStore interface {
+	// Put streams size bytes of content to key, replacing any existing
+	// object.
+	Put(key string, content io.Reader, size int64) error
+	// Get opens the content stored under key. A missing object is reported
+	// with an error wrapping fs.ErrNotExist.
+	Get(key string) (io.ReadCloser, error)
+	// List returns the keys of all objects below prefix.
+	List(prefix string) ([]string, error)
+}
+
+// IsObjectStoreURI reports whether location names object storage, such as
+// "s3://bucket/prefix", rather than a local directory. Single-letter schemes
+// are left alone as Windows drive letters.
+func IsObjectStoreURI(location string) bool {
+	scheme, _, ok := strings.Cut(location, "://")
+	return ok && len(scheme) > 1 && !strings.ContainsAny(scheme, `/\.`)
+}
+
+// ParseObjectStoreURI splits an object storage URI such as
+// "s3://bucket/prefix" into its scheme, bucket, and key prefix, the latter
+// without surrounding slashes.
+func ParseObjectStoreURI(uri string) (scheme, bucket, prefix string, err error) {
+	scheme, rest, ok := strings.Cut(uri, "://")
+	if !ok {
+		return "", "", "", fmt.Errorf("invalid object storage URI %q", uri)
+	}
+	bucket, prefix, _ = strings.Cut(rest, "/")
+	if bucket == "" {
+		return "", "", "", fmt.Errorf("invalid object storage URI %q: missing bucket", uri)
+	}
+	return scheme, bucket, strings.Trim(prefix, "/"), nil
+}
+
+// objectStorage is the Storage of an ObjectStore: file names map to keys by
+// their slash-separated form, so a pull to the prefix "backups/prod" writes
+// the key "backups/prod/app/db.yaml".
+type objectStorage struct {
+	store ObjectStore
+}
+
+// NewObjectStorage returns a Storage that writes and reads files as objects
+// of store, streaming each one directly to and from the bucket. Set it as
+// VaultClient.Storage and pass the key prefix as the output or input
+// directory.
+func NewObjectStorage(store ObjectStore) Storage {
+	return objectStorage{store: store}
+}
+
+// key returns the object key of the file name. Names leading outside the
+// prefix they were built from are rejected.
+func (s objectStorage) key(name string) (string, error) {
+	key := path.Clean(filepath.ToSlash(name))
+	if key == "." || key == ".." || strings.HasPrefix(key, "../") || strings.HasPrefix(key, "/") {
+		return "", fmt.Errorf("invalid object name %s", name)
+	}
+	return key, nil
+}
+
+func (s objectStorage) WriteFile(name string, content []byte) error {
+	key, err := s.key(name)
+	if err != nil {
+		return err
+	}
+	if err := s.store.Put(key, bytes.NewReader(content), int64(len(content))); err != nil {
+		return fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s objectStorage) Open(name string) (io.ReadCloser, error) {
+	key, err := s.key(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.Get(key)
+}
+
+func (s objectStorage) Files(dir string) ([]string, error) {
+	prefix := ""
+	if dir != "" {
+		key, err := s.key(dir)
+		if err != nil {
+			return nil, err
+		}
+		prefix = key + "/"
+	}
+	keys, err := s.store.List(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	files := make([]string, 0, len(keys))
+	for _, key := range keys {
+		rel := strings.TrimPrefix(key, prefix)
+		if rel == "" || strings.HasSuffix(rel, "/") {
+			// Folder placeholder objects hold no file.
+			continue
+		}
+		if clean := path.Clean(rel); clean != rel || clean == ".." || strings.HasPrefix(clean, "../") {
+			return nil, fmt.Errorf("object %s lies outside %s", key, dir)
+		}
+		files = append(files, filepath.Join(dir, filepath.FromSlash(rel)))
+	}
+	slices.Sort(files)
+	return files, nil
+}
@@ -0,0 +1,160 @@
+package vaultsync
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// memStore is an in-memory ObjectStore.
+type memStore map[string][]byte
+
+func (m memStore) Put(key string, content io.Reader, size int64) error {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != size {
+		return errors.New("size does not match content")
+	}
+	m[key] = data
+	return nil
+}
+
+func (m memStore) Get(key string) (io.ReadCloser, error) {
+	content, ok := m[key]
+	if !ok {
+		return nil, &fs.PathError{Op: "get", Path: key, Err: fs.ErrNotExist}
+	}
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
+
+func (m memStore) List(prefix string) ([]string, error) {
+	var keys []string
+	for key := range m {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+func TestIsObjectStoreURI(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]bool{
+		"s3://bucket/prefix": true,
+		"gs://bucket":        true,
+		"./secrets":          false,
+		"/tmp/out":           false,
+		`C://secrets`:        false,
+		"dir/s3://x":         false,
+	}
+	for location, want := range tests {
+		if got := IsObjectStoreURI(location); got != want {
+			t.Errorf("IsObjectStoreURI(%q) = %v, want %v", location, got, want)
+		}
+	}
+}
+
+func TestParseObjectStoreURI(t *testing.T) {
+	t.Parallel()
+
+	scheme, bucket, prefix, err := ParseObjectStoreURI("s3://backups/vault/prod/")
+	if err != nil {
+		t.Fatalf("ParseObjectStoreURI: %v", err)
+	}
+	if scheme != "s3" || bucket != "backups" || prefix != "vault/prod" {
+		t.Errorf("got %q, %q, %q; want s3, backups, vault/prod", scheme, bucket, prefix)
+	}
+
+	if _, _, _, err := ParseObjectStoreURI("s3:///vault"); err == nil {
+		t.Error("expected an error for a URI without bucket")
+	}
+}
+
+func TestObjectStorageFiles(t *testing.T) {
+	t.Parallel()
+
+	store := memStore{
+		"backup/prod/app/db.yaml":      []byte("a: 1\n"),
+		"backup/prod/top.yaml":         []byte("b: 2\n"),
+		"backup/prod/folder/":          nil,
+		"backup/production/other.yaml": []byte("c: 3\n"),
+	}
+	storage := NewObjectStorage(store)
+
+	files, err := storage.Files("backup/prod")
+	if err != nil {
+		t.Fatalf("Files: %v", err)
+	}
+	if want := []string{"backup/prod/app/db.yaml", "backup/prod/top.yaml"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("Files = %v, want %v", files, want)
+	}
+
+	if _, err := storage.Open("backup/prod/missing.yaml"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Open missing error = %v, want fs.ErrNotExist", err)
+	}
+	if err := storage.WriteFile("../evil.yaml", []byte("x: 1\n")); err == nil {
+		t.Error("expected an error for a name outside the bucket")
+	}
+
+	store["backup/prod/../../evil.yaml"] = []byte("x: 1\n")
+	if _, err := storage.Files("backup/prod"); err == nil || !strings.Contains(err.Error(), "outside") {
+		t.Errorf("Files error = %v, want object outside the prefix", err)
+	}
+}
+
+func TestPullAndPushThroughObjectStorage(t *testing.T) {
+	t.Parallel()
+
+	vault := newFakeVault(t, map[string]map[string]any{
+		"kv/app/db":       {"password": "pw"},
+		"kv/app/team/api": {"token": "t"},
+	})
+	client := vault.client()
+
+	store := memStore{}
+	client.Storage = NewObjectStorage(store)
+
+	if err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), "backup/prod"); err != nil {
+		t.Fatalf("pull: %v", err)
+	}
+	keys, _ := store.List("")
+	if want := []string{"backup/prod/app/db.yaml", "backup/prod/app/team/api.yaml"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("objects = %v, want %v", keys, want)
+	}
+	if got := string(store["backup/prod/app/db.yaml"]); got != "password: pw\n" {
+		t.Errorf("db.yaml = %q", got)
+	}
+
+	store["backup/prod/app/db.yaml"] = []byte("password: rotated\n")
+	if err := client.PushSecretsFromFilesAt("backup/prod", NewSecretRef("kv", "app"), false); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	if got := vault.writes["/v1/kv/data/app/db"]["password"]; got != "rotated" {
+		t.Errorf("pushed password = %v, want rotated (writes %v)", got, vault.writes)
+	}
+}
+
+func TestObjectStorageRejectsLocalOnlyFeatures(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Storage = NewObjectStorage(memStore{})
+	client.PullOptions.Mirror = true
+	if err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), "backup"); err == nil || !strings.Contains(err.Error(), "local output directory") {
+		t.Errorf("mirror pull error = %v, want a local output directory error", err)
+	}
+
+	client.PushOptions.Explode = true
+	if err := client.PushSecretsFromFilesAt("backup", NewSecretRef("kv", "app"), true); err == nil || !strings.Contains(err.Error(), "local input directory") {
+		t.Errorf("exploded push error = %v, want a local input directory error", err)
+	}
+}
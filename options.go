@@ -0,0 +1,178 @@
+package vaultsync
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// secretOptionsKey is the reserved top-level key in a secret file whose value
+// configures the secret's KV v2 metadata rather than becoming a secret field.
+const secretOptionsKey = "_options"
+
+// SecretOptions are the per-secret KV v2 metadata settings that can be managed
+// declaratively from a secret file's _options block. Unset fields are left
+// unchanged in Vault.
+type SecretOptions struct {
+	MaxVersions        *int              `yaml:"max_versions" json:"max_versions,omitempty"`
+	CASRequired        *bool             `yaml:"cas_required" json:"cas_required,omitempty"`
+	DeleteVersionAfter string            `yaml:"delete_version_after" json:"delete_version_after,omitempty"`
+	CustomMetadata     map[string]string `yaml:"custom_metadata" json:"custom_metadata,omitempty"`
+}
+
+// extractSecretOptions removes the reserved _options block from secretData and
+// decodes it. It returns nil options when the block is absent. Unknown option
+// names are rejected so typos do not silently do nothing.
+func extractSecretOptions(secretData map[string]interface{}) (*SecretOptions, map[string]interface{}, error) {
+	raw, ok := secretData[secretOptionsKey]
+	if !ok {
+		return nil, secretData, nil
+	}
+
+	data := make(map[string]interface{}, len(secretData)-1)
+	for key, value := range secretData {
+		if key != secretOptionsKey {
+			data[key] = value
+		}
+	}
+
+	encoded, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", secretOptionsKey, err)
+	}
+
+	var options SecretOptions
+	decoder := yaml.NewDecoder(bytes.NewReader(encoded))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&options); err != nil && err != io.EOF {
+		return nil, nil, fmt.Errorf("invalid %s: %w", secretOptionsKey, err)
+	}
+
+	return &options, data, nil
+}
+
+// secretSidecarSuffix names the companion file holding a secret's options
+// outside its data: db.yaml.meta for db.yaml or an exploded db.yaml.d/.
+const secretSidecarSuffix = ".meta"
+
+// sidecarPathFor returns the sidecar file of the secret file or exploded
+// secret directory at filePath.
+func sidecarPathFor(filePath string) string {
+	return strings.TrimSuffix(filePath, explodedSecretSuffix) + secretSidecarSuffix
+}
+
+// isSidecarFile reports whether filePath is the sidecar of a secret file or
+// exploded secret directory next to it, and so is not a secret itself.
+func isSidecarFile(filePath string) bool {
+	if !strings.HasSuffix(filePath, secretSidecarSuffix) {
+		return false
+	}
+	secretFile := strings.TrimSuffix(filePath, secretSidecarSuffix)
+	if isLocalFile(secretFile) {
+		return true
+	}
+	info, err := os.Stat(secretFile + explodedSecretSuffix)
+	return err == nil && info.IsDir()
+}
+
+// readSidecarOptions decodes the sidecar file at sidecarPath in storage, whose
+// top level has the same schema as an _options block. A missing or empty
+// sidecar yields nil options.
+func readSidecarOptions(storage Storage, sidecarPath string) (*SecretOptions, error) {
+	content, err := readStoredFile(storage, sidecarPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", sidecarPath, err)
+	}
+
+	var options SecretOptions
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&options); err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("invalid %s: %w", sidecarPath, err)
+	}
+	return &options, nil
+}
+
+// mergeSecretOptions layers override on top of base field by field, with
+// custom_metadata merged key by key. Either may be nil.
+func mergeSecretOptions(base, override *SecretOptions) *SecretOptions {
+	switch {
+	case base == nil:
+		return override
+	case override == nil:
+		return base
+	}
+
+	merged := *base
+	if override.MaxVersions != nil {
+		merged.MaxVersions = override.MaxVersions
+	}
+	if override.CASRequired != nil {
+		merged.CASRequired = override.CASRequired
+	}
+	if override.DeleteVersionAfter != "" {
+		merged.DeleteVersionAfter = override.DeleteVersionAfter
+	}
+	if len(override.CustomMetadata) > 0 {
+		merged.CustomMetadata = make(map[string]string, len(base.CustomMetadata)+len(override.CustomMetadata))
+		for key, value := range base.CustomMetadata {
+			merged.CustomMetadata[key] = value
+		}
+		for key, value := range override.CustomMetadata {
+			merged.CustomMetadata[key] = value
+		}
+	}
+	return &merged
+}
+
+// PutSecretMetadataAt updates the KV v2 metadata (max_versions, cas_required,
+// delete_version_after, custom_metadata) of the secret at ref.
+func (v *VaultClient) PutSecretMetadataAt(ref SecretRef, options SecretOptions) error {
+	if v.isKVv1() {
+		return fmt.Errorf("metadata: %w", ErrKVv1Unsupported)
+	}
+
+	url := v.kvURL("metadata", ref)
+
+	jsonData, err := json.Marshal(options)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", url, strings.NewReader(string(jsonData)))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("X-Vault-Token", v.token())
+	req.Header.Set("X-Vault-Namespace", v.Namespace)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := v.do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	v.reportWarnings(ref.MetadataPath(), body)
+	return nil
+}
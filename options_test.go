@@ -0,0 +1,259 @@
+package vaultsync
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractSecretOptionsStripsReservedBlock(t *testing.T) {
+	t.Parallel()
+
+	options, data, err := extractSecretOptions(map[string]interface{}{
+		"username": "alice",
+		"_options": map[string]interface{}{
+			"max_versions":    5,
+			"cas_required":    true,
+			"custom_metadata": map[string]interface{}{"owner": "platform"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, ok := data[secretOptionsKey]; ok {
+		t.Fatalf("expected _options stripped from data, got %#v", data)
+	}
+	if data["username"] != "alice" {
+		t.Fatalf("expected secret fields preserved, got %#v", data)
+	}
+
+	if options == nil || options.MaxVersions == nil || *options.MaxVersions != 5 {
+		t.Fatalf("expected max_versions 5, got %+v", options)
+	}
+	if options.CASRequired == nil || !*options.CASRequired {
+		t.Fatalf("expected cas_required true, got %+v", options)
+	}
+	if options.CustomMetadata["owner"] != "platform" {
+		t.Fatalf("expected custom_metadata, got %+v", options.CustomMetadata)
+	}
+}
+
+func TestExtractSecretOptionsRejectsUnknownOptions(t *testing.T) {
+	t.Parallel()
+
+	_, _, err := extractSecretOptions(map[string]interface{}{
+		"_options": map[string]interface{}{"max_version": 5},
+	})
+	if err == nil {
+		t.Fatal("expected error for unknown option, got nil")
+	}
+}
+
+func TestPushSecretsFromFilesSendsOptionsToMetadataEndpoint(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	contents := "username: alice\n_options:\n  max_versions: 3\n  delete_version_after: 24h\n"
+	if err := os.WriteFile(filepath.Join(inputDir, "db"), []byte(contents), 0644); err != nil {
+		t.Fatalf("failed to write fixture secret: %v", err)
+	}
+
+	type write struct {
+		path string
+		body map[string]interface{}
+	}
+	var writes []write
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(r.Body)
+		var parsed map[string]interface{}
+		if err := json.Unmarshal(body, &parsed); err != nil {
+			t.Fatalf("failed to parse request body %q: %v", body, err)
+		}
+		writes = append(writes, write{path: r.URL.Path, body: parsed})
+		return textResponse(http.StatusOK, ""), nil
+	})}
+
+	if err := client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(writes) != 2 {
+		t.Fatalf("expected data and metadata writes, got %+v", writes)
+	}
+
+	if writes[0].path != "/v1/kv/data/app/db" {
+		t.Fatalf("expected data write first, got %q", writes[0].path)
+	}
+	data := writes[0].body["data"].(map[string]interface{})
+	if _, ok := data[secretOptionsKey]; ok {
+		t.Fatalf("expected _options excluded from data payload, got %#v", data)
+	}
+
+	if writes[1].path != "/v1/kv/metadata/app/db" {
+		t.Fatalf("expected metadata write second, got %q", writes[1].path)
+	}
+	if writes[1].body["max_versions"] != float64(3) || writes[1].body["delete_version_after"] != "24h" {
+		t.Fatalf("expected options in metadata payload, got %#v", writes[1].body)
+	}
+	if _, ok := writes[1].body["cas_required"]; ok {
+		t.Fatalf("expected unset options to be omitted, got %#v", writes[1].body)
+	}
+}
+
+func TestPushSecretsFromFilesDataOnlyAndMetadataOnly(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	for name, contents := range map[string]string{
+		"db":    "username: alice\n_options:\n  max_versions: 3\n  cas_required: true\n",
+		"cache": "host: redis\n",
+	} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(contents), 0644); err != nil {
+			t.Fatalf("failed to write fixture secret: %v", err)
+		}
+	}
+
+	pushPaths := func(configure func(*PushOptions)) []string {
+		var paths []string
+		client := NewVaultClient("https://vault.example", "token", "team-a")
+		client.Output = nil
+		client.ErrOutput = nil
+		configure(&client.PushOptions)
+		client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			paths = append(paths, r.Method+" "+r.URL.Path)
+			if r.Method == http.MethodGet {
+				return jsonResponse(t, http.StatusOK, map[string]any{
+					"data": map[string]any{"data": map[string]any{"username": "bob"}, "metadata": map[string]any{"version": 4}},
+				})
+			}
+			return textResponse(http.StatusOK, ""), nil
+		})}
+
+		if err := client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), false); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return paths
+	}
+
+	// cas_required still makes the data write a check-and-set write, which
+	// reads the current version first, though the metadata is left alone.
+	dataOnly := pushPaths(func(o *PushOptions) { o.DataOnly = true })
+	if want := []string{"POST /v1/kv/data/app/cache", "GET /v1/kv/data/app/db", "POST /v1/kv/data/app/db"}; !reflect.DeepEqual(dataOnly, want) {
+		t.Fatalf("data-only requests = %v, want %v", dataOnly, want)
+	}
+
+	metadataOnly := pushPaths(func(o *PushOptions) { o.MetadataOnly = true })
+	if want := []string{"POST /v1/kv/metadata/app/db"}; !reflect.DeepEqual(metadataOnly, want) {
+		t.Fatalf("metadata-only requests = %v, want %v", metadataOnly, want)
+	}
+}
+
+func TestPushSecretsFromFilesRejectsDataOnlyWithMetadataOnly(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.PushOptions.DataOnly = true
+	client.PushOptions.MetadataOnly = true
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		t.Fatalf("unexpected request %s", r.URL.Path)
+		return nil, nil
+	})}
+
+	if err := client.PushSecretsFromFilesDirectAt(t.TempDir(), NewSecretRef("kv", "app"), false); err == nil {
+		t.Fatal("expected an error, got nil")
+	}
+}
+
+func TestPushSecretsFromFilesReadsSidecarOptions(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	for name, contents := range map[string]string{
+		"db":          "username: alice\n_options:\n  max_versions: 3\n  custom_metadata:\n    owner: dba\n",
+		"db.meta":     "max_versions: 10\ndelete_version_after: 24h\ncustom_metadata:\n  owner: platform\n  team: data\n",
+		"cache":       "host: redis\n",
+		"cache.meta":  "cas_required: false\n",
+		"orphan.meta": "note: not a sidecar\n",
+	} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(contents), 0644); err != nil {
+			t.Fatalf("failed to write fixture secret: %v", err)
+		}
+	}
+
+	metadata := make(map[string]map[string]interface{})
+	var dataPaths []string
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(r.Body)
+		var parsed map[string]interface{}
+		if err := json.Unmarshal(body, &parsed); err != nil {
+			t.Fatalf("failed to parse request body %q: %v", body, err)
+		}
+		if strings.HasPrefix(r.URL.Path, "/v1/kv/metadata/") {
+			metadata[strings.TrimPrefix(r.URL.Path, "/v1/kv/metadata/")] = parsed
+		} else {
+			dataPaths = append(dataPaths, r.URL.Path)
+		}
+		return textResponse(http.StatusOK, ""), nil
+	})}
+
+	if err := client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wantData := []string{"/v1/kv/data/app/cache", "/v1/kv/data/app/db", "/v1/kv/data/app/orphan.meta"}
+	if !reflect.DeepEqual(dataPaths, wantData) {
+		t.Fatalf("data writes = %v, want %v", dataPaths, wantData)
+	}
+
+	wantDB := map[string]interface{}{
+		"max_versions":         float64(3),
+		"delete_version_after": "24h",
+		"custom_metadata":      map[string]interface{}{"owner": "dba", "team": "data"},
+	}
+	if !reflect.DeepEqual(metadata["app/db"], wantDB) {
+		t.Fatalf("db metadata = %#v, want %#v", metadata["app/db"], wantDB)
+	}
+	if want := map[string]interface{}{"cas_required": false}; !reflect.DeepEqual(metadata["app/cache"], want) {
+		t.Fatalf("cache metadata = %#v, want %#v", metadata["app/cache"], want)
+	}
+}
+
+func TestPushSecretsFromFilesRejectsInvalidSidecar(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	for name, contents := range map[string]string{
+		"db":      "username: alice\n",
+		"db.meta": "max_version: 3\n",
+	} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(contents), 0644); err != nil {
+			t.Fatalf("failed to write fixture secret: %v", err)
+		}
+	}
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		t.Fatalf("unexpected request %s", r.URL.Path)
+		return nil, nil
+	})}
+
+	err := client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), false)
+	if err == nil || !strings.Contains(err.Error(), "db.meta") {
+		t.Fatalf("expected error naming the sidecar, got %v", err)
+	}
+}
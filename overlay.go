@@ -0,0 +1,52 @@
+package vaultsync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// overlayFilePath returns the overlay of the secret file filePath for the
+// named environment: "db.prod.yaml" for "db.yaml" and overlay "prod".
+func overlayFilePath(filePath, extension, overlay string) string {
+	return trimSecretFileExtension(filePath, extension) + "." + overlay + extension
+}
+
+// findOverlayFile returns the overlay of the local file filePath and its file
+// info, or an empty path when the file has no overlay for the environment.
+func findOverlayFile(filePath, extension, overlay string) (string, os.FileInfo, error) {
+	overlayFile := overlayFilePath(filePath, extension, overlay)
+	info, err := os.Stat(overlayFile)
+	switch {
+	case os.IsNotExist(err):
+		return "", nil, nil
+	case err != nil:
+		return "", nil, fmt.Errorf("failed to access overlay %s: %w", overlayFile, err)
+	case info.IsDir():
+		return "", nil, nil
+	}
+	return overlayFile, info, nil
+}
+
+// isOverlayFile reports whether filePath is the overlay for the named
+// environment of a base secret file beside it, i.e. "db.prod.yaml" next to
+// "db.yaml" for overlay "prod". Such a file is merged into its base rather
+// than pushed as a secret named "db.prod"; files with other suffixes, such as
+// "db.staging.yaml", are ordinary secrets. isFile reports whether a path
+// names a file.
+func isOverlayFile(filePath, extension, overlay string, isFile func(string) bool) bool {
+	name := trimSecretFileExtension(filePath, extension)
+	base, ok := strings.CutSuffix(name, "."+overlay)
+	if !ok || base == "" || strings.HasSuffix(base, string(filepath.Separator)) {
+		return false
+	}
+	return isFile(base + extension)
+}
+
+// isLocalFile reports whether filePath names a local file rather than a
+// directory.
+func isLocalFile(filePath string) bool {
+	info, err := os.Stat(filePath)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,137 @@
+package vaultsync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// PatchSecretAt applies secretData to the secret at ref as a JSON merge patch
+// (RFC 7386) using the KV v2 PATCH endpoint: only the given keys change, a nil
+// value removes its key, and the update is applied atomically by Vault without
+// a client-side read-modify-write.
+//
+// Vault versions without PATCH support answer 405; in that case the patch is
+// applied with a read-merge-write instead and a warning is printed. A secret
+// that does not exist yet (404) is created with a normal write.
+func (v *VaultClient) PatchSecretAt(ref SecretRef, secretData map[string]interface{}) error {
+	return v.patchSecretAt(ref, secretData, true)
+}
+
+// patchSecretAt is PatchSecretAt; unless create is set, a secret that does
+// not exist is reported with an error wrapping ErrSecretNotFound instead of
+// being created.
+func (v *VaultClient) patchSecretAt(ref SecretRef, secretData map[string]interface{}, create bool) error {
+	// KV v1 has no PATCH; merge client-side without the fallback warning.
+	if v.isKVv1() {
+		return v.readMergeWrite(ref, secretData, create)
+	}
+
+	err := v.patchSecret(ref, secretData)
+
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		return err
+	}
+
+	switch httpErr.StatusCode {
+	case http.StatusNotFound:
+		if !create {
+			return patchTargetMissing(ref)
+		}
+		return v.PutSecretAt(ref, mergePatch(nil, secretData))
+	case http.StatusMethodNotAllowed:
+		v.warnf("Vault does not support PATCH for %s; falling back to read-merge-write\n", ref.MetadataPath())
+		return v.readMergeWrite(ref, secretData, create)
+	default:
+		return err
+	}
+}
+
+// patchTargetMissing is the error of a patch of a secret that does not exist.
+func patchTargetMissing(ref SecretRef) error {
+	return fmt.Errorf("secret %s does not exist: %w", displayPath(ref.MetadataPath()), ErrSecretNotFound)
+}
+
+// PatchKeysAt sets the given keys of the secret at ref, leaving its other
+// keys as they are, for quick one-off changes without a pull and push. All
+// keys are updated in a single write (see PatchSecretAt), and the secret must
+// already exist. With dryRun, the diff the patch would make is printed
+// instead.
+func (v *VaultClient) PatchKeysAt(ref SecretRef, secretData map[string]interface{}, dryRun bool) error {
+	vaultPath := ref.MetadataPath()
+	if dryRun {
+		diff := v.pendingSecretDiff(vaultPath, secretData, true, true)
+		if diff.err == nil && strings.Contains(diff.output, "\nnew file mode ") {
+			return patchTargetMissing(ref)
+		}
+		return v.showDryRunDiff(pendingPush{vaultPath: vaultPath, secretData: secretData, diff: diff})
+	}
+
+	v.printf("Patching: %s\n", vaultPath)
+	if err := v.patchSecretAt(ref, secretData, false); err != nil {
+		return err
+	}
+	v.report(SecretResult{Path: displayPath(vaultPath), Action: "patched", Size: payloadSize(secretData)})
+	return nil
+}
+
+func (v *VaultClient) readMergeWrite(ref SecretRef, secretData map[string]interface{}, create bool) error {
+	existing, err := v.GetSecretAt(ref)
+	if errors.Is(err, ErrSecretNotFound) && !create {
+		return patchTargetMissing(ref)
+	}
+	if err != nil && !errors.Is(err, ErrSecretNotFound) {
+		return fmt.Errorf("failed to read secret for merge: %w", err)
+	}
+	return v.PutSecretAt(ref, mergePatch(existing, secretData))
+}
+
+func (v *VaultClient) patchSecret(ref SecretRef, secretData map[string]interface{}) error {
+	url := v.kvURL("data", ref)
+
+	payload := map[string]interface{}{
+		"data": secretData,
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	if err := v.checkPayloadSize(ref, len(jsonData)); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("PATCH", url, strings.NewReader(string(jsonData)))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("X-Vault-Token", v.token())
+	req.Header.Set("X-Vault-Namespace", v.Namespace)
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+
+	resp, err := v.do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	v.reportWarnings(v.kvAPIPath("data", ref), body)
+	return nil
+}
+
+// mergePatch applies patch to target following JSON merge patch semantics
+// (RFC 7386): nested maps merge recursively, a nil value deletes the key, and
+// anything else replaces the existing value. target is not modified.
+func mergePatch(target, patch map[string]interface{}) map[string]interface{} {
+	return mergeSecretData(target, patch, MergeDeep)
+}
@@ -0,0 +1,210 @@
+package vaultsync
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPatchSecretAtSendsMergePatch(t *testing.T) {
+	t.Parallel()
+
+	var method, contentType string
+	var body map[string]interface{}
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		method = r.Method
+		contentType = r.Header.Get("Content-Type")
+		raw, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(raw, &body); err != nil {
+			t.Fatalf("failed to parse request body %q: %v", raw, err)
+		}
+		return textResponse(http.StatusOK, ""), nil
+	})}
+
+	if err := client.PatchSecretAt(NewSecretRef("kv", "app/db"), map[string]interface{}{"password": "new"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if method != http.MethodPatch {
+		t.Fatalf("expected PATCH, got %q", method)
+	}
+	if contentType != "application/merge-patch+json" {
+		t.Fatalf("expected merge-patch content type, got %q", contentType)
+	}
+	if data := body["data"].(map[string]interface{}); data["password"] != "new" {
+		t.Fatalf("expected patched key in payload, got %#v", body)
+	}
+}
+
+func TestPatchSecretAtFallsBackToReadMergeWrite(t *testing.T) {
+	t.Parallel()
+
+	var written map[string]interface{}
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	var stderr bytes.Buffer
+	client.Output = nil
+	client.ErrOutput = &stderr
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		switch r.Method {
+		case http.MethodPatch:
+			return textResponse(http.StatusMethodNotAllowed, "unsupported operation"), nil
+		case http.MethodGet:
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{"data": map[string]any{"username": "alice", "password": "old"}},
+			})
+		case http.MethodPost:
+			raw, _ := io.ReadAll(r.Body)
+			var body map[string]interface{}
+			if err := json.Unmarshal(raw, &body); err != nil {
+				t.Fatalf("failed to parse request body %q: %v", raw, err)
+			}
+			written = body["data"].(map[string]interface{})
+			return textResponse(http.StatusOK, ""), nil
+		}
+		return textResponse(http.StatusNotFound, "not found"), nil
+	})}
+
+	if err := client.PatchSecretAt(NewSecretRef("kv", "app/db"), map[string]interface{}{"password": "new"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if written["username"] != "alice" || written["password"] != "new" {
+		t.Fatalf("expected merged write, got %#v", written)
+	}
+	if !strings.Contains(stderr.String(), "falling back to read-merge-write") {
+		t.Fatalf("expected fallback warning, got %q", stderr.String())
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	t.Parallel()
+
+	target := map[string]interface{}{
+		"keep":   "a",
+		"remove": "b",
+		"nested": map[string]interface{}{"x": 1, "y": 2},
+	}
+	patch := map[string]interface{}{
+		"remove": nil,
+		"added":  "c",
+		"nested": map[string]interface{}{"y": nil, "z": 3},
+	}
+
+	got := mergePatch(target, patch)
+
+	if got["keep"] != "a" || got["added"] != "c" {
+		t.Fatalf("expected kept and added keys, got %#v", got)
+	}
+	if _, ok := got["remove"]; ok {
+		t.Fatalf("expected nil to delete key, got %#v", got)
+	}
+	nested := got["nested"].(map[string]interface{})
+	if nested["x"] != 1 || nested["z"] != 3 {
+		t.Fatalf("expected nested maps merged, got %#v", nested)
+	}
+	if _, ok := nested["y"]; ok {
+		t.Fatalf("expected nested nil to delete key, got %#v", nested)
+	}
+	if _, ok := target["added"]; ok {
+		t.Fatal("expected target to be left unmodified")
+	}
+}
+
+func TestPatchKeysAtDryRunShowsMergedDiff(t *testing.T) {
+	disableExternalDiffTools(t)
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	var stdout bytes.Buffer
+	client.Output = &stdout
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Method != http.MethodGet {
+			t.Fatalf("dry run sent %s %s", r.Method, r.URL.Path)
+		}
+		return jsonResponse(t, http.StatusOK, map[string]any{
+			"data": map[string]any{
+				"data":     map[string]any{"username": "alice", "password": "old"},
+				"metadata": map[string]any{"version": 3},
+			},
+		})
+	})}
+
+	if err := client.PatchKeysAt(NewSecretRef("kv", "app/db"), map[string]interface{}{"password": "new"}, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out := stdout.String()
+	for _, want := range []string{"-password: old", "+password: new", " username: alice", "version v3 → v4"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in dry-run diff, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestPatchKeysAtRejectsMissingSecret(t *testing.T) {
+	t.Parallel()
+
+	for _, dryRun := range []bool{true, false} {
+		var stdout bytes.Buffer
+		client := NewVaultClient("https://vault.example", "token", "team-a")
+		client.Output = &stdout
+		client.ErrOutput = nil
+		client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			if r.Method != http.MethodGet && r.Method != http.MethodPatch {
+				t.Fatalf("dryRun=%v: unexpected %s %s", dryRun, r.Method, r.URL.Path)
+			}
+			return textResponse(http.StatusNotFound, "not found"), nil
+		})}
+
+		err := client.PatchKeysAt(NewSecretRef("kv", "app/db"), map[string]interface{}{"password": "new"}, dryRun)
+		if !errors.Is(err, ErrSecretNotFound) || !strings.Contains(err.Error(), "kv/app/db does not exist") {
+			t.Fatalf("dryRun=%v: error = %v, want a missing secret error", dryRun, err)
+		}
+		if strings.Contains(stdout.String(), "new file") {
+			t.Fatalf("dryRun=%v: expected no diff, got:\n%s", dryRun, stdout.String())
+		}
+	}
+}
+
+func TestPatchKeysAtPatchesAllKeysAtOnce(t *testing.T) {
+	t.Parallel()
+
+	var patches int
+	var body map[string]interface{}
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Method != http.MethodPatch {
+			t.Fatalf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+		patches++
+		raw, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(raw, &body); err != nil {
+			t.Fatalf("failed to parse request body %q: %v", raw, err)
+		}
+		return textResponse(http.StatusOK, ""), nil
+	})}
+
+	data := map[string]interface{}{"user": "app", "password": "new"}
+	if err := client.PatchKeysAt(NewSecretRef("kv", "app/db"), data, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if patches != 1 {
+		t.Fatalf("expected a single PATCH, got %d", patches)
+	}
+	if got := body["data"].(map[string]interface{}); got["user"] != "app" || got["password"] != "new" {
+		t.Fatalf("expected both keys in one payload, got %#v", body)
+	}
+}
@@ -2,11 +2,16 @@ package vaultsync
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 // capturedRequest records the parts of an outbound request we want to assert on.
@@ -177,3 +182,1103 @@ func TestPushSecretsFromFilesDirectAtDryRunSendsNoWrite(t *testing.T) {
 		t.Fatal("expected dry-run to send no write requests")
 	}
 }
+
+func TestPushSecretsFromFilesNoRecurseSkipsSubdirectories(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inputDir, "top"), []byte("value: top\n"), 0644); err != nil {
+		t.Fatalf("failed to write fixture secret: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(inputDir, "nested"), 0755); err != nil {
+		t.Fatalf("failed to create nested dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "nested", "leaf"), []byte("value: leaf\n"), 0644); err != nil {
+		t.Fatalf("failed to write nested fixture secret: %v", err)
+	}
+
+	var paths []string
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.PushOptions.NoRecurse = true
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		paths = append(paths, r.URL.Path)
+		return textResponse(http.StatusOK, ""), nil
+	})}
+
+	if err := client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(paths) != 1 || paths[0] != "/v1/kv/data/app/top" {
+		t.Fatalf("expected only the top-level secret to be pushed, got %v", paths)
+	}
+}
+
+func TestPushSecretsFromFilesRejectsInputPathThatIsAFile(t *testing.T) {
+	t.Parallel()
+
+	inputPath := filepath.Join(t.TempDir(), "secrets")
+	if err := os.WriteFile(inputPath, []byte("username: alice\n"), 0644); err != nil {
+		t.Fatalf("failed to write fixture file: %v", err)
+	}
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+
+	for _, ref := range []SecretRef{NewSecretRef("kv", ""), NewSecretRef("kv", "app")} {
+		err := client.PushSecretsFromFilesAt(inputPath, ref, true)
+		if err == nil || !strings.Contains(err.Error(), "is not a directory") {
+			t.Fatalf("expected clear not-a-directory error for %+v, got %v", ref, err)
+		}
+	}
+}
+
+func TestCustomKVSegmentsRewriteAPIURLs(t *testing.T) {
+	t.Parallel()
+
+	var paths []string
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.DataSegment = "secret-data"
+	client.MetadataSegment = "secret-meta"
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		if r.Method == http.MethodGet && r.URL.RawQuery == "list=true" {
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"keys": []string{"db"}}})
+		}
+		return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"data": map[string]any{}}})
+	})}
+
+	ref := NewSecretRef("kv", "app")
+	if _, err := client.ListSecretsAt(ref); err != nil {
+		t.Fatalf("ListSecretsAt: %v", err)
+	}
+	if _, err := client.GetSecretAt(NewSecretRef("kv", "app/db")); err != nil {
+		t.Fatalf("GetSecretAt: %v", err)
+	}
+	if err := client.PutSecretAt(NewSecretRef("kv", "app/db"), map[string]interface{}{"k": "v"}); err != nil {
+		t.Fatalf("PutSecretAt: %v", err)
+	}
+
+	want := []string{
+		"GET /v1/kv/secret-meta/app",
+		"GET /v1/kv/secret-data/app/db",
+		"POST /v1/kv/secret-data/app/db",
+	}
+	if strings.Join(paths, "|") != strings.Join(want, "|") {
+		t.Fatalf("expected rewritten segments %v, got %v", want, paths)
+	}
+}
+
+func TestPushSecretsFromFilesSniffsFormatForConfiguredExtensions(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	appDir := filepath.Join(inputDir, "app")
+	if err := os.MkdirAll(appDir, 0755); err != nil {
+		t.Fatalf("failed to create app dir: %v", err)
+	}
+	fixtures := map[string]string{
+		"db.json":    `{"username": "alice", "port": 5432}`,
+		"cache.txt":  "host: redis\n",
+		"notes.txt":  "just some notes, not a secret\n",
+		"plain":      "value: no-extension\n",
+		"ignored.md": "# not pushed\n",
+	}
+	for name, content := range fixtures {
+		if err := os.WriteFile(filepath.Join(appDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write fixture %s: %v", name, err)
+		}
+	}
+
+	bodies := make(map[string]map[string]interface{})
+	var stderr strings.Builder
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = &stderr
+	client.PushOptions.Extensions = []string{".json", ".txt", ""}
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		var payload struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Fatalf("failed to decode body: %v", err)
+		}
+		bodies[r.URL.Path] = payload.Data
+		return textResponse(http.StatusOK, ""), nil
+	})}
+
+	if err := client.PushSecretsFromFilesAt(inputDir, NewSecretRef("kv", "app"), false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(bodies) != 3 {
+		t.Fatalf("expected db, cache and plain to be pushed, got %v", bodies)
+	}
+	if bodies["/v1/kv/data/app/db"]["username"] != "alice" {
+		t.Fatalf("expected JSON file pushed with extension stripped, got %v", bodies)
+	}
+	if bodies["/v1/kv/data/app/cache"]["host"] != "redis" {
+		t.Fatalf("expected YAML content in .txt file to be pushed, got %v", bodies)
+	}
+	if bodies["/v1/kv/data/app/plain"]["value"] != "no-extension" {
+		t.Fatalf("expected extensionless file to be pushed, got %v", bodies)
+	}
+
+	if !strings.Contains(stderr.String(), "skipping") || !strings.Contains(stderr.String(), "notes.txt") {
+		t.Fatalf("expected warning for unparseable file, got %q", stderr.String())
+	}
+}
+
+func TestPutSecretAtKVv1SendsUnwrappedBody(t *testing.T) {
+	t.Parallel()
+
+	var captured capturedRequest
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.KVVersion = 1
+	client.client = &http.Client{Transport: captureSingleRequest(t, &captured)}
+
+	if err := client.PutSecretAt(NewSecretRef("secret", "app/db"), map[string]interface{}{"username": "alice"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if captured.path != "/v1/secret/app/db" {
+		t.Fatalf("expected KV v1 path without data segment, got %q", captured.path)
+	}
+	if captured.body["username"] != "alice" || captured.body["data"] != nil {
+		t.Fatalf("expected unwrapped body, got %#v", captured.body)
+	}
+
+	if err := client.PutSecretMetadataAt(NewSecretRef("secret", "app/db"), SecretOptions{}); !errors.Is(err, ErrKVv1Unsupported) {
+		t.Fatalf("expected ErrKVv1Unsupported for metadata, got %v", err)
+	}
+}
+
+func TestPushDryRunAnnotatesResultingVersion(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	for name, content := range map[string]string{"existing": "username: bob\n", "fresh": "username: carol\n"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write fixture secret: %v", err)
+		}
+	}
+
+	disableExternalDiffTools(t)
+
+	var out strings.Builder
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = &out
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path == "/v1/kv/data/app/existing" {
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{
+					"data":     map[string]any{"username": "alice"},
+					"metadata": map[string]any{"version": 3},
+				},
+			})
+		}
+		return textResponse(http.StatusNotFound, "not found"), nil
+	})}
+
+	if err := client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, want := range []string{
+		"diff --git a/kv/metadata/app/existing b/kv/metadata/app/existing\nversion v3 → v4\n",
+		"diff --git a/kv/metadata/app/fresh b/kv/metadata/app/fresh\nversion → v1\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in dry-run output, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestPushOnlyNewSkipsExistingSecrets(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	for name, content := range map[string]string{"existing": "username: bob\n", "fresh": "username: carol\n"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write fixture secret: %v", err)
+		}
+	}
+
+	var out strings.Builder
+	var created []string
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = &out
+	client.ErrOutput = nil
+	client.PushOptions.OnlyNew = true
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Method != http.MethodPost {
+			t.Fatalf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to parse request body: %v", err)
+		}
+		if options, _ := body["options"].(map[string]any); options["cas"] != float64(0) {
+			t.Fatalf("expected a write with cas=0, got %#v", body)
+		}
+		if r.URL.Path == "/v1/kv/data/app/existing" {
+			return textResponse(http.StatusBadRequest, `{"errors":["check-and-set parameter did not match the current version"]}`), nil
+		}
+		created = append(created, r.URL.Path)
+		return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"version": 1}})
+	})}
+
+	if err := client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(created) != 1 || created[0] != "/v1/kv/data/app/fresh" {
+		t.Fatalf("expected only the fresh secret to be created, got %v", created)
+	}
+	for _, want := range []string{
+		"Created: kv/metadata/app/fresh\n",
+		"Skipping: kv/metadata/app/existing (already exists)\n",
+		"Skipped 1 of 2 secrets because they already exist\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in output, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestPushOnlyNewDryRunSkipsExistingSecrets(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	for name, content := range map[string]string{"existing": "username: bob\n", "fresh": "username: carol\n"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write fixture secret: %v", err)
+		}
+	}
+
+	var results []SecretResult
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.PushOptions.OnlyNew = true
+	client.OnResult = func(result SecretResult) { results = append(results, result) }
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Method != http.MethodGet {
+			t.Fatalf("dry run sent %s %s", r.Method, r.URL.Path)
+		}
+		if r.URL.Path == "/v1/kv/data/app/existing" {
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{"data": map[string]any{"username": "alice"}},
+			})
+		}
+		return textResponse(http.StatusNotFound, "not found"), nil
+	})}
+
+	if err := client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []SecretResult{
+		{Path: "kv/app/existing", Action: "skipped"},
+		{Path: "kv/app/fresh", Action: "create", Version: 1, Size: len(`{"username":"carol"}`)},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Fatalf("results = %+v, want %+v", results, want)
+	}
+}
+
+func TestPushOnlyNewRejectsPatch(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.PushOptions.OnlyNew = true
+	client.PushOptions.Patch = true
+	if err := client.PushSecretsFromFilesDirectAt(t.TempDir(), NewSecretRef("kv", "app"), false); err == nil {
+		t.Fatal("expected an error combining only-new with patch")
+	}
+}
+
+func TestPushDryRunDiffsConcurrentlyAndPrintsInOrder(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	names := []string{"a", "b", "c", "d", "e", "f"}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte("value: "+name+"\n"), 0644); err != nil {
+			t.Fatalf("failed to write fixture secret: %v", err)
+		}
+	}
+
+	disableExternalDiffTools(t)
+
+	var out strings.Builder
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = &out
+	client.ErrOutput = nil
+	client.DiffConcurrency = 3
+	var results []string
+	client.OnResult = func(result SecretResult) { results = append(results, result.Path) }
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		return textResponse(http.StatusNotFound, "not found"), nil
+	})}
+
+	if err := client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if maxInFlight < 2 || maxInFlight > 3 {
+		t.Fatalf("expected 2-3 reads in flight at once, got %d", maxInFlight)
+	}
+	last := -1
+	for _, name := range names {
+		index := strings.Index(out.String(), "diff --git a/kv/metadata/app/"+name+" ")
+		if index <= last {
+			t.Fatalf("expected the diff of %s after the previous one, got:\n%s", name, out.String())
+		}
+		last = index
+	}
+	if want := []string{"kv/app/a", "kv/app/b", "kv/app/c", "kv/app/d", "kv/app/e", "kv/app/f"}; !reflect.DeepEqual(results, want) {
+		t.Fatalf("results = %v, want %v", results, want)
+	}
+}
+
+func TestPushDryRunIgnoresFields(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	for name, content := range map[string]string{
+		"rotated": "username: alice\nlast_rotated: \"2026-10-16\"\n",
+		"changed": "username: bob\nlast_rotated: \"2026-10-16\"\nrotated_at: today\n",
+	} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write fixture secret: %v", err)
+		}
+	}
+
+	disableExternalDiffTools(t)
+
+	var out strings.Builder
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = &out
+	client.ErrOutput = nil
+	client.IgnoreFields = []string{"last_rotated", "*_at"}
+	actions := make(map[string]string)
+	client.OnResult = func(result SecretResult) { actions[result.Path] = result.Action }
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return jsonResponse(t, http.StatusOK, map[string]any{
+			"data": map[string]any{
+				"data":     map[string]any{"username": "alice", "last_rotated": "2026-10-01", "rotated_at": "yesterday"},
+				"metadata": map[string]any{"version": 1},
+			},
+		})
+	})}
+
+	if err := client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := map[string]string{"kv/app/changed": "update", "kv/app/rotated": "unchanged"}; !reflect.DeepEqual(actions, want) {
+		t.Fatalf("actions = %v, want %v", actions, want)
+	}
+	if strings.Contains(out.String(), "kv/metadata/app/rotated") {
+		t.Fatalf("expected no diff for a secret differing only in ignored fields, got:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "+username: bob") || strings.Contains(out.String(), "last_rotated") || strings.Contains(out.String(), "rotated_at") {
+		t.Fatalf("expected the diff to show username but not ignored fields, got:\n%s", out.String())
+	}
+}
+
+func TestPushDryRunRejectsInvalidIgnoreFields(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inputDir, "db"), []byte("username: alice\n"), 0644); err != nil {
+		t.Fatalf("failed to write fixture secret: %v", err)
+	}
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.IgnoreFields = []string{"[unclosed"}
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		t.Fatalf("unexpected request %s", r.URL.Path)
+		return nil, nil
+	})}
+
+	err := client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), true)
+	if err == nil || !strings.Contains(err.Error(), "invalid key pattern") {
+		t.Fatalf("expected invalid key pattern error, got %v", err)
+	}
+}
+
+func TestPushSecretsFromFilesChangedSinceSkipsOlderFiles(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	for _, name := range []string{"old", "new"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte("value: "+name+"\n"), 0644); err != nil {
+			t.Fatalf("failed to write fixture secret: %v", err)
+		}
+	}
+
+	now := time.Now()
+	past := now.Add(-2 * time.Hour)
+	if err := os.Chtimes(filepath.Join(inputDir, "old"), past, past); err != nil {
+		t.Fatalf("failed to age fixture: %v", err)
+	}
+
+	var paths []string
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.PushOptions.ChangedSince = now.Add(-time.Hour)
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		paths = append(paths, r.URL.Path)
+		return textResponse(http.StatusOK, ""), nil
+	})}
+
+	if err := client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(paths) != 1 || paths[0] != "/v1/kv/data/app/new" {
+		t.Fatalf("expected only the recently modified file to be pushed, got %v", paths)
+	}
+}
+
+func TestPushSecretsFromFilesMergesOverlay(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	files := map[string]string{
+		"db.yaml":         "host: db.internal\nport: 5432\nuser: app\n",
+		"db.prod.yaml":    "host: db.prod.internal\npassword: prod-secret\nuser: ~\n",
+		"db.staging.yaml": "host: db.staging.internal\n",
+		"api.yaml":        "token: base\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write fixture secret: %v", err)
+		}
+	}
+
+	writes := make(map[string]map[string]interface{})
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.PushOptions.Overlay = "prod"
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		var body struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to parse request body: %v", err)
+		}
+		writes[r.URL.Path] = body.Data
+		return textResponse(http.StatusOK, ""), nil
+	})}
+
+	if err := client.PushSecretsFromFilesAt(inputDir, NewSecretRef("kv", ""), false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(writes) != 3 {
+		t.Fatalf("expected db, api, and db.staging to be pushed, got %v", writes)
+	}
+	// Only the selected environment's suffix marks an overlay; other dotted
+	// names are ordinary secrets.
+	if got := writes["/v1/kv/data/db.staging"]["host"]; got != "db.staging.internal" {
+		t.Fatalf("expected db.staging.yaml to be pushed as its own secret, got %v", writes)
+	}
+
+	want := map[string]interface{}{"host": "db.prod.internal", "port": float64(5432), "password": "prod-secret"}
+	if got := writes["/v1/kv/data/db"]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected merged overlay %v, got %v", want, got)
+	}
+	if got := writes["/v1/kv/data/api"]["token"]; got != "base" {
+		t.Fatalf("expected api without an overlay to be pushed as is, got %v", writes["/v1/kv/data/api"])
+	}
+}
+
+func TestPushSecretsFromFilesFailFastRejectsUnparseableFile(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inputDir, "broken.yaml"), []byte("key: [unterminated\n"), 0644); err != nil {
+		t.Fatalf("failed to write fixture secret: %v", err)
+	}
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.FailFast = true
+	client.PushOptions.Extensions = []string{".yaml"}
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		t.Fatalf("unexpected request %s", r.URL.Path)
+		return nil, nil
+	})}
+
+	err := client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), false)
+	if err == nil || !strings.Contains(err.Error(), "broken.yaml") {
+		t.Fatalf("expected parse error for broken.yaml, got %v", err)
+	}
+}
+
+func TestPushSecretsFromFilesRejectsUnparseableDefaultFile(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inputDir, "broken.yaml"), []byte("key: [unterminated\n"), 0644); err != nil {
+		t.Fatalf("failed to write fixture secret: %v", err)
+	}
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		t.Fatalf("unexpected request %s", r.URL.Path)
+		return nil, nil
+	})}
+
+	err := client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), false)
+	if err == nil || !strings.Contains(err.Error(), "broken.yaml") {
+		t.Fatalf("expected parse error for broken.yaml, got %v", err)
+	}
+}
+
+func TestPushSecretsFromFilesRejectsOversizedFileBeforeUpload(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inputDir, "small.yaml"), []byte("key: value\n"), 0644); err != nil {
+		t.Fatalf("failed to write fixture secret: %v", err)
+	}
+	big := "blob: " + strings.Repeat("x", 200) + "\n"
+	if err := os.WriteFile(filepath.Join(inputDir, "zz-big.yaml"), []byte(big), 0644); err != nil {
+		t.Fatalf("failed to write fixture secret: %v", err)
+	}
+
+	var paths []string
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.PushOptions.MaxSecretSize = 100
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		paths = append(paths, r.URL.Path)
+		return textResponse(http.StatusOK, ""), nil
+	})}
+
+	err := client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), false)
+	if !errors.Is(err, ErrSecretTooLarge) || !strings.Contains(err.Error(), "zz-big.yaml") {
+		t.Fatalf("expected ErrSecretTooLarge naming the file, got %v", err)
+	}
+	// Every file is read before the first write, so nothing is uploaded.
+	if len(paths) != 0 {
+		t.Fatalf("expected nothing to be uploaded, got %v", paths)
+	}
+}
+
+func TestPutSecretAtEnforcesMaxSize(t *testing.T) {
+	t.Parallel()
+
+	data := map[string]interface{}{"blob": strings.Repeat("x", DefaultMaxSecretSize)}
+
+	// The library leaves writes unlimited unless a limit is set.
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return textResponse(http.StatusOK, ""), nil
+	})}
+	if err := client.PutSecretAt(NewSecretRef("kv", "app/big"), data); err != nil {
+		t.Fatalf("expected no limit by default, got %v", err)
+	}
+
+	client.PushOptions.MaxSecretSize = DefaultMaxSecretSize
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		t.Fatalf("unexpected request %s", r.URL.Path)
+		return nil, nil
+	})}
+	if err := client.PutSecretAt(NewSecretRef("kv", "app/big"), data); !errors.Is(err, ErrSecretTooLarge) {
+		t.Fatalf("expected ErrSecretTooLarge, got %v", err)
+	}
+}
+
+// preflightTestClient serves sys/capabilities-self from capabilities (keyed by
+// API path, "deny" otherwise) and records every other request.
+func preflightTestClient(t *testing.T, capabilities map[string][]string, requests *[]string) *VaultClient {
+	t.Helper()
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.PushOptions.Preflight = true
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path != "/v1/sys/capabilities-self" {
+			*requests = append(*requests, r.Method+" "+r.URL.Path)
+			return textResponse(http.StatusOK, ""), nil
+		}
+
+		var body struct {
+			Paths []string `json:"paths"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to parse request body: %v", err)
+		}
+		data := map[string]any{}
+		for _, path := range body.Paths {
+			if caps, ok := capabilities[path]; ok {
+				data[path] = caps
+			} else {
+				data[path] = []string{"deny"}
+			}
+		}
+		return jsonResponse(t, http.StatusOK, map[string]any{"data": data})
+	})}
+	return client
+}
+
+func TestPushSecretsFromFilesPreflightAbortsBeforeWriting(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	appDir := filepath.Join(inputDir, "app")
+	if err := os.Mkdir(appDir, 0755); err != nil {
+		t.Fatalf("failed to create fixture dir: %v", err)
+	}
+	for _, name := range []string{"api.yaml", "db.yaml"} {
+		if err := os.WriteFile(filepath.Join(appDir, name), []byte("key: value\n"), 0644); err != nil {
+			t.Fatalf("failed to write fixture secret: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(appDir, "web.yaml"), []byte("key: value\n_options:\n  max_versions: 5\n"), 0644); err != nil {
+		t.Fatalf("failed to write fixture secret: %v", err)
+	}
+
+	var requests []string
+	client := preflightTestClient(t, map[string][]string{
+		"kv/data/app/api": {"create", "update"},
+		"kv/data/app/db":  {"read"},
+		"kv/data/app/web": {"update"},
+	}, &requests)
+
+	err := client.PushSecretsFromFilesAt(inputDir, NewSecretRef("kv", "app"), false)
+	if !errors.Is(err, ErrMissingCapability) {
+		t.Fatalf("expected ErrMissingCapability, got %v", err)
+	}
+	for _, want := range []string{"2 of 4 paths", "kv/data/app/db (needs create or update, has read)", "kv/metadata/app/web"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in error, got:\n%v", want, err)
+		}
+	}
+	if len(requests) != 0 {
+		t.Fatalf("expected no writes after a failed preflight, got %v", requests)
+	}
+}
+
+func TestPushSecretsFromFilesPreflightPushesWhenWritable(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	appDir := filepath.Join(inputDir, "app")
+	if err := os.Mkdir(appDir, 0755); err != nil {
+		t.Fatalf("failed to create fixture dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(appDir, "db.yaml"), []byte("key: value\n"), 0644); err != nil {
+		t.Fatalf("failed to write fixture secret: %v", err)
+	}
+
+	var requests []string
+	client := preflightTestClient(t, map[string][]string{"kv/data/app/db": {"patch"}}, &requests)
+	client.PushOptions.Patch = true
+
+	if err := client.PushSecretsFromFilesAt(inputDir, NewSecretRef("kv", "app"), false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(requests) != 1 || requests[0] != "PATCH /v1/kv/data/app/db" {
+		t.Fatalf("expected a single patch, got %v", requests)
+	}
+}
+
+func TestPushSecretsFromFilesPushesInVaultPathOrder(t *testing.T) {
+	t.Parallel()
+
+	// filepath.Walk visits the directory "a" before the file "a.b", but
+	// "app/a.b" sorts before "app/a/c".
+	inputDir := t.TempDir()
+	for _, name := range []string{"a/c", "a.b"} {
+		path := filepath.Join(inputDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create fixture dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("key: value\n"), 0644); err != nil {
+			t.Fatalf("failed to write fixture secret: %v", err)
+		}
+	}
+
+	var paths []string
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		paths = append(paths, r.URL.Path)
+		return textResponse(http.StatusOK, ""), nil
+	})}
+
+	if err := client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"/v1/kv/data/app/a.b", "/v1/kv/data/app/a/c"}; !reflect.DeepEqual(paths, want) {
+		t.Fatalf("push order = %v, want %v", paths, want)
+	}
+}
+
+func TestPushDryRunWritesPlanOutput(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	contents := "username: carol\n_options:\n  max_versions: 3\n"
+	if err := os.WriteFile(filepath.Join(inputDir, "db"), []byte(contents), 0644); err != nil {
+		t.Fatalf("failed to write fixture secret: %v", err)
+	}
+
+	disableExternalDiffTools(t)
+
+	var out, plan strings.Builder
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = &out
+	client.ErrOutput = nil
+	client.PushOptions.PlanOutput = &plan
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Method != http.MethodGet {
+			t.Fatalf("unexpected %s %s during dry run", r.Method, r.URL.Path)
+		}
+		return textResponse(http.StatusNotFound, "not found"), nil
+	})}
+
+	if err := client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, want := range []string{
+		"diff --git a/kv/metadata/app/db b/kv/metadata/app/db\nversion → v1\n",
+		"+username: carol",
+		`Metadata options for kv/metadata/app/db: {"max_versions":3}`,
+	} {
+		if !strings.Contains(plan.String(), want) {
+			t.Fatalf("expected %q in plan, got:\n%s", want, plan.String())
+		}
+	}
+}
+
+func TestPushDryRunMaskPlanMasksOnlyPlanOutput(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inputDir, "db"), []byte("password: hunter2\n"), 0644); err != nil {
+		t.Fatalf("failed to write fixture secret: %v", err)
+	}
+
+	disableExternalDiffTools(t)
+
+	var out, plan strings.Builder
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = &out
+	client.ErrOutput = nil
+	client.PushOptions.PlanOutput = &plan
+	client.PushOptions.MaskPlan = true
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return jsonResponse(t, http.StatusOK, map[string]interface{}{
+			"data": map[string]interface{}{
+				"data":     map[string]interface{}{"password": "letmein"},
+				"metadata": map[string]interface{}{"version": 2},
+			},
+		})
+	})}
+
+	if err := client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "+password: hunter2") {
+		t.Fatalf("expected unmasked value on output, got:\n%s", out.String())
+	}
+	if strings.Contains(plan.String(), "hunter2") || strings.Contains(plan.String(), "letmein") {
+		t.Fatalf("expected masked values in plan, got:\n%s", plan.String())
+	}
+	if !strings.Contains(plan.String(), "version v2 → v3\n") || !strings.Contains(plan.String(), "+password: '"+changedSecretValue+"'") {
+		t.Fatalf("expected masked change in plan, got:\n%s", plan.String())
+	}
+}
+
+func TestPushReportsResults(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	for name, content := range map[string]string{"existing": "username: bob\n", "fresh": "username: carol\n", "same": "username: dave\n"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write fixture secret: %v", err)
+		}
+	}
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		switch {
+		case r.Method == http.MethodPost:
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"version": 7}})
+		case r.URL.Path == "/v1/kv/data/app/existing":
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{"data": map[string]any{"username": "alice"}, "metadata": map[string]any{"version": 3}},
+			})
+		case r.URL.Path == "/v1/kv/data/app/same":
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{"data": map[string]any{"username": "dave"}, "metadata": map[string]any{"version": 2}},
+			})
+		}
+		return textResponse(http.StatusNotFound, "not found"), nil
+	})}
+
+	var results []SecretResult
+	client.OnResult = func(result SecretResult) { results = append(results, result) }
+
+	if err := client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	size := len(`{"username":"alice"}`) - len("alice") + len("bob")
+	want := []SecretResult{
+		{Path: "kv/app/existing", Action: "update", Version: 4, Size: size},
+		{Path: "kv/app/fresh", Action: "create", Version: 1, Size: size + 2},
+		{Path: "kv/app/same", Action: "unchanged", Version: 2, Size: size + 1},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Fatalf("unexpected dry-run results:\n%+v\nwant:\n%+v", results, want)
+	}
+
+	results = nil
+	if err := client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 3 || results[0] != (SecretResult{Path: "kv/app/existing", Action: "pushed", Version: 7, Size: size}) {
+		t.Fatalf("unexpected push results: %+v", results)
+	}
+}
+
+func TestEmptySecretRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	secrets := map[string]map[string]interface{}{
+		"app/db":          {"password": "s3cr3t"},
+		"app/placeholder": {},
+	}
+	newClient := func() *VaultClient {
+		client := NewVaultClient("https://vault.example", "token", "namespace")
+		client.Output = io.Discard
+		client.ErrOutput = io.Discard
+		client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case r.Method == http.MethodGet && r.URL.Path == "/v1/kv/metadata/app" && r.URL.RawQuery == "list=true":
+				return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"keys": []string{"db", "placeholder"}}})
+			case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v1/kv/data/"):
+				data, ok := secrets[strings.TrimPrefix(r.URL.Path, "/v1/kv/data/")]
+				if !ok {
+					return textResponse(http.StatusNotFound, `{"errors":[]}`), nil
+				}
+				return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"data": data}})
+			case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/v1/kv/data/"):
+				var body struct {
+					Data map[string]interface{} `json:"data"`
+				}
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Errorf("failed to decode write: %v", err)
+				}
+				if body.Data == nil {
+					t.Errorf("write to %s sent null data", r.URL.Path)
+				}
+				secrets[strings.TrimPrefix(r.URL.Path, "/v1/kv/data/")] = body.Data
+				return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"version": 1}})
+			default:
+				return textResponse(http.StatusNotFound, "not found"), nil
+			}
+		})}
+		return client
+	}
+	ref := NewSecretRef("kv", "app")
+
+	// Without PreserveEmpty the empty secret is written as plain {}.
+	plainDir := t.TempDir()
+	if err := newClient().PullSecretsToFilesAt(ref, plainDir); err != nil {
+		t.Fatalf("pull: %v", err)
+	}
+	plain, err := os.ReadFile(filepath.Join(plainDir, "app", "placeholder.yaml"))
+	if err != nil {
+		t.Fatalf("empty secret was not written without PreserveEmpty: %v", err)
+	}
+	if string(plain) != "{}\n" {
+		t.Fatalf("placeholder.yaml without PreserveEmpty = %q, want {}", plain)
+	}
+
+	outputDir := t.TempDir()
+	puller := newClient()
+	puller.PullOptions.PreserveEmpty = true
+	if err := puller.PullSecretsToFilesAt(ref, outputDir); err != nil {
+		t.Fatalf("pull: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(outputDir, "app", "placeholder.yaml"))
+	if err != nil {
+		t.Fatalf("empty secret was not written: %v", err)
+	}
+	if string(content) != emptySecretFile {
+		t.Fatalf("placeholder.yaml = %q, want %q", content, emptySecretFile)
+	}
+	if directives := parseFileDirectives(content); !directives.Empty || len(directives.Unknown) > 0 {
+		t.Fatalf("directives = %+v, want the empty directive", directives)
+	}
+
+	// Without PreserveEmpty push leaves the placeholder out.
+	mu.Lock()
+	delete(secrets, "app/placeholder")
+	mu.Unlock()
+	if err := newClient().PushSecretsFromFilesAt(outputDir, NewSecretRef("kv", ""), false); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	mu.Lock()
+	_, pushed := secrets["app/placeholder"]
+	mu.Unlock()
+	if pushed {
+		t.Fatal("empty secret was pushed without PreserveEmpty")
+	}
+
+	pusher := newClient()
+	pusher.PushOptions.PreserveEmpty = true
+	if err := pusher.PushSecretsFromFilesAt(outputDir, NewSecretRef("kv", ""), false); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if data, ok := secrets["app/placeholder"]; !ok || len(data) != 0 {
+		t.Fatalf("placeholder after push = %v (present %v), want an empty secret", data, ok)
+	}
+}
+
+func TestPushReviewDecidesWhatIsWritten(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	for name, content := range map[string]string{"edited": "username: bob\n", "skipped": "username: carol\n", "same": "username: dave\n"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write fixture secret: %v", err)
+		}
+	}
+
+	written := make(map[string]string)
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		switch {
+		case r.Method == http.MethodPost:
+			body, _ := io.ReadAll(r.Body)
+			written[r.URL.Path] = string(body)
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"version": 2}})
+		case r.URL.Path == "/v1/kv/data/app/same":
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{"data": map[string]any{"username": "dave"}, "metadata": map[string]any{"version": 1}},
+			})
+		}
+		return textResponse(http.StatusNotFound, "not found"), nil
+	})}
+
+	var reviewed []string
+	client.PushOptions.Review = func(change PushReview) (map[string]interface{}, bool, error) {
+		reviewed = append(reviewed, change.Path)
+		if !strings.Contains(change.Diff, "+username:") {
+			t.Errorf("diff for %s does not show the change:\n%s", change.Path, change.Diff)
+		}
+		if change.Path == "kv/app/skipped" {
+			return nil, false, nil
+		}
+		return map[string]interface{}{"username": "erin"}, true, nil
+	}
+
+	if err := client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"kv/app/edited", "kv/app/skipped"}; !reflect.DeepEqual(reviewed, want) {
+		t.Fatalf("reviewed %v, want %v", reviewed, want)
+	}
+	if len(written) != 1 || !strings.Contains(written["/v1/kv/data/app/edited"], `"erin"`) {
+		t.Fatalf("unexpected writes: %v", written)
+	}
+}
+
+func TestPushReviewErrorStopsPush(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	for _, name := range []string{"a", "b"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte("username: bob\n"), 0644); err != nil {
+			t.Fatalf("failed to write fixture secret: %v", err)
+		}
+	}
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Method == http.MethodPost {
+			t.Errorf("unexpected write to %s", r.URL.Path)
+		}
+		return textResponse(http.StatusNotFound, "not found"), nil
+	})}
+
+	stop := errors.New("stop")
+	calls := 0
+	client.PushOptions.Review = func(PushReview) (map[string]interface{}, bool, error) {
+		calls++
+		return nil, false, stop
+	}
+
+	if err := client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), false); !errors.Is(err, stop) {
+		t.Fatalf("expected the review error, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("review called %d times, want 1", calls)
+	}
+}
@@ -0,0 +1,106 @@
+package vaultsync
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type vaultRawResponse struct {
+	LeaseID string                 `json:"lease_id"`
+	Data    map[string]interface{} `json:"data"`
+}
+
+// ReadRaw performs a plain GET on an arbitrary API path (relative to /v1/) and
+// returns the response's data field. Unlike GetSecretAt, the path is used as
+// given, with no KV v2 data/metadata rewriting, so it works for dynamic
+// secrets such as "database/creds/readonly" and for non-KV endpoints. The
+// lease of a dynamic secret is tracked for RevokeLeases.
+func (v *VaultClient) ReadRaw(path string) (map[string]interface{}, error) {
+	url := fmt.Sprintf("%s/v1/%s", v.Address, strings.Trim(path, "/"))
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("X-Vault-Token", v.token())
+	req.Header.Set("X-Vault-Namespace", v.Namespace)
+
+	resp, err := v.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	v.reportWarnings(path, body)
+
+	var vaultResp vaultRawResponse
+	if err := json.Unmarshal(body, &vaultResp); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	v.trackLease(vaultResp.LeaseID)
+
+	return vaultResp.Data, nil
+}
+
+// WriteRaw POSTs data as the JSON body of an arbitrary API path (relative to
+// /v1/), without the KV v2 "data" wrapper PutSecretAt adds, for configuring
+// engines and writing to non-KV endpoints. It returns the response's data
+// field, which is nil for endpoints that reply 204 No Content.
+func (v *VaultClient) WriteRaw(path string, data map[string]interface{}) (map[string]interface{}, error) {
+	url := fmt.Sprintf("%s/v1/%s", v.Address, strings.Trim(path, "/"))
+
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", url, strings.NewReader(string(jsonData)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("X-Vault-Token", v.token())
+	req.Header.Set("X-Vault-Namespace", v.Namespace)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := v.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	v.reportWarnings(path, body)
+
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return nil, nil
+	}
+
+	var vaultResp vaultRawResponse
+	if err := json.Unmarshal(body, &vaultResp); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	v.trackLease(vaultResp.LeaseID)
+
+	return vaultResp.Data, nil
+}
@@ -0,0 +1,80 @@
+package vaultsync
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestReadRawUsesPathVerbatim(t *testing.T) {
+	t.Parallel()
+
+	var path string
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		path = r.URL.Path
+		return jsonResponse(t, http.StatusOK, map[string]any{
+			"lease_id": "database/creds/readonly/abc",
+			"data":     map[string]any{"username": "v-token-readonly", "password": "pw"},
+		})
+	})}
+
+	data, err := client.ReadRaw("/database/creds/readonly")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if path != "/v1/database/creds/readonly" {
+		t.Fatalf("expected path without KV rewriting, got %q", path)
+	}
+	if data["username"] != "v-token-readonly" {
+		t.Fatalf("expected data field, got %#v", data)
+	}
+}
+
+func TestReadRawReturnsHTTPError(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return textResponse(http.StatusForbidden, "permission denied"), nil
+	})}
+
+	_, err := client.ReadRaw("sys/mounts")
+
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 HTTPError, got %v", err)
+	}
+}
+
+func TestWriteRawSendsUnwrappedBody(t *testing.T) {
+	t.Parallel()
+
+	var captured capturedRequest
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: captureSingleRequest(t, &captured)}
+
+	data, err := client.WriteRaw("database/roles/readonly", map[string]interface{}{"db_name": "postgres"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data != nil {
+		t.Fatalf("expected no data for empty response, got %#v", data)
+	}
+
+	if captured.method != http.MethodPost || captured.path != "/v1/database/roles/readonly" {
+		t.Fatalf("unexpected request %s %s", captured.method, captured.path)
+	}
+	if captured.body["db_name"] != "postgres" || captured.body["data"] != nil {
+		t.Fatalf("expected unwrapped body, got %#v", captured.body)
+	}
+}
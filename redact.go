@@ -0,0 +1,132 @@
+package vaultsync
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redactedValue replaces every secret value in a redacted file.
+const redactedValue = "***"
+
+// RedactSecretFiles rewrites the YAML secret files below dir in place with
+// every value replaced by "***", keeping keys, nesting, and comments, so the
+// structure of a pulled tree can be shared without its contents. Secret files
+// are the *.yaml files and the key files of exploded secrets; null values and
+// the _options block are kept as they are, and every document of a
+// multi-document file is redacted. It returns the files that were rewritten.
+// Files that cannot be parsed are left untouched and reported in the returned
+// error.
+func RedactSecretFiles(dir string) ([]string, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to access %s: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	var redacted []string
+	fileErrs := &MultiError{}
+	err = filepath.Walk(dir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		inExploded := strings.HasSuffix(filepath.Dir(filePath), ".yaml"+explodedSecretSuffix)
+		if !inExploded && filepath.Ext(filePath) != ".yaml" {
+			return nil
+		}
+
+		changed, err := redactSecretFile(filePath, info.Mode().Perm())
+		if err != nil {
+			fileErrs.Add(filePath, err)
+			return nil
+		}
+		if changed {
+			redacted = append(redacted, filePath)
+		}
+		return nil
+	})
+	if err != nil {
+		return redacted, err
+	}
+	return redacted, fileErrs.ErrorOrNil()
+}
+
+// redactSecretFile redacts one file, rewriting it only when its content
+// changes. Every document of a multi-document file is redacted and kept.
+func redactSecretFile(filePath string, perm os.FileMode) (bool, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return false, err
+	}
+
+	var docs []*yaml.Node
+	dec := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); err == io.EOF {
+			break
+		} else if err != nil {
+			return false, fmt.Errorf("invalid YAML: %w", err)
+		}
+		redactNode(&doc, true)
+		docs = append(docs, &doc)
+	}
+	if len(docs) == 0 {
+		return false, nil // empty file
+	}
+
+	var redacted bytes.Buffer
+	enc := yaml.NewEncoder(&redacted)
+	for _, doc := range docs {
+		if err := enc.Encode(doc); err != nil {
+			return false, err
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return false, err
+	}
+	if bytes.Equal(redacted.Bytes(), content) {
+		return false, nil
+	}
+	return true, os.WriteFile(filePath, redacted.Bytes(), perm)
+}
+
+// redactNode replaces the scalar values below n with redactedValue. Mapping
+// keys are kept, and so is the top-level _options block when topLevel is set.
+func redactNode(n *yaml.Node, topLevel bool) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, child := range n.Content {
+			redactNode(child, topLevel)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if topLevel && n.Content[i].Value == secretOptionsKey {
+				continue
+			}
+			redactNode(n.Content[i+1], false)
+		}
+	case yaml.SequenceNode:
+		for _, child := range n.Content {
+			redactNode(child, false)
+		}
+	case yaml.ScalarNode:
+		if n.Tag == "!!null" {
+			return
+		}
+		n.Value = redactedValue
+		n.Tag = "!!str"
+		n.Style = 0
+	}
+}
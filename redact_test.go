@@ -0,0 +1,90 @@
+package vaultsync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRedactSecretFilesKeepsStructure(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"app/db.yaml":          "# primary database\nuser: admin\nport: 5432\nreplica:\n    host: db-2\ntags:\n    - a\n    - b\nremoved: ~\n_options:\n    max_versions: 5\n",
+		"app/api.yaml.d/token": "abc123\n",
+		"notes.txt":            "leave me alone\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatalf("failed to create fixture dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("failed to write fixture: %v", err)
+		}
+	}
+
+	redacted, err := RedactSecretFiles(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(redacted) != 2 {
+		t.Fatalf("expected 2 redacted files, got %v", redacted)
+	}
+
+	want := map[string]string{
+		"app/db.yaml":          "# primary database\nuser: '***'\nport: '***'\nreplica:\n    host: '***'\ntags:\n    - '***'\n    - '***'\nremoved: ~\n_options:\n    max_versions: 5\n",
+		"app/api.yaml.d/token": "'***'\n",
+		"notes.txt":            "leave me alone\n",
+	}
+	for name, content := range want {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", name, err)
+		}
+		if string(got) != content {
+			t.Fatalf("%s = %q, want %q", name, got, content)
+		}
+	}
+
+	// Redacting again changes nothing.
+	if redacted, err := RedactSecretFiles(dir); err != nil || len(redacted) != 0 {
+		t.Fatalf("expected a second redaction to be a no-op, got %v, %v", redacted, err)
+	}
+}
+
+func TestRedactSecretFilesReportsInvalidYAML(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "broken.yaml"), []byte("key: [unterminated\n"), 0600); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	if _, err := RedactSecretFiles(dir); err == nil {
+		t.Fatal("expected an error for invalid YAML")
+	}
+}
+
+func TestRedactSecretFilesKeepsEveryDocument(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "multi.yaml")
+	if err := os.WriteFile(path, []byte("user: admin\n---\ntoken: abc123\n"), 0600); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	if _, err := RedactSecretFiles(dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read redacted file: %v", err)
+	}
+	if want := "user: '***'\n---\ntoken: '***'\n"; string(got) != want {
+		t.Fatalf("redacted file = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,43 @@
+package vaultsync
+
+// SecretResult describes what a pull or push did with one secret, for
+// summaries such as the CLI's table output (see VaultClient.OnResult).
+type SecretResult struct {
+	// Path is the secret's Vault path, "engine/sub/path". It is empty for
+	// files a mirroring pull deletes, which belong to no secret.
+	Path string
+
+	// Action is what happened to the secret. Pull reports "written",
+	// "unchanged", or "skipped", and "deleted" or "would delete" for files
+	// removed by mirroring; push reports "pushed", "patched", "metadata", or
+	// "skipped", and a dry run "create", "update", or "unchanged".
+	Action string
+
+	// Version is the KV version the secret has after a push, or will have
+	// after a dry-run push. Zero when unknown, e.g. on pull, patch, or KV v1.
+	Version int
+
+	// Size is the size in bytes of the file written on pull, or of the JSON
+	// payload on push. Zero for exploded secrets and skipped secrets.
+	Size int
+
+	// File is the local file or exploded directory a pull wrote to.
+	File string
+}
+
+// report passes result to OnResult, if set.
+func (v *VaultClient) report(result SecretResult) {
+	if v.OnResult != nil {
+		v.OnResult(result)
+	}
+}
+
+// displayPath turns an internal "engine/metadata/sub/path" into the
+// "engine/sub/path" form used in SecretResult.
+func displayPath(metadataPath string) string {
+	ref := secretRefFromMetadataPath(metadataPath)
+	if ref.Path == "" {
+		return ref.Engine
+	}
+	return ref.Engine + "/" + ref.Path
+}
@@ -0,0 +1,313 @@
+// Package s3store implements vaultsync.ObjectStore for S3 buckets and
+// S3-compatible services such as MinIO, so pulls can be written to and pushes
+// read from a bucket without staging the secrets on local disk.
+package s3store
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kriipke/vaultsync"
+)
+
+// requestTimeout bounds each request to the bucket, including reading the
+// response body, so a stalled connection cannot hang a pull or push.
+const requestTimeout = 60 * time.Second
+
+// unsignedPayload is the payload hash of a request whose body is streamed
+// rather than hashed up front; TLS protects it in transit.
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// serverSideEncryption is requested for every uploaded object, so pulled
+// secrets are encrypted at rest even in buckets without default encryption.
+const serverSideEncryption = "AES256"
+
+// Store is a vaultsync.ObjectStore backed by an S3 bucket. Requests are signed
+// with AWS Signature Version 4.
+type Store struct {
+	Bucket          string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Endpoint, when set, is the base URL of an S3-compatible service, which
+	// is addressed path-style (endpoint/bucket/key). Otherwise the bucket's
+	// virtual-hosted AWS endpoint is used.
+	Endpoint string
+
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewFromEnv returns a Store for bucket configured the way the AWS CLI is:
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and the optional AWS_SESSION_TOKEN
+// hold the credentials, AWS_REGION or AWS_DEFAULT_REGION the region (default
+// us-east-1), and AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL an S3-compatible
+// endpoint.
+func NewFromEnv(bucket string) (*Store, error) {
+	store := &Store{
+		Bucket:          bucket,
+		Region:          firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"),
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		Endpoint:        firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"),
+	}
+	if store.Region == "" {
+		store.Region = "us-east-1"
+	}
+	if store.AccessKeyID == "" || store.SecretAccessKey == "" {
+		return nil, errors.New("S3 credentials not set: AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required")
+	}
+	return store, nil
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// Put streams size bytes of content to key, encrypted at rest with SSE-S3.
+func (s *Store) Put(key string, content io.Reader, size int64) error {
+	resp, err := s.do(http.MethodPut, key, nil, content, size)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Get opens the object at key for reading. A missing object is reported with
+// an error wrapping fs.ErrNotExist.
+func (s *Store) Get(key string) (io.ReadCloser, error) {
+	resp, err := s.do(http.MethodGet, key, nil, nil, 0)
+	var httpErr *vaultsync.HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound {
+		return nil, &fs.PathError{Op: "get", Path: key, Err: fs.ErrNotExist}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// List returns the keys of all objects below prefix, following
+// ListObjectsV2 pagination.
+func (s *Store) List(prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		query := map[string]string{"list-type": "2", "prefix": prefix}
+		if token != "" {
+			query["continuation-token"] = token
+		}
+		resp, err := s.do(http.MethodGet, "", query, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		var result struct {
+			Contents []struct {
+				Key string
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse S3 listing: %w", err)
+		}
+		for _, object := range result.Contents {
+			keys = append(keys, object.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// do sends a signed request for key (the bucket itself when empty) and returns
+// the response for the caller to read and close, or a *vaultsync.HTTPError for
+// a non-2xx status. A body, when given, is streamed as is with its size as the
+// Content-Length.
+func (s *Store) do(method, key string, query map[string]string, body io.Reader, size int64) (*http.Response, error) {
+	host, escapedPath, err := s.location(key)
+	if err != nil {
+		return nil, err
+	}
+	scheme := "https"
+	if s.Endpoint != "" {
+		endpoint, _ := url.Parse(s.Endpoint)
+		scheme = endpoint.Scheme
+	}
+	rawQuery := canonicalQuery(query)
+
+	req, err := http.NewRequest(method, scheme+"://"+host+escapedPath, body)
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawQuery = rawQuery
+	payloadHash := sha256Hex(nil)
+	if body != nil {
+		req.ContentLength = size
+		payloadHash = unsignedPayload
+	}
+	if method == http.MethodPut {
+		req.Header.Set("X-Amz-Server-Side-Encryption", serverSideEncryption)
+	}
+	s.sign(req, escapedPath, rawQuery, payloadHash)
+
+	resp, err := s.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, &vaultsync.HTTPError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+	return resp, nil
+}
+
+// defaultClient is used by stores without their own client.
+var defaultClient = &http.Client{Timeout: requestTimeout}
+
+func (s *Store) httpClient() *http.Client {
+	if s.client != nil {
+		return s.client
+	}
+	return defaultClient
+}
+
+// location returns the host and escaped path addressing key.
+func (s *Store) location(key string) (host, escapedPath string, err error) {
+	escapedKey := ""
+	if key != "" {
+		segments := strings.Split(key, "/")
+		for i, segment := range segments {
+			segments[i] = escape(segment)
+		}
+		escapedKey = strings.Join(segments, "/")
+	}
+
+	if s.Endpoint == "" {
+		return s.Bucket + ".s3." + s.Region + ".amazonaws.com", "/" + escapedKey, nil
+	}
+	endpoint, err := url.Parse(s.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return "", "", fmt.Errorf("invalid S3 endpoint %q", s.Endpoint)
+	}
+	escapedPath = strings.TrimSuffix(endpoint.EscapedPath(), "/") + "/" + escape(s.Bucket)
+	if escapedKey != "" {
+		escapedPath += "/" + escapedKey
+	}
+	return endpoint.Host, escapedPath, nil
+}
+
+// sign adds the AWS Signature Version 4 headers to req, whose body hashes to
+// payloadHash. Every x-amz-* header already set on req is signed.
+func (s *Store) sign(req *http.Request, escapedPath, rawQuery, payloadHash string) {
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	t := now().UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		if name := strings.ToLower(name); strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.Join(values, ",")
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method, escapedPath, rawQuery, canonicalHeaders.String(), signedHeaders, payloadHash,
+	}, "\n")
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), date)
+	for _, part := range []string{s.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalQuery encodes query sorted by name, as both the request and its
+// signature need it.
+func canonicalQuery(query map[string]string) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = escape(name) + "=" + escape(query[name])
+	}
+	return strings.Join(pairs, "&")
+}
+
+// escape percent-encodes everything but the characters SigV4 leaves
+// unreserved.
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
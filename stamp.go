@@ -0,0 +1,63 @@
+package vaultsync
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// stampMetadata merges the pushed_by, pushed_at, and source fields of
+// PushOptions.StampMetadata into the custom_metadata of the secret at ref.
+func (v *VaultClient) stampMetadata(ref SecretRef) error {
+	if v.pushedBy == "" {
+		info, err := v.LookupSelf()
+		if err != nil {
+			return fmt.Errorf("failed to look up token: %w", err)
+		}
+		v.pushedBy = info.DisplayName
+		if v.pushedBy == "" {
+			v.pushedBy = info.EntityID
+		}
+		if v.pushSource, err = os.Hostname(); err != nil {
+			v.pushSource = "unknown"
+		}
+	}
+
+	payload := map[string]interface{}{
+		"custom_metadata": map[string]string{
+			"pushed_by": v.pushedBy,
+			"pushed_at": time.Now().UTC().Format(time.RFC3339),
+			"source":    v.pushSource,
+		},
+	}
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	req, err := http.NewRequest("PATCH", v.kvURL("metadata", ref), strings.NewReader(string(jsonData)))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("X-Vault-Token", v.token())
+	req.Header.Set("X-Vault-Namespace", v.Namespace)
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+
+	resp, err := v.do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	v.reportWarnings(ref.MetadataPath(), body)
+	return nil
+}
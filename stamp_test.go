@@ -0,0 +1,95 @@
+package vaultsync
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPushStampsMetadata(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	for name, contents := range map[string]string{
+		"db":    "username: alice\n_options:\n  custom_metadata:\n    owner: web\n",
+		"cache": "host: redis\n",
+	} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(contents), 0644); err != nil {
+			t.Fatalf("failed to write fixture secret: %v", err)
+		}
+	}
+
+	var requests []string
+	stamps := make(map[string]map[string]string)
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.PushOptions.StampMetadata = true
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch {
+		case r.URL.Path == "/v1/auth/token/lookup-self":
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"display_name": "approle-ci"}})
+		case r.Method == http.MethodPatch:
+			if ct := r.Header.Get("Content-Type"); ct != "application/merge-patch+json" {
+				t.Errorf("unexpected content type %q", ct)
+			}
+			var payload struct {
+				CustomMetadata map[string]string `json:"custom_metadata"`
+			}
+			body, _ := io.ReadAll(r.Body)
+			if err := json.Unmarshal(body, &payload); err != nil {
+				t.Errorf("invalid stamp payload: %v", err)
+			}
+			stamps[r.URL.Path] = payload.CustomMetadata
+		}
+		return textResponse(http.StatusOK, ""), nil
+	})}
+
+	if err := client.PushSecretsFromFilesDirectAt(inputDir, NewSecretRef("kv", "app"), false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		"POST /v1/kv/data/app/cache",
+		"GET /v1/auth/token/lookup-self",
+		"PATCH /v1/kv/metadata/app/cache",
+		"POST /v1/kv/data/app/db",
+		"POST /v1/kv/metadata/app/db",
+		"PATCH /v1/kv/metadata/app/db",
+	}
+	if !reflect.DeepEqual(requests, want) {
+		t.Fatalf("requests = %v, want %v", requests, want)
+	}
+
+	hostname, _ := os.Hostname()
+	stamp := stamps["/v1/kv/metadata/app/db"]
+	if stamp["pushed_by"] != "approle-ci" || stamp["source"] != hostname || len(stamp) != 3 {
+		t.Fatalf("unexpected stamp: %v", stamp)
+	}
+	if _, err := time.Parse(time.RFC3339, stamp["pushed_at"]); err != nil {
+		t.Fatalf("pushed_at is not RFC 3339: %v", err)
+	}
+}
+
+func TestPushStampMetadataNeedsKVv2(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.KVVersion = 1
+	client.PushOptions.StampMetadata = true
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		return textResponse(http.StatusOK, ""), nil
+	})}
+
+	if err := client.PushSecretsFromFilesDirectAt(t.TempDir(), NewSecretRef("secret", "app"), false); !errors.Is(err, ErrKVv1Unsupported) {
+		t.Fatalf("expected ErrKVv1Unsupported, got %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package vaultsync
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Storage is where a pull writes its secret files and a push reads them. File
+// names are built below the output or input directory the same way for every
+// Storage. Features that depend on the local file system, such as exploded
+// secrets, mirroring, or file modification times, need the local Storage.
+type Storage interface {
+	// WriteFile replaces the file name with content, creating any missing
+	// parent directories.
+	WriteFile(name string, content []byte) error
+	// Open opens the file name for reading. A missing file is reported with
+	// an error wrapping fs.ErrNotExist.
+	Open(name string) (io.ReadCloser, error)
+	// Files returns the names of all files below dir, sorted.
+	Files(dir string) ([]string, error)
+}
+
+// localStorage is the Storage of the local file system, used unless
+// VaultClient.Storage is set.
+type localStorage struct{}
+
+func (localStorage) WriteFile(name string, content []byte) error {
+	// 0700: secret directories must not be world/group-accessible
+	dir := filepath.Dir(name)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+	// 0600: secret material must not be world/group-readable
+	if err := os.WriteFile(name, content, 0600); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", name, err)
+	}
+	return nil
+}
+
+func (localStorage) Open(name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
+
+func (localStorage) Files(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			files = append(files, filePath)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// storage returns the Storage pulls write to and pushes read from.
+func (v *VaultClient) storage() Storage {
+	if v.Storage != nil {
+		return v.Storage
+	}
+	return localStorage{}
+}
+
+// usesLocalStorage reports whether pulls and pushes use the local file system.
+func (v *VaultClient) usesLocalStorage() bool {
+	_, local := v.storage().(localStorage)
+	return local
+}
+
+// readStoredFile returns the content of the file name in storage.
+func readStoredFile(storage Storage, name string) ([]byte, error) {
+	file, err := storage.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// writeSecretFile writes content to filePath in storage. With onlyChanged, an
+// existing file that already holds exactly content is left untouched and
+// written is false.
+func writeSecretFile(storage Storage, filePath string, content []byte, onlyChanged bool) (written bool, err error) {
+	if onlyChanged {
+		if existing, err := readStoredFile(storage, filePath); err == nil && bytes.Equal(existing, content) {
+			return false, nil
+		}
+	}
+
+	if err := storage.WriteFile(filePath, content); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,64 @@
+package vaultsync
+
+import (
+	"path"
+	"strings"
+	"sync/atomic"
+)
+
+// TreeSummary counts what a pull of a path would fetch.
+type TreeSummary struct {
+	// Secrets and Folders count the secrets and folders below the path,
+	// not counting the path itself.
+	Secrets int
+	Folders int
+
+	// Size is the total size in bytes of the secrets' data as JSON, which
+	// is close to what Vault stores. It is only set when the summary was
+	// asked to read every secret.
+	Size int64
+	// Unreadable counts the secrets whose size could not be read.
+	Unreadable int
+}
+
+// SummarizeTreeAt counts the secrets and folders below ref, as a pull would
+// walk them (PullOptions.NoRecurse is honored), without writing anything.
+// Only LIST requests are made unless withSizes is set, in which case every
+// secret is also read, up to ListConcurrency at a time, to total its size.
+// Secrets that cannot be read are counted in Unreadable and reported together
+// in the returned *MultiError alongside the summary.
+func (v *VaultClient) SummarizeTreeAt(ref SecretRef, withSizes bool) (TreeSummary, error) {
+	basePath := ref.MetadataPath()
+	secrets, err := v.listSecretTree(basePath)
+	if err != nil {
+		return TreeSummary{}, err
+	}
+
+	// Folders only exist in KV for as long as they hold a secret, so every
+	// folder is the parent of some listed secret.
+	folders := make(map[string]bool)
+	for _, secretPath := range secrets {
+		for dir := path.Dir(secretPath); dir != basePath && strings.HasPrefix(dir, basePath+"/") && !folders[dir]; dir = path.Dir(dir) {
+			folders[dir] = true
+		}
+	}
+	summary := TreeSummary{Secrets: len(secrets), Folders: len(folders)}
+	if !withSizes {
+		return summary, nil
+	}
+
+	errs := &MultiError{}
+	var size int64
+	forEachConcurrently(v.ListConcurrency, len(secrets), func(i int) {
+		secretData, err := v.GetSecretAt(secretRefFromMetadataPath(secrets[i]))
+		if err != nil {
+			v.addFailure(errs, secrets[i], err)
+			return
+		}
+		atomic.AddInt64(&size, int64(payloadSize(secretData)))
+	})
+
+	summary.Size = size
+	summary.Unreadable = errs.Len()
+	return summary, errs.ErrorOrNil()
+}
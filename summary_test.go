@@ -0,0 +1,57 @@
+package vaultsync
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestSummarizeTreeAtCountsWithoutReading(t *testing.T) {
+	t.Parallel()
+
+	vault := newFakeVault(t, moveTestSecrets)
+	client := vault.client()
+	// Any read would fail, so a count-only summary must not make one.
+	vault.fail["/v1/kv/data/old/db"] = http.StatusForbidden
+
+	summary, err := client.SummarizeTreeAt(NewSecretRef("kv", "old"), false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := (TreeSummary{Secrets: 2, Folders: 1}); summary != want {
+		t.Fatalf("summary = %+v, want %+v", summary, want)
+	}
+}
+
+func TestSummarizeTreeAtWithSizes(t *testing.T) {
+	t.Parallel()
+
+	client := newFakeVault(t, moveTestSecrets).client()
+	client.ListConcurrency = 4
+
+	summary, err := client.SummarizeTreeAt(NewSecretRef("kv", "old"), true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// {"name":"db"} and {"name":"api"}
+	if want := (TreeSummary{Secrets: 2, Folders: 1, Size: 13 + 14}); summary != want {
+		t.Fatalf("summary = %+v, want %+v", summary, want)
+	}
+}
+
+func TestSummarizeTreeAtReportsUnreadableSecrets(t *testing.T) {
+	t.Parallel()
+
+	vault := newFakeVault(t, moveTestSecrets)
+	client := vault.client()
+	vault.fail["/v1/kv/data/old/db"] = http.StatusForbidden
+
+	summary, err := client.SummarizeTreeAt(NewSecretRef("kv", "old"), true)
+	var multi *MultiError
+	if !errors.As(err, &multi) || multi.Len() != 1 {
+		t.Fatalf("error = %v, want one unreadable secret", err)
+	}
+	if want := (TreeSummary{Secrets: 2, Folders: 1, Size: 14, Unreadable: 1}); summary != want {
+		t.Fatalf("summary = %+v, want %+v", summary, want)
+	}
+}
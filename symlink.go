@@ -0,0 +1,140 @@
+package vaultsync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// inputTreeWalker walks a directory of secret files like filepath.Walk, in
+// lexical order, but resolves symbolic links itself so the set of files read
+// stays confined to the tree. By default a symlink to a directory is skipped
+// rather than followed, and a symlink to a file is read only when its target
+// lies inside the root. With follow, symlinked directories are walked as if
+// they were part of the tree (paths are reported below the link) and file
+// symlinks may point anywhere; a link back into a directory being walked is
+// still skipped so cycles terminate.
+type inputTreeWalker struct {
+	// root is the real path of the walk root, for the containment check.
+	root   string
+	follow bool
+	fn     filepath.WalkFunc
+	// skip is told about every symlink left out of the walk, and why.
+	skip func(path, reason string)
+
+	// active holds the real paths of the directories being walked.
+	active map[string]bool
+}
+
+// walkInputTree calls fn for root and everything below it, with the
+// symlink handling described on inputTreeWalker. Symlinks are passed to fn
+// with the FileInfo of their target. root itself is followed if it is a link.
+func walkInputTree(root string, follow bool, skip func(path, reason string), fn filepath.WalkFunc) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		return fn(root, nil, err)
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return fn(root, nil, err)
+	}
+
+	w := &inputTreeWalker{root: realRoot, follow: follow, fn: fn, skip: skip, active: make(map[string]bool)}
+	err = w.walk(root, info)
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+func (w *inputTreeWalker) walk(path string, info os.FileInfo) error {
+	if !info.IsDir() {
+		return w.fn(path, info, nil)
+	}
+
+	if err := w.fn(path, info, nil); err != nil {
+		if err == filepath.SkipDir {
+			return nil
+		}
+		return err
+	}
+
+	if realPath, err := filepath.EvalSymlinks(path); err == nil {
+		w.active[realPath] = true
+		defer delete(w.active, realPath)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		if err := w.fn(path, info, err); err != nil && err != filepath.SkipDir {
+			return err
+		}
+		return nil
+	}
+
+	for _, entry := range entries {
+		child := filepath.Join(path, entry.Name())
+		childInfo, err := w.resolve(child, entry)
+		if err != nil {
+			if err := w.fn(child, nil, err); err != nil && err != filepath.SkipDir {
+				return err
+			}
+			continue
+		}
+		if childInfo == nil {
+			continue
+		}
+
+		if err := w.walk(child, childInfo); err != nil {
+			if err == filepath.SkipDir && !childInfo.IsDir() {
+				// As with filepath.Walk, SkipDir on a file skips the rest
+				// of its directory.
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve returns the FileInfo to walk entry with: its own for anything but
+// a symlink, its target's for a symlink that may be followed, or nil for one
+// that is skipped.
+func (w *inputTreeWalker) resolve(path string, entry os.DirEntry) (os.FileInfo, error) {
+	info, err := entry.Info()
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		return info, nil
+	}
+
+	target, err := os.Stat(path)
+	if err != nil {
+		w.skip(path, "broken symlink")
+		return nil, nil
+	}
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		w.skip(path, "broken symlink")
+		return nil, nil
+	}
+
+	switch {
+	case target.IsDir() && !w.follow:
+		w.skip(path, "symlink to a directory")
+		return nil, nil
+	case target.IsDir() && w.active[realPath]:
+		w.skip(path, "symlink loop")
+		return nil, nil
+	case !target.IsDir() && !w.follow && !filePathWithin(realPath, w.root):
+		w.skip(path, "symlink to "+realPath+", outside "+w.root)
+		return nil, nil
+	}
+	return target, nil
+}
+
+// filePathWithin is pathWithin for clean filesystem paths.
+func filePathWithin(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
@@ -0,0 +1,102 @@
+package vaultsync
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newSymlinkTree lays out an input directory with a regular secret, a
+// symlinked directory and file outside it, a symlinked file inside it, and a
+// symlink back to the input directory itself.
+func newSymlinkTree(t *testing.T) string {
+	t.Helper()
+
+	outside := t.TempDir()
+	inputDir := t.TempDir()
+	files := map[string]string{
+		filepath.Join(outside, "elsewhere", "stray.yaml"): "stray: true\n",
+		filepath.Join(outside, "outside.yaml"):            "outside: true\n",
+		filepath.Join(inputDir, "app", "db.yaml"):         "username: alice\n",
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write fixture: %v", err)
+		}
+	}
+
+	links := map[string]string{
+		filepath.Join(inputDir, "linked"):               filepath.Join(outside, "elsewhere"),
+		filepath.Join(inputDir, "outside.yaml"):         filepath.Join(outside, "outside.yaml"),
+		filepath.Join(inputDir, "alias.yaml"):           filepath.Join(inputDir, "app", "db.yaml"),
+		filepath.Join(inputDir, "app", "loop"):          inputDir,
+		filepath.Join(inputDir, "app", "dangling.yaml"): filepath.Join(outside, "missing.yaml"),
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+	return inputDir
+}
+
+func pushedPaths(t *testing.T, inputDir string, follow bool) ([]string, string) {
+	t.Helper()
+
+	var out strings.Builder
+	var pushed []string
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = &out
+	client.ErrOutput = nil
+	client.PushOptions.FollowSymlinks = follow
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		pushed = append(pushed, strings.TrimPrefix(r.URL.Path, "/v1/kv/data/"))
+		return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"version": 1}})
+	})}
+
+	if err := client.PushSecretsFromFilesAt(inputDir, NewSecretRef("kv", ""), false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return pushed, out.String()
+}
+
+func TestPushSkipsSymlinksLeavingTheInputDirectory(t *testing.T) {
+	t.Parallel()
+
+	inputDir := newSymlinkTree(t)
+	pushed, out := pushedPaths(t, inputDir, false)
+
+	if want := []string{"alias", "app/db"}; !slices.Equal(pushed, want) {
+		t.Fatalf("expected pushes %v, got %v", want, pushed)
+	}
+	for _, want := range []string{
+		"Skipping: " + filepath.Join(inputDir, "linked") + " (symlink to a directory)",
+		"Skipping: " + filepath.Join(inputDir, "outside.yaml") + " (symlink to ",
+		"Skipping: " + filepath.Join(inputDir, "app", "loop") + " (symlink to a directory)",
+		"Skipping: " + filepath.Join(inputDir, "app", "dangling.yaml") + " (broken symlink)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestPushFollowSymlinksWalksLinkedDirectoriesWithoutLooping(t *testing.T) {
+	t.Parallel()
+
+	inputDir := newSymlinkTree(t)
+	pushed, out := pushedPaths(t, inputDir, true)
+
+	if want := []string{"alias", "app/db", "linked/stray", "outside"}; !slices.Equal(pushed, want) {
+		t.Fatalf("expected pushes %v, got %v", want, pushed)
+	}
+	if !strings.Contains(out, "Skipping: "+filepath.Join(inputDir, "app", "loop")+" (symlink loop)") {
+		t.Fatalf("expected the loop to be skipped, got:\n%s", out)
+	}
+}
@@ -3,6 +3,10 @@ package vaultsync
 import (
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 )
 
 // DefaultKVEngine is the KV v2 secrets engine used for config-driven syncs when
@@ -11,7 +15,9 @@ const DefaultKVEngine = "kv"
 
 // ClientFactory constructs a VaultClient scoped to the given namespace. The
 // default factory, NewVaultClientFromEnv, reads VAULT_ADDR/VAULT_TOKEN from the
-// environment; tests inject their own to drive a mock transport.
+// environment; tests inject their own to drive a mock transport. When
+// ParallelNamespaces is above one the factory is called concurrently and must
+// be safe for that.
 type ClientFactory func(namespace string) (*VaultClient, error)
 
 // RunPullAll pulls every sync target defined in cfg into its configured
@@ -48,19 +54,100 @@ func runSyncs(cfg *VaultSyncConfig, kvEngine string, newClient ClientFactory, ac
 		newClient = NewVaultClientFromEnv
 	}
 
-	var resultErr error
+	parallel := cfg.ParallelNamespaces
+	if parallel < 1 {
+		parallel = 1
+	}
+	if parallel > 1 {
+		if err := validateDisjointLocalPaths(cfg.Syncs); err != nil {
+			return err
+		}
+	}
+
+	// Each target gets its own client and local_path, so targets can run
+	// concurrently without sharing state. Errors are kept per target and
+	// reported in config order so the report does not depend on scheduling.
+	errs := make([]error, len(cfg.Syncs))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+
 	for i, target := range cfg.Syncs {
-		client, err := newClient(target.Namespace)
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, target SyncTarget) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			namespace := JoinNamespace(cfg.BaseNamespace, target.Namespace)
+			client, err := newClient(namespace)
+			if err != nil {
+				errs[i] = fmt.Errorf("sync entry %d (namespace %q): %w", i+1, namespace, err)
+				return
+			}
+
+			ref := NewSecretRef(kvEngine, target.VaultPath)
+			if version := cfg.KVVersionFor(ref.Engine + "/" + ref.Path); version != 0 {
+				client.KVVersion = version
+			}
+
+			if err := action(client, ref, target); err != nil {
+				errs[i] = fmt.Errorf("sync entry %d (%s -> %s): %w", i+1, target.VaultPath, target.LocalPath, err)
+			}
+		}(i, target)
+	}
+	wg.Wait()
+
+	report := &MultiError{}
+	for _, err := range errs {
+		report.Add("", err)
+	}
+	return report.ErrorOrNil()
+}
+
+// RunPushNamespaceDirs pushes a tree whose top-level directories name Vault
+// namespaces: the files under <inputDir>/<namespace>/ are pushed to ref in
+// that namespace, laid out as for PushSecretsFromFilesAt, so one push fans out
+// across namespaces. A client is created per namespace with newClient, which
+// is where push options should be set. Hidden directories and files directly
+// in inputDir are ignored. Failures are aggregated per namespace, except that
+// the first one stops the push when the client has FailFast set.
+func RunPushNamespaceDirs(inputDir string, ref SecretRef, dryRun bool, newClient ClientFactory) error {
+	if newClient == nil {
+		newClient = NewVaultClientFromEnv
+	}
+
+	entries, err := os.ReadDir(inputDir)
+	if err != nil {
+		return fmt.Errorf("failed to read input directory %s: %w", inputDir, err)
+	}
+
+	errs := &MultiError{}
+	pushed := 0
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		namespace := entry.Name()
+		pushed++
+
+		client, err := newClient(namespace)
 		if err != nil {
-			resultErr = errors.Join(resultErr, fmt.Errorf("sync entry %d (namespace %q): %w", i+1, target.Namespace, err))
+			errs.Add("namespace "+namespace, err)
 			continue
 		}
 
-		ref := NewSecretRef(kvEngine, target.VaultPath)
-		if err := action(client, ref, target); err != nil {
-			resultErr = errors.Join(resultErr, fmt.Errorf("sync entry %d (%s -> %s): %w", i+1, target.VaultPath, target.LocalPath, err))
+		dir := filepath.Join(inputDir, namespace)
+		client.printf("Namespace %s (from %s):\n", namespace, dir)
+		if err := client.PushSecretsFromFilesAt(dir, ref, dryRun); err != nil {
+			errs.Add("namespace "+namespace, err)
+			if client.FailFast {
+				break
+			}
 		}
 	}
 
-	return resultErr
+	if pushed == 0 {
+		return fmt.Errorf("no namespace directories found in %s", inputDir)
+	}
+	return errs.ErrorOrNil()
 }
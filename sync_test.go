@@ -7,7 +7,9 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 // newMockClient returns a VaultClient wired to a mock transport that serves a
@@ -215,3 +217,209 @@ func TestRunPullAllDefaultsKVEngine(t *testing.T) {
 		t.Fatalf("expected default 'kv' engine in listed path, got %v", listedPaths)
 	}
 }
+
+func TestRunPullAllParallelNamespacesBoundsConcurrency(t *testing.T) {
+	root := t.TempDir()
+
+	cfg := &VaultSyncConfig{ParallelNamespaces: 2}
+	for _, ns := range []string{"a", "b", "c", "d"} {
+		cfg.Syncs = append(cfg.Syncs, SyncTarget{Namespace: ns, VaultPath: "app", LocalPath: filepath.Join(root, ns)})
+	}
+
+	var mu sync.Mutex
+	active, maxActive := 0, 0
+
+	factory := func(namespace string) (*VaultClient, error) {
+		mu.Lock()
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		mu.Unlock()
+
+		// Hold the slot briefly so overlapping targets are observable.
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		active--
+		mu.Unlock()
+
+		return newMockClient(t, namespace, nil), nil
+	}
+
+	if err := RunPullAll(cfg, "kv", factory); err != nil {
+		t.Fatalf("RunPullAll returned error: %v", err)
+	}
+
+	if maxActive > 2 {
+		t.Fatalf("expected at most 2 concurrent namespaces, saw %d", maxActive)
+	}
+	if maxActive < 2 {
+		t.Fatalf("expected namespaces to run concurrently, saw %d at once", maxActive)
+	}
+
+	// Every namespace lands in its own directory with its own data.
+	for _, ns := range []string{"a", "b", "c", "d"} {
+		contents, err := os.ReadFile(filepath.Join(root, ns, "db"))
+		if err != nil {
+			t.Fatalf("expected secret for namespace %s, got %v", ns, err)
+		}
+		if !strings.Contains(string(contents), ns+"-user") {
+			t.Fatalf("expected namespace %s data, got %q", ns, contents)
+		}
+	}
+}
+
+func TestRunPullAllParallelNamespacesRejectsSharedLocalPath(t *testing.T) {
+	root := t.TempDir()
+
+	cfg := &VaultSyncConfig{
+		ParallelNamespaces: 2,
+		Syncs: []SyncTarget{
+			{Namespace: "a", VaultPath: "app", LocalPath: root},
+			{Namespace: "b", VaultPath: "app", LocalPath: filepath.Join(root, "b")},
+		},
+	}
+
+	factory := func(namespace string) (*VaultClient, error) {
+		t.Errorf("no target should run, got a client for %s", namespace)
+		return newMockClient(t, namespace, nil), nil
+	}
+
+	if err := RunPullAll(cfg, "kv", factory); err == nil || !strings.Contains(err.Error(), "overlapping local paths") {
+		t.Fatalf("expected an overlapping local paths error, got %v", err)
+	}
+}
+
+func TestRunPullAllUsesPerPrefixKVVersion(t *testing.T) {
+	dirV1 := t.TempDir()
+	dirV2 := t.TempDir()
+
+	cfg := &VaultSyncConfig{
+		KVVersions: map[string]int{"kv/legacy": 1},
+		Syncs: []SyncTarget{
+			{Namespace: "team-a", VaultPath: "legacy/app", LocalPath: dirV1},
+			{Namespace: "team-a", VaultPath: "modern/app", LocalPath: dirV2},
+		},
+	}
+
+	var mu sync.Mutex
+	var requested []string
+
+	factory := func(namespace string) (*VaultClient, error) {
+		client := NewVaultClient("https://vault.example", "token", namespace)
+		client.Output = nil
+		client.ErrOutput = nil
+		client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			mu.Lock()
+			requested = append(requested, r.URL.Path)
+			mu.Unlock()
+
+			switch r.URL.Path {
+			case "/v1/kv/legacy/app", "/v1/kv/metadata/modern/app":
+				return jsonResponse(t, http.StatusOK, map[string]any{
+					"data": map[string]any{"keys": []string{"db"}},
+				})
+			case "/v1/kv/legacy/app/db":
+				// KV v1 returns the secret itself as data.
+				return jsonResponse(t, http.StatusOK, map[string]any{
+					"data": map[string]any{"username": "v1-user"},
+				})
+			case "/v1/kv/data/modern/app/db":
+				return jsonResponse(t, http.StatusOK, map[string]any{
+					"data": map[string]any{"data": map[string]any{"username": "v2-user"}},
+				})
+			default:
+				return textResponse(http.StatusNotFound, "not found"), nil
+			}
+		})}
+		return client, nil
+	}
+
+	if err := RunPullAll(cfg, "kv", factory); err != nil {
+		t.Fatalf("RunPullAll returned error: %v (requests %v)", err, requested)
+	}
+
+	for dir, want := range map[string]string{dirV1: "v1-user", dirV2: "v2-user"} {
+		contents, err := os.ReadFile(filepath.Join(dir, "db"))
+		if err != nil {
+			t.Fatalf("expected secret file in %s, got %v", dir, err)
+		}
+		if !strings.Contains(string(contents), want) {
+			t.Fatalf("expected %s in %s, got %q", want, dir, contents)
+		}
+	}
+}
+
+func TestRunPushNamespaceDirsPushesEachDirectoryToItsNamespace(t *testing.T) {
+	inputDir := t.TempDir()
+	for _, file := range []string{"team-a/app/db.yaml", "team-b/db.yaml", ".git/config.yaml", "README.yaml"} {
+		path := filepath.Join(inputDir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatalf("failed to create fixture dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("username: u\n"), 0600); err != nil {
+			t.Fatalf("failed to write fixture: %v", err)
+		}
+	}
+
+	var mu sync.Mutex
+	writesByNamespace := make(map[string][]string)
+	factory := func(namespace string) (*VaultClient, error) {
+		var writes []*http.Request
+		client := newMockClient(t, namespace, &writes)
+		transport := client.client.Transport
+		client.client.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			if r.Method == http.MethodPost {
+				mu.Lock()
+				writesByNamespace[r.Header.Get("X-Vault-Namespace")] = append(writesByNamespace[r.Header.Get("X-Vault-Namespace")], r.URL.Path)
+				mu.Unlock()
+			}
+			return transport.RoundTrip(r)
+		})
+		return client, nil
+	}
+
+	if err := RunPushNamespaceDirs(inputDir, NewSecretRef("kv", ""), false, factory); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(writesByNamespace) != 2 {
+		t.Fatalf("expected writes to two namespaces, got %v", writesByNamespace)
+	}
+	if got := writesByNamespace["team-a"]; len(got) != 1 || got[0] != "/v1/kv/data/app/db" {
+		t.Fatalf("unexpected team-a writes %v", got)
+	}
+	if got := writesByNamespace["team-b"]; len(got) != 1 || got[0] != "/v1/kv/data/db" {
+		t.Fatalf("unexpected team-b writes %v", got)
+	}
+}
+
+func TestRunPushNamespaceDirsRequiresNamespaceDirectories(t *testing.T) {
+	if err := RunPushNamespaceDirs(t.TempDir(), NewSecretRef("kv", ""), false, nil); err == nil {
+		t.Fatal("expected an error for an input directory without namespace directories")
+	}
+}
+
+func TestRunPullAllResolvesNamespacesUnderBaseNamespace(t *testing.T) {
+	cfg := &VaultSyncConfig{
+		BaseNamespace: "org",
+		Syncs: []SyncTarget{
+			{Namespace: "team-a/dev", VaultPath: "kv/app", LocalPath: t.TempDir()},
+		},
+	}
+
+	var namespaces []string
+	var writes []*http.Request
+	factory := func(namespace string) (*VaultClient, error) {
+		namespaces = append(namespaces, namespace)
+		return newMockClient(t, namespace, &writes), nil
+	}
+
+	if err := RunPullAll(cfg, "", factory); err != nil {
+		t.Fatalf("RunPullAll returned error: %v", err)
+	}
+	if len(namespaces) != 1 || namespaces[0] != "org/team-a/dev" {
+		t.Fatalf("expected the child namespace org/team-a/dev, got %v", namespaces)
+	}
+}
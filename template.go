@@ -0,0 +1,44 @@
+package vaultsync
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"text/template"
+)
+
+// secretTemplateData is what PullOptions.Template is executed against for
+// each secret.
+type secretTemplateData struct {
+	// Path is the secret's path below the pulled path, e.g. "team/db".
+	Path string
+	// Name is the last segment of Path, e.g. "db".
+	Name string
+	// Data holds the secret's keys and values.
+	Data map[string]interface{}
+}
+
+// parseSecretTemplate parses a pull template. Referencing a key a secret does
+// not have is an error rather than rendering "<no value>".
+func parseSecretTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("secret").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// renderSecretTemplate executes options.Template for one secret.
+func renderSecretTemplate(relativePath string, secretData map[string]interface{}, options PullOptions) ([]byte, error) {
+	tmpl, err := parseSecretTemplate(options.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	data := secretTemplateData{Path: relativePath, Name: path.Base(relativePath), Data: secretData}
+	if err := tmpl.Execute(&out, data); err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
+	}
+	return out.Bytes(), nil
+}
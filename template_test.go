@@ -0,0 +1,58 @@
+package vaultsync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// templateTestSecrets is kv/app holding one secret in a subfolder.
+var templateTestSecrets = map[string]map[string]any{
+	"kv/app/team/db": {"user": "app", "password": "s3cret"},
+}
+
+func TestPullSecretsToFilesRendersTemplatePerSecret(t *testing.T) {
+	t.Parallel()
+
+	client := newFakeVault(t, templateTestSecrets).client()
+	client.PullOptions.Template = "# {{.Path}} ({{.Name}})\nDB_USER={{.Data.user}}\nDB_PASSWORD={{.Data.password}}\n"
+	client.PullOptions.TemplateExtension = ".env"
+
+	outputDir := t.TempDir()
+	if err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), outputDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "app", "team", "db.env"))
+	if err != nil {
+		t.Fatalf("expected rendered file: %v", err)
+	}
+	want := "# team/db (db)\nDB_USER=app\nDB_PASSWORD=s3cret\n"
+	if string(content) != want {
+		t.Fatalf("expected %q, got %q", want, content)
+	}
+}
+
+func TestPullSecretsToFilesTemplateErrorNamesSecret(t *testing.T) {
+	t.Parallel()
+
+	client := newFakeVault(t, templateTestSecrets).client()
+	client.PullOptions.Template = "{{.Data.missing}}"
+
+	err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "kv/metadata/app/team/db") {
+		t.Fatalf("expected error naming the secret, got %v", err)
+	}
+}
+
+func TestPullOptionsValidateRejectsInvalidTemplate(t *testing.T) {
+	t.Parallel()
+
+	if err := (PullOptions{Template: "{{.Data"}).validate(); err == nil {
+		t.Fatal("expected parse error for an unterminated action")
+	}
+	if err := (PullOptions{Template: "{{.Name}}", Explode: true}).validate(); err == nil {
+		t.Fatal("expected error combining a template with explode")
+	}
+}
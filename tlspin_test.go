@@ -0,0 +1,70 @@
+package vaultsync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseTLSPin(t *testing.T) {
+	t.Parallel()
+
+	want := strings.Repeat("ab", 32)
+	for _, pin := range []string{
+		"sha256:" + want,
+		"SHA256:" + strings.ToUpper(want),
+		"sha256:" + strings.TrimSuffix(strings.Repeat("AB:", 32), ":"),
+	} {
+		got, err := ParseTLSPin(pin)
+		if err != nil {
+			t.Fatalf("ParseTLSPin(%q) error = %v", pin, err)
+		}
+		if got != want {
+			t.Fatalf("ParseTLSPin(%q) = %q, want %q", pin, got, want)
+		}
+	}
+
+	for _, pin := range []string{"", want, "sha1:" + want, "sha256:abcd", "sha256:" + strings.Repeat("zz", 32)} {
+		if _, err := ParseTLSPin(pin); err == nil {
+			t.Fatalf("ParseTLSPin(%q) succeeded, want error", pin)
+		}
+	}
+}
+
+func TestPinCertificatesAcceptsOnlyPinnedServer(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"data":{"password":"secret"}}}`))
+	}))
+	defer server.Close()
+
+	sum := sha256.Sum256(server.Certificate().Raw)
+	pinned := "sha256:" + hex.EncodeToString(sum[:])
+
+	// The test server's certificate is self-signed; the pin alone admits it.
+	client := NewVaultClient(server.URL, "token", "")
+	if err := client.PinCertificates([]string{"sha256:" + strings.Repeat("00", 32), pinned}); err != nil {
+		t.Fatalf("PinCertificates() error = %v", err)
+	}
+	if _, err := client.GetSecretAt(SecretRef{Engine: "kv", Path: "app"}); err != nil {
+		t.Fatalf("GetSecretAt() with matching pin error = %v", err)
+	}
+
+	client = NewVaultClient(server.URL, "token", "")
+	if err := client.PinCertificates([]string{"sha256:" + strings.Repeat("00", 32)}); err != nil {
+		t.Fatalf("PinCertificates() error = %v", err)
+	}
+	_, err := client.GetSecretAt(SecretRef{Engine: "kv", Path: "app"})
+	if !errors.Is(err, ErrTLSPinMismatch) {
+		t.Fatalf("GetSecretAt() with wrong pin error = %v, want ErrTLSPinMismatch", err)
+	}
+	if !strings.Contains(err.Error(), pinned) {
+		t.Fatalf("error %q does not name the presented fingerprint %s", err, pinned)
+	}
+}
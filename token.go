@@ -0,0 +1,81 @@
+package vaultsync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// TokenInfo is the subset of auth/token/lookup-self describing who the client
+// token belongs to.
+type TokenInfo struct {
+	Accessor    string   `json:"accessor"`
+	DisplayName string   `json:"display_name"`
+	EntityID    string   `json:"entity_id"`
+	Policies    []string `json:"policies"`
+	// NamespacePath is the namespace the token was created in, with a
+	// trailing slash ("team-a/"); empty for the root namespace.
+	NamespacePath string `json:"namespace_path"`
+	TTL           int    `json:"ttl"`
+	Renewable     bool   `json:"renewable"`
+}
+
+type vaultLookupSelfResponse struct {
+	Data TokenInfo `json:"data"`
+}
+
+// LookupSelf returns information about the client's own token, including the
+// identity entity it is bound to.
+func (v *VaultClient) LookupSelf() (*TokenInfo, error) {
+	url := fmt.Sprintf("%s/v1/auth/token/lookup-self", v.Address)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("X-Vault-Token", v.token())
+	req.Header.Set("X-Vault-Namespace", v.Namespace)
+
+	resp, err := v.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var vaultResp vaultLookupSelfResponse
+	if err := json.Unmarshal(body, &vaultResp); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	return &vaultResp.Data, nil
+}
+
+// ErrNamespaceUnknown is returned by TokenNamespace when the token's
+// namespace cannot be looked up.
+var ErrNamespaceUnknown = errors.New("cannot determine the namespace of the token")
+
+// TokenNamespace returns the namespace the client's token was created in, as
+// reported by lookup-self, without the trailing slash; "" is the root
+// namespace. The lookup is made in the client's Namespace, which must be the
+// token's namespace or one of its parents.
+func (v *VaultClient) TokenNamespace() (string, error) {
+	info, err := v.LookupSelf()
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrNamespaceUnknown, err)
+	}
+	return strings.TrimSuffix(info.NamespacePath, "/"), nil
+}
@@ -0,0 +1,101 @@
+package vaultsync
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestLookupSelfReturnsTokenIdentity(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Method == http.MethodGet && r.URL.Path == "/v1/auth/token/lookup-self" {
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{
+					"display_name":   "approle-ci",
+					"entity_id":      "7d2e3179-f69b-450c-7179-ac8ee8bd8ca9",
+					"policies":       []string{"default", "ci-read"},
+					"namespace_path": "team-a/",
+				},
+			})
+		}
+		return textResponse(http.StatusNotFound, "not found"), nil
+	})}
+
+	info, err := client.LookupSelf()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if info.DisplayName != "approle-ci" || info.EntityID != "7d2e3179-f69b-450c-7179-ac8ee8bd8ca9" {
+		t.Fatalf("unexpected identity: %+v", info)
+	}
+	if len(info.Policies) != 2 || info.NamespacePath != "team-a/" {
+		t.Fatalf("unexpected token details: %+v", info)
+	}
+}
+
+func TestLookupSelfReturnsHTTPErrorForBadToken(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return textResponse(http.StatusForbidden, "permission denied"), nil
+	})}
+
+	_, err := client.LookupSelf()
+	httpErr, ok := err.(*HTTPError)
+	if !ok || httpErr.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 HTTPError, got %v", err)
+	}
+}
+
+func TestTokenNamespace(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		status  int
+		data    map[string]any
+		want    string
+		wantErr bool
+	}{
+		{name: "namespaced token", status: http.StatusOK, data: map[string]any{"namespace_path": "parent/team-a/"}, want: "parent/team-a"},
+		{name: "root token", status: http.StatusOK, data: map[string]any{"namespace_path": ""}, want: ""},
+		{name: "failed lookup", status: http.StatusForbidden, wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := NewVaultClient("https://vault.example", "token", "")
+			client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				if tt.status != http.StatusOK {
+					return textResponse(tt.status, "permission denied"), nil
+				}
+				return jsonResponse(t, tt.status, map[string]any{"data": tt.data})
+			})}
+
+			namespace, err := client.TokenNamespace()
+			if tt.wantErr {
+				if !errors.Is(err, ErrNamespaceUnknown) {
+					t.Fatalf("expected ErrNamespaceUnknown, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if namespace != tt.want {
+				t.Fatalf("namespace = %q, want %q", namespace, tt.want)
+			}
+		})
+	}
+}
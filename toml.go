@@ -0,0 +1,89 @@
+package vaultsync
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+
+	"github.com/BurntSushi/toml"
+)
+
+// PullFormatTOML renders each pulled secret as a TOML document.
+const PullFormatTOML = "toml"
+
+// tomlExtension is the file extension of PullFormatTOML files, and the one
+// that makes a pushed file parse as TOML.
+const tomlExtension = ".toml"
+
+// emptyTOMLSecretFile is emptySecretFile for PullFormatTOML, where an empty
+// document is already an empty table.
+const emptyTOMLSecretFile = "# " + directivePrefix + " empty\n"
+
+// renderTOML renders secretData as a TOML document. Vault returns every
+// number as a float; whole numbers are written as TOML integers so they
+// round-trip as integers, as they do through YAML. TOML has no null, so a
+// null value is an error rather than being silently dropped.
+func renderTOML(secretData map[string]interface{}) ([]byte, error) {
+	normalized, err := tomlValue(secretData, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	encoder := toml.NewEncoder(&buf)
+	encoder.Indent = ""
+	if err := encoder.Encode(normalized); err != nil {
+		return nil, fmt.Errorf("failed to encode secret as TOML: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// tomlValue returns a copy of value ready for the TOML encoder; key is its
+// dotted path, for errors.
+func tomlValue(value interface{}, key string) (interface{}, error) {
+	switch value := value.(type) {
+	case nil:
+		return nil, fmt.Errorf("key %q is null, which TOML cannot represent", key)
+	case float64:
+		if value == math.Trunc(value) && math.Abs(value) < 1<<63 {
+			return int64(value), nil
+		}
+		return value, nil
+	case map[string]interface{}:
+		table := make(map[string]interface{}, len(value))
+		for name, item := range value {
+			itemKey := name
+			if key != "" {
+				itemKey = key + "." + name
+			}
+			converted, err := tomlValue(item, itemKey)
+			if err != nil {
+				return nil, err
+			}
+			table[name] = converted
+		}
+		return table, nil
+	case []interface{}:
+		array := make([]interface{}, len(value))
+		for i, item := range value {
+			converted, err := tomlValue(item, fmt.Sprintf("%s[%d]", key, i))
+			if err != nil {
+				return nil, err
+			}
+			array[i] = converted
+		}
+		return array, nil
+	default:
+		return value, nil
+	}
+}
+
+// parseTOMLSecret decodes a TOML secret file. Integers decode as int64 and
+// datetimes as time.Time, which Vault stores as RFC 3339 strings.
+func parseTOMLSecret(content []byte) (map[string]interface{}, error) {
+	secretData := map[string]interface{}{}
+	if _, err := toml.Decode(string(content), &secretData); err != nil {
+		return nil, fmt.Errorf("invalid TOML: %w", err)
+	}
+	return secretData, nil
+}
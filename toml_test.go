@@ -0,0 +1,129 @@
+package vaultsync
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderTOML(t *testing.T) {
+	t.Parallel()
+
+	// Vault hands every number to us as a float64.
+	data := map[string]interface{}{
+		"password": `p"w`,
+		"port":     float64(5432),
+		"ratio":    0.5,
+		"enabled":  true,
+		"tags":     []interface{}{"a", "b"},
+		"db":       map[string]interface{}{"host": "x", "opts": map[string]interface{}{"ssl": true}},
+	}
+
+	got, err := renderTOML(data)
+	if err != nil {
+		t.Fatalf("renderTOML: %v", err)
+	}
+	want := "enabled = true\npassword = \"p\\\"w\"\nport = 5432\nratio = 0.5\ntags = [\"a\", \"b\"]\n\n[db]\nhost = \"x\"\n[db.opts]\nssl = true\n"
+	if string(got) != want {
+		t.Fatalf("renderTOML =\n%s\nwant:\n%s", got, want)
+	}
+
+	// Parsed back, the secret is what Vault held.
+	parsed, err := parseSecretFile("app/db.toml", got)
+	if err != nil {
+		t.Fatalf("parseSecretFile: %v", err)
+	}
+	original, _ := json.Marshal(data)
+	roundTripped, _ := json.Marshal(parsed)
+	if string(original) != string(roundTripped) {
+		t.Fatalf("round trip changed the secret:\n%s\nwant:\n%s", roundTripped, original)
+	}
+}
+
+func TestRenderTOMLRejectsNull(t *testing.T) {
+	t.Parallel()
+
+	_, err := renderTOML(map[string]interface{}{"db": map[string]interface{}{"password": nil}})
+	if err == nil || !strings.Contains(err.Error(), `"db.password" is null`) {
+		t.Fatalf("error = %v, want the null key named", err)
+	}
+}
+
+func TestParseSecretFileTOMLByExtension(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("# vaultsync: empty\n")
+	data, err := parseSecretFile("app/empty.toml", content)
+	if err != nil || len(data) != 0 {
+		t.Fatalf("parseSecretFile(.toml) = %v, %v; want an empty secret", data, err)
+	}
+
+	if _, err := parseSecretFile("app/db.toml", []byte("password = ")); err == nil || !strings.Contains(err.Error(), "invalid TOML") {
+		t.Fatalf("error = %v, want invalid TOML", err)
+	}
+}
+
+func TestPullSecretsToFilesTOMLFormat(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.PullOptions.Format = PullFormatTOML
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path == "/v1/kv/metadata/app" {
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"keys": []string{"db"}}})
+		}
+		return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"data": map[string]any{"port": 5432, "user": "app"}}})
+	})}
+
+	outputDir := t.TempDir()
+	if err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), outputDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(outputDir, "app", "db.toml"))
+	if err != nil {
+		t.Fatalf("failed to read pulled file: %v", err)
+	}
+	if want := "port = 5432\nuser = \"app\"\n"; string(content) != want {
+		t.Fatalf("expected %q, got %q", want, content)
+	}
+}
+
+func TestPushSecretsFromFilesParsesTOML(t *testing.T) {
+	t.Parallel()
+
+	inputDir := t.TempDir()
+	appDir := filepath.Join(inputDir, "app")
+	if err := os.MkdirAll(appDir, 0755); err != nil {
+		t.Fatalf("failed to create app dir: %v", err)
+	}
+	content := "port = 5432\nuser = \"app\"\n\n[tls]\nenabled = true\n"
+	if err := os.WriteFile(filepath.Join(appDir, "db.toml"), []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	var body []byte
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.PushOptions.Extensions = []string{".yaml", ".toml"}
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path != "/v1/kv/data/app/db" {
+			t.Fatalf("unexpected request %s %s", r.Method, r.URL)
+		}
+		body, _ = io.ReadAll(r.Body)
+		return textResponse(http.StatusOK, ""), nil
+	})}
+
+	if err := client.PushSecretsFromFilesAt(inputDir, NewSecretRef("kv", "app"), false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := `{"data":{"port":5432,"tls":{"enabled":true},"user":"app"}}`; string(body) != want {
+		t.Fatalf("pushed %s, want %s", body, want)
+	}
+}
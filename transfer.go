@@ -0,0 +1,39 @@
+package vaultsync
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SyncSecretsTo copies every secret below src, read through v, to the same
+// relative path below dstRef in dst, which is usually a client for another
+// Vault cluster. Nothing is staged on disk: each secret is written to dst as a
+// push of the same data would write it, honoring dst's PushOptions. With
+// dryRun, each secret is diffed against what dst currently holds instead, as
+// a dry-run push does, and dst is not modified. Secrets that could not be read
+// from src are reported together at the end; the others are still copied.
+//
+// As with MoveSecretsAt, only the current version of each secret is copied.
+func (v *VaultClient) SyncSecretsTo(dst *VaultClient, src, dstRef SecretRef, dryRun bool) error {
+	synced := 0
+	fetchErr, visitErr := v.walkSecrets(src.MetadataPath(), func(fullPath string, secretData map[string]interface{}) error {
+		relativePath := strings.TrimPrefix(fullPath, src.MetadataPath()+"/")
+		target := NewSecretRef(dstRef.Engine, dstRef.Path+"/"+relativePath)
+
+		if err := dst.pushSecret(pendingPush{vaultPath: target.MetadataPath(), secretData: secretData}, dryRun); err != nil {
+			return fmt.Errorf("failed to sync %s to %s: %w", fullPath, target.MetadataPath(), err)
+		}
+		synced++
+		return nil
+	})
+	if visitErr != nil {
+		return visitErr
+	}
+
+	if dryRun {
+		dst.printf("Would sync %d secrets\n", synced)
+	} else {
+		dst.printf("Synced %d secrets\n", synced)
+	}
+	return fetchErr
+}
@@ -0,0 +1,85 @@
+package vaultsync
+
+import (
+	"bytes"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// syncDestinationSecrets is a second cluster that already holds kv/new/db.
+var syncDestinationSecrets = map[string]map[string]any{
+	"kv/new/db": {"name": "stale"},
+}
+
+func TestSyncSecretsToCopiesBetweenClusters(t *testing.T) {
+	t.Parallel()
+
+	source := newFakeVault(t, moveTestSecrets)
+	src := source.client()
+	destination := newFakeVault(t, syncDestinationSecrets)
+	var out bytes.Buffer
+	dst := destination.client()
+	dst.Output = &out
+
+	if err := src.SyncSecretsTo(dst, NewSecretRef("kv", "old"), NewSecretRef("kv", "new"), false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]map[string]interface{}{
+		"/v1/kv/data/new/db":       {"name": "db"},
+		"/v1/kv/data/new/team/api": {"name": "api"},
+	}
+	if !reflect.DeepEqual(destination.writes, want) {
+		t.Fatalf("writes = %v, want %v", destination.writes, want)
+	}
+	if len(source.writes) != 0 {
+		t.Fatalf("source was written to: %v", source.writes)
+	}
+	if !strings.Contains(out.String(), "Synced 2 secrets\n") {
+		t.Fatalf("missing summary in output:\n%s", out.String())
+	}
+}
+
+func TestSyncSecretsToDryRunDiffsAgainstDestination(t *testing.T) {
+	disableExternalDiffTools(t)
+
+	src := newFakeVault(t, moveTestSecrets).client()
+	destination := newFakeVault(t, syncDestinationSecrets)
+	var out bytes.Buffer
+	dst := destination.client()
+	dst.Output = &out
+
+	if err := src.SyncSecretsTo(dst, NewSecretRef("kv", "old"), NewSecretRef("kv", "new"), true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(destination.writes) != 0 {
+		t.Fatalf("dry run wrote to the destination: %v", destination.writes)
+	}
+	output := out.String()
+	for _, want := range []string{"-name: stale", "+name: db", "new file mode", "+name: api", "Would sync 2 secrets\n"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q:\n%s", want, output)
+		}
+	}
+}
+
+func TestSyncSecretsToReportsUnreadableSecrets(t *testing.T) {
+	t.Parallel()
+
+	source := newFakeVault(t, moveTestSecrets)
+	src := source.client()
+	source.fail["/v1/kv/data/old/db"] = http.StatusForbidden
+	destination := newFakeVault(t, syncDestinationSecrets)
+	dst := destination.client()
+
+	err := src.SyncSecretsTo(dst, NewSecretRef("kv", "old"), NewSecretRef("kv", "new"), false)
+	if err == nil || !strings.Contains(err.Error(), "kv/metadata/old/db") {
+		t.Fatalf("error = %v, want the unreadable secret reported", err)
+	}
+	if _, ok := destination.writes["/v1/kv/data/new/team/api"]; !ok || len(destination.writes) != 1 {
+		t.Fatalf("writes = %v, want only the readable secret copied", destination.writes)
+	}
+}
@@ -0,0 +1,50 @@
+package vaultsync
+
+import (
+	"fmt"
+)
+
+// SecretKeys is one secret of a tree and the names of its keys.
+type SecretKeys struct {
+	// Path is the secret as "engine/path".
+	Path string
+	// Keys are the secret's keys as sorted dotted paths, as FlattenSubkeys
+	// renders them.
+	Keys []string
+	// Unreadable is set when the secret's keys could not be read.
+	Unreadable bool
+}
+
+// SecretTreeAt lists the secrets below ref, as a pull would walk them
+// (PullOptions.NoRecurse is honored), together with their key names. Keys are
+// read from the subkeys endpoint, up to ListConcurrency secrets at a time, so
+// no value is ever fetched and a token that may only list and read subkeys is
+// enough. Secrets come in walk order. Those whose keys cannot be read are
+// marked Unreadable and reported together in the returned *MultiError
+// alongside the tree.
+func (v *VaultClient) SecretTreeAt(ref SecretRef) ([]SecretKeys, error) {
+	if v.isKVv1() {
+		return nil, fmt.Errorf("tree: %w", ErrKVv1Unsupported)
+	}
+
+	secrets, err := v.listSecretTree(ref.MetadataPath())
+	if err != nil {
+		return nil, err
+	}
+
+	tree := make([]SecretKeys, len(secrets))
+	errs := &MultiError{}
+	forEachConcurrently(v.ListConcurrency, len(secrets), func(i int) {
+		secretRef := secretRefFromMetadataPath(secrets[i])
+		tree[i].Path = secretRef.Engine + "/" + secretRef.Path
+		subkeys, err := v.GetSubkeysAt(secretRef)
+		if err != nil {
+			tree[i].Unreadable = true
+			v.addFailure(errs, secrets[i], fmt.Errorf("failed to get subkeys: %w", err))
+			return
+		}
+		tree[i].Keys = FlattenSubkeys(subkeys)
+	})
+
+	return tree, errs.ErrorOrNil()
+}
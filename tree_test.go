@@ -0,0 +1,51 @@
+package vaultsync
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// treeTestSecrets is kv/app, with a secret in a subfolder.
+var treeTestSecrets = map[string]map[string]any{
+	"kv/app/api":        {"token": "t"},
+	"kv/app/db/primary": {"password": "p", "replica": map[string]any{"host": "h"}},
+	"kv/app/locked":     {"key": "v"},
+}
+
+func TestSecretTreeAtListsKeysWithoutValues(t *testing.T) {
+	t.Parallel()
+
+	vault := newFakeVault(t, treeTestSecrets)
+	vault.fail["/v1/kv/subkeys/app/locked"] = http.StatusForbidden
+	vault.noValues = true
+	client := vault.client()
+	client.ListConcurrency = 4
+
+	tree, err := client.SecretTreeAt(NewSecretRef("kv", "app"))
+	var multi *MultiError
+	if !errors.As(err, &multi) || multi.Len() != 1 {
+		t.Fatalf("error = %v, want one unreadable secret", err)
+	}
+
+	want := []SecretKeys{
+		{Path: "kv/app/api", Keys: []string{"token"}},
+		{Path: "kv/app/db/primary", Keys: []string{"password", "replica.host"}},
+		{Path: "kv/app/locked", Unreadable: true},
+	}
+	if !reflect.DeepEqual(tree, want) {
+		t.Fatalf("tree = %+v, want %+v", tree, want)
+	}
+}
+
+func TestSecretTreeAtRejectsKVv1(t *testing.T) {
+	t.Parallel()
+
+	client := newFakeVault(t, treeTestSecrets).client()
+	client.KVVersion = 1
+
+	if _, err := client.SecretTreeAt(NewSecretRef("kv", "app")); !errors.Is(err, ErrKVv1Unsupported) {
+		t.Fatalf("error = %v, want ErrKVv1Unsupported", err)
+	}
+}
@@ -2,16 +2,20 @@ package vaultsync
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -21,15 +25,393 @@ type VaultClient struct {
 	Address   string
 	Token     string
 	Namespace string
+
+	// WriteAddress, when set, is the address mutating requests (POST, PUT,
+	// PATCH, and DELETE) are sent to instead of Address, such as the primary
+	// cluster of a performance-replicated setup, while reads stay on the
+	// local replica at Address.
+	WriteAddress string
+
 	client    *http.Client
 	Output    io.Writer
 	ErrOutput io.Writer
+
+	// Storage, when set, is where pulls write their files and pushes read
+	// them, such as a bucket from NewObjectStorage; the output and input
+	// directories are then paths within it. Nil uses the local file system.
+	Storage Storage
+
+	// DataSegment and MetadataSegment override the "data" and "metadata"
+	// path segments used when building KV v2 API URLs, for gateways that
+	// rewrite them. Empty values use the standard names.
+	DataSegment     string
+	MetadataSegment string
+
+	// KVVersion selects the KV engine version the client talks to: 1 for
+	// KV v1, where secrets live directly under the mount with no
+	// data/metadata segments and no versioning, or 0/2 for KV v2.
+	KVVersion int
+
+	// MaskValues replaces secret values in dry-run and compare diffs with
+	// placeholders, so the diff shows which keys changed without revealing
+	// their contents in logs or scrollback.
+	MaskValues bool
+
+	// RedactPatterns partially masks, in the same diffs, string values
+	// matching any of the expressions (anywhere in the value): only their
+	// first and last four characters are shown, and short values are masked
+	// completely. Other values stay visible. Only displayed output is
+	// affected, never what is written. MaskValues takes precedence.
+	RedactPatterns []*regexp.Regexp
+
+	// IgnoreFields lists top-level secret keys, or path.Match globs such as
+	// "last_*", left out of dry-run and compare diffs, so fields that change
+	// on every rotation do not show up as drift. Pushes still write them.
+	IgnoreFields []string
+
+	// UserAgent is sent as the User-Agent header of every request, so
+	// vaultsync traffic can be recognized in Vault's audit logs and
+	// rate-limit quotas. Empty sends DefaultUserAgent.
+	UserAgent string
+
+	// Deadline, when non-zero, bounds the whole operation: every request
+	// made after it passes fails with ErrOperationTimeout, and a request in
+	// flight when it passes is cancelled. It is independent of the 30s
+	// per-request HTTP timeout.
+	Deadline time.Time
+
+	// ReadTimeout, when non-zero, bounds each secret read, so a read that
+	// hangs (e.g. on a plugin-backed path) fails with ErrReadTimeout and a
+	// tree walk moves on to the next secret, reporting it with the other
+	// secrets that could not be read.
+	ReadTimeout time.Duration
+
+	// FolderDetect selects how listed keys are told apart as folders:
+	// FolderDetectSlash (or empty) trusts Vault's trailing "/", and
+	// FolderDetectProbe additionally lists each key without one, for
+	// proxies that strip the slash.
+	FolderDetect string
+
+	// ListConcurrency, when above one, lists the folders of a tree walk
+	// ahead of it with up to this many list requests in flight, so wide
+	// trees are enumerated concurrently. Secrets are still fetched and
+	// visited one at a time in sorted order.
+	ListConcurrency int
+
+	// DiffConcurrency, when above one, has a dry-run push read and diff up
+	// to this many secrets at once, printing the diffs in path order once
+	// all are ready.
+	DiffConcurrency int
+
+	// BatchSize, when above zero, splits a pull or push into batches of
+	// this many secrets. After each batch a progress line such as
+	// "Batch 3/20 complete" is printed and BatchPause is waited, so a very
+	// large run goes easy on Vault and can be followed as it goes. Work done
+	// concurrently stays within its batch: dry-run diffs are computed one
+	// batch at a time, and listing ahead stops during a pause. Dry runs do
+	// not pause. A streaming pull does not know its total and prints
+	// "Batch 3 complete"; a pull with PullOptions.Checkpoint syncs the
+	// checkpoint to disk at each batch boundary.
+	BatchSize  int
+	BatchPause time.Duration
+
+	// FailFast stops a pull, push, or other tree walk at the first
+	// secret-level error (a failed list or read, or a push file that cannot
+	// be parsed) instead of reporting it and continuing with the rest.
+	FailFast bool
+
+	// PullOptions and PushOptions tune how secrets are laid out on disk. The
+	// zero values keep the default one-YAML-file-per-secret layout.
+	PullOptions PullOptions
+	PushOptions PushOptions
+
+	// OnResult, when set, is called with the outcome of each secret a pull
+	// or push handles, in the order they are handled, for summaries.
+	OnResult func(SecretResult)
+
+	// WarningLog, when set, receives a copy of every warning printed to
+	// ErrOutput, and an "Error: path: error" line for each secret or folder
+	// a tree walk could not read, one per line, so they can be reviewed
+	// after a run whose stderr was not kept.
+	WarningLog io.Writer
+
+	// Auth, when set, is the auth method the token came from. A request
+	// denied with 403 because the token expired or reached its max TTL is
+	// retried once after logging in again with it, so long runs outlive
+	// the token. A 403 from a token that is still valid is returned as is.
+	Auth AuthMethod
+
+	// authMu guards Token while a re-login may replace it.
+	authMu sync.Mutex
+	// retryDelay overrides authRetryBaseDelay and
+	// consistencyRetryBaseDelay, for tests.
+	retryDelay time.Duration
+
+	// leases holds the lease IDs of responses read through ReadRaw and
+	// WriteRaw, for RevokeLeases.
+	leasesMu sync.Mutex
+	leases   []string
+
+	// pushedBy and pushSource are the parts of a metadata stamp that stay
+	// the same for the whole push; see PushOptions.StampMetadata.
+	pushedBy, pushSource string
 }
 
-type VaultListResponse struct {
-	Data struct {
-		Keys []string `json:"keys"`
-	} `json:"data"`
+// PullOptions controls how pulled secrets are written to disk.
+type PullOptions struct {
+	// Explode writes each key of a secret into its own file under a
+	// directory named after the secret (see explodedSecretSuffix), so that
+	// changes to independent fields show up as per-key git diffs.
+	Explode bool
+
+	// NoRecurse pulls only the secrets directly at the requested path and
+	// does not descend into folders.
+	NoRecurse bool
+
+	// YAMLIndent sets the number of spaces used to indent nested YAML (2-9).
+	// Zero keeps the yaml.v3 default of 4. Long values are never folded
+	// across lines regardless of this setting.
+	YAMLIndent int
+
+	// Format selects how each secret file is rendered: empty for plain YAML,
+	// PullFormatVaultKV for `vault kv put -` JSON in .json files,
+	// PullFormatTOML for TOML in .toml files,
+	// PullFormatK8sSecret for a Kubernetes Secret manifest, or
+	// PullFormatEnvCombined for a single dotenv file of the whole tree. For
+	// manifests, K8sNamespace sets metadata.namespace (omitted when empty) and
+	// K8sNameTemplate is a text/template over .Path and .Name producing
+	// metadata.name (default "{{.Name}}"), sanitized to a valid Secret
+	// name.
+	Format          string
+	K8sNamespace    string
+	K8sNameTemplate string
+	// CompactJSON writes PullFormatVaultKV files on a single line instead
+	// of indented.
+	CompactJSON bool
+	// EnvSeparator joins the path segments and key of each variable name
+	// in PullFormatEnvCombined (default "_"); it may only contain letters,
+	// digits, and underscores. EnvKeepCase leaves the names in their
+	// original case instead of uppercasing them.
+	EnvSeparator string
+	EnvKeepCase  bool
+	// StripPrefix is removed from the start of the pulled Vault sub-path
+	// when building local paths, e.g. pulling "teams/platform/prod" with
+	// StripPrefix "teams/platform" writes under <output-dir>/prod.
+	StripPrefix string
+
+	// OnlyChanged skips rewriting files whose content would not change, so
+	// unchanged secrets keep their mtimes and do not show up in git status.
+	OnlyChanged bool
+
+	// Template, when set, is a text/template rendered once per secret in
+	// place of the YAML file, over .Path, .Name, and .Data (the secret's
+	// keys). TemplateExtension, when set, replaces the file extension of
+	// the rendered files.
+	Template          string
+	TemplateExtension string
+
+	// Gitignore writes a .gitignore ignoring everything into the output
+	// directory, unless it already has one, so pulled secrets cannot be
+	// committed by accident.
+	Gitignore bool
+
+	// Manifest writes an integrity manifest (see ManifestFileName) of the
+	// whole output directory once a pull has read every secret, for
+	// VerifyManifest to check later.
+	Manifest bool
+
+	// Mirror makes the pulled subtree of the output directory an exact
+	// copy of Vault: after a successful pull, secret files there that the
+	// pull did not write are deleted (see deleteExtraFiles). With
+	// MirrorDryRun they are only listed. Files of secrets the pull skipped
+	// are kept. Mirroring needs a file extension, so it is refused by
+	// PullSecretsToFilesDirectAt unless a template or format sets one.
+	Mirror       bool
+	MirrorDryRun bool
+
+	// Checkpoint names a file in which a recursive pull records the tree
+	// listing it started from and every secret written since, so that an
+	// interrupted pull of a large tree can continue with Resume instead of
+	// starting over. The file is removed when the pull completes. It cannot
+	// be combined with Mirror.
+	Checkpoint string
+	Resume     bool
+
+	// KeyInclude and KeyExclude select which top-level keys of each secret
+	// are written, as path.Match globs such as "*_public": a key is kept if
+	// it matches any KeyInclude pattern (or KeyInclude is empty) and no
+	// KeyExclude pattern. Secrets left without keys are not written.
+	KeyInclude []string
+	KeyExclude []string
+
+	// DropKeys names top-level keys, matched exactly, that are removed from
+	// every secret before it is written, such as bookkeeping fields that
+	// should never reach exported files. Like KeyExclude, but meant to be set
+	// once for every pull rather than per command.
+	DropKeys []string
+
+	// ValueFilter is a shell command every string value is piped through
+	// before it is written, e.g. a decryptor; its stdout becomes the value.
+	// A failing command fails the pull of that secret, which is reported
+	// with the secrets that could not be read while the others are written.
+	ValueFilter string
+
+	// NameField names each file after the value of this top-level field of
+	// the secret instead of the secret's key, e.g. "id"; see nameFromField.
+	// Secrets without a usable value keep their key. Two secrets that end
+	// up with the same file fail the pull with ErrFileNameCollision. It
+	// cannot be combined with Checkpoint.
+	NameField string
+
+	// PreserveEmpty marks the files of secrets without any keys, such as
+	// placeholders, with an "# vaultsync: empty" comment; see
+	// PushOptions.PreserveEmpty. Otherwise they are written as plain {}.
+	PreserveEmpty bool
+
+	// JoinChunks joins values that a PushOptions.ChunkFields push split into
+	// chunks back under their original key. Otherwise the chunk and marker
+	// keys are written as Vault stores them.
+	JoinChunks bool
+}
+
+// PushOptions controls how local files are read back into secrets.
+type PushOptions struct {
+	// Explode reassembles directories written by an exploded pull into a
+	// single secret, one key per file.
+	Explode bool
+
+	// ExpandEnv substitutes ${VAR} references in string values from the
+	// environment before writing. With ExpandEnvStrict, a reference to an
+	// unset variable fails the secret instead of expanding to "".
+	ExpandEnv       bool
+	ExpandEnvStrict bool
+
+	// NoRecurse pushes only the files directly in the input directory and
+	// skips its subdirectories.
+	NoRecurse bool
+
+	// FollowSymlinks lets the walk of the input directory follow symlinks
+	// to directories, and symlinks to files outside it. By default both are
+	// skipped with a message, so only files in the intended tree are pushed.
+	FollowSymlinks bool
+
+	// Patch sends each file as a KV v2 PATCH (JSON merge patch) so only the
+	// keys present locally are changed; see PatchSecretAt.
+	Patch bool
+
+	// MergeStrategy decides how a Patch push combines nested maps and
+	// lists with those in Vault: MergeDeep (the default), MergeReplace, or
+	// MergeAppend. Strategies other than MergeDeep merge client-side and
+	// write with check-and-set. It requires Patch.
+	MergeStrategy string
+
+	// OnlyNew creates secrets that do not exist yet and skips the rest,
+	// never overwriting a secret, e.g. when seeding a fresh environment.
+	// On KV v2 each write uses check-and-set with cas=0, so a secret
+	// created by someone else in the meantime is not overwritten either.
+	// It cannot be combined with Patch or MetadataOnly.
+	OnlyNew bool
+
+	// PreserveEmpty pushes files without any secret keys, such as those a
+	// PullOptions.PreserveEmpty pull writes, as empty secrets. Otherwise
+	// they are skipped, except by a MetadataOnly push.
+	PreserveEmpty bool
+
+	// StripPrefix mirrors PullOptions.StripPrefix: files are read from the
+	// local sub-path with the prefix removed and pushed under the full path.
+	StripPrefix string
+
+	// ChangedSince, when non-zero, pushes only files modified after it
+	// (by mtime); older files are skipped silently. An exploded secret
+	// counts as modified when any of its key files is.
+	ChangedSince time.Time
+
+	// Extensions, when non-empty, replaces the default set of file
+	// extensions that are pushed (".yaml", or every file for config-driven
+	// syncs). An empty entry matches files without an extension. The
+	// matched extension is stripped to form the secret name, and each file's
+	// format (JSON or YAML) is detected from its content. Files matched this
+	// way that do not parse are skipped with a warning; with the default set
+	// they fail the push.
+	Extensions []string
+
+	// Overlay names an environment whose overlay files are merged onto the
+	// base secret files before pushing: "db.prod.yaml" onto "db.yaml" for
+	// Overlay "prod". Those overlay files are never pushed as secrets of
+	// their own; files with another environment's suffix are ordinary
+	// secrets.
+	Overlay string
+
+	// MaxSecretSize caps the JSON payload of each secret write in bytes, so
+	// an oversized file fails early with ErrSecretTooLarge instead of after
+	// a slow upload. Zero, the default, leaves writes unlimited;
+	// DefaultMaxSecretSize is a sensible cap.
+	MaxSecretSize int
+
+	// ChunkFields maps keys whose values can outgrow what Vault accepts to
+	// a chunk size in bytes. A longer string value is pushed as numbered
+	// chunks ("cert__chunk_1", ...) plus a "cert__chunks" marker holding
+	// their count, which a PullOptions.JoinChunks pull uses to join them
+	// back into "cert". It
+	// cannot be combined with Patch, which would leave stale chunks behind.
+	ChunkFields map[string]int
+
+	// ValueFilter is a shell command every string value is piped through
+	// before it is pushed, typically the inverse of PullOptions.ValueFilter.
+	// It runs after ExpandEnv. A failing command fails that secret.
+	ValueFilter string
+
+	// Preflight reads every file before writing anything and checks with
+	// sys/capabilities-self that the token can write all of the target
+	// secrets, failing with ErrMissingCapability and a report of every
+	// path it cannot write instead of partway through the push.
+	Preflight bool
+
+	// DataOnly writes only the secret data and ignores _options blocks, so
+	// the secrets' metadata in Vault is left untouched; files marked
+	// cas_required are still written with check-and-set. MetadataOnly is the
+	// reverse: it applies only the _options blocks and never creates a new
+	// data version; files without _options are skipped. The two are
+	// mutually exclusive.
+	DataOnly     bool
+	MetadataOnly bool
+
+	// StampMetadata records in each written secret's custom_metadata who
+	// pushed it (pushed_by, the token's display name from lookup-self),
+	// when (pushed_at, RFC 3339 UTC), and from where (source, the host
+	// name). The stamp is merged into custom_metadata with a metadata PATCH
+	// after the data write, so other custom_metadata keys are kept. It
+	// needs KV v2 and is not applied by dry runs.
+	StampMetadata bool
+
+	// PlanOutput, when set, receives a plain copy of what a dry run prints
+	// for each secret: its diff and metadata options. It is meant for a
+	// file kept as a change-review artifact, so unlike Output it is never
+	// piped through a diff viewer.
+	PlanOutput io.Writer
+
+	// MaskPlan masks values in the diffs written to PlanOutput as
+	// MaskValues does, leaving what Output shows alone.
+	MaskPlan bool
+
+	// Review, when set, is consulted before each secret a push (not a dry
+	// run) would change. It gets the change's diff and the data to be
+	// written and returns the data to write, possibly edited, and whether
+	// to write it at all; an error stops the push. Secrets whose content
+	// would not change are not written and Review is not called for them.
+	// OnlyNew and MetadataOnly pushes, which never rewrite existing data,
+	// do not consult it.
+	Review func(PushReview) (map[string]interface{}, bool, error)
+}
+
+// PushReview is a pending change offered to PushOptions.Review.
+type PushReview struct {
+	// Path is the secret's Vault path, "engine/sub/path".
+	Path string
+	// Diff is the unified diff of the change, as a dry run prints it.
+	Diff string
+	// Data is the secret data the push would write.
+	Data map[string]interface{}
 }
 
 type VaultSecretResponse struct {
@@ -41,6 +423,12 @@ type VaultSecretResponse struct {
 	} `json:"data"`
 }
 
+type VaultSubkeysResponse struct {
+	Data struct {
+		Subkeys map[string]interface{} `json:"subkeys"`
+	} `json:"data"`
+}
+
 type SecretRef struct {
 	Engine string
 	Path   string
@@ -48,6 +436,26 @@ type SecretRef struct {
 
 var ErrSecretNotFound = errors.New("vault secret not found")
 
+// ErrKVv1Unsupported is returned by operations that only exist on KV v2, such
+// as subkeys and metadata, when the client is configured for KV v1.
+var ErrKVv1Unsupported = errors.New("not supported by KV v1")
+
+// ErrSecretTooLarge is returned for writes whose payload exceeds
+// PushOptions.MaxSecretSize.
+var ErrSecretTooLarge = errors.New("secret exceeds maximum size")
+
+// DefaultMaxSecretSize is the write payload limit the CLI applies unless
+// --max-secret-size says otherwise: 1 MiB, the largest entry Vault's
+// integrated storage accepts by default.
+const DefaultMaxSecretSize = 1 << 20
+
+// ErrOperationTimeout is returned for requests cut off by VaultClient.Deadline.
+var ErrOperationTimeout = errors.New("operation deadline exceeded")
+
+// ErrReadTimeout is returned for secret reads cut off by
+// VaultClient.ReadTimeout.
+var ErrReadTimeout = errors.New("secret read timed out")
+
 type HTTPError struct {
 	StatusCode int
 	Body       string
@@ -57,14 +465,6 @@ func (e *HTTPError) Error() string {
 	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Body)
 }
 
-func metadataToDataPath(path string) string {
-	parts := strings.Split(path, "/")
-	if len(parts) >= 2 && parts[1] == "metadata" {
-		return parts[0] + "/data/" + strings.Join(parts[2:], "/")
-	}
-	return path
-}
-
 func metadataSubPath(path string) string {
 	parts := strings.Split(path, "/")
 	if len(parts) <= 2 {
@@ -88,6 +488,76 @@ func (r SecretRef) MetadataPath() string {
 	return r.Engine + "/metadata/" + r.Path
 }
 
+// ParseQualifiedPath parses a fully-qualified "namespace:engine/path" string
+// into its namespace and SecretRef, so paths can be copied around as a single
+// token. A "metadata" or "data" segment directly after the engine is accepted
+// and dropped, matching how paths appear in the Vault API docs. An empty
+// namespace (":kv/app") selects the root namespace.
+func ParseQualifiedPath(path string) (string, SecretRef, error) {
+	namespace, rest, ok := strings.Cut(strings.TrimSpace(path), ":")
+	if !ok {
+		return "", SecretRef{}, fmt.Errorf("qualified path %q must have the form namespace:engine/path", path)
+	}
+
+	parts := strings.Split(strings.Trim(rest, "/"), "/")
+	if parts[0] == "" {
+		return "", SecretRef{}, fmt.Errorf("qualified path %q is missing the engine", path)
+	}
+
+	engine, subParts := parts[0], parts[1:]
+	if len(subParts) > 0 && (subParts[0] == "metadata" || subParts[0] == "data") {
+		subParts = subParts[1:]
+	}
+
+	return strings.Trim(strings.TrimSpace(namespace), "/"), NewSecretRef(engine, strings.Join(subParts, "/")), nil
+}
+
+// IsQualifiedPath reports whether path uses the "namespace:engine/path" form
+// understood by ParseQualifiedPath.
+func IsQualifiedPath(path string) bool {
+	return strings.Contains(path, ":")
+}
+
+// ErrAmbiguousPath is returned by CheckStrictPath for a path whose "data" or
+// "metadata" segment would be dropped or doubled by path rewriting.
+var ErrAmbiguousPath = errors.New("ambiguous path")
+
+// CheckStrictPath rejects the paths that lenient parsing silently rewrites,
+// for --strict-paths. path is a "namespace:engine/path" target or a sub-path
+// below the engine. A qualified target may name the engine's "metadata"
+// segment, which list and pull address, but not "data", which
+// ParseQualifiedPath would quietly turn into "metadata". A sub-path, or the
+// rest of a qualified target, must not start with either segment, since
+// vaultsync adds it itself and the result would read a folder called "data"
+// or "metadata".
+func CheckStrictPath(path string) error {
+	subPath := path
+	if IsQualifiedPath(path) {
+		namespace, rest, _ := strings.Cut(strings.TrimSpace(path), ":")
+		parts := strings.SplitN(strings.Trim(rest, "/"), "/", 3)
+		switch {
+		case len(parts) > 1 && parts[1] == "data":
+			return fmt.Errorf("%w: %q uses the \"data\" segment, but list and pull read the metadata API; write %s:%s/metadata/... or leave the segment out", ErrAmbiguousPath, path, namespace, parts[0])
+		case len(parts) > 1 && parts[1] == "metadata":
+			subPath = strings.Join(parts[2:], "/")
+		default:
+			subPath = strings.Join(parts[1:], "/")
+		}
+	}
+
+	first, _, _ := strings.Cut(strings.Trim(strings.TrimSpace(subPath), "/"), "/")
+	if first == "data" || first == "metadata" {
+		return fmt.Errorf("%w: %q starts with a %q segment, which vaultsync adds itself; without --strict-paths it would be read as a folder named %q", ErrAmbiguousPath, path, first, first)
+	}
+	return nil
+}
+
+// SubkeysPath returns the KV v2 subkeys endpoint for the secret, which reports
+// the secret's key structure without its values.
+func (r SecretRef) SubkeysPath() string {
+	return r.Engine + "/subkeys/" + r.Path
+}
+
 func secretRefFromMetadataPath(path string) SecretRef {
 	path = strings.Trim(strings.TrimSpace(path), "/")
 	if path == "" {
@@ -106,7 +576,7 @@ func NewVaultClient(address, token, namespace string) *VaultClient {
 	return &VaultClient{
 		Address:   address,
 		Token:     token,
-		Namespace: namespace,
+		Namespace: NormalizeNamespace(namespace),
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
@@ -143,392 +613,2159 @@ func (v *VaultClient) errOutput() io.Writer {
 	return v.ErrOutput
 }
 
-func (v *VaultClient) printf(format string, args ...interface{}) {
-	fmt.Fprintf(v.output(), format, args...)
+func (v *VaultClient) printf(format string, args ...interface{}) {
+	fmt.Fprintf(v.output(), format, args...)
+}
+
+// warnf prints a "Warning: " line to ErrOutput, and to WarningLog when set.
+func (v *VaultClient) warnf(format string, args ...interface{}) {
+	line := fmt.Sprintf("Warning: "+format, args...)
+	fmt.Fprint(v.errOutput(), line)
+	if v.WarningLog != nil {
+		fmt.Fprint(v.WarningLog, line)
+	}
+}
+
+// addFailure collects the error of a path a tree walk could not read into
+// errs, for the caller to report, and records it in WarningLog.
+func (v *VaultClient) addFailure(errs *MultiError, path string, err error) {
+	errs.Add(path, err)
+	if v.WarningLog != nil {
+		fmt.Fprintf(v.WarningLog, "Error: %s: %v\n", path, err)
+	}
+}
+
+// planf is printf for dry-run output that also belongs in
+// PushOptions.PlanOutput.
+func (v *VaultClient) planf(format string, args ...interface{}) {
+	v.printf(format, args...)
+	if v.PushOptions.PlanOutput != nil {
+		fmt.Fprintf(v.PushOptions.PlanOutput, format, args...)
+	}
+}
+
+// kvURL builds the API URL for ref under the given KV v2 path segment
+// ("data" or "metadata"), honoring any DataSegment/MetadataSegment override.
+// On KV v1 the segment is omitted.
+func (v *VaultClient) kvURL(segment string, ref SecretRef) string {
+	return fmt.Sprintf("%s/v1/%s", v.Address, v.kvAPIPath(segment, ref))
+}
+
+// kvAPIPath is the API path (relative to /v1/) kvURL requests, as it appears
+// in policies.
+func (v *VaultClient) kvAPIPath(segment string, ref SecretRef) string {
+	if v.isKVv1() {
+		return strings.TrimSuffix(ref.Engine+"/"+ref.Path, "/")
+	}
+
+	switch {
+	case segment == "data" && v.DataSegment != "":
+		segment = strings.Trim(v.DataSegment, "/")
+	case segment == "metadata" && v.MetadataSegment != "":
+		segment = strings.Trim(v.MetadataSegment, "/")
+	}
+
+	apiPath := ref.Engine + "/" + segment
+	if ref.Path != "" {
+		apiPath += "/" + ref.Path
+	}
+	return apiPath
+}
+
+func (v *VaultClient) isKVv1() bool {
+	return v.KVVersion == 1
+}
+
+// reportWarnings prints any warnings in a Vault response body to ErrOutput.
+// Vault uses them for deprecations and misconfigured paths; they never fail
+// the request.
+func (v *VaultClient) reportWarnings(path string, body []byte) {
+	var resp struct {
+		Warnings []string `json:"warnings"`
+	}
+	if json.Unmarshal(body, &resp) != nil {
+		return
+	}
+	v.printWarnings(path, resp.Warnings)
+}
+
+func (v *VaultClient) printWarnings(path string, warnings []string) {
+	for _, warning := range warnings {
+		v.warnf("Vault warning for %s: %s\n", path, warning)
+	}
+}
+
+// do sends req, bounding it by v.Deadline when one is set, retries it while a
+// standby has not caught up (see sendConsistent), and retries it once after
+// re-authenticating when the token has expired (see Auth).
+func (v *VaultClient) do(req *http.Request) (*http.Response, error) {
+	req, err := v.routeWrite(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := v.sendConsistent(req)
+	if err != nil || resp.StatusCode != http.StatusForbidden || v.Auth == nil {
+		return resp, err
+	}
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+
+	retry, reauthErr := v.reauthenticate(req.Header.Get("X-Vault-Token"))
+	if reauthErr != nil {
+		v.warnf("failed to log in again: %v\n", reauthErr)
+		return resp, nil
+	}
+	if !retry {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	retried := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if retried.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	retried.Header.Set("X-Vault-Token", v.token())
+	return v.sendConsistent(retried)
+}
+
+// routeWrite redirects a mutating request built against Address to
+// WriteAddress, when one is set. Reads, and requests to other hosts, are
+// returned unchanged.
+func (v *VaultClient) routeWrite(req *http.Request) (*http.Request, error) {
+	if v.WriteAddress == "" {
+		return req, nil
+	}
+	switch req.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		return req, nil
+	}
+
+	rest, ok := strings.CutPrefix(req.URL.String(), strings.TrimSuffix(v.Address, "/"))
+	if !ok || (rest != "" && rest[0] != '/' && rest[0] != '?') {
+		return req, nil
+	}
+	target, err := url.Parse(strings.TrimSuffix(v.WriteAddress, "/") + rest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid write address %q: %w", v.WriteAddress, err)
+	}
+	routed := req.Clone(req.Context())
+	routed.URL = target
+	routed.Host = ""
+	return routed, nil
+}
+
+// consistencyMaxAttempts caps the attempts sendConsistent makes at one
+// request, and consistencyRetryBaseDelay is its wait before the second,
+// doubled for each later attempt.
+const (
+	consistencyMaxAttempts    = 4
+	consistencyRetryBaseDelay = 100 * time.Millisecond
+)
+
+// sendConsistent is send that retries a request Vault rejects with 412
+// Precondition Failed. A performance standby answers 412 when the request's
+// X-Vault-Index names WAL state it has not replicated yet, as happens for a
+// read right after a write through another node; it clears up within
+// moments. The request is retried with exponential backoff, up to
+// consistencyMaxAttempts in all and never past v.Deadline, and the last 412
+// is returned if the standby still lags. A request whose body cannot be
+// rebuilt is not retried.
+func (v *VaultClient) sendConsistent(req *http.Request) (*http.Response, error) {
+	if req.Body != nil && req.GetBody == nil {
+		return v.send(req)
+	}
+
+	first := true
+	newReq := func() (*http.Request, error) {
+		if first {
+			first = false
+			return req, nil
+		}
+		retried := req.Clone(req.Context())
+		if req.GetBody != nil {
+			var err error
+			if retried.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		return retried, nil
+	}
+	return v.sendWithRetry(newReq, retryPolicy{
+		maxAttempts: consistencyMaxAttempts,
+		baseDelay:   consistencyRetryBaseDelay,
+		retryable: func(resp *http.Response, err error) bool {
+			return err == nil && resp.StatusCode == http.StatusPreconditionFailed
+		},
+	})
+}
+
+// retryPolicy says when and how often sendWithRetry retries a request.
+type retryPolicy struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	retryable   func(resp *http.Response, err error) bool
+	// notify, when set, is called before each wait with the failed attempt.
+	notify func(req *http.Request, resp *http.Response, err error, delay time.Duration)
+}
+
+// sendWithRetry sends the request built by newReq, building and sending it
+// again while policy.retryable holds, up to policy.maxAttempts in all. The
+// wait starts at policy.baseDelay (v.retryDelay in tests) and doubles after
+// each attempt; a retry that would wait past v.Deadline is not made, and the
+// last response is returned.
+func (v *VaultClient) sendWithRetry(newReq func() (*http.Request, error), policy retryPolicy) (*http.Response, error) {
+	delay := policy.baseDelay
+	if v.retryDelay > 0 {
+		delay = v.retryDelay
+	}
+
+	for attempt := 1; ; attempt++ {
+		req, err := newReq()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := v.send(req)
+		if attempt == policy.maxAttempts || !policy.retryable(resp, err) {
+			return resp, err
+		}
+		if !v.Deadline.IsZero() && time.Now().Add(delay).After(v.Deadline) {
+			return resp, err
+		}
+
+		if policy.notify != nil {
+			policy.notify(req, resp, err, delay)
+		}
+		if err == nil {
+			resp.Body.Close()
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// DefaultUserAgent is the User-Agent of a client without its own UserAgent.
+const DefaultUserAgent = "vaultsync"
+
+// send sends req once, bounding it by v.Deadline when one is set. Every
+// request goes through send, which is where it gets its User-Agent.
+func (v *VaultClient) send(req *http.Request) (*http.Response, error) {
+	userAgent := v.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	if v.Deadline.IsZero() {
+		return v.client.Do(req)
+	}
+	if !time.Now().Before(v.Deadline) {
+		return nil, ErrOperationTimeout
+	}
+
+	// The context is released when the response body is closed, so the
+	// caller can still read it after do returns.
+	ctx, cancel := context.WithDeadline(req.Context(), v.Deadline)
+	resp, err := v.client.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		// A shorter deadline on the request itself (ReadTimeout) is the
+		// caller's to report.
+		if errors.Is(err, context.DeadlineExceeded) && req.Context().Err() == nil {
+			return nil, ErrOperationTimeout
+		}
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnClose releases a request context once its response body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// ListSecretsAt lists the keys directly below ref, with folders ending in
+// "/". With FolderDetect set to FolderDetectProbe, keys without the slash are
+// probed (see probeFolders).
+func (v *VaultClient) ListSecretsAt(ref SecretRef) ([]string, error) {
+	keys, _, err := v.listKeys(ref)
+	if err != nil {
+		return nil, err
+	}
+	if v.FolderDetect == FolderDetectProbe {
+		return v.probeFolders(ref, keys)
+	}
+	return keys, nil
+}
+
+// listKeys sends the LIST request for ref and returns the keys as Vault
+// returned them, along with the response status; a non-200 status is also
+// an error.
+func (v *VaultClient) listKeys(ref SecretRef) ([]string, int, error) {
+	url := v.kvURL("metadata", ref) + "?list=true"
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("X-Vault-Token", v.token())
+	req.Header.Set("X-Vault-Namespace", v.Namespace)
+
+	resp, err := v.do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, resp.StatusCode, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
+	}
+
+	keys, warnings, err := decodeListResponse(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	v.printWarnings(ref.MetadataPath(), warnings)
+
+	return keys, resp.StatusCode, nil
+}
+
+// decodeListResponse streams a LIST response body, decoding data.keys one key
+// at a time so a listing with tens of thousands of keys is never held in
+// memory as raw JSON alongside the decoded slice. Fields other than
+// data.keys and warnings are skipped.
+func decodeListResponse(r io.Reader) (keys, warnings []string, err error) {
+	dec := json.NewDecoder(r)
+
+	err = decodeObject(dec, func(field string) error {
+		switch field {
+		case "warnings":
+			return dec.Decode(&warnings)
+		case "data":
+			return decodeObject(dec, func(field string) error {
+				if field != "keys" {
+					return skipValue(dec)
+				}
+				return decodeStringArray(dec, func(key string) {
+					keys = append(keys, key)
+				})
+			})
+		default:
+			return skipValue(dec)
+		}
+	})
+	return keys, warnings, err
+}
+
+// decodeObject reads a JSON object from dec, calling field for each member
+// name with the decoder positioned at that member's value. A null is treated
+// as an empty object.
+func decodeObject(dec *json.Decoder, field func(name string) error) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return fmt.Errorf("expected object, got %v", tok)
+	}
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if err := field(tok.(string)); err != nil {
+			return err
+		}
+	}
+
+	_, err = dec.Token()
+	return err
+}
+
+// decodeStringArray reads a JSON array of strings from dec, passing each
+// element to item as it is decoded. A null is treated as an empty array.
+func decodeStringArray(dec *json.Decoder, item func(string)) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("expected array, got %v", tok)
+	}
+
+	for dec.More() {
+		var value string
+		if err := dec.Decode(&value); err != nil {
+			return err
+		}
+		item(value)
+	}
+
+	_, err = dec.Token()
+	return err
+}
+
+func skipValue(dec *json.Decoder) error {
+	var discard json.RawMessage
+	return dec.Decode(&discard)
+}
+
+func (v *VaultClient) GetSecretAt(ref SecretRef) (map[string]interface{}, error) {
+	secretData, _, err := v.GetSecretWithVersionAt(ref)
+	return secretData, err
+}
+
+// GetSecretWithVersionAt is like GetSecretAt but also returns the current
+// version number of the secret, for use as a check-and-set value.
+func (v *VaultClient) GetSecretWithVersionAt(ref SecretRef) (map[string]interface{}, int, error) {
+	url := v.kvURL("data", ref)
+
+	ctx := context.Background()
+	if v.ReadTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, v.ReadTimeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("X-Vault-Token", v.token())
+	req.Header.Set("X-Vault-Namespace", v.Namespace)
+
+	resp, err := v.do(req)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, 0, fmt.Errorf("%w after %s", ErrReadTimeout, v.ReadTimeout)
+		}
+		return nil, 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		httpErr := &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, 0, fmt.Errorf("%w: %s", ErrSecretNotFound, httpErr)
+		}
+		return nil, 0, httpErr
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, 0, fmt.Errorf("%w after %s", ErrReadTimeout, v.ReadTimeout)
+		}
+		return nil, 0, fmt.Errorf("failed to read response: %w", err)
+	}
+	v.reportWarnings(v.kvAPIPath("data", ref), body)
+
+	// KV v1 returns the secret itself as data, with no versioning.
+	if v.isKVv1() {
+		var vaultResp vaultRawResponse
+		if err := json.Unmarshal(body, &vaultResp); err != nil {
+			return nil, 0, fmt.Errorf("failed to parse JSON: %w", err)
+		}
+		return vaultResp.Data, 0, nil
+	}
+
+	var vaultResp VaultSecretResponse
+	if err := json.Unmarshal(body, &vaultResp); err != nil {
+		return nil, 0, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	return vaultResp.Data.Data, vaultResp.Data.Metadata.Version, nil
+}
+
+// GetSubkeysAt returns the key structure of the secret at ref without reading
+// any values. Leaf keys map to nil; keys holding nested objects map to their
+// own subkey structure. This only requires read access on the subkeys
+// endpoint, so it works for least-privilege tokens that cannot read the data.
+func (v *VaultClient) GetSubkeysAt(ref SecretRef) (map[string]interface{}, error) {
+	if v.isKVv1() {
+		return nil, fmt.Errorf("subkeys: %w", ErrKVv1Unsupported)
+	}
+
+	url := fmt.Sprintf("%s/v1/%s", v.Address, ref.SubkeysPath())
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("X-Vault-Token", v.token())
+	req.Header.Set("X-Vault-Namespace", v.Namespace)
+
+	resp, err := v.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		httpErr := &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%w: %s", ErrSecretNotFound, httpErr)
+		}
+		return nil, httpErr
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	v.reportWarnings(ref.SubkeysPath(), body)
+
+	var vaultResp VaultSubkeysResponse
+	if err := json.Unmarshal(body, &vaultResp); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	return vaultResp.Data.Subkeys, nil
+}
+
+// FlattenSubkeys renders a subkeys structure as sorted dotted key paths, e.g.
+// {"db": {"user": nil}, "port": nil} becomes ["db.user", "port"].
+func FlattenSubkeys(subkeys map[string]interface{}) []string {
+	var keys []string
+	for key, value := range subkeys {
+		nested, ok := value.(map[string]interface{})
+		if !ok || len(nested) == 0 {
+			keys = append(keys, key)
+			continue
+		}
+		for _, child := range FlattenSubkeys(nested) {
+			keys = append(keys, key+"."+child)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func (v *VaultClient) PullSecretsRecursivelyAt(ref SecretRef) (map[string]map[string]interface{}, error) {
+	secrets := make(map[string]map[string]interface{})
+
+	fetchErr, _ := v.walkSecrets(ref.MetadataPath(), func(fullPath string, secretData map[string]interface{}) error {
+		secrets[fullPath] = secretData
+		return nil
+	})
+
+	return secrets, fetchErr
+}
+
+// walkSecrets traverses the tree below currentPath depth-first, visiting keys
+// in sorted order and calling visit for each secret as soon as it is fetched,
+// so callers never need the whole tree in memory at once. List and fetch
+// failures are collected per path into fetchErr, a *MultiError, and the walk
+// continues past them, except ErrOperationTimeout or with FailFast set, either
+// of which ends it. An error returned by visit aborts the walk and is returned
+// as visitErr, unless it is a *secretError, which is collected into fetchErr
+// like a failed fetch. Folders are listed by a folderLister, ahead of the walk
+// with ListConcurrency.
+func (v *VaultClient) walkSecrets(currentPath string, visit func(fullPath string, secretData map[string]interface{}) error) (fetchErr, visitErr error) {
+	return v.walkSecretsInBatches(currentPath, nil, visit)
+}
+
+// walkSecretsInBatches is walkSecrets for a visit that counts its secrets
+// with progress: listing ahead is held while progress pauses between
+// batches, so no request is sent during a pause.
+func (v *VaultClient) walkSecretsInBatches(currentPath string, progress *batchProgress, visit func(fullPath string, secretData map[string]interface{}) error) (fetchErr, visitErr error) {
+	lister := v.newFolderLister()
+	defer lister.stop()
+	if progress != nil {
+		progress.hold = lister.hold
+	}
+	errs := &MultiError{}
+	visitErr = v.walkFolder(lister, lister.start(currentPath), errs, visit)
+	return errs.ErrorOrNil(), visitErr
+}
+
+func (v *VaultClient) walkFolder(lister *folderLister, listing *folderListing, errs *MultiError, visit func(fullPath string, secretData map[string]interface{}) error) error {
+	currentPath := listing.path
+	keys, err := lister.wait(listing)
+	if err != nil {
+		v.addFailure(errs, currentPath, err)
+		return nil
+	}
+
+	for _, key := range keys {
+		// Past the deadline every remaining request would fail the same way.
+		if errs.Len() > 0 && (v.FailFast || errors.Is(errs, ErrOperationTimeout)) {
+			return nil
+		}
+
+		// Odd list responses can contain "" or a lone "/"; neither names a
+		// secret or folder, and following them would loop or build a
+		// malformed path.
+		entry := ParseListKey(key)
+		if entry.Name == "" {
+			v.warnf("skipping invalid key %q listed under %s\n", key, currentPath)
+			continue
+		}
+
+		fullPath := currentPath + "/" + key
+
+		if entry.IsFolder {
+			if v.PullOptions.NoRecurse {
+				continue
+			}
+
+			if err := v.walkFolder(lister, lister.folder(listing, key), errs, visit); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// It's a secret - fetch its data
+		secretData, err := v.GetSecretAt(secretRefFromMetadataPath(fullPath))
+		if errors.Is(err, ErrReadTimeout) {
+			v.warnf("reading %s timed out, skipping\n", fullPath)
+		}
+		if err != nil {
+			v.addFailure(errs, fullPath, fmt.Errorf("failed to get secret: %w", err))
+			continue
+		}
+
+		if err := visit(fullPath, secretData); err != nil {
+			var failed *secretError
+			if errors.As(err, &failed) {
+				v.addFailure(errs, fullPath, failed.err)
+				continue
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// secretError marks an error that fails a single secret rather than the
+// whole operation, such as a failing PullOptions.ValueFilter: walks collect
+// it with the other per-secret failures and carry on.
+type secretError struct {
+	err error
+}
+
+func (e *secretError) Error() string { return e.err.Error() }
+
+func (e *secretError) Unwrap() error { return e.err }
+
+func (v *VaultClient) PullSecretsToFilesAt(ref SecretRef, outputDir string) error {
+	return v.pullSecretsToFiles(ref.MetadataPath(), outputDir, true, ".yaml")
+}
+
+func (v *VaultClient) PullSecretsToFilesDirectAt(ref SecretRef, outputDir string) error {
+	return v.pullSecretsToFiles(ref.MetadataPath(), outputDir, false, "")
+}
+
+// pullSecretsToFiles writes each secret to disk as soon as it is fetched
+// rather than collecting the whole tree first, so memory use stays flat
+// regardless of tree size. Fetch failures are reported after the walk; a write
+// failure stops it immediately.
+func (v *VaultClient) pullSecretsToFiles(basePath, outputDir string, mirrorBasePath bool, fileExtension string) error {
+	if err := v.PullOptions.validate(); err != nil {
+		return err
+	}
+	// Without an extension a mirror could not tell secret files from any
+	// other file in the output directory.
+	if v.PullOptions.Mirror && v.pullFileExtension(fileExtension) == "" {
+		return fmt.Errorf("mirroring needs a file extension to recognize secret files")
+	}
+	if mirrorBasePath {
+		if _, err := stripLocalPrefix(metadataSubPath(basePath), v.PullOptions.StripPrefix); err != nil {
+			return err
+		}
+	}
+
+	if err := v.prepareOutputDir(outputDir); err != nil {
+		return err
+	}
+	if v.PullOptions.Format == PullFormatEnvCombined {
+		return v.pullEnvCombined(basePath, outputDir)
+	}
+	if v.PullOptions.Checkpoint != "" {
+		return v.pullWithCheckpoint(basePath, outputDir, mirrorBasePath, fileExtension)
+	}
+
+	written := make(map[string]bool)
+	claimed := make(map[string]string)
+	progress := v.newBatchProgress(0, false)
+	fetchErr, writeErr := v.walkSecretsInBatches(basePath, progress, func(secretPath string, secretData map[string]interface{}) error {
+		filePath, err := v.writeSecretToFile(secretPath, secretData, basePath, outputDir, mirrorBasePath, fileExtension, claimed)
+		if err != nil {
+			return fmt.Errorf("failed to write secret %s: %w", secretPath, err)
+		}
+		written[filePath] = true
+		progress.step()
+		return nil
+	})
+
+	var pullErr error
+	if fetchErr != nil {
+		pullErr = fmt.Errorf("failed to pull secrets: %w", fetchErr)
+	}
+
+	if writeErr != nil {
+		return errors.Join(writeErr, pullErr)
+	}
+
+	if v.PullOptions.Mirror {
+		// A secret that could not be read still exists in Vault, so its
+		// file must not be mistaken for a stale one.
+		if pullErr != nil {
+			v.warnf("not deleting extra files because some secrets could not be read\n")
+			return pullErr
+		}
+		targetDir := v.pullTargetDir(basePath, outputDir, mirrorBasePath)
+		if err := v.deleteExtraFiles(targetDir, v.pullFileExtension(fileExtension), written); err != nil {
+			return err
+		}
+	}
+
+	if err := v.writePullManifest(outputDir, pullErr); err != nil {
+		return errors.Join(pullErr, err)
+	}
+	return pullErr
+}
+
+// writePullManifest writes the integrity manifest of outputDir after a pull
+// with PullOptions.Manifest, unless some secrets could not be read and so
+// their files may be stale.
+func (v *VaultClient) writePullManifest(outputDir string, pullErr error) error {
+	if !v.PullOptions.Manifest {
+		return nil
+	}
+	if pullErr != nil {
+		v.warnf("not writing a manifest because some secrets could not be read\n")
+		return nil
+	}
+	if err := WriteManifest(outputDir); err != nil {
+		return err
+	}
+	v.printf("Written: %s\n", filepath.Join(outputDir, ManifestFileName))
+	return nil
+}
+
+// PullSecretListToFiles fetches exactly the secrets in refs, without listing
+// or recursing, and writes each one below outputDir at its path within its
+// engine, e.g. kv/app/db to <output-dir>/app/db.yaml. A secret that does not
+// exist is reported and skipped, unless FailFast is set; other read failures
+// are collected like in a recursive pull. PullOptions.StripPrefix does not
+// apply.
+func (v *VaultClient) PullSecretListToFiles(refs []SecretRef, outputDir string) error {
+	if err := v.PullOptions.validate(); err != nil {
+		return err
+	}
+	if v.PullOptions.Mirror {
+		return fmt.Errorf("mirroring is not supported when pulling a list of secrets")
+	}
+	if err := v.prepareOutputDir(outputDir); err != nil {
+		return err
+	}
+
+	errs := &MultiError{}
+	var env *envCombined
+	var envSecrets []string
+	if v.PullOptions.Format == PullFormatEnvCombined {
+		if err := validateEnvSeparator(v.PullOptions.EnvSeparator); err != nil {
+			return err
+		}
+		env = newEnvCombined(v.PullOptions)
+	}
+	claimed := make(map[string]string)
+	for _, ref := range refs {
+		if errs.Len() > 0 && (v.FailFast || errors.Is(errs, ErrOperationTimeout)) {
+			break
+		}
+
+		if ref.Path == "" {
+			return fmt.Errorf("secret path is required for %s", ref.MetadataPath())
+		}
+
+		secretData, err := v.GetSecretAt(ref)
+		if errors.Is(err, ErrSecretNotFound) && !v.FailFast {
+			v.warnf("secret %s not found, skipping\n", ref.MetadataPath())
+			continue
+		}
+		if err != nil {
+			v.addFailure(errs, ref.MetadataPath(), fmt.Errorf("failed to get secret: %w", err))
+			continue
+		}
+
+		if env != nil {
+			added, err := v.addEnvSecret(env, ref.MetadataPath(), ref.Path, secretData)
+			if err != nil {
+				return errors.Join(fmt.Errorf("failed to add secret %s: %w", ref.MetadataPath(), err), errs.ErrorOrNil())
+			}
+			if added {
+				envSecrets = append(envSecrets, ref.MetadataPath())
+			}
+			continue
+		}
+
+		engineRoot := NewSecretRef(ref.Engine, "").MetadataPath()
+		if _, err := v.writeSecretToFile(ref.MetadataPath(), secretData, engineRoot, outputDir, false, ".yaml", claimed); err != nil {
+			var failed *secretError
+			if errors.As(err, &failed) {
+				v.addFailure(errs, ref.MetadataPath(), failed.err)
+				continue
+			}
+			return errors.Join(fmt.Errorf("failed to write secret %s: %w", ref.MetadataPath(), err), errs.ErrorOrNil())
+		}
+	}
+
+	if errs.Len() > 0 {
+		return fmt.Errorf("failed to pull secrets: %w", errs)
+	}
+	if env != nil {
+		if err := v.writeEnvCombined(env, outputDir, envSecrets); err != nil {
+			return err
+		}
+	}
+	return v.writePullManifest(outputDir, nil)
+}
+
+// marshalYAML encodes value as YAML with the given indentation, or the yaml.v3
+// default when indent is zero. yaml.v3 never wraps long scalars, so values
+// stay on one line and remain copy-pasteable.
+func marshalYAML(value interface{}, indent int) ([]byte, error) {
+	if indent == 0 {
+		return yaml.Marshal(value)
+	}
+	if indent < 2 || indent > 9 {
+		return nil, fmt.Errorf("YAML indent must be between 2 and 9, got %d", indent)
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(indent)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// prepareOutputDir readies outputDir for a pull. A local one is created if
+// missing and guarded against being committed; other Storage needs no setup,
+// but cannot hold what only a local directory supports.
+func (v *VaultClient) prepareOutputDir(outputDir string) error {
+	if !v.usesLocalStorage() {
+		o := v.PullOptions
+		if o.Explode || o.Mirror || o.Manifest || o.Gitignore || o.Checkpoint != "" {
+			return fmt.Errorf("exploded secrets, mirroring, manifests, .gitignore files, and checkpoints need a local output directory")
+		}
+		return nil
+	}
+	if err := ensureOutputDir(outputDir); err != nil {
+		return err
+	}
+	return v.guardOutputDir(outputDir)
+}
+
+// ensureOutputDir creates outputDir if it does not exist and fails clearly if
+// the path is taken by something other than a directory.
+func ensureOutputDir(outputDir string) error {
+	info, err := os.Stat(outputDir)
+	switch {
+	case os.IsNotExist(err):
+		// 0700: secret directories must not be world/group-accessible
+		if err := os.MkdirAll(outputDir, 0700); err != nil {
+			return fmt.Errorf("failed to create output directory %s: %w", outputDir, err)
+		}
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to access output directory %s: %w", outputDir, err)
+	case !info.IsDir():
+		return fmt.Errorf("output path %s exists but is not a directory", outputDir)
+	}
+	return nil
+}
+
+// writeSecretToFile writes one pulled secret below outputDir and returns the
+// path it manages: the secret file, or its directory when exploded. A secret
+// that is skipped still returns its path, so a mirroring pull does not take
+// an existing file for a stale one. claimed, when non-nil, maps the paths
+// written so far in this pull to their secrets; a second secret mapping to
+// the same path fails with ErrFileNameCollision instead of overwriting the
+// first.
+func (v *VaultClient) writeSecretToFile(secretPath string, secretData map[string]interface{}, metadataPath, outputDir string, mirrorBasePath bool, fileExtension string, claimed map[string]string) (string, error) {
+	// Extract the relative path from the secret path
+	relativePath := strings.TrimPrefix(secretPath, metadataPath)
+	relativePath = strings.TrimPrefix(relativePath, "/") // Remove leading slash if present
+
+	if relativePath == "" {
+		// Handle edge case where secret name would be empty
+		return "", fmt.Errorf("cannot determine file name for secret %s", secretPath)
+	}
+
+	secretData = v.joinChunks(secretPath, secretData)
+
+	if v.PullOptions.NameField != "" {
+		relativePath = v.nameFromField(secretPath, relativePath, secretData)
+	}
+
+	targetDir := v.pullTargetDir(metadataPath, outputDir, mirrorBasePath)
+	filePath := filepath.Join(targetDir, relativePath+v.pullFileExtension(fileExtension))
+	managedPath := filePath
+	if v.PullOptions.Explode {
+		managedPath = filePath + explodedSecretSuffix
+	}
+
+	if v.PullOptions.selectsKeys() {
+		secretData = v.PullOptions.selectKeys(secretData)
+		if len(secretData) == 0 {
+			v.printf("Skipping: %s (no selected keys)\n", secretPath)
+			v.report(SecretResult{Path: displayPath(secretPath), Action: "skipped"})
+			return managedPath, nil
+		}
+	}
+
+	if v.PullOptions.ValueFilter != "" && secretData != nil {
+		filtered, err := filterValues(v.PullOptions.ValueFilter, secretData)
+		if err != nil {
+			return "", &secretError{err: err}
+		}
+		secretData = filtered
+	}
+
+	if claimed != nil {
+		if other, ok := claimed[filePath]; ok && other != secretPath {
+			return "", fmt.Errorf("%w: %s and %s both map to %s", ErrFileNameCollision, other, secretPath, filePath)
+		}
+		claimed[filePath] = secretPath
+	}
+
+	if v.PullOptions.Explode {
+		changed, err := writeExplodedSecret(managedPath, secretData, v.PullOptions)
+		if err != nil {
+			return "", err
+		}
+
+		if changed {
+			v.printf("Written: %s\n", managedPath)
+		}
+		v.report(SecretResult{Path: displayPath(secretPath), Action: writtenAction(changed), File: managedPath})
+		return managedPath, nil
+	}
+
+	// Convert to YAML
+	var yamlData []byte
+	var err error
+	switch {
+	case v.PullOptions.Template != "":
+		yamlData, err = renderSecretTemplate(relativePath, secretData, v.PullOptions)
+		if err != nil {
+			return "", err
+		}
+	case v.PullOptions.Format == PullFormatK8sSecret:
+		yamlData, err = renderK8sSecret(relativePath, secretData, v.PullOptions)
+	case v.PullOptions.Format == PullFormatVaultKV:
+		yamlData, err = renderVaultKV(secretData, v.PullOptions.CompactJSON)
+	case v.PullOptions.Format == PullFormatTOML && len(secretData) == 0 && v.PullOptions.PreserveEmpty:
+		yamlData = []byte(emptyTOMLSecretFile)
+	case v.PullOptions.Format == PullFormatTOML:
+		yamlData, err = renderTOML(secretData)
+	case len(secretData) == 0 && v.PullOptions.PreserveEmpty:
+		yamlData = []byte(emptySecretFile)
+	default:
+		yamlData, err = marshalYAML(secretData, v.PullOptions.YAMLIndent)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to convert to YAML: %w", err)
+	}
+
+	written, err := writeSecretFile(v.storage(), filePath, yamlData, v.PullOptions.OnlyChanged)
+	if err != nil {
+		return "", err
+	}
+
+	if written {
+		v.printf("Written: %s\n", filePath)
+	}
+	v.report(SecretResult{Path: displayPath(secretPath), Action: writtenAction(written), Size: len(yamlData), File: filePath})
+	return filePath, nil
+}
+
+// pullTargetDir is the local directory the secrets below metadataPath are
+// written to. pullSecretsToFiles has already checked the sub-path against
+// StripPrefix.
+func (v *VaultClient) pullTargetDir(metadataPath, outputDir string, mirrorBasePath bool) string {
+	if mirrorBasePath {
+		subPath, _ := stripLocalPrefix(metadataSubPath(metadataPath), v.PullOptions.StripPrefix)
+		if subPath != "" {
+			return filepath.Join(outputDir, subPath)
+		}
+	}
+	return outputDir
+}
+
+// pullFileExtension is the extension of pulled files: fileExtension, unless a
+// template or the vault-kv or toml format sets its own.
+func (v *VaultClient) pullFileExtension(fileExtension string) string {
+	if v.PullOptions.Template != "" && v.PullOptions.TemplateExtension != "" {
+		return v.PullOptions.TemplateExtension
+	}
+	switch v.PullOptions.Format {
+	case PullFormatVaultKV:
+		return vaultKVExtension
+	case PullFormatTOML:
+		return tomlExtension
+	}
+	return fileExtension
+}
+
+// stripLocalPrefix removes prefix from the start of a Vault sub-path to form
+// the matching local sub-path, so deep Vault layouts can map onto shallow
+// local trees. The prefix must cover whole path segments.
+func stripLocalPrefix(subPath, prefix string) (string, error) {
+	prefix = strings.Trim(prefix, "/")
+	switch {
+	case prefix == "":
+		return subPath, nil
+	case subPath == prefix:
+		return "", nil
+	case strings.HasPrefix(subPath, prefix+"/"):
+		return strings.TrimPrefix(subPath, prefix+"/"), nil
+	default:
+		return "", fmt.Errorf("path %q does not start with strip prefix %q", subPath, prefix)
+	}
+}
+
+func (v *VaultClient) PutSecretAt(ref SecretRef, secretData map[string]interface{}) error {
+	_, err := v.putSecret(ref, secretData, nil)
+	return err
+}
+
+// PutSecretCASAt writes secretData with check-and-set: Vault rejects the write
+// unless the secret's current version is cas. A cas of 0 only succeeds when
+// the secret does not exist yet.
+func (v *VaultClient) PutSecretCASAt(ref SecretRef, secretData map[string]interface{}, cas int) error {
+	_, err := v.putSecret(ref, secretData, &cas)
+	return err
+}
+
+// checkPayloadSize fails with ErrSecretTooLarge when a write payload of size
+// bytes exceeds PushOptions.MaxSecretSize, before anything is uploaded.
+func (v *VaultClient) checkPayloadSize(ref SecretRef, size int) error {
+	limit := v.PushOptions.MaxSecretSize
+	if limit > 0 && size > limit {
+		return fmt.Errorf("%w: %s is %d bytes, limit is %d", ErrSecretTooLarge, ref.MetadataPath(), size, limit)
+	}
+	return nil
+}
+
+// checkSecretSize is checkPayloadSize for secretData as it would be written
+// to ref.
+func (v *VaultClient) checkSecretSize(ref SecretRef, secretData map[string]interface{}) error {
+	jsonData, err := json.Marshal(map[string]interface{}{"data": secretData})
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	return v.checkPayloadSize(ref, len(jsonData))
+}
+
+// putSecret writes secretData to ref and returns the version Vault reports
+// for the write, or 0 when it reports none (KV v1).
+func (v *VaultClient) putSecret(ref SecretRef, secretData map[string]interface{}, cas *int) (int, error) {
+	url := v.kvURL("data", ref)
+
+	// KVv2 requires wrapping data in a "data" field; KV v1 takes the secret
+	// as the body itself.
+	var payload interface{}
+	if v.isKVv1() {
+		if cas != nil {
+			return 0, fmt.Errorf("check-and-set: %w", ErrKVv1Unsupported)
+		}
+		payload = secretData
+	} else {
+		wrapped := map[string]interface{}{
+			"data": secretData,
+		}
+		if cas != nil {
+			wrapped["options"] = map[string]interface{}{"cas": *cas}
+		}
+		payload = wrapped
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	if err := v.checkPayloadSize(ref, len(jsonData)); err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest("POST", url, strings.NewReader(string(jsonData)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("X-Vault-Token", v.token())
+	req.Header.Set("X-Vault-Namespace", v.Namespace)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := v.do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return 0, &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	v.reportWarnings(v.kvAPIPath("data", ref), body)
+
+	var written struct {
+		Data struct {
+			Version int `json:"version"`
+		} `json:"data"`
+	}
+	_ = json.Unmarshal(body, &written)
+	return written.Data.Version, nil
+}
+
+func (v *VaultClient) PushSecretsFromFilesAt(inputDir string, ref SecretRef, dryRun bool) error {
+	return v.pushSecretsFromFiles(inputDir, ref.MetadataPath(), dryRun, true, ".yaml")
+}
+
+func (v *VaultClient) PushSecretsFromFilesDirectAt(inputDir string, ref SecretRef, dryRun bool) error {
+	return v.pushSecretsFromFiles(inputDir, ref.MetadataPath(), dryRun, false, "")
+}
+
+func shouldProcessSecretFile(filePath string, fileExtension string) bool {
+	if fileExtension == "" {
+		return true
+	}
+
+	return strings.HasSuffix(filePath, fileExtension)
+}
+
+// matchSecretFile reports whether filePath should be pushed and which
+// extension to strip from it, honoring PushOptions.Extensions over the
+// caller's default.
+func (v *VaultClient) matchSecretFile(filePath string, defaultExtension string) (string, bool) {
+	return matchSecretExtension(filePath, defaultExtension, v.PushOptions.Extensions)
+}
+
+// matchSecretExtension is matchSecretFile for an explicit extension list;
+// "" in extensions matches files without an extension.
+func matchSecretExtension(filePath string, defaultExtension string, extensions []string) (string, bool) {
+	if len(extensions) == 0 {
+		return defaultExtension, shouldProcessSecretFile(filePath, defaultExtension)
+	}
+
+	for _, ext := range extensions {
+		if ext == "" {
+			if filepath.Ext(filePath) == "" {
+				return "", true
+			}
+			continue
+		}
+		if strings.HasSuffix(filePath, ext) {
+			return ext, true
+		}
+	}
+	return "", false
+}
+
+// parseSecretFile decodes the secret file at filePath. A .toml file is TOML;
+// otherwise the format is sniffed from the content rather than the name: a
+// document starting with "{" is JSON, anything else is YAML.
+func parseSecretFile(filePath string, content []byte) (map[string]interface{}, error) {
+	if strings.HasSuffix(filePath, tomlExtension) {
+		return parseTOMLSecret(content)
+	}
+
+	var secretData map[string]interface{}
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte("{")) {
+		if err := json.Unmarshal(content, &secretData); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		return secretData, nil
+	}
+
+	if err := yaml.Unmarshal(content, &secretData); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+	return secretData, nil
+}
+
+func trimSecretFileExtension(path string, fileExtension string) string {
+	if fileExtension == "" {
+		return path
+	}
+
+	return strings.TrimSuffix(path, fileExtension)
+}
+
+func (v *VaultClient) pushSecretsFromFiles(inputDir, metadataPath string, dryRun bool, mirrorBasePath bool, fileExtension string) error {
+	if v.PushOptions.DataOnly && v.PushOptions.MetadataOnly {
+		return fmt.Errorf("data-only and metadata-only pushes are mutually exclusive")
+	}
+	if v.PushOptions.MetadataOnly && v.isKVv1() {
+		return fmt.Errorf("metadata-only push: %w", ErrKVv1Unsupported)
+	}
+	if v.PushOptions.OnlyNew && (v.PushOptions.Patch || v.PushOptions.MetadataOnly) {
+		return fmt.Errorf("an only-new push cannot be combined with patch or metadata-only")
+	}
+	if v.PushOptions.StampMetadata && v.isKVv1() {
+		return fmt.Errorf("metadata stamp: %w", ErrKVv1Unsupported)
+	}
+	if len(v.PushOptions.ChunkFields) > 0 && v.PushOptions.Patch {
+		return fmt.Errorf("chunked fields cannot be pushed as a patch")
+	}
+	if err := validateMergeStrategy(v.PushOptions.MergeStrategy); err != nil {
+		return err
+	}
+	if v.PushOptions.MergeStrategy != "" && !v.PushOptions.Patch {
+		return fmt.Errorf("a merge strategy requires a patch push")
+	}
+
+	// Read every file first and push in Vault path order, so dry-run output
+	// is stable across runs and the preflight check covers the whole push
+	// before anything is written.
+	var pending []pendingPush
+	err := v.walkSecretFiles(inputDir, metadataPath, mirrorBasePath, fileExtension, func(push pendingPush) error {
+		pending = append(pending, push)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	slices.SortStableFunc(pending, func(a, b pendingPush) int {
+		return strings.Compare(a.vaultPath, b.vaultPath)
+	})
+
+	if v.PushOptions.Preflight {
+		if err := v.preflightPush(pending); err != nil {
+			return err
+		}
+	}
+	if v.PushOptions.OnlyNew {
+		return v.pushNewSecrets(pending, dryRun)
+	}
+	progress := v.newBatchProgress(len(pending), dryRun)
+	for _, wave := range v.batches(pending) {
+		v.diffWave(wave, dryRun)
+		for _, push := range wave {
+			if err := v.pushSecret(push, dryRun); err != nil {
+				return err
+			}
+			progress.step()
+		}
+	}
+	return nil
+}
+
+// diffWave computes the dry-run diffs of one batch of pending pushes ahead,
+// concurrently, when DiffConcurrency asks for it, so concurrent reads stay
+// within the batch.
+func (v *VaultClient) diffWave(wave []pendingPush, dryRun bool) {
+	if dryRun && !v.PushOptions.MetadataOnly && v.DiffConcurrency > 1 {
+		v.diffPendingPushes(wave)
+	}
+}
+
+// pushNewSecrets pushes the secrets of pending that do not exist yet, for
+// PushOptions.OnlyNew, and reports how many were skipped.
+func (v *VaultClient) pushNewSecrets(pending []pendingPush, dryRun bool) error {
+	skipped := 0
+	progress := v.newBatchProgress(len(pending), dryRun)
+	for _, wave := range v.batches(pending) {
+		v.diffWave(wave, dryRun)
+		for _, push := range wave {
+			created, err := v.pushNewSecret(push, dryRun)
+			if err != nil {
+				return err
+			}
+			if !created {
+				skipped++
+			}
+			progress.step()
+		}
+	}
+	v.printf("Skipped %d of %d secrets because they already exist\n", skipped, len(pending))
+	return nil
+}
+
+// pushNewSecret writes push unless its secret already exists and reports
+// whether it was, or with dryRun would be, created. KV v2 writes with
+// check-and-set at version 0, which Vault rejects once the secret exists;
+// KV v1 has no check-and-set, so existence is checked with a read first.
+func (v *VaultClient) pushNewSecret(push pendingPush, dryRun bool) (bool, error) {
+	vaultPath := push.vaultPath
+	skip := func() (bool, error) {
+		v.printf("Skipping: %s (already exists)\n", vaultPath)
+		v.report(SecretResult{Path: displayPath(vaultPath), Action: "skipped"})
+		return false, nil
+	}
+
+	if dryRun {
+		if push.diff == nil {
+			push.diff = v.pendingSecretDiff(vaultPath, push.secretData, v.PushOptions.Patch, true)
+		}
+		if push.diff.err == nil && !strings.Contains(push.diff.output, "\nnew file mode ") {
+			return skip()
+		}
+		return true, v.pushSecret(push, true)
+	}
+
+	ref := secretRefFromMetadataPath(vaultPath)
+	var cas *int
+	if v.isKVv1() {
+		_, err := v.GetSecretAt(ref)
+		switch {
+		case err == nil:
+			return skip()
+		case !errors.Is(err, ErrSecretNotFound):
+			return false, fmt.Errorf("failed to check whether %s exists: %w", vaultPath, err)
+		}
+	} else {
+		cas = new(int)
+	}
+
+	version, err := v.putSecret(ref, push.secretData, cas)
+	if isCASMismatch(err) {
+		return skip()
+	}
+	if err != nil {
+		return false, err
+	}
+	v.printf("Created: %s\n", vaultPath)
+
+	if push.options != nil && !v.PushOptions.DataOnly {
+		if err := v.PutSecretMetadataAt(ref, *push.options); err != nil {
+			return true, fmt.Errorf("failed to update metadata for %s: %w", vaultPath, err)
+		}
+	}
+	if v.PushOptions.StampMetadata {
+		if err := v.stampMetadata(ref); err != nil {
+			return true, fmt.Errorf("failed to stamp metadata for %s: %w", vaultPath, err)
+		}
+	}
+	v.report(SecretResult{Path: displayPath(vaultPath), Action: "pushed", Version: version, Size: payloadSize(push.secretData)})
+	return true, nil
+}
+
+// pendingPush is a secret read from disk and waiting to be written.
+type pendingPush struct {
+	vaultPath  string
+	secretData map[string]interface{}
+	options    *SecretOptions
+	// cas writes the data with check-and-set, for a file marked
+	// cas_required by its _options or a "# vaultsync: cas" directive. It is
+	// kept apart from options so that DataOnly, which drops the metadata
+	// update, still honors it.
+	cas bool
+	// diff is the dry-run diff, when diffPendingPushes computed it ahead.
+	diff *pendingDiff
+}
+
+// pendingDiff is the result of versionedSecretDiff for a pendingPush.
+type pendingDiff struct {
+	output string
+	// plan is output as written to PushOptions.PlanOutput.
+	plan    string
+	version int
+	err     error
+}
+
+// walkSecretFiles reads the secret files below inputDir as a push would and
+// calls visit with each secret, in walk order.
+func (v *VaultClient) walkSecretFiles(inputDir, metadataPath string, mirrorBasePath bool, fileExtension string, visit func(push pendingPush) error) error {
+	var baseDir string
+
+	// Extract the KV engine name and subpath
+	parts := strings.Split(metadataPath, "/")
+	if len(parts) < 2 || parts[1] != "metadata" {
+		return fmt.Errorf("invalid metadata path: %s", metadataPath)
+	}
+
+	kvEngine := parts[0]
+	subPath := metadataSubPath(metadataPath)
+	if mirrorBasePath && subPath != "" {
+		localSubPath, err := stripLocalPrefix(subPath, v.PushOptions.StripPrefix)
+		if err != nil {
+			return err
+		}
+		baseDir = filepath.Join(inputDir, localSubPath)
+	} else {
+		baseDir = inputDir
+	}
+
+	if !v.usesLocalStorage() {
+		if v.PushOptions.Explode || !v.PushOptions.ChangedSince.IsZero() {
+			return fmt.Errorf("exploded secrets and changed-since filtering need a local input directory")
+		}
+	}
+
+	// Check the input directory up front so a missing path or a regular file
+	// fails with a clear message instead of deep inside the walk.
+	for _, dir := range []string{inputDir, baseDir} {
+		if !v.usesLocalStorage() {
+			break
+		}
+		info, err := os.Stat(dir)
+		switch {
+		case os.IsNotExist(err):
+			return fmt.Errorf("directory %s does not exist (derived from vault path %s)", dir, metadataPath)
+		case err != nil:
+			return fmt.Errorf("failed to access input directory %s: %w", dir, err)
+		case !info.IsDir():
+			return fmt.Errorf("input path %s exists but is not a directory", dir)
+		}
+	}
+
+	// vaultPathFor converts a path below baseDir back into the full vault
+	// metadata path of the secret it holds.
+	vaultPathFor := func(filePath, extension string) (string, error) {
+		secretPath, err := secretPathForFile(baseDir, filePath, extension)
+		if err != nil {
+			return "", err
+		}
+
+		if subPath != "" {
+			return kvEngine + "/metadata/" + subPath + "/" + secretPath, nil
+		}
+		return kvEngine + "/metadata/" + secretPath, nil
+	}
+
+	// pushFile reads the secret file at filePath, with overlayFile merged over
+	// it when set, and visits the secret it holds.
+	pushFile := func(filePath, extension, overlayFile string) error {
+		content, err := readStoredFile(v.storage(), filePath)
+		if err != nil {
+			return fmt.Errorf("failed to read file %s: %w", filePath, err)
+		}
+
+		// Mixed directories picked with Extensions may hold files that are
+		// not secrets at all; skip those rather than failing the whole push.
+		// A default secret file that does not parse is an error.
+		secretData, err := parseSecretFile(filePath, content)
+		if err != nil {
+			if v.FailFast || len(v.PushOptions.Extensions) == 0 {
+				return fmt.Errorf("%s: %w", filePath, err)
+			}
+			v.warnf("skipping %s: %v\n", filePath, err)
+			return nil
+		}
+
+		directives := parseFileDirectives(content)
+		if overlayFile != "" {
+			overlayContent, err := readStoredFile(v.storage(), overlayFile)
+			if err != nil {
+				return fmt.Errorf("failed to read overlay %s: %w", overlayFile, err)
+			}
+			overlayData, err := parseSecretFile(overlayFile, overlayContent)
+			if err != nil {
+				return fmt.Errorf("overlay %s: %w", overlayFile, err)
+			}
+
+			v.printf("Applying overlay: %s\n", overlayFile)
+			secretData = mergePatch(secretData, overlayData)
+			directives = directives.merge(parseFileDirectives(overlayContent))
+		}
+		for _, name := range directives.Unknown {
+			v.warnf("ignoring unknown directive %q in %s\n", name, filePath)
+		}
+		if directives.Skip {
+			v.printf("Skipping: %s (vaultsync: skip)\n", filePath)
+			return nil
+		}
+
+		secretData, options, err := v.prepareSecretData(filePath, secretData)
+		if err != nil {
+			return err
+		}
+		cas := directives.CASRequired || (options != nil && options.CASRequired != nil && *options.CASRequired)
+		options = directives.apply(options)
+
+		vaultPath, err := vaultPathFor(filePath, extension)
+		if err != nil {
+			return err
+		}
+		secretData, skipEmpty := v.checkEmptySecret(filePath, secretData)
+		if skipEmpty {
+			return nil
+		}
+		if err := v.checkSecretSize(secretRefFromMetadataPath(vaultPath), secretData); err != nil {
+			return fmt.Errorf("%s: %w", filePath, err)
+		}
+
+		return visit(pendingPush{vaultPath: vaultPath, secretData: secretData, options: options, cas: cas})
+	}
+	if !v.usesLocalStorage() {
+		return v.walkStoredSecretFiles(baseDir, fileExtension, pushFile)
+	}
+
+	skip := func(filePath, reason string) {
+		v.printf("Skipping: %s (%s)\n", filePath, reason)
+	}
+	return walkInputTree(baseDir, v.PushOptions.FollowSymlinks, skip, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if filePath == baseDir {
+				return nil
+			}
+
+			if !v.PushOptions.Explode || !strings.HasSuffix(filePath, explodedSecretSuffix) {
+				if v.PushOptions.NoRecurse {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			// An exploded secret directory holds a single secret, one key
+			// per file; reassemble it and do not descend any further.
+			if !v.PushOptions.ChangedSince.IsZero() {
+				modified, err := modifiedSince(filePath, v.PushOptions.ChangedSince)
+				if err != nil {
+					return err
+				}
+				if !modified {
+					return filepath.SkipDir
+				}
+			}
+
+			secretData, err := readExplodedSecret(filePath)
+			if err != nil {
+				return err
+			}
+
+			secretData, options, err := v.prepareSecretData(filePath, secretData)
+			if err != nil {
+				return err
+			}
+
+			secretFile := strings.TrimSuffix(filePath, explodedSecretSuffix)
+			extension, _ := v.matchSecretFile(secretFile, fileExtension)
+			vaultPath, err := vaultPathFor(secretFile, extension)
+			if err != nil {
+				return err
+			}
+			secretData, skipEmpty := v.checkEmptySecret(filePath, secretData)
+			if skipEmpty {
+				return filepath.SkipDir
+			}
+			if err := v.checkSecretSize(secretRefFromMetadataPath(vaultPath), secretData); err != nil {
+				return fmt.Errorf("%s: %w", filePath, err)
+			}
+
+			cas := options != nil && options.CASRequired != nil && *options.CASRequired
+			if err := visit(pendingPush{vaultPath: vaultPath, secretData: secretData, options: options, cas: cas}); err != nil {
+				return err
+			}
+			return filepath.SkipDir
+		}
+
+		// Skip files outside the configured secret extensions, the
+		// .gitignore a pull may have written next to the secrets, and the
+		// sidecar option files of other secrets.
+		extension, ok := v.matchSecretFile(filePath, fileExtension)
+		if !ok || info.Name() == ".gitignore" || isSidecarFile(filePath) {
+			return nil
+		}
+
+		var overlayFile string
+		var overlayInfo os.FileInfo
+		if v.PushOptions.Overlay != "" {
+			if isOverlayFile(filePath, extension, v.PushOptions.Overlay, isLocalFile) {
+				return nil
+			}
+			overlayFile, overlayInfo, err = findOverlayFile(filePath, extension, v.PushOptions.Overlay)
+			if err != nil {
+				return err
+			}
+		}
+
+		if !v.PushOptions.ChangedSince.IsZero() && !info.ModTime().After(v.PushOptions.ChangedSince) &&
+			(overlayInfo == nil || !overlayInfo.ModTime().After(v.PushOptions.ChangedSince)) {
+			return nil
+		}
+
+		return pushFile(filePath, extension, overlayFile)
+	})
+}
+
+// walkStoredSecretFiles is the push walk of walkSecretFiles over Storage
+// other than the local file system, which has no directories to descend:
+// the files below baseDir are listed, and pushFile is called with each secret
+// file a local walk would push, in sorted order.
+func (v *VaultClient) walkStoredSecretFiles(baseDir, fileExtension string, pushFile func(filePath, extension, overlayFile string) error) error {
+	files, err := v.storage().Files(baseDir)
+	if err != nil {
+		return err
+	}
+	present := make(map[string]bool, len(files))
+	for _, filePath := range files {
+		present[filePath] = true
+	}
+	isFile := func(filePath string) bool { return present[filePath] }
+
+	for _, filePath := range files {
+		relativePath, err := filepath.Rel(baseDir, filePath)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path: %w", err)
+		}
+		if v.PushOptions.NoRecurse && strings.ContainsRune(relativePath, filepath.Separator) {
+			continue
+		}
+
+		extension, ok := v.matchSecretFile(filePath, fileExtension)
+		if !ok || filepath.Base(filePath) == ".gitignore" {
+			continue
+		}
+		if secretFile, ok := strings.CutSuffix(filePath, secretSidecarSuffix); ok && present[secretFile] {
+			continue
+		}
+
+		var overlayFile string
+		if overlay := v.PushOptions.Overlay; overlay != "" {
+			if isOverlayFile(filePath, extension, overlay, isFile) {
+				continue
+			}
+			if candidate := overlayFilePath(filePath, extension, overlay); present[candidate] {
+				overlayFile = candidate
+			}
+		}
+
+		if err := pushFile(filePath, extension, overlayFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// secretPathForFile maps a secret file below baseDir to the secret's path
+// relative to the pushed Vault path: the file's relative path with the
+// matched extension removed and slash-separated.
+func secretPathForFile(baseDir, filePath, extension string) (string, error) {
+	relativePath, err := filepath.Rel(baseDir, filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to get relative path: %w", err)
+	}
+
+	secretPath := trimSecretFileExtension(relativePath, extension)
+	return strings.ReplaceAll(secretPath, string(filepath.Separator), "/"), nil
 }
 
-func (v *VaultClient) ListSecretsAt(ref SecretRef) ([]string, error) {
-	kvPath := ref.MetadataPath()
-	url := fmt.Sprintf("%s/v1/%s?list=true", v.Address, kvPath)
+// checkEmptySecret handles a file read for push that holds no secret keys.
+// Unless PreserveEmpty (or MetadataOnly, which writes no data) is set, the
+// file is reported and skip is true; otherwise missing data is replaced by an
+// empty map, so the secret is written as {} rather than null.
+func (v *VaultClient) checkEmptySecret(filePath string, secretData map[string]interface{}) (data map[string]interface{}, skip bool) {
+	if len(secretData) > 0 || v.PushOptions.MetadataOnly {
+		return secretData, false
+	}
+	if !v.PushOptions.PreserveEmpty {
+		v.printf("Skipping: %s (empty secret)\n", filePath)
+		return nil, true
+	}
+	if secretData == nil {
+		secretData = map[string]interface{}{}
+	}
+	return secretData, false
+}
 
-	req, err := http.NewRequest("GET", url, nil)
+// prepareSecretData applies the configured PushOptions transformations to the
+// data read from filePath before it is pushed, and splits off the reserved
+// _options block that configures the secret's metadata, layered over the
+// options in filePath's sidecar file, if any.
+func (v *VaultClient) prepareSecretData(filePath string, secretData map[string]interface{}) (map[string]interface{}, *SecretOptions, error) {
+	options, secretData, err := extractSecretOptions(secretData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, nil, fmt.Errorf("%s: %w", filePath, err)
 	}
 
-	req.Header.Set("X-Vault-Token", v.Token)
-	req.Header.Set("X-Vault-Namespace", v.Namespace)
-
-	resp, err := v.client.Do(req)
+	// Options embedded in the data win over the sidecar's, field by field.
+	sidecar, err := readSidecarOptions(v.storage(), sidecarPathFor(filePath))
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, nil, err
 	}
-	defer resp.Body.Close()
+	options = mergeSecretOptions(sidecar, options)
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
+	if v.PushOptions.ExpandEnv && secretData != nil {
+		expanded, err := expandEnvValues(secretData, v.PushOptions.ExpandEnvStrict)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to expand environment in %s: %w", filePath, err)
+		}
+		secretData = expanded
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+	if v.PushOptions.ValueFilter != "" && secretData != nil {
+		filtered, err := filterValues(v.PushOptions.ValueFilter, secretData)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", filePath, err)
+		}
+		secretData = filtered
 	}
 
-	var vaultResp VaultListResponse
-	if err := json.Unmarshal(body, &vaultResp); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	if len(v.PushOptions.ChunkFields) > 0 && secretData != nil {
+		chunked, err := chunkValues(secretData, v.PushOptions.ChunkFields)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", filePath, err)
+		}
+		secretData = chunked
 	}
 
-	return vaultResp.Data.Keys, nil
+	return secretData, options, nil
 }
 
-func (v *VaultClient) GetSecretAt(ref SecretRef) (map[string]interface{}, error) {
-	secretPath := ref.MetadataPath()
-	dataPath := metadataToDataPath(secretPath)
-	url := fmt.Sprintf("%s/v1/%s", v.Address, dataPath)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+// pushSecret writes the secret data of push to its Vault path, or previews the
+// change as a diff when dryRun is set. When the push has options the secret's
+// metadata is updated after the data write, so a newly enabled cas_required
+// does not reject it.
+func (v *VaultClient) pushSecret(push pendingPush, dryRun bool) error {
+	vaultPath, secretData, options := push.vaultPath, push.secretData, push.options
+	if v.PushOptions.MetadataOnly {
+		return v.pushSecretMetadata(vaultPath, options, dryRun)
+	}
+	if v.PushOptions.DataOnly {
+		options = nil
 	}
 
-	req.Header.Set("X-Vault-Token", v.Token)
-	req.Header.Set("X-Vault-Namespace", v.Namespace)
-
-	resp, err := v.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+	if dryRun {
+		if err := v.showDryRunDiff(push); err != nil {
+			return err
+		}
+		if options != nil {
+			optionsJSON, _ := json.Marshal(options)
+			v.planf("Metadata options for %s: %s\n", vaultPath, optionsJSON)
+		}
+		return nil
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		httpErr := &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
-		if resp.StatusCode == http.StatusNotFound {
-			return nil, fmt.Errorf("%w: %s", ErrSecretNotFound, httpErr)
+	if v.PushOptions.Review != nil {
+		reviewed, apply, err := v.reviewPush(vaultPath, secretData)
+		if err != nil || !apply {
+			return err
 		}
-		return nil, httpErr
+		secretData = reviewed
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	ref := secretRefFromMetadataPath(vaultPath)
+	result := SecretResult{Path: displayPath(vaultPath), Action: "pushed", Size: payloadSize(secretData)}
+	var err error
+	if v.PushOptions.Patch {
+		v.printf("Patching: %s\n", vaultPath)
+		result.Action = "patched"
+		err = v.mergeSecret(ref, secretData, v.PushOptions.MergeStrategy, push.cas)
+	} else if push.cas {
+		v.printf("Pushing (check-and-set): %s\n", vaultPath)
+		result.Version, err = v.putSecretCAS(ref, secretData)
+	} else {
+		v.printf("Pushing: %s\n", vaultPath)
+		result.Version, err = v.putSecret(ref, secretData, nil)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return err
 	}
 
-	var vaultResp VaultSecretResponse
-	if err := json.Unmarshal(body, &vaultResp); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	if options != nil {
+		if err := v.PutSecretMetadataAt(ref, *options); err != nil {
+			return fmt.Errorf("failed to update metadata for %s: %w", vaultPath, err)
+		}
 	}
-
-	return vaultResp.Data.Data, nil
-}
-
-func (v *VaultClient) PullSecretsRecursivelyAt(ref SecretRef) (map[string]map[string]interface{}, error) {
-	secrets := make(map[string]map[string]interface{})
-
-	return v.pullSecretsRecursivelyHelper(ref.MetadataPath(), secrets)
+	if v.PushOptions.StampMetadata {
+		if err := v.stampMetadata(ref); err != nil {
+			return fmt.Errorf("failed to stamp metadata for %s: %w", vaultPath, err)
+		}
+	}
+	v.report(result)
+	return nil
 }
 
-func (v *VaultClient) pullSecretsRecursivelyHelper(currentPath string, secrets map[string]map[string]interface{}) (map[string]map[string]interface{}, error) {
-	keys, err := v.ListSecretsAt(secretRefFromMetadataPath(currentPath))
+// reviewPush offers the change a push of secretData to vaultPath would make to
+// PushOptions.Review and returns the data to write and whether to write it.
+// Unchanged and declined secrets are reported and not written.
+func (v *VaultClient) reviewPush(vaultPath string, secretData map[string]interface{}) (map[string]interface{}, bool, error) {
+	diffOutput, currentVersion, err := v.versionedSecretDiff(vaultPath, secretData)
 	if err != nil {
-		return secrets, fmt.Errorf("failed to list secrets at %s: %w", currentPath, err)
+		return nil, false, err
 	}
-
-	var resultErr error
-
-	for _, key := range keys {
-		fullPath := currentPath + "/" + key
-
-		// If key ends with /, it's a folder - recurse into it
-		if key[len(key)-1] == '/' {
-			folderPath := currentPath + "/" + key[:len(key)-1]
-			secrets, err = v.pullSecretsRecursivelyHelper(folderPath, secrets)
-			if err != nil {
-				resultErr = errors.Join(resultErr, err)
-				continue
-			}
-		} else {
-			// It's a secret - fetch its data
-			secretPath := currentPath + "/" + key
-
-			secretData, err := v.GetSecretAt(secretRefFromMetadataPath(secretPath))
-			if err != nil {
-				resultErr = errors.Join(resultErr, fmt.Errorf("failed to get secret %s: %w", fullPath, err))
-				continue
-			}
-			secrets[fullPath] = secretData
-		}
+	if diffOutput == "" {
+		v.printf("Unchanged: %s\n", vaultPath)
+		v.report(SecretResult{Path: displayPath(vaultPath), Action: "unchanged", Version: currentVersion})
+		return nil, false, nil
+	}
+	if !v.isKVv1() {
+		diffOutput = annotateDiffVersion(diffOutput, currentVersion)
 	}
 
-	return secrets, resultErr
+	reviewed, apply, err := v.PushOptions.Review(PushReview{Path: displayPath(vaultPath), Diff: diffOutput, Data: secretData})
+	if err != nil {
+		return nil, false, err
+	}
+	if !apply {
+		v.printf("Skipping: %s (not applied)\n", vaultPath)
+		v.report(SecretResult{Path: displayPath(vaultPath), Action: "skipped"})
+		return nil, false, nil
+	}
+	if reviewed == nil {
+		reviewed = secretData
+	}
+	return reviewed, true, nil
 }
 
-func (v *VaultClient) PullSecretsToFilesAt(ref SecretRef, outputDir string) error {
-	return v.pullSecretsToFiles(ref.MetadataPath(), outputDir, true, ".yaml")
+// payloadSize is the size of secretData's JSON encoding, as reported in
+// SecretResult.Size.
+func payloadSize(secretData map[string]interface{}) int {
+	jsonData, _ := json.Marshal(secretData)
+	return len(jsonData)
 }
 
-func (v *VaultClient) PullSecretsToFilesDirectAt(ref SecretRef, outputDir string) error {
-	return v.pullSecretsToFiles(ref.MetadataPath(), outputDir, false, "")
+func writtenAction(written bool) string {
+	if written {
+		return "written"
+	}
+	return "unchanged"
 }
 
-func (v *VaultClient) pullSecretsToFiles(basePath, outputDir string, mirrorBasePath bool, fileExtension string) error {
-	secrets, pullErr := v.PullSecretsRecursivelyAt(secretRefFromMetadataPath(basePath))
-	if pullErr != nil {
-		pullErr = fmt.Errorf("failed to pull secrets: %w", pullErr)
+// pushSecretMetadata applies only the _options of a secret file, for
+// PushOptions.MetadataOnly.
+func (v *VaultClient) pushSecretMetadata(vaultPath string, options *SecretOptions, dryRun bool) error {
+	if options == nil {
+		v.printf("Skipping: %s (no %s)\n", vaultPath, secretOptionsKey)
+		v.report(SecretResult{Path: displayPath(vaultPath), Action: "skipped"})
+		return nil
 	}
 
-	secretPaths := make([]string, 0, len(secrets))
-	for secretPath := range secrets {
-		secretPaths = append(secretPaths, secretPath)
+	optionsJSON, _ := json.Marshal(options)
+	if dryRun {
+		v.planf("Metadata options for %s: %s\n", vaultPath, optionsJSON)
+		v.report(SecretResult{Path: displayPath(vaultPath), Action: "metadata", Size: len(optionsJSON)})
+		return nil
 	}
-	slices.Sort(secretPaths)
 
-	for _, secretPath := range secretPaths {
-		secretData := secrets[secretPath]
-		if err := v.writeSecretToFile(secretPath, secretData, basePath, outputDir, mirrorBasePath, fileExtension); err != nil {
-			writeErr := fmt.Errorf("failed to write secret %s: %w", secretPath, err)
-			if pullErr != nil {
-				return errors.Join(writeErr, pullErr)
-			}
-			return writeErr
-		}
+	v.printf("Updating metadata: %s\n", vaultPath)
+	if err := v.PutSecretMetadataAt(secretRefFromMetadataPath(vaultPath), *options); err != nil {
+		return fmt.Errorf("failed to update metadata for %s: %w", vaultPath, err)
 	}
+	v.report(SecretResult{Path: displayPath(vaultPath), Action: "metadata", Size: len(optionsJSON)})
+	return nil
+}
 
-	return pullErr
+// putSecretCAS writes secretData with check-and-set against the version read
+// just before, so a concurrent change to the secret fails the write instead of
+// being overwritten. Secrets that require CAS reject writes without it.
+func (v *VaultClient) putSecretCAS(ref SecretRef, secretData map[string]interface{}) (int, error) {
+	_, version, err := v.GetSecretWithVersionAt(ref)
+	if err != nil && !errors.Is(err, ErrSecretNotFound) {
+		return 0, fmt.Errorf("failed to read current version of %s: %w", ref.MetadataPath(), err)
+	}
+	return v.putSecret(ref, secretData, &version)
 }
 
-func (v *VaultClient) writeSecretToFile(secretPath string, secretData map[string]interface{}, metadataPath, outputDir string, mirrorBasePath bool, fileExtension string) error {
-	// Extract the relative path from the secret path
-	relativePath := strings.TrimPrefix(secretPath, metadataPath)
-	relativePath = strings.TrimPrefix(relativePath, "/") // Remove leading slash if present
+// isCASMismatch reports whether err is Vault rejecting a check-and-set write
+// because the secret is not at the expected version.
+func isCASMismatch(err error) bool {
+	var httpErr *HTTPError
+	return errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusBadRequest &&
+		strings.Contains(httpErr.Body, "check-and-set")
+}
 
-	if relativePath == "" {
-		// Handle edge case where secret name would be empty
-		return fmt.Errorf("cannot determine file name for secret %s", secretPath)
+// showDryRunDiff prints the diff push would make, computing it now unless
+// diffPendingPushes already has.
+func (v *VaultClient) showDryRunDiff(push pendingPush) error {
+	vaultPath, newData := push.vaultPath, push.secretData
+	if push.diff == nil {
+		push.diff = v.pendingSecretDiff(vaultPath, newData, v.PushOptions.Patch, true)
 	}
-
-	// Extract subpath from metadataPath to determine target directory
-	targetDir := outputDir
-	if mirrorBasePath {
-		if subPath := metadataSubPath(metadataPath); subPath != "" {
-			targetDir = filepath.Join(outputDir, subPath)
-		}
+	diffOutput, planOutput, currentVersion, err := push.diff.output, push.diff.plan, push.diff.version, push.diff.err
+	if err != nil {
+		return err
 	}
 
-	// Create file path with optional extension
-	filePath := filepath.Join(targetDir, relativePath+fileExtension)
-
-	// Create directory structure (0700: secret directories must not be world/group-accessible)
-	dir := filepath.Dir(filePath)
-	if err := os.MkdirAll(dir, 0700); err != nil {
-		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	result := SecretResult{Path: displayPath(vaultPath), Action: "unchanged", Version: currentVersion, Size: payloadSize(newData)}
+	switch {
+	case diffOutput == "":
+	case strings.Contains(diffOutput, "\nnew file mode "):
+		result.Action, result.Version = "create", 1
+	default:
+		result.Action, result.Version = "update", currentVersion+1
 	}
-
-	// Convert to YAML
-	yamlData, err := yaml.Marshal(secretData)
-	if err != nil {
-		return fmt.Errorf("failed to convert to YAML: %w", err)
+	if v.isKVv1() {
+		result.Version = 0
 	}
+	v.report(result)
 
-	// Write to file (0600: secret material must not be world/group-readable)
-	if err := os.WriteFile(filePath, yamlData, 0600); err != nil {
-		return fmt.Errorf("failed to write file %s: %w", filePath, err)
+	// Only output if there are changes
+	if diffOutput != "" {
+		if !v.isKVv1() {
+			diffOutput = annotateDiffVersion(diffOutput, currentVersion)
+			planOutput = annotateDiffVersion(planOutput, currentVersion)
+		}
+		outputDiff(diffOutput, v.output(), v.errOutput())
+		if v.PushOptions.PlanOutput != nil {
+			fmt.Fprint(v.PushOptions.PlanOutput, planOutput)
+		}
 	}
 
-	v.printf("Written: %s\n", filePath)
 	return nil
 }
 
-func (v *VaultClient) PutSecretAt(ref SecretRef, secretData map[string]interface{}) error {
-	secretPath := ref.MetadataPath()
-	dataPath := metadataToDataPath(secretPath)
-
-	url := fmt.Sprintf("%s/v1/%s", v.Address, dataPath)
-
-	// KVv2 requires wrapping data in a "data" field
-	payload := map[string]interface{}{
-		"data": secretData,
-	}
-
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal JSON: %w", err)
-	}
+// diffPendingPushes computes the dry-run diff of every pending push with up
+// to DiffConcurrency secrets read and diffed at once, storing each in its
+// push's diff so the diffs can then be printed in order.
+func (v *VaultClient) diffPendingPushes(pending []pendingPush) {
+	forEachConcurrently(v.DiffConcurrency, len(pending), func(i int) {
+		push := &pending[i]
+		push.diff = v.pendingSecretDiff(push.vaultPath, push.secretData, v.PushOptions.Patch, true)
+	})
+}
 
-	req, err := http.NewRequest("POST", url, strings.NewReader(string(jsonData)))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+// forEachConcurrently calls work for each index below n, with at most limit
+// calls running at once (one at a time for a limit below one), and returns
+// when all of them have. work must only touch state of its own index or
+// guard what it shares.
+func forEachConcurrently(limit, n int, work func(i int)) {
+	sem := make(chan struct{}, max(limit, 1))
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			work(i)
+		}(i)
 	}
+	wg.Wait()
+}
 
-	req.Header.Set("X-Vault-Token", v.Token)
-	req.Header.Set("X-Vault-Namespace", v.Namespace)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := v.client.Do(req)
-	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+// annotateDiffVersion adds a header line after "diff --git" showing the
+// version the secret will move to when the push is applied: "v3 → v4" for an
+// existing secret at version 3, "→ v1" for a new one.
+func annotateDiffVersion(diffOutput string, currentVersion int) string {
+	annotation := fmt.Sprintf("→ v%d", currentVersion+1)
+	if currentVersion > 0 {
+		annotation = fmt.Sprintf("v%d %s", currentVersion, annotation)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		body, _ := io.ReadAll(resp.Body)
-		return &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
-	}
+	firstLine, rest, _ := strings.Cut(diffOutput, "\n")
+	return firstLine + "\nversion " + annotation + "\n" + rest
+}
 
-	return nil
+// secretDiff renders a unified diff between the secret currently stored at
+// vaultPath and newData. A missing secret is rendered as a new file; an empty
+// string means there are no changes.
+func (v *VaultClient) secretDiff(vaultPath string, newData map[string]interface{}) (string, error) {
+	diffOutput, _, err := v.versionedSecretDiff(vaultPath, newData)
+	return diffOutput, err
 }
 
-func (v *VaultClient) PushSecretsFromFilesAt(inputDir string, ref SecretRef, dryRun bool) error {
-	return v.pushSecretsFromFiles(inputDir, ref.MetadataPath(), dryRun, true, ".yaml")
+// versionedSecretDiff is secretDiff that also returns the version of the
+// secret it diffed against, or 0 when the secret does not exist.
+func (v *VaultClient) versionedSecretDiff(vaultPath string, newData map[string]interface{}) (string, int, error) {
+	return v.versionedSecretDiffPatch(vaultPath, newData, v.PushOptions.Patch)
 }
 
-func (v *VaultClient) PushSecretsFromFilesDirectAt(inputDir string, ref SecretRef, dryRun bool) error {
-	return v.pushSecretsFromFiles(inputDir, ref.MetadataPath(), dryRun, false, "")
+// versionedSecretDiffPatch is versionedSecretDiff with patch, when set,
+// diffing against what merging newData into the secret would leave.
+func (v *VaultClient) versionedSecretDiffPatch(vaultPath string, newData map[string]interface{}, patch bool) (string, int, error) {
+	diff := v.pendingSecretDiff(vaultPath, newData, patch, false)
+	return diff.output, diff.version, diff.err
 }
 
-func shouldProcessSecretFile(filePath string, fileExtension string) bool {
-	if fileExtension == "" {
-		return true
+// pendingSecretDiff diffs newData against the secret at vaultPath like
+// versionedSecretDiffPatch. With plan set and PushOptions.MaskPlan asking for
+// it, the result also carries a masked copy of the diff for PlanOutput, so the
+// secret is only read once.
+func (v *VaultClient) pendingSecretDiff(vaultPath string, newData map[string]interface{}, patch, plan bool) *pendingDiff {
+	if err := validateKeyPatterns(v.IgnoreFields); err != nil {
+		return &pendingDiff{err: fmt.Errorf("ignore fields: %w", err)}
 	}
 
-	return strings.HasSuffix(filePath, fileExtension)
+	existingData, currentVersion, secretMissing, err := v.existingSecret(vaultPath)
+	if err != nil {
+		return &pendingDiff{err: err}
+	}
+	return v.diffSecretData(vaultPath, existingData, currentVersion, secretMissing, newData, patch, plan)
 }
 
-func trimSecretFileExtension(path string, fileExtension string) string {
-	if fileExtension == "" {
-		return path
+// existingSecret reads the secret at vaultPath to diff against. A missing
+// secret is reported by secretMissing rather than as an error.
+func (v *VaultClient) existingSecret(vaultPath string) (data map[string]interface{}, version int, secretMissing bool, err error) {
+	data, version, err = v.GetSecretWithVersionAt(secretRefFromMetadataPath(vaultPath))
+	if errors.Is(err, ErrSecretNotFound) {
+		return nil, 0, true, nil
 	}
-
-	return strings.TrimSuffix(path, fileExtension)
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("failed to get existing secret %s: %w", vaultPath, err)
+	}
+	return data, version, false, nil
 }
 
-func (v *VaultClient) pushSecretsFromFiles(inputDir, metadataPath string, dryRun bool, mirrorBasePath bool, fileExtension string) error {
-	var baseDir string
-
-	// Extract the KV engine name and subpath
-	parts := strings.Split(metadataPath, "/")
-	if len(parts) < 2 || parts[1] != "metadata" {
-		return fmt.Errorf("invalid metadata path: %s", metadataPath)
+// diffSecretData is pendingSecretDiff for a secret that was already read.
+func (v *VaultClient) diffSecretData(vaultPath string, existingData map[string]interface{}, currentVersion int, secretMissing bool, newData map[string]interface{}, patch, plan bool) *pendingDiff {
+	diff := &pendingDiff{}
+	if len(v.IgnoreFields) > 0 {
+		newData = selectKeys(newData, nil, v.IgnoreFields)
+		if existingData != nil {
+			existingData = selectKeys(existingData, nil, v.IgnoreFields)
+		}
+	}
+	if patch && !secretMissing {
+		// Preview what the merge will leave in Vault.
+		newData = mergeSecretData(existingData, newData, v.PushOptions.MergeStrategy)
 	}
 
-	kvEngine := parts[0]
-	subPath := metadataSubPath(metadataPath)
-	if mirrorBasePath && subPath != "" {
-		baseDir = filepath.Join(inputDir, subPath)
+	diff.version = currentVersion
+	diff.output, diff.err = v.renderSecretDiff(vaultPath, existingData, newData, secretMissing, v.MaskValues)
+	if diff.err == nil && plan && v.PushOptions.MaskPlan && !v.MaskValues {
+		diff.plan, diff.err = v.renderSecretDiff(vaultPath, existingData, newData, secretMissing, true)
 	} else {
-		baseDir = inputDir
+		diff.plan = diff.output
 	}
+	return diff
+}
 
-	// Check if base directory exists
-	if _, err := os.Stat(baseDir); os.IsNotExist(err) {
-		return fmt.Errorf("directory %s does not exist (derived from vault path %s)", baseDir, metadataPath)
+// renderSecretDiff renders the unified diff from existingData to newData,
+// masking every value when mask is set and otherwise redacting those
+// matching RedactPatterns.
+func (v *VaultClient) renderSecretDiff(vaultPath string, existingData, newData map[string]interface{}, secretMissing, mask bool) (string, error) {
+	if mask {
+		existingData, newData = maskSecretValues(existingData, newData)
+	} else if len(v.RedactPatterns) > 0 {
+		existingData, newData = redactSecretValues(existingData, newData, v.RedactPatterns)
 	}
 
-	return filepath.Walk(baseDir, func(filePath string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Skip directories and files outside the configured secret format.
-		if info.IsDir() || !shouldProcessSecretFile(filePath, fileExtension) {
-			return nil
-		}
+	newYaml, err := yaml.Marshal(newData)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal new secret %s: %w", vaultPath, err)
+	}
+	if secretMissing {
+		return generateNewFileDiff(string(newYaml), vaultPath), nil
+	}
 
-		// Read YAML file
-		yamlData, err := os.ReadFile(filePath)
-		if err != nil {
-			return fmt.Errorf("failed to read file %s: %w", filePath, err)
-		}
+	existingYaml, err := yaml.Marshal(existingData)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal existing secret %s: %w", vaultPath, err)
+	}
+	return generateUnifiedDiff(string(existingYaml), string(newYaml), vaultPath), nil
+}
 
-		// Parse YAML
-		var secretData map[string]interface{}
-		if err := yaml.Unmarshal(yamlData, &secretData); err != nil {
-			return fmt.Errorf("failed to parse YAML in %s: %w", filePath, err)
-		}
+// maskedSecretValue and changedSecretValue stand in for secret values in
+// masked diffs. A changed value gets a different placeholder than the one it
+// replaces so the key still shows up as modified.
+const (
+	maskedSecretValue  = "********"
+	changedSecretValue = "******** (changed)"
+)
 
-		// Convert file path back to vault path
-		relativePath, err := filepath.Rel(baseDir, filePath)
-		if err != nil {
-			return fmt.Errorf("failed to get relative path: %w", err)
-		}
+// maskSecretValues returns copies of existing and updated with every value
+// replaced by a placeholder. Keys present on both sides with equal values get
+// the same placeholder, so a diff of the results lists exactly the keys that
+// were added, removed, or changed. Nested maps are masked key by key.
+func maskSecretValues(existing, updated map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	maskedExisting := make(map[string]interface{}, len(existing))
+	maskedUpdated := make(map[string]interface{}, len(updated))
 
-		// Remove any configured file extension and convert to vault path.
-		secretPath := trimSecretFileExtension(relativePath, fileExtension)
-		secretPath = strings.ReplaceAll(secretPath, string(filepath.Separator), "/")
+	for key := range existing {
+		maskedExisting[key] = maskedSecretValue
+	}
 
-		// Construct full vault metadata path
-		var vaultPath string
-		if subPath != "" {
-			vaultPath = kvEngine + "/metadata/" + subPath + "/" + secretPath
-		} else {
-			vaultPath = kvEngine + "/metadata/" + secretPath
+	for key, value := range updated {
+		oldValue, ok := existing[key]
+		if !ok {
+			maskedUpdated[key] = maskedSecretValue
+			continue
 		}
 
-		if dryRun {
-			return v.showDryRunDiff(vaultPath, secretData)
-		} else {
-			v.printf("Pushing: %s\n", vaultPath)
-			return v.PutSecretAt(secretRefFromMetadataPath(vaultPath), secretData)
+		oldMap, oldIsMap := oldValue.(map[string]interface{})
+		newMap, newIsMap := value.(map[string]interface{})
+		switch {
+		case oldIsMap && newIsMap:
+			maskedExisting[key], maskedUpdated[key] = maskSecretValues(oldMap, newMap)
+		case sameYAML(oldValue, value):
+			maskedUpdated[key] = maskedSecretValue
+		default:
+			maskedUpdated[key] = changedSecretValue
 		}
-	})
+	}
+
+	return maskedExisting, maskedUpdated
 }
 
-func (v *VaultClient) showDryRunDiff(vaultPath string, newData map[string]interface{}) error {
-	// Try to get existing secret
-	existingData, err := v.GetSecretAt(secretRefFromMetadataPath(vaultPath))
-	secretMissing := false
+// sameYAML reports whether a and b render to the same YAML, which is what the
+// unmasked diff compares. It treats a JSON-decoded 5432.0 from Vault and a
+// YAML-decoded 5432 from disk as equal.
+func sameYAML(a, b interface{}) bool {
+	aYAML, aErr := yaml.Marshal(a)
+	bYAML, bErr := yaml.Marshal(b)
+	return aErr == nil && bErr == nil && bytes.Equal(aYAML, bYAML)
+}
 
-	var existingYaml []byte
+// CompareSecretToFileAt diffs the secret stored at ref against the local YAML
+// (or JSON or TOML) file at filePath and returns the unified diff, which is
+// empty when the two match. A reserved _options block in the file is ignored,
+// since it describes metadata rather than secret data.
+func (v *VaultClient) CompareSecretToFileAt(ref SecretRef, filePath string) (string, error) {
+	yamlData, err := os.ReadFile(filePath)
 	if err != nil {
-		if !errors.Is(err, ErrSecretNotFound) {
-			return fmt.Errorf("failed to get existing secret %s: %w", vaultPath, err)
-		}
-
-		// Secret doesn't exist, use empty content
-		secretMissing = true
-		existingYaml = []byte{}
-	} else {
-		var marshalErr error
-		existingYaml, marshalErr = yaml.Marshal(existingData)
-		if marshalErr != nil {
-			return fmt.Errorf("failed to marshal existing secret %s: %w", vaultPath, marshalErr)
-		}
+		return "", fmt.Errorf("failed to read file %s: %w", filePath, err)
 	}
 
-	newYaml, err := yaml.Marshal(newData)
+	secretData, err := parseSecretFile(filePath, yamlData)
 	if err != nil {
-		return fmt.Errorf("failed to marshal new secret %s: %w", vaultPath, err)
+		return "", fmt.Errorf("failed to parse %s: %w", filePath, err)
 	}
 
-	// Generate unified diff
-	var diffOutput string
-
-	if secretMissing {
-		// New file case
-		var newFileDiff bytes.Buffer
-		newFileDiff.WriteString(fmt.Sprintf("diff --git a/%s b/%s\n", vaultPath, vaultPath))
-		newFileDiff.WriteString("new file mode 100644\n")
-		newFileDiff.WriteString(fmt.Sprintf("index 0000000..%s\n", generateShortHash(string(newYaml))))
-		newFileDiff.WriteString("--- /dev/null\n")
-		newFileDiff.WriteString(fmt.Sprintf("+++ b/%s\n", vaultPath))
-		for _, line := range strings.Split(string(newYaml), "\n") {
-			if line != "" || strings.HasSuffix(string(newYaml), "\n") {
-				newFileDiff.WriteString(fmt.Sprintf("+%s\n", line))
-			}
-		}
-		diffOutput = newFileDiff.String()
-	} else {
-		diffOutput = generateUnifiedDiff(string(existingYaml), string(newYaml), vaultPath)
+	_, secretData, err = extractSecretOptions(secretData)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", filePath, err)
 	}
 
-	// Only output if there are changes
-	if diffOutput != "" {
-		outputDiff(diffOutput, v.output(), v.errOutput())
+	if err := validateKeyPatterns(v.IgnoreFields); err != nil {
+		return "", fmt.Errorf("ignore fields: %w", err)
+	}
+	vaultPath := ref.MetadataPath()
+	existingData, currentVersion, secretMissing, err := v.existingSecret(vaultPath)
+	if err != nil {
+		return "", err
+	}
+	// A file without chunk markers holds chunked values joined, as a
+	// JoinChunks pull writes them, so compare it to the joined secret.
+	if len(chunkMarkers(secretData)) == 0 {
+		existingData = v.warnJoinChunks(vaultPath, existingData)
 	}
 
-	return nil
+	diff := v.diffSecretData(vaultPath, existingData, currentVersion, secretMissing, secretData, v.PushOptions.Patch, false)
+	return diff.output, diff.err
 }
 
 // diffOp is a single line of an edit script: kind is ' ' (unchanged), '-'
@@ -539,13 +2776,15 @@ type diffOp struct {
 }
 
 func generateUnifiedDiff(existing, updated, filename string) string {
-	if existing == updated {
-		return "" // No changes
-	}
-
 	existingLines := splitDiffLines(existing)
 	updatedLines := splitDiffLines(updated)
 
+	// Content that differs only in its trailing newline has no changed
+	// lines, so it must not produce a header with no hunks.
+	if slices.Equal(existingLines, updatedLines) {
+		return "" // No changes
+	}
+
 	ops := lcsDiff(existingLines, updatedLines)
 
 	var diff bytes.Buffer
@@ -560,6 +2799,52 @@ func generateUnifiedDiff(existing, updated, filename string) string {
 	return diff.String()
 }
 
+// generateNewFileDiff renders content as a diff creating filename, every
+// line an addition under a single "@@ -0,0 +1,N @@" hunk.
+func generateNewFileDiff(content, filename string) string {
+	var diff bytes.Buffer
+	diff.WriteString(fmt.Sprintf("diff --git a/%s b/%s\n", filename, filename))
+	diff.WriteString("new file mode 100644\n")
+	diff.WriteString(fmt.Sprintf("index 0000000..%s\n", generateShortHash(content)))
+	diff.WriteString("--- /dev/null\n")
+	diff.WriteString(fmt.Sprintf("+++ b/%s\n", filename))
+
+	writeHunks(&diff, lcsDiff(nil, splitDiffLines(content)))
+
+	return diff.String()
+}
+
+// generateDeletedFileDiff renders content as a diff deleting filename, every
+// line a removal under a single "@@ -1,N +0,0 @@" hunk.
+func generateDeletedFileDiff(content, filename string) string {
+	var diff bytes.Buffer
+	diff.WriteString(fmt.Sprintf("diff --git a/%s b/%s\n", filename, filename))
+	diff.WriteString("deleted file mode 100644\n")
+	diff.WriteString(fmt.Sprintf("index %s..0000000\n", generateShortHash(content)))
+	diff.WriteString(fmt.Sprintf("--- a/%s\n", filename))
+	diff.WriteString("+++ /dev/null\n")
+
+	writeHunks(&diff, lcsDiff(splitDiffLines(content), nil))
+
+	return diff.String()
+}
+
+// deletionDiff renders the removal of a secret holding data, named name in
+// the diff header, with its values masked when MaskValues is set, or redacted
+// under RedactPatterns.
+func (v *VaultClient) deletionDiff(name string, data map[string]interface{}) (string, error) {
+	if v.MaskValues {
+		data, _ = maskSecretValues(data, nil)
+	} else if len(v.RedactPatterns) > 0 {
+		data, _ = redactSecretValues(data, nil, v.RedactPatterns)
+	}
+	content, err := yaml.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal %s: %w", name, err)
+	}
+	return generateDeletedFileDiff(string(content), name), nil
+}
+
 // splitDiffLines splits content into lines, dropping the trailing empty element
 // produced by a final newline so a newline-terminated file is not diffed as
 // having a spurious blank last line.
@@ -691,6 +2976,15 @@ func writeHunks(diff *bytes.Buffer, ops []diffOp) {
 			}
 		}
 
+		// An empty side is addressed by the line before it, so a hunk
+		// that only adds to an empty file starts at -0,0.
+		if oldCount == 0 {
+			oldStart--
+		}
+		if newCount == 0 {
+			newStart--
+		}
+
 		diff.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount))
 		for k := lo; k < end; k++ {
 			diff.WriteByte(ops[k].kind)
@@ -8,8 +8,12 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestNewSecretRefNormalizesPath(t *testing.T) {
@@ -29,6 +33,104 @@ func TestNewSecretRefNormalizesPath(t *testing.T) {
 	}
 }
 
+func TestParseQualifiedPath(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in            string
+		wantNamespace string
+		wantRef       SecretRef
+		wantErr       bool
+	}{
+		{in: "myns:kv/metadata/app", wantNamespace: "myns", wantRef: SecretRef{Engine: "kv", Path: "app"}},
+		{in: "myns:kv/data/app/db", wantNamespace: "myns", wantRef: SecretRef{Engine: "kv", Path: "app/db"}},
+		{in: "myns:secrets/app", wantNamespace: "myns", wantRef: SecretRef{Engine: "secrets", Path: "app"}},
+		{in: "parent/child:kv", wantNamespace: "parent/child", wantRef: SecretRef{Engine: "kv"}},
+		{in: ":kv/app/", wantNamespace: "", wantRef: SecretRef{Engine: "kv", Path: "app"}},
+		{in: "myns:", wantErr: true},
+		{in: "kv/app", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		namespace, ref, err := ParseQualifiedPath(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseQualifiedPath(%q): expected error, got nil", tt.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseQualifiedPath(%q): unexpected error: %v", tt.in, err)
+			continue
+		}
+		if namespace != tt.wantNamespace || ref != tt.wantRef {
+			t.Errorf("ParseQualifiedPath(%q) = %q, %+v; want %q, %+v", tt.in, namespace, ref, tt.wantNamespace, tt.wantRef)
+		}
+	}
+}
+
+func TestCheckStrictPath(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in      string
+		wantErr bool
+	}{
+		{in: "app/db"},
+		{in: ""},
+		{in: "app/data/db"},
+		{in: "myns:kv/metadata/app"},
+		{in: "myns:kv/app"},
+		{in: "myns:kv"},
+		{in: "myns:kv/metadata"},
+		{in: "data/app", wantErr: true},
+		{in: "/metadata/app", wantErr: true},
+		{in: "myns:kv/data/app", wantErr: true},
+		{in: "myns:kv/metadata/data/app", wantErr: true},
+		{in: "myns:kv/metadata/metadata", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		err := CheckStrictPath(tt.in)
+		if tt.wantErr && !errors.Is(err, ErrAmbiguousPath) {
+			t.Errorf("CheckStrictPath(%q) = %v, want ErrAmbiguousPath", tt.in, err)
+		}
+		if !tt.wantErr && err != nil {
+			t.Errorf("CheckStrictPath(%q): unexpected error: %v", tt.in, err)
+		}
+	}
+}
+
+func TestMarshalYAMLIndentAndNoFolding(t *testing.T) {
+	t.Parallel()
+
+	long := strings.Repeat("word ", 40)
+	data := map[string]interface{}{
+		"long":   long,
+		"nested": map[string]interface{}{"key": "value"},
+	}
+
+	out, err := marshalYAML(data, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(string(out), "\n  key: value\n") {
+		t.Fatalf("expected 2-space indentation, got:\n%s", out)
+	}
+
+	// The long value must stay on a single line.
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.HasPrefix(line, "long:") && !strings.Contains(line, strings.TrimSpace(long)) {
+			t.Fatalf("expected long value on one line, got:\n%s", out)
+		}
+	}
+
+	if _, err := marshalYAML(data, 1); err == nil {
+		t.Fatal("expected error for out-of-range indent, got nil")
+	}
+}
+
 func TestPullSecretsToFilesWritesRestrictivePermissions(t *testing.T) {
 	t.Parallel()
 
@@ -109,7 +211,7 @@ func TestPullSecretsRecursivelyReturnsErrorOnSecretFetchFailure(t *testing.T) {
 		t.Fatal("expected error, got nil")
 	}
 
-	if !strings.Contains(err.Error(), "failed to get secret kv/metadata/app/bad") {
+	if !strings.Contains(err.Error(), "kv/metadata/app/bad: failed to get secret") {
 		t.Fatalf("expected secret-specific error, got %v", err)
 	}
 
@@ -143,9 +245,10 @@ func TestPullSecretsToFilesReturnsErrorOnWriteFailure(t *testing.T) {
 		}
 	})}
 
-	tempDir := t.TempDir()
-	outputPath := filepath.Join(tempDir, "blocked")
-	if err := os.WriteFile(outputPath, []byte("not a directory"), 0o644); err != nil {
+	// Block the mirrored "app" subdirectory so the failure happens at write
+	// time, after the output directory itself passed validation.
+	outputPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outputPath, "app"), []byte("not a directory"), 0o644); err != nil {
 		t.Fatalf("failed to create blocking file: %v", err)
 	}
 
@@ -163,6 +266,34 @@ func TestPullSecretsToFilesReturnsErrorOnWriteFailure(t *testing.T) {
 	}
 }
 
+func TestPullSecretsToFilesRejectsOutputPathThatIsAFile(t *testing.T) {
+	t.Parallel()
+
+	var requests int
+
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		requests++
+		return textResponse(http.StatusNotFound, "not found"), nil
+	})}
+
+	outputPath := filepath.Join(t.TempDir(), "blocked")
+	if err := os.WriteFile(outputPath, []byte("not a directory"), 0o644); err != nil {
+		t.Fatalf("failed to create blocking file: %v", err)
+	}
+
+	err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), outputPath)
+	if err == nil || !strings.Contains(err.Error(), "exists but is not a directory") {
+		t.Fatalf("expected clear not-a-directory error, got %v", err)
+	}
+
+	if requests != 0 {
+		t.Fatalf("expected validation before any Vault request, got %d requests", requests)
+	}
+}
+
 func TestPullSecretsToFilesWritesFetchedSecretsBeforeReturningPullError(t *testing.T) {
 	t.Parallel()
 
@@ -255,7 +386,7 @@ func TestPullSecretsToFilesContinuesTraversalAfterSecretFetchFailure(t *testing.
 		t.Fatal("expected error, got nil")
 	}
 
-	if !strings.Contains(err.Error(), "failed to get secret kv/metadata/app/bad") {
+	if !strings.Contains(err.Error(), "kv/metadata/app/bad: failed to get secret") {
 		t.Fatalf("expected bad secret error, got %v", err)
 	}
 
@@ -337,6 +468,80 @@ func TestPullSecretsToFilesWritesSecretsInDeterministicOrderBeforeWriteFailure(t
 	}
 }
 
+func TestPullSecretsToFilesWritesEachSecretBeforeFetchingTheNext(t *testing.T) {
+	t.Parallel()
+
+	outputDir := t.TempDir()
+
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/kv/metadata/app" && r.URL.RawQuery == "list=true":
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{"keys": []string{"second", "first"}},
+			})
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/kv/data/app/first":
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{"data": map[string]any{"value": "1"}},
+			})
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/kv/data/app/second":
+			// Streaming: the earlier secret is already on disk by the time
+			// the next one is fetched.
+			if _, err := os.Stat(filepath.Join(outputDir, "app", "first.yaml")); err != nil {
+				t.Errorf("expected first secret written before fetching second, got %v", err)
+			}
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{"data": map[string]any{"value": "2"}},
+			})
+		default:
+			return textResponse(http.StatusNotFound, "not found"), nil
+		}
+	})}
+
+	if err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), outputDir); err != nil {
+		t.Fatalf("expected pull to succeed, got %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(outputDir, "app", "second.yaml")); err != nil {
+		t.Fatalf("expected second secret written, got %v", err)
+	}
+}
+
+func TestPullSecretsToFilesNoRecurseSkipsFolders(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.PullOptions.NoRecurse = true
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/kv/metadata/app" && r.URL.RawQuery == "list=true":
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{"keys": []string{"top", "nested/"}},
+			})
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/kv/data/app/top":
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{"data": map[string]any{"value": "top"}},
+			})
+		case strings.Contains(r.URL.Path, "nested"):
+			t.Errorf("expected no requests below the top level, got %s", r.URL.Path)
+		}
+		return textResponse(http.StatusNotFound, "not found"), nil
+	})}
+
+	outputDir := t.TempDir()
+	if err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), outputDir); err != nil {
+		t.Fatalf("expected pull to succeed, got %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(outputDir, "app", "top.yaml")); err != nil {
+		t.Fatalf("expected top-level secret written, got %v", err)
+	}
+}
+
 func TestShowDryRunDiffReturnsErrorOnVaultFailure(t *testing.T) {
 	disableExternalDiffTools(t)
 
@@ -351,7 +556,7 @@ func TestShowDryRunDiffReturnsErrorOnVaultFailure(t *testing.T) {
 		return textResponse(http.StatusNotFound, "not found"), nil
 	})}
 
-	err := client.showDryRunDiff("kv/metadata/app/db", map[string]any{"username": "alice"})
+	err := client.showDryRunDiff(pendingPush{vaultPath: "kv/metadata/app/db", secretData: map[string]any{"username": "alice"}})
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
@@ -379,7 +584,7 @@ func TestShowDryRunDiffTreatsNotFoundAsNewSecret(t *testing.T) {
 		return textResponse(http.StatusNotFound, "not found"), nil
 	})}
 
-	err := client.showDryRunDiff("kv/metadata/app/db", map[string]any{"username": "alice"})
+	err := client.showDryRunDiff(pendingPush{vaultPath: "kv/metadata/app/db", secretData: map[string]any{"username": "alice"}})
 	if err != nil {
 		t.Fatalf("expected nil error, got %v", err)
 	}
@@ -394,6 +599,222 @@ func TestShowDryRunDiffTreatsNotFoundAsNewSecret(t *testing.T) {
 	}
 }
 
+func TestGetSubkeysAtReadsKeyStructureWithoutValues(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Method == http.MethodGet && r.URL.Path == "/v1/kv/subkeys/app/db" {
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{
+					"subkeys": map[string]any{
+						"password": nil,
+						"replica":  map[string]any{"host": nil, "port": nil},
+					},
+				},
+			})
+		}
+		if strings.Contains(r.URL.Path, "/data/") {
+			t.Errorf("subkeys lookup must not read secret data, got %s", r.URL.Path)
+		}
+		return textResponse(http.StatusNotFound, "not found"), nil
+	})}
+
+	subkeys, err := client.GetSubkeysAt(NewSecretRef("kv", "app/db"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := FlattenSubkeys(subkeys)
+	want := []string{"password", "replica.host", "replica.port"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected flattened keys %v, got %v", want, got)
+	}
+}
+
+func TestGetSubkeysAtMapsNotFound(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return textResponse(http.StatusNotFound, "missing"), nil
+	})}
+
+	_, err := client.GetSubkeysAt(NewSecretRef("kv", "app/missing"))
+	if !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("expected ErrSecretNotFound, got %v", err)
+	}
+}
+
+func TestRequestsRetryPreconditionFailed(t *testing.T) {
+	t.Parallel()
+
+	var bodies []string
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.retryDelay = time.Millisecond
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) < 3 {
+			return textResponse(http.StatusPreconditionFailed, `{"errors":["required index state not present"]}`), nil
+		}
+		return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"version": 1}})
+	})}
+
+	if err := client.PutSecretAt(NewSecretRef("kv", "app/db"), map[string]interface{}{"username": "alice"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(bodies) != 3 || bodies[0] == "" || bodies[1] != bodies[0] || bodies[2] != bodies[0] {
+		t.Fatalf("expected the write to be resent twice with the same body, got %q", bodies)
+	}
+}
+
+func TestRequestsGiveUpOnPersistentPreconditionFailed(t *testing.T) {
+	t.Parallel()
+
+	var attempts int
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.retryDelay = time.Millisecond
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		attempts++
+		return textResponse(http.StatusPreconditionFailed, `{"errors":["required index state not present"]}`), nil
+	})}
+
+	_, err := client.GetSecretAt(NewSecretRef("kv", "app/db"))
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("expected the 412 to be returned, got %v", err)
+	}
+	if attempts != consistencyMaxAttempts {
+		t.Fatalf("attempts = %d, want %d", attempts, consistencyMaxAttempts)
+	}
+}
+
+func TestRequestsCarryUserAgent(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		userAgent string
+		want      string
+	}{
+		{userAgent: "", want: DefaultUserAgent},
+		{userAgent: "vaultsync/1.2.3 (backup)", want: "vaultsync/1.2.3 (backup)"},
+	} {
+		var got []string
+		client := NewVaultClient("https://vault.example", "token", "namespace")
+		client.Output = nil
+		client.ErrOutput = nil
+		client.UserAgent = tt.userAgent
+		client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			got = append(got, r.Header.Get("User-Agent"))
+			if r.Method == http.MethodGet {
+				return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"data": map[string]any{}}})
+			}
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"version": 1}})
+		})}
+
+		ref := NewSecretRef("kv", "app/db")
+		if _, err := client.GetSecretAt(ref); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := client.PutSecretAt(ref, map[string]interface{}{"username": "alice"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != 2 || got[0] != tt.want || got[1] != tt.want {
+			t.Fatalf("User-Agent headers = %q, want %q on every request", got, tt.want)
+		}
+	}
+}
+
+func TestWriteAddressRoutesMutatingRequests(t *testing.T) {
+	t.Parallel()
+
+	var got []string
+	client := NewVaultClient("https://replica.example:8200", "token", "namespace")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.WriteAddress = "https://primary.example:8200/"
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		got = append(got, r.Method+" "+r.URL.String())
+		if r.Method == http.MethodGet {
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"data": map[string]any{}}})
+		}
+		return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"version": 1}})
+	})}
+
+	ref := NewSecretRef("kv", "app/db")
+	if _, err := client.GetSecretAt(ref); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := client.PutSecretAt(ref, map[string]interface{}{"username": "alice"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := client.DeleteSecretAt(ref); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		"GET https://replica.example:8200/v1/kv/data/app/db",
+		"POST https://primary.example:8200/v1/kv/data/app/db",
+		"DELETE https://primary.example:8200/v1/kv/metadata/app/db",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("requests = %q, want %q", got, want)
+	}
+}
+
+func TestCompareSecretToFileAt(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Method == http.MethodGet && r.URL.Path == "/v1/kv/data/app/db" {
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{"data": map[string]any{"username": "alice"}},
+			})
+		}
+		return textResponse(http.StatusNotFound, "not found"), nil
+	})}
+
+	dir := t.TempDir()
+	same := filepath.Join(dir, "same.yaml")
+	changed := filepath.Join(dir, "changed.yaml")
+	if err := os.WriteFile(same, []byte("username: alice\n_options:\n  max_versions: 2\n"), 0o600); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+	if err := os.WriteFile(changed, []byte("username: bob\n"), 0o600); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	ref := NewSecretRef("kv", "app/db")
+
+	diff, err := client.CompareSecretToFileAt(ref, same)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff != "" {
+		t.Fatalf("expected no diff for matching file, got:\n%s", diff)
+	}
+
+	diff, err = client.CompareSecretToFileAt(ref, changed)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(diff, "-username: alice") || !strings.Contains(diff, "+username: bob") {
+		t.Fatalf("expected changed value in diff, got:\n%s", diff)
+	}
+}
+
 type roundTripFunc func(*http.Request) (*http.Response, error)
 
 func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
@@ -434,3 +855,536 @@ func disableExternalDiffTools(t *testing.T) {
 		diffToolDetected = originalDetected
 	})
 }
+
+func TestSecretDiffMaskValuesHidesValuesButShowsChangedKeys(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.MaskValues = true
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return jsonResponse(t, http.StatusOK, map[string]any{
+			"data": map[string]any{"data": map[string]any{
+				"username": "alice",
+				"password": "old-secret",
+				"port":     5432,
+				"removed":  "gone",
+			}},
+		})
+	})}
+
+	diff, err := client.secretDiff("kv/metadata/app/db", map[string]interface{}{
+		"username": "alice",
+		"password": "new-secret",
+		"port":     5432,
+		"added":    "fresh",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, value := range []string{"alice", "old-secret", "new-secret", "gone", "fresh", "5432"} {
+		if strings.Contains(diff, value) {
+			t.Fatalf("expected value %q to be masked, got:\n%s", value, diff)
+		}
+	}
+
+	for _, line := range []string{
+		"-password: '********'",
+		"+password: '******** (changed)'",
+		"-removed: '********'",
+		"+added: '********'",
+		" username: '********'",
+		" port: '********'",
+	} {
+		if !strings.Contains(diff, line) {
+			t.Fatalf("expected diff line %q, got:\n%s", line, diff)
+		}
+	}
+}
+
+func TestSecretDiffMaskValuesNoChangesIsEmpty(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.MaskValues = true
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return jsonResponse(t, http.StatusOK, map[string]any{
+			"data": map[string]any{"data": map[string]any{"nested": map[string]any{"a": "b"}}},
+		})
+	})}
+
+	diff, err := client.secretDiff("kv/metadata/app/db", map[string]interface{}{
+		"nested": map[string]interface{}{"a": "b"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff != "" {
+		t.Fatalf("expected no diff, got:\n%s", diff)
+	}
+}
+
+func TestDeadlineCancelsInFlightRequest(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.Deadline = time.Now().Add(20 * time.Millisecond)
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		<-r.Context().Done()
+		return nil, r.Context().Err()
+	})}
+
+	_, err := client.GetSecretAt(NewSecretRef("kv", "app/db"))
+	if !errors.Is(err, ErrOperationTimeout) {
+		t.Fatalf("expected ErrOperationTimeout, got %v", err)
+	}
+}
+
+func TestDeadlineStopsRecursivePullEarly(t *testing.T) {
+	t.Parallel()
+
+	var gets atomic.Int32
+
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.Deadline = time.Now().Add(20 * time.Millisecond)
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.RawQuery == "list=true" {
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{"keys": []string{"a", "b", "c"}},
+			})
+		}
+		gets.Add(1)
+		<-r.Context().Done()
+		return nil, r.Context().Err()
+	})}
+
+	_, err := client.PullSecretsRecursivelyAt(NewSecretRef("kv", "app"))
+	if !errors.Is(err, ErrOperationTimeout) {
+		t.Fatalf("expected ErrOperationTimeout, got %v", err)
+	}
+	if n := gets.Load(); n != 1 {
+		t.Fatalf("expected traversal to stop after the deadline, saw %d secret reads", n)
+	}
+}
+
+func TestPullSecretsRecursivelySkipsPathologicalKeys(t *testing.T) {
+	t.Parallel()
+
+	var stderr strings.Builder
+
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = nil
+	client.ErrOutput = &stderr
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		switch {
+		case r.URL.Path == "/v1/kv/metadata/app" && r.URL.RawQuery == "list=true":
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{"keys": []string{"", "/", "//", "db"}},
+			})
+		case r.URL.Path == "/v1/kv/data/app/db":
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{"data": map[string]any{"k": "v"}},
+			})
+		default:
+			t.Fatalf("unexpected request %s?%s", r.URL.Path, r.URL.RawQuery)
+			return nil, nil
+		}
+	})}
+
+	secrets, err := client.PullSecretsRecursivelyAt(NewSecretRef("kv", "app"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(secrets) != 1 || secrets["kv/metadata/app/db"] == nil {
+		t.Fatalf("expected only the valid secret, got %v", secrets)
+	}
+	if strings.Count(stderr.String(), "skipping invalid key") != 3 {
+		t.Fatalf("expected a warning per invalid key, got %q", stderr.String())
+	}
+}
+
+func TestPullSecretsToFilesOnlyChangedSkipsIdenticalFiles(t *testing.T) {
+	t.Parallel()
+
+	password := "old"
+
+	var out strings.Builder
+
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = &out
+	client.ErrOutput = nil
+	client.PullOptions.OnlyChanged = true
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		switch r.URL.Path {
+		case "/v1/kv/metadata/app":
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{"keys": []string{"cache", "db"}},
+			})
+		case "/v1/kv/data/app/cache":
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{"data": map[string]any{"host": "redis"}},
+			})
+		default:
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{"data": map[string]any{"password": password}},
+			})
+		}
+	})}
+
+	outputDir := t.TempDir()
+	ref := NewSecretRef("kv", "app")
+	if err := client.PullSecretsToFilesAt(ref, outputDir); err != nil {
+		t.Fatalf("first pull failed: %v", err)
+	}
+
+	cachePath := filepath.Join(outputDir, "app", "cache.yaml")
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for _, name := range []string{"cache.yaml", "db.yaml"} {
+		if err := os.Chtimes(filepath.Join(outputDir, "app", name), past, past); err != nil {
+			t.Fatalf("failed to age %s: %v", name, err)
+		}
+	}
+
+	password = "new"
+	out.Reset()
+	if err := client.PullSecretsToFilesAt(ref, outputDir); err != nil {
+		t.Fatalf("second pull failed: %v", err)
+	}
+
+	info, err := os.Stat(cachePath)
+	if err != nil {
+		t.Fatalf("stat failed: %v", err)
+	}
+	if !info.ModTime().Equal(past) {
+		t.Fatalf("expected unchanged file to keep its mtime, got %v", info.ModTime())
+	}
+
+	if strings.Contains(out.String(), "cache.yaml") {
+		t.Fatalf("expected no Written line for unchanged file, got %q", out.String())
+	}
+	if !strings.Contains(out.String(), "Written: "+filepath.Join(outputDir, "app", "db.yaml")) {
+		t.Fatalf("expected changed file to be rewritten, got %q", out.String())
+	}
+}
+
+func TestVaultResponseWarningsAreReported(t *testing.T) {
+	t.Parallel()
+
+	var stderr strings.Builder
+
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = nil
+	client.ErrOutput = &stderr
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Method == http.MethodPost {
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data":     map[string]any{"version": 2},
+				"warnings": []string{"put warning"},
+			})
+		}
+		return jsonResponse(t, http.StatusOK, map[string]any{
+			"data":     map[string]any{"data": map[string]any{"k": "v"}},
+			"warnings": []string{"Invalid path for a versioned K/V secrets engine."},
+		})
+	})}
+
+	ref := NewSecretRef("kv", "app/db")
+	if _, err := client.GetSecretAt(ref); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := client.PutSecretAt(ref, map[string]interface{}{"k": "v"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, want := range []string{
+		"Warning: Vault warning for kv/data/app/db: Invalid path for a versioned K/V secrets engine.",
+		"Warning: Vault warning for kv/data/app/db: put warning",
+	} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Fatalf("expected %q on stderr, got %q", want, stderr.String())
+		}
+	}
+}
+
+func TestStripLocalPrefix(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		subPath, prefix, want string
+		wantErr               bool
+	}{
+		{subPath: "teams/platform/prod", prefix: "", want: "teams/platform/prod"},
+		{subPath: "teams/platform/prod", prefix: "teams/platform", want: "prod"},
+		{subPath: "teams/platform/prod", prefix: "/teams/platform/", want: "prod"},
+		{subPath: "teams/platform", prefix: "teams/platform", want: ""},
+		{subPath: "teams/platformx/prod", prefix: "teams/platform", wantErr: true},
+		{subPath: "other", prefix: "teams", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := stripLocalPrefix(tt.subPath, tt.prefix)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("stripLocalPrefix(%q, %q) = %q, %v; want %q, error %v", tt.subPath, tt.prefix, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestPullAndPushWithStripPrefix(t *testing.T) {
+	t.Parallel()
+
+	var pushedPaths []string
+
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.PullOptions.StripPrefix = "teams/platform"
+	client.PushOptions.StripPrefix = "teams/platform"
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		switch {
+		case r.Method == http.MethodPost:
+			pushedPaths = append(pushedPaths, r.URL.Path)
+			return textResponse(http.StatusOK, ""), nil
+		case r.URL.RawQuery == "list=true":
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{"keys": []string{"db"}},
+			})
+		default:
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{"data": map[string]any{"k": "v"}},
+			})
+		}
+	})}
+
+	dir := t.TempDir()
+	ref := NewSecretRef("kv", "teams/platform/prod")
+	if err := client.PullSecretsToFilesAt(ref, dir); err != nil {
+		t.Fatalf("pull failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "prod", "db.yaml")); err != nil {
+		t.Fatalf("expected file under stripped path, got %v", err)
+	}
+
+	if err := client.PushSecretsFromFilesAt(dir, ref, false); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+	if len(pushedPaths) != 1 || pushedPaths[0] != "/v1/kv/data/teams/platform/prod/db" {
+		t.Fatalf("expected push to re-add the prefix, got %v", pushedPaths)
+	}
+
+	if err := client.PullSecretsToFilesAt(NewSecretRef("kv", "other/prod"), dir); err == nil {
+		t.Fatal("expected error when the path does not start with the prefix")
+	}
+}
+
+func TestDecodeListResponseStreamsKeys(t *testing.T) {
+	t.Parallel()
+
+	body := `{"request_id":"abc","lease_id":"","data":{"extra":{"nested":[1,2]},"keys":["a","b/","c"]},"warnings":["deprecated"],"auth":null}`
+
+	keys, warnings, err := decodeListResponse(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(keys, ",") != "a,b/,c" {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+	if len(warnings) != 1 || warnings[0] != "deprecated" {
+		t.Fatalf("unexpected warnings %v", warnings)
+	}
+}
+
+func TestDecodeListResponseHandlesNullsAndRejectsMalformedBodies(t *testing.T) {
+	t.Parallel()
+
+	keys, _, err := decodeListResponse(strings.NewReader(`{"data":null,"warnings":null}`))
+	if err != nil || len(keys) != 0 {
+		t.Fatalf("expected no keys and no error, got %v, %v", keys, err)
+	}
+
+	for _, body := range []string{`[]`, `{"data":{"keys":"a"}}`, `{"data":{"keys":[1]}}`, `{"data":{"keys":["a"`} {
+		if _, _, err := decodeListResponse(strings.NewReader(body)); err == nil {
+			t.Fatalf("expected error for %s", body)
+		}
+	}
+}
+
+func TestPullSecretsToFilesFailFastStopsAtFirstError(t *testing.T) {
+	t.Parallel()
+
+	for _, failFast := range []bool{false, true} {
+		var requested []string
+
+		client := NewVaultClient("https://vault.example", "token", "team-a")
+		client.Output = nil
+		client.ErrOutput = nil
+		client.FailFast = failFast
+		client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			requested = append(requested, r.URL.Path)
+			switch r.URL.Path {
+			case "/v1/kv/metadata/app":
+				return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"keys": []string{"a", "b"}}})
+			case "/v1/kv/data/app/a":
+				return textResponse(http.StatusForbidden, "permission denied"), nil
+			default:
+				return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"data": map[string]any{"k": "v"}}})
+			}
+		})}
+
+		err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), t.TempDir())
+		if err == nil || !strings.Contains(err.Error(), "app/a") {
+			t.Fatalf("failFast=%v: expected error for app/a, got %v", failFast, err)
+		}
+
+		readB := slices.Contains(requested, "/v1/kv/data/app/b")
+		if readB == failFast {
+			t.Fatalf("failFast=%v: unexpected requests %v", failFast, requested)
+		}
+	}
+}
+
+func TestPullSecretListToFilesFetchesOnlyListedPaths(t *testing.T) {
+	t.Parallel()
+
+	var requested []string
+
+	var errOut bytes.Buffer
+	client := NewVaultClient("https://vault.example", "token", "team-a")
+	client.Output = nil
+	client.ErrOutput = &errOut
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		requested = append(requested, r.URL.Path)
+		if r.URL.Path == "/v1/kv/data/app/missing" {
+			return textResponse(http.StatusNotFound, ""), nil
+		}
+		return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"data": map[string]any{"path": r.URL.Path}}})
+	})}
+
+	outputDir := t.TempDir()
+	refs := []SecretRef{NewSecretRef("kv", "app/db"), NewSecretRef("kv", "app/missing"), NewSecretRef("other", "team/api")}
+	if err := client.PullSecretListToFiles(refs, outputDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"/v1/kv/data/app/db", "/v1/kv/data/app/missing", "/v1/other/data/team/api"}
+	if !slices.Equal(requested, want) {
+		t.Fatalf("expected requests %v, got %v", want, requested)
+	}
+	for _, file := range []string{"app/db.yaml", "team/api.yaml"} {
+		if _, err := os.Stat(filepath.Join(outputDir, file)); err != nil {
+			t.Fatalf("expected %s to be written: %v", file, err)
+		}
+	}
+	if !strings.Contains(errOut.String(), "kv/metadata/app/missing not found") {
+		t.Fatalf("expected a warning for the missing secret, got %q", errOut.String())
+	}
+
+	client.FailFast = true
+	if err := client.PullSecretListToFiles(refs, t.TempDir()); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("expected ErrSecretNotFound with FailFast, got %v", err)
+	}
+}
+
+func TestReadTimeoutSkipsSlowSecret(t *testing.T) {
+	t.Parallel()
+
+	var errOut strings.Builder
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = nil
+	client.ErrOutput = &errOut
+	client.ReadTimeout = 20 * time.Millisecond
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		switch r.URL.Path {
+		case "/v1/kv/metadata/app":
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"keys": []string{"db", "slow"}}})
+		case "/v1/kv/data/app/slow":
+			<-r.Context().Done()
+			return nil, r.Context().Err()
+		default:
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"data": map[string]any{"k": "v"}}})
+		}
+	})}
+
+	secrets, err := client.PullSecretsRecursivelyAt(NewSecretRef("kv", "app"))
+	if !errors.Is(err, ErrReadTimeout) || !strings.Contains(err.Error(), "kv/metadata/app/slow") {
+		t.Fatalf("expected the slow secret to be reported as timed out, got %v", err)
+	}
+	if errors.Is(err, ErrOperationTimeout) {
+		t.Fatalf("expected a per-secret timeout, not an operation timeout: %v", err)
+	}
+	if _, ok := secrets["kv/metadata/app/db"]; !ok {
+		t.Fatalf("expected the other secret to be pulled, got %v", secrets)
+	}
+	if !strings.Contains(errOut.String(), "Warning: reading kv/metadata/app/slow timed out, skipping") {
+		t.Fatalf("expected a warning for the skipped secret, got %q", errOut.String())
+	}
+}
+
+func TestPullSecretsToFilesReportsResults(t *testing.T) {
+	t.Parallel()
+
+	client := newFakeVault(t, gitignoreTestSecrets).client()
+	client.PullOptions.OnlyChanged = true
+	var results []SecretResult
+	client.OnResult = func(result SecretResult) { results = append(results, result) }
+
+	outputDir := t.TempDir()
+	for i := 0; i < 2; i++ {
+		if err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), outputDir); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	file := filepath.Join(outputDir, "app", "db.yaml")
+	size := len("password: s3cret\n")
+	want := []SecretResult{
+		{Path: "kv/app/db", Action: "written", Size: size, File: file},
+		{Path: "kv/app/db", Action: "unchanged", Size: size, File: file},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Fatalf("unexpected results:\n%+v\nwant:\n%+v", results, want)
+	}
+}
+
+func TestWarningLogCollectsWarningsAndUnreadableSecrets(t *testing.T) {
+	t.Parallel()
+
+	var stdout, stderr, warnings bytes.Buffer
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = &stdout
+	client.ErrOutput = &stderr
+	client.WarningLog = &warnings
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		switch r.URL.Path {
+		case "/v1/kv/metadata/app":
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"keys": []string{"", "db", "locked"}}})
+		case "/v1/kv/data/app/locked":
+			return textResponse(http.StatusForbidden, "permission denied"), nil
+		default:
+			return jsonResponse(t, http.StatusOK, map[string]any{"data": map[string]any{"data": map[string]any{"k": "v"}}})
+		}
+	})}
+
+	if err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), t.TempDir()); err == nil {
+		t.Fatalf("expected the unreadable secret to fail the pull")
+	}
+
+	lines := strings.Split(strings.TrimSuffix(warnings.String(), "\n"), "\n")
+	if len(lines) != 2 ||
+		lines[0] != `Warning: skipping invalid key "" listed under kv/metadata/app` ||
+		!strings.HasPrefix(lines[1], "Error: kv/metadata/app/locked: failed to get secret: ") {
+		t.Fatalf("unexpected warnings file:\n%s", warnings.String())
+	}
+	// Warnings still go to stderr, and never to stdout.
+	if !strings.Contains(stderr.String(), lines[0]) || strings.Contains(stdout.String(), "Warning:") {
+		t.Fatalf("warning not routed to stderr only:\nstdout: %s\nstderr: %s", stdout.String(), stderr.String())
+	}
+}
@@ -0,0 +1,36 @@
+package vaultsync
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// PullFormatVaultKV renders each pulled secret as the JSON object the
+// official CLI reads from stdin in `vault kv put <path> -`.
+const PullFormatVaultKV = "vault-kv"
+
+// vaultKVExtension is the file extension of PullFormatVaultKV files.
+const vaultKVExtension = ".json"
+
+// renderVaultKV renders secretData as `vault kv put -` input: a single JSON
+// object mapping each key to its value. Values keep their JSON types, so
+// nested maps and lists round-trip, and are written without HTML escaping so
+// strings are byte-for-byte what Vault holds. With compact, the object is
+// written on one line.
+func renderVaultKV(secretData map[string]interface{}, compact bool) ([]byte, error) {
+	if secretData == nil {
+		secretData = map[string]interface{}{}
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if !compact {
+		encoder.SetIndent("", "  ")
+	}
+	if err := encoder.Encode(secretData); err != nil {
+		return nil, fmt.Errorf("failed to encode secret as JSON: %w", err)
+	}
+	return buf.Bytes(), nil
+}
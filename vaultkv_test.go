@@ -0,0 +1,87 @@
+package vaultsync
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderVaultKV(t *testing.T) {
+	t.Parallel()
+
+	secret := map[string]interface{}{
+		"url":   "https://example.com/?a=1&b=<2>",
+		"port":  float64(5432),
+		"hosts": []interface{}{"a", "b"},
+	}
+	got, err := renderVaultKV(secret, false)
+	if err != nil {
+		t.Fatalf("renderVaultKV() error = %v", err)
+	}
+
+	want := `{
+  "hosts": [
+    "a",
+    "b"
+  ],
+  "port": 5432,
+  "url": "https://example.com/?a=1&b=<2>"
+}
+`
+	if string(got) != want {
+		t.Fatalf("renderVaultKV() = %q, want %q", got, want)
+	}
+
+	compact, err := renderVaultKV(secret, true)
+	if err != nil {
+		t.Fatalf("renderVaultKV() error = %v", err)
+	}
+	if want := `{"hosts":["a","b"],"port":5432,"url":"https://example.com/?a=1&b=<2>"}` + "\n"; string(compact) != want {
+		t.Fatalf("compact renderVaultKV() = %q, want %q", compact, want)
+	}
+
+	empty, err := renderVaultKV(nil, false)
+	if err != nil {
+		t.Fatalf("renderVaultKV(nil) error = %v", err)
+	}
+	if string(empty) != "{}\n" {
+		t.Fatalf("renderVaultKV(nil) = %q, want {}", empty)
+	}
+}
+
+func TestPullSecretsToFilesVaultKVFormatWritesJSONFiles(t *testing.T) {
+	t.Parallel()
+
+	client := NewVaultClient("https://vault.example", "token", "namespace")
+	client.Output = nil
+	client.ErrOutput = nil
+	client.PullOptions.Format = PullFormatVaultKV
+	client.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		switch r.URL.Path {
+		case "/v1/kv/metadata/app":
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{"keys": []string{"db"}},
+			})
+		case "/v1/kv/data/app/db":
+			return jsonResponse(t, http.StatusOK, map[string]any{
+				"data": map[string]any{"data": map[string]any{"password": "pw"}},
+			})
+		}
+		t.Fatalf("unexpected request %s", r.URL.Path)
+		return nil, nil
+	})}
+
+	outputDir := t.TempDir()
+	if err := client.PullSecretsToFilesAt(NewSecretRef("kv", "app"), outputDir); err != nil {
+		t.Fatalf("PullSecretsToFilesAt() error = %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "app", "db.json"))
+	if err != nil {
+		t.Fatalf("expected db.json to be written: %v", err)
+	}
+	if want := "{\n  \"password\": \"pw\"\n}\n"; string(content) != want {
+		t.Fatalf("db.json = %q, want %q", content, want)
+	}
+}